@@ -0,0 +1,10 @@
+// Package api embeds the OpenAPI document of the HTTP API into the binary,
+// so the server can publish and enforce it without depending on the working directory.
+package api
+
+import _ "embed"
+
+// Spec is the OpenAPI 3 document (swagger.yaml) of the /v1 API.
+//
+//go:embed swagger.yaml
+var Spec []byte
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"rentor/internal/apperr"
+	"rentor/internal/config"
+	"rentor/internal/models"
+	"rentor/internal/storage"
+	"rentor/internal/store"
+
+	"github.com/pressly/goose/v3"
+)
+
+// command is a CLI subcommand of the rentor binary (rentor <name> ...)
+type command struct {
+	name  string
+	usage string
+	help  string
+	run   func(db *storage.DB, cfg *config.Config, args []string) error
+}
+
+var commands = []command{
+	{
+		name:  "migrate",
+		usage: "migrate <command> [version|name]",
+		help:  "manage migrations: status, up, up-to, down, down-to, redo, create, validate",
+		run:   runMigrateCommand,
+	},
+	{
+		name:  "backup",
+		usage: "backup [create|list]",
+		help:  "back up the SQLite database and images, or list backups",
+		run:   runBackupCommand,
+	},
+	{
+		name:  "restore",
+		usage: "restore <backup>",
+		help:  "verify a backup and restore the database and images from it (fails while the server runs)",
+		run:   runRestoreCommand,
+	},
+	{
+		name:  "images",
+		usage: "images reconcile [-dry-run] [-grace 1h]",
+		help:  "check image files against the DB and repair inconsistencies",
+		run:   runImagesCommand,
+	},
+	{
+		name:  "users",
+		usage: "users set-role <email> <user|admin>",
+		help:  "change the role of a user",
+		run:   runUsersCommand,
+	},
+	{
+		name:  "import",
+		usage: "import [-format F] [-mapping JSON] [-dry-run] <email> <file>",
+		help:  "import advertisements of a user from a CSV, JSON or XML feed file",
+		run:   runImportCommand,
+	},
+}
+
+// migrationsDir is the migrations directory of the repository, `rentor migrate create` and
+// `rentor migrate validate` are run from the repository root
+const migrationsDir = "./migrations"
+
+// runFileCommand runs the subcommands that work with files only, before the database is opened.
+// It reports false if args is another command.
+func runFileCommand(args []string) (bool, error) {
+	if len(args) < 2 || args[0] != "migrate" {
+		return false, nil
+	}
+
+	switch args[1] {
+	case "create":
+		if len(args) != 3 {
+			return true, fmt.Errorf("usage: rentor migrate create <name>")
+		}
+		return true, storage.CreateMigration(migrationsDir, args[2])
+	case "validate":
+		// the files on disk are checked, the embedded ones are only updated by the next build
+		if err := storage.ValidateMigrations(os.DirFS(migrationsDir)); err != nil {
+			return true, fmt.Errorf("migrate validate:\n%w", err)
+		}
+		fmt.Println("migrations are valid")
+		return true, nil
+	}
+	return false, nil
+}
+
+// runCommand dispatches CLI arguments to the matching subcommand
+func runCommand(db *storage.DB, cfg *config.Config, args []string) error {
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c.run(db, cfg, args[1:])
+		}
+	}
+	return fmt.Errorf("unknown command %q\n%s", args[0], commandsUsage())
+}
+
+func commandsUsage() string {
+	var b strings.Builder
+	b.WriteString("usage: rentor [command]\n\nwithout a command the HTTP server is started\n\ncommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(&b, "  %-45s %s\n", c.usage, c.help)
+	}
+	return b.String()
+}
+
+// ============================================
+// rentor backup ...
+// ============================================
+func runBackupCommand(db *storage.DB, cfg *config.Config, args []string) error {
+	backuper, err := storage.NewBackuper(db, cfg)
+	if err != nil {
+		return err
+	}
+
+	var result any
+	switch {
+	case len(args) == 0 || len(args) == 1 && args[0] == "create":
+		result, err = backuper.Create(context.Background())
+	case len(args) == 1 && args[0] == "list":
+		result, err = backuper.List()
+	default:
+		return fmt.Errorf("usage: rentor backup [create|list]")
+	}
+	if err != nil {
+		return fmt.Errorf("backup: %w", err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(result)
+}
+
+// ============================================
+// rentor restore ...
+// ============================================
+func runRestoreCommand(db *storage.DB, cfg *config.Config, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: rentor restore <backup>")
+	}
+
+	backuper, err := storage.NewBackuper(db, cfg)
+	if err != nil {
+		return err
+	}
+
+	report, err := backuper.Restore(context.Background(), args[0])
+	if err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// ============================================
+// rentor images ...
+// ============================================
+func runImagesCommand(db *storage.DB, cfg *config.Config, args []string) error {
+	if len(args) == 0 || args[0] != "reconcile" {
+		return fmt.Errorf("usage: rentor images reconcile [-dry-run] [-grace 1h]")
+	}
+
+	fs := flag.NewFlagSet("images reconcile", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "only report inconsistencies, do not delete anything")
+	grace := fs.Duration("grace", cfg.Images.OrphanGracePeriod, "files younger than this are never treated as orphans")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	dataStore := store.NewStore(db, cfg)
+
+	report, err := dataStore.ImageReconcile.Reconcile(context.Background(), models.ReconcileOptions{
+		DryRun:      *dryRun,
+		GracePeriod: *grace,
+	})
+	if err != nil {
+		return fmt.Errorf("images reconcile: %w", err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// ============================================
+// rentor users ...
+// ============================================
+func runUsersCommand(db *storage.DB, cfg *config.Config, args []string) error {
+	if len(args) != 3 || args[0] != "set-role" {
+		return fmt.Errorf("usage: rentor users set-role <email> <user|admin>")
+	}
+
+	dataStore := store.NewStore(db, cfg)
+
+	if err := dataStore.UserService.SetUserRole(context.Background(), args[1], args[2]); err != nil {
+		return fmt.Errorf("users set-role: %w", err)
+	}
+
+	fmt.Printf("role of %s set to %s\n", args[1], args[2])
+	return nil
+}
+
+// ============================================
+// rentor import ...
+// ============================================
+func runImportCommand(db *storage.DB, cfg *config.Config, args []string) error {
+	usage := fmt.Errorf("usage: rentor import [-format csv|json|xml] [-mapping JSON] [-dry-run] <email> <file>")
+
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := fs.String("format", "", "file format, detected from the file extension by default")
+	mapping := fs.String("mapping", "", `advertisement field -> CSV column or JSON key, e.g. {"price":"Цена"}`)
+	dryRun := fs.Bool("dry-run", false, "only report what would change")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return usage
+	}
+	email, path := fs.Arg(0), fs.Arg(1)
+
+	input := &models.ImportInput{Format: *format, DryRun: *dryRun}
+	if *mapping != "" {
+		if err := json.Unmarshal([]byte(*mapping), &input.Mapping); err != nil {
+			return fmt.Errorf("import: invalid mapping: %w", err)
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+	defer file.Close()
+
+	dataStore := store.NewStore(db, cfg)
+	ctx := context.Background()
+
+	user, err := dataStore.User.GetUserByEmail(ctx, email)
+	if err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+	if user == nil {
+		return fmt.Errorf("import: no user with email %s: %w", email, apperr.ErrUserNotFound)
+	}
+
+	// the CLI imports the whole file at once, photos included
+	report, err := dataStore.ImportService.ImportNow(ctx, user.UserID, input, filepath.Base(path), file)
+	if err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// ============================================
+// rentor migrate ...
+// ============================================
+func runMigrateCommand(db *storage.DB, cfg *config.Config, args []string) error {
+	usage := fmt.Errorf("usage: rentor migrate <status|up|up-to V|down|down-to V|redo|create NAME|validate>")
+	if len(args) == 0 {
+		return usage
+	}
+
+	// create and validate are handled by runFileCommand before the database is opened
+
+	migrator, err := storage.NewMigrator(db.DB, cfg.StorageDriver)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return fmt.Errorf("migrate status: %w", err)
+		}
+		fmt.Printf("%-25s %s\n", "Applied At", "Migration")
+		for _, s := range statuses {
+			appliedAt := "Pending"
+			if s.State == goose.StateApplied {
+				appliedAt = s.AppliedAt.UTC().Format("2006-01-02 15:04:05 UTC")
+			}
+			fmt.Printf("%-25s %s\n", appliedAt, filepath.Base(s.Source.Path))
+		}
+		return nil
+	case "up":
+		return migrator.Up(ctx)
+	case "up-to", "down-to":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: rentor migrate %s <version>", args[0])
+		}
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		if args[0] == "up-to" {
+			return migrator.UpTo(ctx, version)
+		}
+		return migrator.DownTo(ctx, version)
+	case "down":
+		return migrator.Down(ctx)
+	case "redo":
+		return migrator.Redo(ctx)
+	default:
+		return usage
+	}
+}
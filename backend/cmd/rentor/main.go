@@ -10,6 +10,8 @@ import (
 	"time"
 
 	"rentor/internal/config"
+	"rentor/internal/jobs"
+	"rentor/internal/models"
 	"rentor/internal/store"
 
 	httpserver "rentor/internal/http-server"
@@ -40,15 +42,38 @@ func main() {
 
 	logger.Info("Config loaded successfully",
 		logger.Field("env", cfg.Env),
+		logger.Field("storage_driver", cfg.StorageDriver),
 		logger.Field("storage_path", cfg.StoragePath),
 		logger.Field("http_host", cfg.HTTPServer.Host),
 		logger.Field("http_port", cfg.HTTPServer.Port),
 	)
 
+	// commands working with migration files only don't need the database
+	if len(os.Args) > 1 {
+		if handled, err := runFileCommand(os.Args[1:]); handled {
+			if err != nil {
+				logger.Fatal(err.Error())
+			}
+			return
+		}
+	}
+
 	// ============================================
 	// 3. DB connection
 	// ============================================
-	db, err := storage.Connect(cfg.StoragePath)
+	if len(os.Args) > 1 {
+		// CLI commands don't need the read pool, restore has to close every connection before it swaps the files
+		cfg.SQLite.MaxReadConns = 0
+	} else if cfg.StorageDriver == storage.DriverSQLite {
+		// the server holds the database lock while it runs, `rentor restore` refuses to run while it is held
+		lock, err := storage.LockDatabase(cfg.StoragePath)
+		if err != nil {
+			logger.Fatal(err.Error())
+		}
+		defer lock.Release()
+	}
+
+	db, err := storage.Connect(cfg)
 	if err != nil {
 		logger.Fatal(err.Error())
 	}
@@ -56,15 +81,39 @@ func main() {
 
 	logger.Info("Database connected successfully")
 
+	// ============================================
+	// CLI subcommands (rentor <command> ...)
+	// ============================================
+	if len(os.Args) > 1 {
+		if err := runCommand(db, cfg, os.Args[1:]); err != nil {
+			logger.Fatal(err.Error())
+		}
+		return
+	}
+
 	// ============================================
 	// 4. Migrations
 	// ============================================
-	err = storage.RunMigrations(db, "./migrations", "up")
+	migrator, err := storage.NewMigrator(db.DB, cfg.StorageDriver)
 	if err != nil {
 		logger.Fatal(err.Error())
 	}
 
-	logger.Info("Database migrations completed")
+	if cfg.Migrations.AutoApply {
+		if err := migrator.Up(context.Background()); err != nil {
+			logger.Fatal(err.Error())
+		}
+		logger.Info("Database migrations completed")
+	} else {
+		pending, err := migrator.HasPending(context.Background())
+		if err != nil {
+			logger.Fatal(err.Error())
+		}
+		if pending {
+			logger.Fatal("Database has pending migrations, run `rentor migrate up` or enable migrations.auto_apply")
+		}
+		logger.Info("Database schema is up to date")
+	}
 
 	// ============================================
 	// 5. Store initialization ( Repositories + Services )
@@ -86,15 +135,16 @@ func main() {
 	router.Use(
 		cors.Handler(cors.Options{
 			AllowedOrigins:   []string{"http://localhost:5173"},
-			AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+			AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 			AllowedHeaders:   []string{"*"},
-			ExposedHeaders:   []string{"Link"},
+			ExposedHeaders:   []string{"Link", "ETag"},
 			AllowCredentials: true,
 			MaxAge:           300,
 		}))
 	// ============================================
 	// 7. Middlewares registration
 	// ============================================
+	// the request timeout is set per route, see httpserver.RegisterRoutes
 	router.Use(mwLogger.LoggingMiddleware())
 
 	logger.Info("HTTP middlewares registered")
@@ -103,24 +153,57 @@ func main() {
 	// 8. HTTP handlers registration
 	// ============================================
 
-	httpserver.RegisterRoutes(router, dataStore, cfg)
+	if err := httpserver.RegisterRoutes(router, dataStore, cfg); err != nil {
+		logger.Fatal("HTTP handlers registration failed", logger.Field("error", err.Error()))
+	}
 
 	logger.Info("HTTP handlers registered")
 
 	// ============================================
-	// 9. Server start
+	// 9. Background jobs
+	// ============================================
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	jobs.Every(jobsCtx, "upload-sessions-gc", cfg.Uploads.CleanupInterval, dataStore.UploadService.CleanupExpiredSessions)
+	jobs.Every(jobsCtx, "image-reconcile", cfg.Images.ReconcileInterval, func(ctx context.Context) error {
+		return dataStore.ImageReconcile.RunScheduled(ctx, models.ReconcileOptions{
+			DryRun:      cfg.Images.ReconcileDryRun,
+			GracePeriod: cfg.Images.OrphanGracePeriod,
+		})
+	})
+	jobs.Every(jobsCtx, "city-analytics", cfg.Analytics.RefreshInterval, dataStore.AnalyticsService.RefreshCityStats)
+	// the rollups are also built right away, otherwise the analytics endpoint has nothing to serve until the first tick
+	go func() {
+		if err := dataStore.AnalyticsService.RefreshCityStats(jobsCtx); err != nil {
+			logger.Error("city statistics refresh failed", logger.Field("error", err.Error()))
+		}
+	}()
+	jobs.Every(jobsCtx, "purge-deleted", cfg.SoftDelete.PurgeInterval, dataStore.PurgeService.PurgeDeleted)
+	jobs.Every(jobsCtx, "erase-accounts", cfg.SoftDelete.PurgeInterval, dataStore.AccountService.EraseDueAccounts)
+	jobs.Every(jobsCtx, "stats-flush", cfg.Stats.FlushInterval, dataStore.StatsService.Flush)
+	jobs.Every(jobsCtx, "recently-viewed-gc", cfg.Recommendations.CleanupInterval, dataStore.RecommendationService.CleanupRecentlyViewed)
+	jobs.Every(jobsCtx, "imports", cfg.Imports.PollInterval, dataStore.ImportService.ProcessQueuedJobs)
+	jobs.Every(jobsCtx, "export-archives", cfg.Exports.PollInterval, dataStore.ExportService.ProcessQueuedArchives)
+	jobs.Every(jobsCtx, "export-archives-gc", cfg.Exports.CleanupInterval, dataStore.ExportService.CleanupExpiredArchives)
+
+	if backuper, err := storage.NewBackuper(db, cfg); err != nil {
+		logger.Warn("scheduled backups disabled", logger.Field("reason", err.Error()))
+	} else {
+		jobs.Every(jobsCtx, "backup", cfg.Backup.Interval, func(ctx context.Context) error {
+			_, err := backuper.Create(ctx)
+			return err
+		})
+	}
+
+	// ============================================
+	// 10. Server start
 	// ============================================
 
 	done := make(chan os.Signal, 1)
 	signal.Notify(done, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
 
-	server := &http.Server{
-		Addr:         cfg.HTTPServer.Host + ":" + cfg.HTTPServer.Port,
-		Handler:      router,
-		ReadTimeout:  cfg.HTTPServer.TimeoutSeconds,
-		WriteTimeout: cfg.HTTPServer.TimeoutSeconds,
-		IdleTimeout:  cfg.HTTPServer.IdleTimeoutSeconds,
-	}
+	server := httpserver.NewServer(cfg, router)
 
 	logger.Info("Starting HTTP server", logger.Field("addr", server.Addr))
 
@@ -134,6 +217,7 @@ func main() {
 
 	<-done
 	logger.Info("Shutting down server...")
+	stopJobs()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -143,5 +227,10 @@ func main() {
 		return
 	}
 
+	// requests are done, the events still buffered in memory are written before exiting
+	if err := dataStore.StatsService.Flush(ctx); err != nil {
+		logger.Error("stats flush failed", logger.Field("error", err.Error()))
+	}
+
 	logger.Info("Server shutdown completed")
 }
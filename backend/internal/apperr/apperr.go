@@ -0,0 +1,109 @@
+// Package apperr defines the domain errors returned by repositories and services.
+//
+// Every error has a Kind, which the HTTP layer maps to a status code, and a stable Code
+// clients can rely on. Check the kind with errors.Is(err, apperr.ErrNotFound) and a concrete
+// error with errors.Is(err, apperr.ErrAdvertisementNotFound).
+package apperr
+
+// Kind is the category of a domain error
+type Kind string
+
+const (
+	KindInternal        Kind = "internal"
+	KindNotFound        Kind = "not_found"
+	KindUnauthorized    Kind = "unauthorized"
+	KindForbidden       Kind = "forbidden"
+	KindConflict        Kind = "conflict"
+	KindPrecondition    Kind = "precondition_failed"
+	KindGone            Kind = "gone"
+	KindValidation      Kind = "validation"
+	KindRateLimited     Kind = "rate_limited"
+	KindTooLarge        Kind = "too_large"
+	KindUnsupportedType Kind = "unsupported_type"
+)
+
+// Kind sentinels, errors.Is matches them against any error of the kind
+var (
+	ErrNotFound     = &Error{Kind: KindNotFound}
+	ErrUnauthorized = &Error{Kind: KindUnauthorized}
+	ErrForbidden    = &Error{Kind: KindForbidden}
+	ErrConflict     = &Error{Kind: KindConflict}
+	ErrPrecondition = &Error{Kind: KindPrecondition}
+	ErrValidation   = &Error{Kind: KindValidation}
+	ErrRateLimited  = &Error{Kind: KindRateLimited}
+)
+
+// Error is a domain error
+type Error struct {
+	Kind    Kind
+	Code    string // stable machine readable code, e.g. advertisement_not_found
+	Message string // description safe to show to the client
+	Err     error  // cause, only logged
+}
+
+func (e *Error) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = string(e.Kind)
+	}
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches errors of the same kind and code; a target without a code (a kind sentinel) matches the whole kind
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+	return e.Kind == t.Kind && (t.Code == "" || t.Code == e.Code)
+}
+
+// Wrap returns a copy of e with cause attached, so the cause is logged while the client still gets e
+func (e *Error) Wrap(cause error) *Error {
+	wrapped := *e
+	wrapped.Err = cause
+	return &wrapped
+}
+
+// New creates a domain error of the given kind
+func New(kind Kind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+// NotFound creates an error for a missing resource
+func NotFound(code, message string) *Error {
+	return New(KindNotFound, code, message)
+}
+
+// Unauthorized creates an error for missing or invalid credentials
+func Unauthorized(code, message string) *Error {
+	return New(KindUnauthorized, code, message)
+}
+
+// Forbidden creates an error for an action the user is not allowed to do
+func Forbidden(code, message string) *Error {
+	return New(KindForbidden, code, message)
+}
+
+// Conflict creates an error for an action that conflicts with the current state
+func Conflict(code, message string) *Error {
+	return New(KindConflict, code, message)
+}
+
+// Invalid creates an error for input that can't be processed. Field-level failures are
+// reported with validation.Errors instead.
+func Invalid(code, message string) *Error {
+	return New(KindValidation, code, message)
+}
+
+// RateLimited creates an error for an action attempted too often
+func RateLimited(code, message string) *Error {
+	return New(KindRateLimited, code, message)
+}
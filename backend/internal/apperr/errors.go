@@ -0,0 +1,25 @@
+package apperr
+
+// Domain errors shared by repositories and services
+var (
+	ErrAdvertisementNotFound        = NotFound("advertisement_not_found", "advertisement not found")
+	ErrDeletedAdvertisementNotFound = NotFound("deleted_advertisement_not_found", "deleted advertisement not found")
+	ErrImageNotFound                = NotFound("image_not_found", "image not found")
+	ErrUserNotFound                 = NotFound("user_not_found", "user not found")
+	ErrDeletedUserNotFound          = NotFound("deleted_user_not_found", "deleted user not found")
+	ErrProfileNotFound              = NotFound("profile_not_found", "user profile not found")
+	ErrUploadSessionNotFound        = NotFound("upload_session_not_found", "upload session not found")
+	ErrUploadNotFound               = NotFound("upload_not_found", "upload not found")
+	ErrModerationItemNotFound       = NotFound("moderation_item_not_found", "moderation item not found")
+	ErrOTPNotFound                  = NotFound("otp_not_found", "OTP not found")
+	ErrImportJobNotFound            = NotFound("import_job_not_found", "import job not found")
+	ErrExportArchiveNotFound        = NotFound("export_archive_not_found", "export archive not found")
+
+	ErrUploadSessionCommitted = Conflict("upload_session_committed", "upload session is already committed")
+
+	ErrNotOwner     = Forbidden("not_owner", "you are not the owner of this advertisement")
+	ErrInvalidToken = Unauthorized("invalid_token", "invalid or expired token")
+
+	// ErrVersionMismatch is returned when the resource changed since the client read it (If-Match failed)
+	ErrVersionMismatch = New(KindPrecondition, "version_mismatch", "the resource was modified by someone else, reload it and retry")
+)
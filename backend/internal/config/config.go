@@ -5,6 +5,8 @@ import (
 	"os"
 	"time"
 
+	"rentor/internal/money"
+
 	"github.com/spf13/viper"
 )
 
@@ -15,6 +17,7 @@ type HTTPServer struct {
 	Port               string        `mapstructure:"port" yaml:"port"`
 	TimeoutSeconds     time.Duration `mapstructure:"timeout_seconds" yaml:"timeout_seconds"`
 	IdleTimeoutSeconds time.Duration `mapstructure:"idle_timeout_seconds" yaml:"idle_timeout_seconds"`
+	StreamTimeout      time.Duration `mapstructure:"stream_timeout" yaml:"stream_timeout"`
 }
 
 type Auth struct {
@@ -33,14 +36,136 @@ type SMTP struct {
 	SMTPPort     string `mapstructure:"smtp_port" yaml:"smtp_port" default:""`
 }
 
+type SMS struct {
+	GatewayURL string `mapstructure:"gateway_url" yaml:"gateway_url"`
+	Token      string `mapstructure:"token" yaml:"token" default:""`
+}
+
+type Uploads struct {
+	StagingPath     string        `mapstructure:"staging_path" yaml:"staging_path"`
+	MaxFileSize     int64         `mapstructure:"max_file_size" yaml:"max_file_size"`
+	MaxFiles        int           `mapstructure:"max_files" yaml:"max_files"`
+	AllowedTypes    []string      `mapstructure:"allowed_types" yaml:"allowed_types"`
+	SessionTTL      time.Duration `mapstructure:"session_ttl" yaml:"session_ttl"`
+	CleanupInterval time.Duration `mapstructure:"cleanup_interval" yaml:"cleanup_interval"`
+}
+
+type Images struct {
+	ReconcileInterval time.Duration `mapstructure:"reconcile_interval" yaml:"reconcile_interval"`
+	ReconcileDryRun   bool          `mapstructure:"reconcile_dry_run" yaml:"reconcile_dry_run"`
+	OrphanGracePeriod time.Duration `mapstructure:"orphan_grace_period" yaml:"orphan_grace_period"`
+}
+
+type Moderation struct {
+	PhotoMaxDistance          int     `mapstructure:"photo_max_distance" yaml:"photo_max_distance"`
+	DuplicateListingThreshold float64 `mapstructure:"duplicate_listing_threshold" yaml:"duplicate_listing_threshold"`
+}
+
+type SQLite struct {
+	JournalMode     string        `mapstructure:"journal_mode" yaml:"journal_mode"`
+	BusyTimeout     time.Duration `mapstructure:"busy_timeout" yaml:"busy_timeout"`
+	ForeignKeys     bool          `mapstructure:"foreign_keys" yaml:"foreign_keys"`
+	Synchronous     string        `mapstructure:"synchronous" yaml:"synchronous"`
+	MaxReadConns    int           `mapstructure:"max_read_conns" yaml:"max_read_conns"`
+	ConnMaxIdleTime time.Duration `mapstructure:"conn_max_idle_time" yaml:"conn_max_idle_time"`
+}
+
+type Migrations struct {
+	AutoApply bool `mapstructure:"auto_apply" yaml:"auto_apply"`
+}
+
+type Backup struct {
+	Dir           string        `mapstructure:"dir" yaml:"dir"`
+	Interval      time.Duration `mapstructure:"interval" yaml:"interval"`
+	Retention     int           `mapstructure:"retention" yaml:"retention"`
+	IncludeImages bool          `mapstructure:"include_images" yaml:"include_images"`
+}
+
+type SoftDelete struct {
+	UndoWindow         time.Duration `mapstructure:"undo_window" yaml:"undo_window"`
+	Retention          time.Duration `mapstructure:"retention" yaml:"retention"`
+	PurgeInterval      time.Duration `mapstructure:"purge_interval" yaml:"purge_interval"`
+	AccountGracePeriod time.Duration `mapstructure:"account_grace_period" yaml:"account_grace_period"`
+}
+
+type History struct {
+	PriceDropPeriod time.Duration `mapstructure:"price_drop_period" yaml:"price_drop_period"`
+}
+
+type Analytics struct {
+	RefreshInterval time.Duration `mapstructure:"refresh_interval" yaml:"refresh_interval"`
+}
+
+type Stats struct {
+	FlushInterval time.Duration `mapstructure:"flush_interval" yaml:"flush_interval"`
+	MaxBuffered   int           `mapstructure:"max_buffered" yaml:"max_buffered"`
+	DashboardDays int           `mapstructure:"dashboard_days" yaml:"dashboard_days"`
+}
+
+type Recommendations struct {
+	SimilarLimit        int           `mapstructure:"similar_limit" yaml:"similar_limit"`
+	PriceBand           float64       `mapstructure:"price_band" yaml:"price_band"`
+	MaxDistanceKm       float64       `mapstructure:"max_distance_km" yaml:"max_distance_km"`
+	RecentlyViewedLimit int           `mapstructure:"recently_viewed_limit" yaml:"recently_viewed_limit"`
+	RecentlyViewedTTL   time.Duration `mapstructure:"recently_viewed_ttl" yaml:"recently_viewed_ttl"`
+	CleanupInterval     time.Duration `mapstructure:"cleanup_interval" yaml:"cleanup_interval"`
+}
+
+type Imports struct {
+	MaxRows           int           `mapstructure:"max_rows" yaml:"max_rows"`
+	MaxSyncRows       int           `mapstructure:"max_sync_rows" yaml:"max_sync_rows"`
+	MaxFileSize       int64         `mapstructure:"max_file_size" yaml:"max_file_size"`
+	MaxPhotos         int           `mapstructure:"max_photos" yaml:"max_photos"`
+	PhotoTimeout      time.Duration `mapstructure:"photo_timeout" yaml:"photo_timeout"`
+	AllowPrivateHosts bool          `mapstructure:"allow_private_hosts" yaml:"allow_private_hosts"`
+	PollInterval      time.Duration `mapstructure:"poll_interval" yaml:"poll_interval"`
+}
+
+type Exports struct {
+	Path            string        `mapstructure:"path" yaml:"path"`
+	SiteURL         string        `mapstructure:"site_url" yaml:"site_url"`
+	ArchiveTTL      time.Duration `mapstructure:"archive_ttl" yaml:"archive_ttl"`
+	PollInterval    time.Duration `mapstructure:"poll_interval" yaml:"poll_interval"`
+	CleanupInterval time.Duration `mapstructure:"cleanup_interval" yaml:"cleanup_interval"`
+}
+
+type Currency struct {
+	Default string             `mapstructure:"default" yaml:"default"`
+	Rates   map[string]float64 `mapstructure:"rates" yaml:"rates"`
+
+	Table *money.Rates `mapstructure:"-" yaml:"-"` // built from Default and Rates by LoadConfig
+}
+
+type API struct {
+	ContractValidation string `mapstructure:"contract_validation" yaml:"contract_validation"`
+}
+
 type Config struct {
-	Env              string     `mapstructure:"env" yaml:"env"`
-	StoragePath      string     `mapstructure:"storage_path" yaml:"storage_path"`
-	ImageStoragePath string     `mapstructure:"image_storage_path" yaml:"image_storage_path"`
-	BaseURL          string     `mapstructure:"base_url" yaml:"base_url"`
-	HTTPServer       HTTPServer `mapstructure:"http_server" yaml:"http_server"`
-	Auth             Auth       `mapstructure:"auth" yaml:"auth"`
-	SMTP             SMTP       `mapstructure:"smtp" yaml:"smtp"`
+	Env              string          `mapstructure:"env" yaml:"env"`
+	StorageDriver    string          `mapstructure:"storage_driver" yaml:"storage_driver"`
+	StoragePath      string          `mapstructure:"storage_path" yaml:"storage_path"`
+	StorageDSN       string          `mapstructure:"storage_dsn" yaml:"storage_dsn"`
+	SQLite           SQLite          `mapstructure:"sqlite" yaml:"sqlite"`
+	Migrations       Migrations      `mapstructure:"migrations" yaml:"migrations"`
+	Backup           Backup          `mapstructure:"backup" yaml:"backup"`
+	ImageStoragePath string          `mapstructure:"image_storage_path" yaml:"image_storage_path"`
+	BaseURL          string          `mapstructure:"base_url" yaml:"base_url"`
+	HTTPServer       HTTPServer      `mapstructure:"http_server" yaml:"http_server"`
+	API              API             `mapstructure:"api" yaml:"api"`
+	Auth             Auth            `mapstructure:"auth" yaml:"auth"`
+	SMTP             SMTP            `mapstructure:"smtp" yaml:"smtp"`
+	SMS              SMS             `mapstructure:"sms" yaml:"sms"`
+	Uploads          Uploads         `mapstructure:"uploads" yaml:"uploads"`
+	Images           Images          `mapstructure:"images" yaml:"images"`
+	Moderation       Moderation      `mapstructure:"moderation" yaml:"moderation"`
+	SoftDelete       SoftDelete      `mapstructure:"soft_delete" yaml:"soft_delete"`
+	History          History         `mapstructure:"history" yaml:"history"`
+	Analytics        Analytics       `mapstructure:"analytics" yaml:"analytics"`
+	Stats            Stats           `mapstructure:"stats" yaml:"stats"`
+	Recommendations  Recommendations `mapstructure:"recommendations" yaml:"recommendations"`
+	Imports          Imports         `mapstructure:"imports" yaml:"imports"`
+	Exports          Exports         `mapstructure:"exports" yaml:"exports"`
+	Currency         Currency        `mapstructure:"currency" yaml:"currency"`
 }
 
 // LoadConfig loads configuration from a YAML file specified by CONFIG_PATH env variable
@@ -52,6 +177,7 @@ func LoadConfig() (*Config, error) {
 
 	viper.SetConfigFile(config_path)
 	viper.SetConfigType("yaml")
+	setDefaults()
 
 	if err := viper.ReadInConfig(); err != nil {
 		return nil, errors.New("LoadConfig: error reading config file: " + err.Error())
@@ -62,6 +188,19 @@ func LoadConfig() (*Config, error) {
 		return nil, errors.New("LoadConfig: error unmarshalling config: " + err.Error())
 	}
 
+	switch config.StorageDriver {
+	case "sqlite":
+	case "postgres":
+		if config.StorageDSN == "" {
+			config.StorageDSN = os.Getenv("STORAGE_DSN")
+			if config.StorageDSN == "" {
+				return nil, errors.New("LoadConfig: STORAGE_DSN env variable is not set")
+			}
+		}
+	default:
+		return nil, errors.New("LoadConfig: unsupported storage_driver " + config.StorageDriver + " (sqlite|postgres)")
+	}
+
 	if config.Auth.JWTSecret == "" {
 		config.Auth.JWTSecret = os.Getenv("JWT_SECRET")
 		if config.Auth.JWTSecret == "" {
@@ -97,9 +236,78 @@ func LoadConfig() (*Config, error) {
 		}
 	}
 
+	rates, err := money.NewRates(config.Currency.Default, config.Currency.Rates)
+	if err != nil {
+		return nil, errors.New("LoadConfig: currency: " + err.Error())
+	}
+	config.Currency.Table = rates
+
+	// the gateway token is optional, without a gateway codes sent by SMS are only logged
+	if config.SMS.Token == "" {
+		config.SMS.Token = os.Getenv("SMS_GATEWAY_TOKEN")
+	}
+
 	if os.Getenv("DOCKER") == "true" {
 		config.HTTPServer.Host = "0.0.0.0"
 	}
 
 	return &config, nil
 }
+
+// setDefaults sets fallback values for optional configuration fields
+func setDefaults() {
+	viper.SetDefault("storage_driver", "sqlite")
+	viper.SetDefault("http_server.stream_timeout", 10*time.Minute)
+	viper.SetDefault("migrations.auto_apply", true)
+	viper.SetDefault("api.contract_validation", "off")
+	viper.SetDefault("sqlite.journal_mode", "WAL")
+	viper.SetDefault("sqlite.busy_timeout", 5*time.Second)
+	viper.SetDefault("sqlite.foreign_keys", true)
+	viper.SetDefault("sqlite.synchronous", "NORMAL")
+	viper.SetDefault("sqlite.max_read_conns", 4)
+	viper.SetDefault("sqlite.conn_max_idle_time", 5*time.Minute)
+	viper.SetDefault("backup.dir", "./storage/backups")
+	viper.SetDefault("backup.interval", 24*time.Hour)
+	viper.SetDefault("backup.retention", 7)
+	viper.SetDefault("backup.include_images", true)
+	viper.SetDefault("sms.gateway_url", "")
+	viper.SetDefault("uploads.staging_path", "./storage/uploads")
+	viper.SetDefault("uploads.max_file_size", 10<<20)
+	viper.SetDefault("uploads.max_files", 20)
+	viper.SetDefault("uploads.allowed_types", []string{"image/jpeg", "image/png", "image/webp"})
+	viper.SetDefault("uploads.session_ttl", 30*time.Minute)
+	viper.SetDefault("uploads.cleanup_interval", 10*time.Minute)
+	viper.SetDefault("images.reconcile_interval", 24*time.Hour)
+	viper.SetDefault("images.reconcile_dry_run", true)
+	viper.SetDefault("images.orphan_grace_period", time.Hour)
+	viper.SetDefault("moderation.photo_max_distance", 6)
+	viper.SetDefault("moderation.duplicate_listing_threshold", 0.6)
+	viper.SetDefault("soft_delete.undo_window", 30*time.Minute)
+	viper.SetDefault("soft_delete.retention", 30*24*time.Hour)
+	viper.SetDefault("soft_delete.purge_interval", time.Hour)
+	viper.SetDefault("soft_delete.account_grace_period", 30*24*time.Hour)
+	viper.SetDefault("history.price_drop_period", 14*24*time.Hour)
+	viper.SetDefault("analytics.refresh_interval", time.Hour)
+	viper.SetDefault("stats.flush_interval", 10*time.Second)
+	viper.SetDefault("stats.max_buffered", 10000)
+	viper.SetDefault("stats.dashboard_days", 30)
+	viper.SetDefault("recommendations.similar_limit", 10)
+	viper.SetDefault("recommendations.price_band", 0.3)
+	viper.SetDefault("recommendations.max_distance_km", 20)
+	viper.SetDefault("recommendations.recently_viewed_limit", 20)
+	viper.SetDefault("recommendations.recently_viewed_ttl", 90*24*time.Hour)
+	viper.SetDefault("recommendations.cleanup_interval", 24*time.Hour)
+	viper.SetDefault("imports.max_rows", 5000)
+	viper.SetDefault("imports.max_sync_rows", 50)
+	viper.SetDefault("imports.max_file_size", 20<<20)
+	viper.SetDefault("imports.max_photos", 20)
+	viper.SetDefault("imports.photo_timeout", 30*time.Second)
+	viper.SetDefault("imports.allow_private_hosts", false)
+	viper.SetDefault("imports.poll_interval", 5*time.Second)
+	viper.SetDefault("currency.default", "KZT")
+	viper.SetDefault("exports.path", "./storage/exports")
+	viper.SetDefault("exports.site_url", "")
+	viper.SetDefault("exports.archive_ttl", 72*time.Hour)
+	viper.SetDefault("exports.poll_interval", 10*time.Second)
+	viper.SetDefault("exports.cleanup_interval", time.Hour)
+}
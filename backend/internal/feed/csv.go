@@ -0,0 +1,108 @@
+package feed
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// readCSV reads a table with a header row. The delimiter is detected from the header:
+// spreadsheets exported with a comma decimal separator use semicolons.
+func readCSV(r io.Reader, mapping Mapping, maxRows int) ([]Record, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(4096)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, ErrInvalidFile.Wrap(err)
+	}
+	if i := bytes.IndexByte(header, '\n'); i >= 0 {
+		header = header[:i]
+	}
+
+	cr := csv.NewReader(br)
+	cr.Comma = detectDelimiter(header)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	columns, err := cr.Read()
+	if err != nil {
+		return nil, ErrInvalidFile.Wrap(fmt.Errorf("header: %w", err))
+	}
+	if len(columns) > 0 {
+		columns[0] = strings.TrimPrefix(columns[0], "\ufeff")
+	}
+
+	// column index of every mapped field
+	index := make(map[string]int, len(Fields))
+	for i, column := range columns {
+		column = strings.TrimSpace(column)
+		for _, field := range Fields {
+			if mapping.source(field) == column {
+				index[field] = i
+			}
+		}
+	}
+	if len(index) == 0 {
+		return nil, ErrInvalidFile.Wrap(errors.New("no column matches an advertisement field, check the header or the mapping"))
+	}
+
+	var records []Record
+	for {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, ErrInvalidFile.Wrap(err)
+		}
+		if isBlank(row) {
+			continue
+		}
+		if tooMany(len(records)+1, maxRows) {
+			return nil, ErrTooManyRows
+		}
+
+		line, _ := cr.FieldPos(0)
+		rec := Record{Line: line, Fields: make(map[string]string)}
+		for field, i := range index {
+			if i >= len(row) {
+				continue
+			}
+			value := strings.TrimSpace(row[i])
+			if value == "" {
+				continue
+			}
+			if field == "photos" {
+				rec.Photos = splitPhotos(value)
+				continue
+			}
+			rec.Fields[field] = value
+		}
+		records = append(records, rec)
+	}
+
+	return records, nil
+}
+
+// detectDelimiter picks the delimiter used in the header row
+func detectDelimiter(header []byte) rune {
+	best, count := ',', bytes.Count(header, []byte{','})
+	for _, d := range []rune{';', '\t'} {
+		if n := bytes.Count(header, []byte(string(d))); n > count {
+			best, count = d, n
+		}
+	}
+	return best
+}
+
+func isBlank(row []string) bool {
+	for _, value := range row {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
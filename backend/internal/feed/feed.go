@@ -0,0 +1,122 @@
+// Package feed reads and writes listings in files of other systems: CSV tables, JSON exports and
+// real-estate XML feeds (the Yandex.Realty format most portals understand).
+//
+// Every format is read into Records keyed by the advertisement fields (see Fields).
+// CSV columns and JSON keys are matched to the fields by a Mapping, the XML feed has a fixed layout.
+// Writers produce the same layouts, so an exported file can be imported again.
+package feed
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"rentor/internal/apperr"
+	"rentor/internal/validation"
+)
+
+// Supported file formats
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+	FormatXML  = "xml"
+)
+
+// Formats lists the supported file formats
+var Formats = []string{FormatCSV, FormatJSON, FormatXML}
+
+// Fields are the advertisement fields a record can carry. Photos holds photo URLs,
+// all other fields hold a single value.
+var Fields = []string{
+	"externalId", "title", "description", "price", "currency", "pricePeriod", "type", "rooms",
+	"city", "address", "latitude", "longitude", "square", "status", "photos",
+}
+
+var (
+	ErrUnsupportedFormat = apperr.Invalid("unsupported_format", "unsupported file format, expected csv, json or xml")
+	ErrInvalidFile       = apperr.Invalid("invalid_file", "the file can't be read in the given format")
+	ErrInvalidMapping    = apperr.Invalid("invalid_mapping", "the mapping names unknown advertisement fields")
+	ErrTooManyRows       = apperr.Invalid("too_many_rows", "the file has too many listings")
+)
+
+// Record is one listing read from a file
+type Record struct {
+	Line   int                     // line (CSV), position (JSON) or offer number (XML) in the file, starting at 1
+	Fields map[string]string       // advertisement field -> value, missing and empty values are left out
+	Photos []string                // photo URLs in file order
+	Errors []validation.FieldError // values that could not be read
+
+	// only written: the XML feed links the listing page and dates the offer
+	URL       string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Mapping maps advertisement fields to CSV columns or JSON keys.
+// Fields that are not mapped are read from the column or key of the same name.
+type Mapping map[string]string
+
+// Validate checks that the mapping only names known fields
+func (m Mapping) Validate() error {
+	for field := range m {
+		if !slices.Contains(Fields, field) {
+			return ErrInvalidMapping.Wrap(fmt.Errorf("unknown field %q", field))
+		}
+	}
+	return nil
+}
+
+// source returns the column or key field is read from
+func (m Mapping) source(field string) string {
+	if src, ok := m[field]; ok && src != "" {
+		return src
+	}
+	return field
+}
+
+// DetectFormat guesses the format from a file name, "" if the extension is unknown
+func DetectFormat(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv", ".tsv", ".txt":
+		return FormatCSV
+	case ".json":
+		return FormatJSON
+	case ".xml", ".yml":
+		return FormatXML
+	}
+	return ""
+}
+
+// Read reads all listings of r. Reading stops with ErrTooManyRows after maxRows listings (0 means no limit).
+// Values that can't be read are reported per record, only a broken file fails as a whole.
+func Read(r io.Reader, format string, mapping Mapping, maxRows int) ([]Record, error) {
+	if err := mapping.Validate(); err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatCSV:
+		return readCSV(r, mapping, maxRows)
+	case FormatJSON:
+		return readJSON(r, mapping, maxRows)
+	case FormatXML:
+		return readXML(r, maxRows)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+// splitPhotos splits a list of photo URLs separated by whitespace or |
+func splitPhotos(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return r == '|' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})
+}
+
+// tooMany reports whether a record beyond the limit has been reached
+func tooMany(count, maxRows int) bool {
+	return maxRows > 0 && count > maxRows
+}
@@ -0,0 +1,243 @@
+package feed_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"rentor/internal/feed"
+)
+
+// record is the part of a feed.Record the readers fill in
+type record struct {
+	Line   int
+	Fields map[string]string
+	Photos []string
+	Errors []string // field:code
+}
+
+func records(got []feed.Record) []record {
+	out := make([]record, 0, len(got))
+	for _, rec := range got {
+		r := record{Line: rec.Line, Fields: rec.Fields, Photos: rec.Photos}
+		for _, fe := range rec.Errors {
+			r.Errors = append(r.Errors, fe.Field+":"+fe.Code)
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+type readTest struct {
+	name    string
+	input   string
+	mapping feed.Mapping
+	maxRows int
+	want    []record
+	wantErr error
+}
+
+func runReadTests(t *testing.T, format string, tests []readTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := feed.Read(strings.NewReader(tt.input), format, tt.mapping, tt.maxRows)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Read: %v", err)
+			}
+			if recs := records(got); !reflect.DeepEqual(recs, tt.want) {
+				t.Errorf("records:\n got %+v\nwant %+v", recs, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	runReadTests(t, feed.FormatCSV, []readTest{
+		{
+			name:  "comma separated",
+			input: "externalId,title,price,photos\nA1,Flat,150000,https://a/1.jpg|https://a/2.jpg https://a/3.jpg\n",
+			want: []record{{Line: 2, Fields: map[string]string{"externalId": "A1", "title": "Flat", "price": "150000"},
+				Photos: []string{"https://a/1.jpg", "https://a/2.jpg", "https://a/3.jpg"}}},
+		},
+		{
+			name:  "semicolons with a decimal comma",
+			input: "externalId;price;square\nA1;150 000,50;42,5\n",
+			want:  []record{{Line: 2, Fields: map[string]string{"externalId": "A1", "price": "150 000,50", "square": "42,5"}}},
+		},
+		{
+			name:  "tab separated",
+			input: "externalId\ttitle\nA1\tFlat, sea view\n",
+			want:  []record{{Line: 2, Fields: map[string]string{"externalId": "A1", "title": "Flat, sea view"}}},
+		},
+		{
+			name:  "byte order mark and padded header",
+			input: "\ufeffexternalId, title \nA1, Flat\n",
+			want:  []record{{Line: 2, Fields: map[string]string{"externalId": "A1", "title": "Flat"}}},
+		},
+		{
+			name:    "mapped columns",
+			input:   "Код,Название,Цена,title\nA1,Квартира,150000,ignored\n",
+			mapping: feed.Mapping{"externalId": "Код", "title": "Название", "price": "Цена"},
+			want:    []record{{Line: 2, Fields: map[string]string{"externalId": "A1", "title": "Квартира", "price": "150000"}}},
+		},
+		{
+			name:  "blank rows and empty values are skipped",
+			input: "externalId,title,city\n\nA1,,Almaty\n , ,\nA2,Flat\n",
+			want: []record{
+				{Line: 3, Fields: map[string]string{"externalId": "A1", "city": "Almaty"}},
+				{Line: 5, Fields: map[string]string{"externalId": "A2", "title": "Flat"}},
+			},
+		},
+		{
+			name:  "quoted multi-line value",
+			input: "externalId,description\nA1,\"first line\nsecond line\"\nA2,short\n",
+			want: []record{
+				{Line: 2, Fields: map[string]string{"externalId": "A1", "description": "first line\nsecond line"}},
+				{Line: 4, Fields: map[string]string{"externalId": "A2", "description": "short"}},
+			},
+		},
+		{
+			name:    "rows up to the limit",
+			input:   "externalId\nA1\nA2\n",
+			maxRows: 2,
+			want: []record{
+				{Line: 2, Fields: map[string]string{"externalId": "A1"}},
+				{Line: 3, Fields: map[string]string{"externalId": "A2"}},
+			},
+		},
+		{name: "too many rows", input: "externalId\nA1\nA2\nA3\n", maxRows: 2, wantErr: feed.ErrTooManyRows},
+		{name: "no known column", input: "foo,bar\n1,2\n", wantErr: feed.ErrInvalidFile},
+		{name: "empty file", input: "", wantErr: feed.ErrInvalidFile},
+		{name: "broken quotes", input: "externalId,title\nA1,\"Flat\n", wantErr: feed.ErrInvalidFile},
+		{name: "unknown mapped field", input: "externalId\nA1\n", mapping: feed.Mapping{"colour": "Цвет"}, wantErr: feed.ErrInvalidMapping},
+	})
+}
+
+func TestReadJSON(t *testing.T) {
+	runReadTests(t, feed.FormatJSON, []readTest{
+		{
+			name:  "array of objects",
+			input: `[{"externalId":"A1","title":"Flat","price":150000.5,"square":42,"photos":["https://a/1.jpg","https://a/2.jpg"]}]`,
+			want: []record{{Line: 1, Fields: map[string]string{"externalId": "A1", "title": "Flat", "price": "150000.5", "square": "42"},
+				Photos: []string{"https://a/1.jpg", "https://a/2.jpg"}}},
+		},
+		{
+			name:  "items member of an object",
+			input: `{"generated":"2026-10-01","meta":{"count":2},"items":[{"externalId":"A1"},{"externalId":"A2","photos":"https://a/1.jpg|https://a/2.jpg"}]}`,
+			want: []record{
+				{Line: 1, Fields: map[string]string{"externalId": "A1"}},
+				{Line: 2, Fields: map[string]string{"externalId": "A2"}, Photos: []string{"https://a/1.jpg", "https://a/2.jpg"}},
+			},
+		},
+		{
+			name:    "mapped keys",
+			input:   `[{"id":7,"name":"Квартира","price":1}]`,
+			mapping: feed.Mapping{"externalId": "id", "title": "name"},
+			want:    []record{{Line: 1, Fields: map[string]string{"externalId": "7", "title": "Квартира", "price": "1"}}},
+		},
+		{
+			name:  "null and empty values are skipped",
+			input: `[{"externalId":"A1","title":null,"city":""}]`,
+			want:  []record{{Line: 1, Fields: map[string]string{"externalId": "A1"}}},
+		},
+		{
+			name:  "values of the wrong type are reported",
+			input: `[{"externalId":"A1","title":{"ru":"Квартира"},"photos":[1,2]}]`,
+			want:  []record{{Line: 1, Fields: map[string]string{"externalId": "A1"}, Errors: []string{"title:invalid_type", "photos:invalid_type"}}},
+		},
+		{name: "too many items", input: `[{"externalId":"A1"},{"externalId":"A2"}]`, maxRows: 1, wantErr: feed.ErrTooManyRows},
+		{name: "object without items", input: `{"listings":[]}`, wantErr: feed.ErrInvalidFile},
+		{name: "items is not an array", input: `{"items":{}}`, wantErr: feed.ErrInvalidFile},
+		{name: "not an array", input: `"A1"`, wantErr: feed.ErrInvalidFile},
+		{name: "broken item", input: `[{"externalId":"A1"},{"externalId":]`, wantErr: feed.ErrInvalidFile},
+	})
+}
+
+// offer wraps offer elements into a realty feed
+func offers(elements ...string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<realty-feed xmlns="http://webmaster.yandex.ru/schemas/feed"><generation-date>2026-10-01T00:00:00+00:00</generation-date>` +
+		strings.Join(elements, "") + `</realty-feed>`
+}
+
+func TestReadXML(t *testing.T) {
+	runReadTests(t, feed.FormatXML, []readTest{
+		{
+			name: "rent offer",
+			input: offers(`<offer internal-id="A1"><type>аренда</type><category>квартира</category>
+                <location><locality-name>Almaty</locality-name><address>Abay 10</address><latitude>43.2</latitude><longitude>76.9</longitude></location>
+                <price><value>150000</value><currency>KZT</currency><period>месяц</period></price>
+                <area><value>42.5</value><unit>кв. м</unit></area><rooms>2</rooms>
+                <description>Sunny</description><image>https://a/1.jpg</image><image> </image><image>https://a/2.jpg</image></offer>`),
+			want: []record{{Line: 1, Fields: map[string]string{
+				"externalId": "A1", "title": "2-room apartment, Abay 10", "description": "Sunny",
+				"price": "150000", "currency": "KZT", "pricePeriod": "month", "type": "apartment", "rooms": "2",
+				"city": "Almaty", "address": "Abay 10", "latitude": "43.2", "longitude": "76.9", "square": "42.5",
+			}, Photos: []string{"https://a/1.jpg", "https://a/2.jpg"}}},
+		},
+		{
+			name: "studio, large flat and RUR prices",
+			input: offers(
+				`<offer internal-id="A1"><type>rent</type><category>flat</category><title>Own title</title><studio>да</studio><rooms>1</rooms>
+                    <price><value>30000</value><currency>RUR</currency><period>day</period></price></offer>`,
+				`<offer internal-id="A2"><type>аренда</type><category>коттедж</category><rooms>7</rooms></offer>`,
+				`<offer internal-id="A3"><type>аренда</type><category>комната</category><rooms>1</rooms></offer>`,
+			),
+			want: []record{
+				{Line: 1, Fields: map[string]string{"externalId": "A1", "title": "Own title", "type": "apartment", "rooms": "studio",
+					"price": "30000", "currency": "RUB", "pricePeriod": "day"}},
+				{Line: 2, Fields: map[string]string{"externalId": "A2", "title": "6+-room house", "type": "house", "rooms": "6+"}},
+				{Line: 3, Fields: map[string]string{"externalId": "A3", "title": "Room", "type": "room", "rooms": "1"}},
+			},
+		},
+		{
+			name: "sale offers and weekly prices are reported",
+			input: offers(`<offer internal-id="A1"><type>продажа</type><category>гараж</category>
+                <price><value>100</value><currency>KZT</currency><period>неделя</period></price></offer>`),
+			want: []record{{Line: 1, Fields: map[string]string{"externalId": "A1", "title": "Гараж", "type": "гараж", "price": "100", "currency": "KZT"},
+				Errors: []string{"type:unsupported_offer", "pricePeriod:unsupported_period"}}},
+		},
+		{name: "too many offers", input: offers(`<offer internal-id="A1"/>`, `<offer internal-id="A2"/>`), maxRows: 1, wantErr: feed.ErrTooManyRows},
+		{name: "no offers", input: offers(), wantErr: feed.ErrInvalidFile},
+		{name: "not XML", input: `<realty-feed><offer>`, wantErr: feed.ErrInvalidFile},
+	})
+}
+
+func TestReadUnsupportedFormat(t *testing.T) {
+	if _, err := feed.Read(strings.NewReader("a"), "xlsx", nil, 0); !errors.Is(err, feed.ErrUnsupportedFormat) {
+		t.Errorf("err = %v, want %v", err, feed.ErrUnsupportedFormat)
+	}
+}
+
+func TestMappingValidate(t *testing.T) {
+	if err := (feed.Mapping{"price": "Цена", "photos": "Фото"}).Validate(); err != nil {
+		t.Errorf("valid mapping: %v", err)
+	}
+	if err := (feed.Mapping{"price": "Цена", "colour": "Цвет"}).Validate(); !errors.Is(err, feed.ErrInvalidMapping) {
+		t.Errorf("err = %v, want %v", err, feed.ErrInvalidMapping)
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	for name, want := range map[string]string{
+		"listings.csv":  feed.FormatCSV,
+		"listings.TSV":  feed.FormatCSV,
+		"export.json":   feed.FormatJSON,
+		"feed.xml":      feed.FormatXML,
+		"realty.yml":    feed.FormatXML,
+		"listings.xlsx": "",
+		"listings":      "",
+	} {
+		if got := feed.DetectFormat(name); got != want {
+			t.Errorf("DetectFormat(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package feed
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"rentor/internal/validation"
+)
+
+// readJSON reads an array of flat objects, either at the top level or in the "items" member
+// of the top-level object. Photos are an array of URLs or a string of URLs.
+func readJSON(r io.Reader, mapping Mapping, maxRows int) ([]Record, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	if err := openItems(dec); err != nil {
+		return nil, ErrInvalidFile.Wrap(err)
+	}
+
+	var records []Record
+	for dec.More() {
+		if tooMany(len(records)+1, maxRows) {
+			return nil, ErrTooManyRows
+		}
+
+		var item map[string]any
+		if err := dec.Decode(&item); err != nil {
+			return nil, ErrInvalidFile.Wrap(fmt.Errorf("item %d: %w", len(records)+1, err))
+		}
+
+		rec := Record{Line: len(records) + 1, Fields: make(map[string]string)}
+		for _, field := range Fields {
+			value, ok := item[mapping.source(field)]
+			if !ok || value == nil {
+				continue
+			}
+			if field == "photos" {
+				photos, ok := jsonPhotos(value)
+				if !ok {
+					rec.Errors = append(rec.Errors, *validation.NewError(field, "invalid_type", "must be an array of URLs"))
+					continue
+				}
+				rec.Photos = photos
+				continue
+			}
+			s, ok := jsonScalar(value)
+			if !ok {
+				rec.Errors = append(rec.Errors, *validation.NewError(field, "invalid_type", "must be a string, number or boolean"))
+				continue
+			}
+			if s != "" {
+				rec.Fields[field] = s
+			}
+		}
+		records = append(records, rec)
+	}
+
+	return records, nil
+}
+
+// openItems moves dec into the array of items
+func openItems(dec *json.Decoder) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch tok {
+	case json.Delim('['):
+		return nil
+	case json.Delim('{'):
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			if key == "items" {
+				tok, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				if tok != json.Delim('[') {
+					return errors.New(`"items" must be an array`)
+				}
+				return nil
+			}
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return err
+			}
+		}
+		return errors.New(`the object has no "items" array`)
+	}
+	return errors.New(`expected an array of listings or an object with an "items" array`)
+}
+
+// jsonScalar converts a JSON scalar to its string form
+func jsonScalar(value any) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		if v {
+			return "true", true
+		}
+		return "false", true
+	}
+	return "", false
+}
+
+// jsonPhotos reads photo URLs given as an array or as a separated string
+func jsonPhotos(value any) ([]string, bool) {
+	switch v := value.(type) {
+	case string:
+		return splitPhotos(v), true
+	case []any:
+		photos := make([]string, 0, len(v))
+		for _, item := range v {
+			url, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			photos = append(photos, url)
+		}
+		return photos, true
+	}
+	return nil, false
+}
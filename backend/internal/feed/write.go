@@ -0,0 +1,284 @@
+package feed
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// numericFields are written as JSON numbers
+var numericFields = []string{"price", "latitude", "longitude", "square"}
+
+// Writer writes listings one by one, nothing is buffered beyond the current listing
+type Writer interface {
+	Write(rec *Record) error
+	Close() error // finishes the document, the underlying writer is left open
+}
+
+// NewWriter creates a writer of the given format
+func NewWriter(w io.Writer, format string) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w)
+	case FormatJSON:
+		return &jsonWriter{w: w}, nil
+	case FormatXML:
+		return newXMLWriter(w)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+// ContentType returns the media type of a format
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatJSON:
+		return "application/json"
+	case FormatXML:
+		return "application/xml; charset=utf-8"
+	}
+	return "application/octet-stream"
+}
+
+// csvWriter writes a table with a column per field, photos are separated by |
+type csvWriter struct {
+	cw *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	// the BOM makes spreadsheets open the file as UTF-8, readCSV skips it
+	if _, err := io.WriteString(w, "\ufeff"); err != nil {
+		return nil, err
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(Fields); err != nil {
+		return nil, err
+	}
+	return &csvWriter{cw: cw}, nil
+}
+
+func (c *csvWriter) Write(rec *Record) error {
+	row := make([]string, len(Fields))
+	for i, field := range Fields {
+		if field == "photos" {
+			row[i] = strings.Join(rec.Photos, "|")
+			continue
+		}
+		row[i] = rec.Fields[field]
+	}
+	if err := c.cw.Write(row); err != nil {
+		return err
+	}
+	c.cw.Flush()
+	return c.cw.Error()
+}
+
+func (c *csvWriter) Close() error {
+	c.cw.Flush()
+	return c.cw.Error()
+}
+
+// jsonWriter writes {"items": [...]} with an object per listing, keys in the order of Fields
+type jsonWriter struct {
+	w     io.Writer
+	count int
+}
+
+func (j *jsonWriter) Write(rec *Record) error {
+	var b bytes.Buffer
+	if j.count == 0 {
+		b.WriteString("{\"items\":[\n")
+	} else {
+		b.WriteString(",\n")
+	}
+	j.count++
+
+	b.WriteByte('{')
+	first := true
+	member := func(key string, value any) error {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		if !first {
+			b.WriteByte(',')
+		}
+		first = false
+		b.WriteString(strconv.Quote(key))
+		b.WriteByte(':')
+		b.Write(encoded)
+		return nil
+	}
+	for _, field := range Fields {
+		var err error
+		switch value, ok := rec.Fields[field]; {
+		case field == "photos":
+			photos := rec.Photos
+			if photos == nil {
+				photos = []string{}
+			}
+			err = member(field, photos)
+		case !ok:
+			continue
+		case slices.Contains(numericFields, field) && isNumber(value):
+			err = member(field, json.Number(value))
+		default:
+			err = member(field, value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	b.WriteByte('}')
+
+	_, err := j.w.Write(b.Bytes())
+	return err
+}
+
+func (j *jsonWriter) Close() error {
+	closing := "\n]}\n"
+	if j.count == 0 {
+		closing = "{\"items\":[]}\n"
+	}
+	_, err := io.WriteString(j.w, closing)
+	return err
+}
+
+// realtyFeedNamespace is the namespace of Yandex.Realty feeds
+const realtyFeedNamespace = "http://webmaster.yandex.ru/schemas/feed/realty/2010-06"
+
+// realtyPeriods maps advertisement price periods to offer price periods
+var realtyPeriods = map[string]string{
+	"day":   "день",
+	"month": "месяц",
+}
+
+// realtyTypes maps advertisement types to offer categories
+var realtyTypes = map[string]string{
+	"apartment": "квартира",
+	"room":      "комната",
+	"house":     "дом",
+}
+
+// realtyOfferOut is an <offer> of a written feed, in the element order of the format
+type realtyOfferOut struct {
+	XMLName      xml.Name          `xml:"offer"`
+	InternalID   string            `xml:"internal-id,attr"`
+	Type         string            `xml:"type"`
+	PropertyType string            `xml:"property-type"`
+	Category     string            `xml:"category"`
+	URL          string            `xml:"url,omitempty"`
+	CreationDate string            `xml:"creation-date,omitempty"`
+	LastUpdate   string            `xml:"last-update-date,omitempty"`
+	Location     realtyLocationOut `xml:"location"`
+	SalesAgent   realtySalesAgent  `xml:"sales-agent"`
+	Price        realtyPriceOut    `xml:"price"`
+	Area         *realtyAreaOut    `xml:"area,omitempty"`
+	Rooms        string            `xml:"rooms,omitempty"`
+	Studio       string            `xml:"studio,omitempty"`
+	Title        string            `xml:"title,omitempty"`
+	Description  string            `xml:"description,omitempty"`
+	Images       []string          `xml:"image"`
+}
+
+type realtyLocationOut struct {
+	LocalityName string `xml:"locality-name,omitempty"`
+	Address      string `xml:"address,omitempty"`
+	Latitude     string `xml:"latitude,omitempty"`
+	Longitude    string `xml:"longitude,omitempty"`
+}
+
+type realtySalesAgent struct {
+	Category string `xml:"category"`
+}
+
+type realtyPriceOut struct {
+	Value    string `xml:"value"`
+	Currency string `xml:"currency"`
+	Period   string `xml:"period"`
+}
+
+type realtyAreaOut struct {
+	Value string `xml:"value"`
+	Unit  string `xml:"unit"`
+}
+
+// xmlWriter writes a realty feed with an <offer> per listing
+type xmlWriter struct {
+	w   io.Writer
+	enc *xml.Encoder
+}
+
+func newXMLWriter(w io.Writer) (*xmlWriter, error) {
+	header := xml.Header + `<realty-feed xmlns="` + realtyFeedNamespace + `">` + "\n" +
+		"<generation-date>" + time.Now().Format(time.RFC3339) + "</generation-date>\n"
+	if _, err := io.WriteString(w, header); err != nil {
+		return nil, err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return &xmlWriter{w: w, enc: enc}, nil
+}
+
+func (x *xmlWriter) Write(rec *Record) error {
+	f := rec.Fields
+	offer := realtyOfferOut{
+		InternalID:   f["externalId"],
+		Type:         "аренда",
+		PropertyType: "жилая",
+		Category:     realtyTypes[f["type"]],
+		URL:          rec.URL,
+		Location: realtyLocationOut{
+			LocalityName: f["city"],
+			Address:      f["address"],
+			Latitude:     f["latitude"],
+			Longitude:    f["longitude"],
+		},
+		SalesAgent:  realtySalesAgent{Category: "владелец"},
+		Price:       realtyPriceOut{Value: f["price"], Currency: f["currency"], Period: realtyPeriods[f["pricePeriod"]]},
+		Title:       f["title"],
+		Description: f["description"],
+		Images:      rec.Photos,
+	}
+	if !rec.CreatedAt.IsZero() {
+		offer.CreationDate = rec.CreatedAt.Format(time.RFC3339)
+	}
+	if !rec.UpdatedAt.IsZero() {
+		offer.LastUpdate = rec.UpdatedAt.Format(time.RFC3339)
+	}
+	if square := f["square"]; square != "" && square != "0" {
+		offer.Area = &realtyAreaOut{Value: square, Unit: "кв. м"}
+	}
+	switch rooms := f["rooms"]; rooms {
+	case "studio":
+		offer.Studio = "1"
+	case "6+":
+		offer.Rooms = "6"
+	default:
+		offer.Rooms = rooms
+	}
+
+	if err := x.enc.Encode(offer); err != nil {
+		return err
+	}
+	_, err := io.WriteString(x.w, "\n")
+	return err
+}
+
+func (x *xmlWriter) Close() error {
+	_, err := io.WriteString(x.w, "</realty-feed>\n")
+	return err
+}
+
+func isNumber(value string) bool {
+	_, err := strconv.ParseFloat(value, 64)
+	return err == nil
+}
@@ -0,0 +1,213 @@
+package feed
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"rentor/internal/validation"
+)
+
+// realtyOffer is an <offer> of a Yandex.Realty feed (<realty-feed>), only the elements of rent listings are read
+type realtyOffer struct {
+	InternalID  string         `xml:"internal-id,attr"`
+	Type        string         `xml:"type"`     // аренда|продажа
+	Category    string         `xml:"category"` // квартира|комната|дом|...
+	Title       string         `xml:"title"`    // not part of the format, some portals add it
+	Description string         `xml:"description"`
+	Location    realtyLocation `xml:"location"`
+	Price       realtyPrice    `xml:"price"`
+	Area        realtyValue    `xml:"area"`
+	Rooms       string         `xml:"rooms"`
+	Studio      string         `xml:"studio"`
+	Images      []string       `xml:"image"`
+}
+
+type realtyLocation struct {
+	LocalityName string `xml:"locality-name"`
+	Address      string `xml:"address"`
+	Latitude     string `xml:"latitude"`
+	Longitude    string `xml:"longitude"`
+}
+
+type realtyValue struct {
+	Value string `xml:"value"`
+}
+
+type realtyPrice struct {
+	Value    string `xml:"value"`
+	Currency string `xml:"currency"`
+	Period   string `xml:"period"`
+}
+
+// realtyCurrencies maps currency codes of feeds that differ from ISO 4217
+var realtyCurrencies = map[string]string{
+	"RUR": "RUB",
+}
+
+// realtyPricePeriods maps offer price periods to advertisement price periods
+var realtyPricePeriods = map[string]string{
+	"день":  "day",
+	"day":   "day",
+	"месяц": "month",
+	"month": "month",
+}
+
+// realtyCategories maps offer categories to advertisement types
+var realtyCategories = map[string]string{
+	"квартира":   "apartment",
+	"flat":       "apartment",
+	"apartment":  "apartment",
+	"комната":    "room",
+	"room":       "room",
+	"дом":        "house",
+	"house":      "house",
+	"коттедж":    "house",
+	"cottage":    "house",
+	"таунхаус":   "house",
+	"townhouse":  "house",
+	"часть дома": "house",
+}
+
+// readXML reads the <offer> elements of a realty feed one by one
+func readXML(r io.Reader, maxRows int) ([]Record, error) {
+	dec := xml.NewDecoder(r)
+
+	var records []Record
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, ErrInvalidFile.Wrap(err)
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "offer" {
+			continue
+		}
+		if tooMany(len(records)+1, maxRows) {
+			return nil, ErrTooManyRows
+		}
+
+		var offer realtyOffer
+		if err := dec.DecodeElement(&offer, &start); err != nil {
+			return nil, ErrInvalidFile.Wrap(fmt.Errorf("offer %d: %w", len(records)+1, err))
+		}
+		records = append(records, offer.record(len(records)+1))
+	}
+
+	if len(records) == 0 {
+		return nil, ErrInvalidFile.Wrap(errors.New("the feed has no <offer> elements"))
+	}
+	return records, nil
+}
+
+// record converts an offer to advertisement fields
+func (o *realtyOffer) record(line int) Record {
+	rec := Record{Line: line, Fields: make(map[string]string)}
+	set := func(field, value string) {
+		if value = strings.TrimSpace(value); value != "" {
+			rec.Fields[field] = value
+		}
+	}
+
+	if dealType := strings.ToLower(strings.TrimSpace(o.Type)); dealType != "аренда" && dealType != "rent" {
+		rec.Errors = append(rec.Errors, *validation.NewError("type", "unsupported_offer", "only rent offers can be imported"))
+	}
+
+	category := strings.ToLower(strings.TrimSpace(o.Category))
+	adType, ok := realtyCategories[category]
+	if !ok {
+		adType = category
+	}
+
+	rooms := strings.TrimSpace(o.Rooms)
+	if isTrue(o.Studio) {
+		rooms = "studio"
+	} else if n, err := strconv.Atoi(rooms); err == nil && n >= 6 {
+		rooms = "6+"
+	}
+
+	set("externalId", o.InternalID)
+	set("title", o.Title)
+	set("description", o.Description)
+	set("price", o.Price.Value)
+	set("currency", o.currency())
+	set("pricePeriod", o.pricePeriod(&rec))
+	set("type", adType)
+	set("rooms", rooms)
+	set("city", o.Location.LocalityName)
+	set("address", o.Location.Address)
+	set("latitude", o.Location.Latitude)
+	set("longitude", o.Location.Longitude)
+	set("square", o.Area.Value)
+	if _, ok := rec.Fields["title"]; !ok {
+		set("title", offerTitle(adType, rooms, o.Location.Address))
+	}
+
+	for _, image := range o.Images {
+		if image = strings.TrimSpace(image); image != "" {
+			rec.Photos = append(rec.Photos, image)
+		}
+	}
+
+	return rec
+}
+
+// currency returns the ISO code of the offer price currency
+func (o *realtyOffer) currency() string {
+	code := strings.ToUpper(strings.TrimSpace(o.Price.Currency))
+	if iso, ok := realtyCurrencies[code]; ok {
+		return iso
+	}
+	return code
+}
+
+// pricePeriod returns the advertisement price period of the offer, prices per week or year can't be imported
+func (o *realtyOffer) pricePeriod(rec *Record) string {
+	period := strings.ToLower(strings.TrimSpace(o.Price.Period))
+	if period == "" {
+		return ""
+	}
+	if p, ok := realtyPricePeriods[period]; ok {
+		return p
+	}
+	rec.Errors = append(rec.Errors, *validation.NewError("pricePeriod", "unsupported_period", "only prices per day or month can be imported"))
+	return ""
+}
+
+// offerTitle builds a title for feeds without one, e.g. "2-room apartment, Abaya 10"
+func offerTitle(adType, rooms, address string) string {
+	title := adType
+	switch {
+	case adType == "room":
+	case rooms == "studio":
+		title = "studio " + adType
+	case rooms != "":
+		title = rooms + "-room " + adType
+	}
+	if address = strings.TrimSpace(address); address != "" {
+		title += ", " + address
+	}
+	if title == "" {
+		return ""
+	}
+
+	first, size := utf8.DecodeRuneInString(title)
+	return string(unicode.ToUpper(first)) + title[size:]
+}
+
+func isTrue(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "1", "true", "да", "yes", "+":
+		return true
+	}
+	return false
+}
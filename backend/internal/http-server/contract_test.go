@@ -0,0 +1,336 @@
+package httpserver_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"mime/multipart"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	httpserver "rentor/internal/http-server"
+	"rentor/internal/models"
+)
+
+// TestAPIContract drives every operation of the API and checks the requests and responses against api/swagger.yaml
+func TestAPIContract(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	owner := s.login(t, "owner@example.com")
+	guest := s.login(t, "guest@example.com")
+	admin := s.login(t, "admin@example.com")
+	adminUser, err := s.store.UserService.GetUserByEmail(ctx, "admin@example.com")
+	if err != nil {
+		t.Fatalf("get admin: %v", err)
+	}
+	if err := s.store.User.UpdateUserRole(ctx, adminUser.UserID, models.RoleAdmin); err != nil {
+		t.Fatalf("make admin: %v", err)
+	}
+
+	expectStatus(t, s.do(t, request(t, http.MethodGet, "/openapi.yaml", "", nil)), http.StatusOK)
+	expectStatus(t, s.do(t, request(t, http.MethodGet, "/amenities", "", nil)), http.StatusOK)
+
+	// sign in with a code sent by email, refresh the tokens and sign out
+	newcomer, refreshToken := s.signIn(t, "newcomer@example.com")
+	refresh := request(t, http.MethodPost, "/auth/refresh", "", nil)
+	refresh.AddCookie(&http.Cookie{Name: "refresh_token", Value: refreshToken})
+	expectStatus(t, s.do(t, refresh), http.StatusOK)
+	expectStatus(t, s.do(t, request(t, http.MethodPost, "/auth/logout", newcomer, nil)), http.StatusOK)
+
+	// account deletion, cancelled by signing in again
+	expectStatus(t, s.do(t, request(t, http.MethodPost, "/user/account/deletion-code", newcomer, nil)), http.StatusOK)
+	expectStatus(t, s.do(t, request(t, http.MethodDelete, "/user/account", newcomer, map[string]any{
+		"otp_code": s.mail.code(t, "newcomer@example.com"),
+	})), http.StatusAccepted)
+	newcomer, _ = s.signIn(t, "newcomer@example.com")
+	expectStatus(t, s.do(t, request(t, http.MethodGet, "/user/profile", newcomer, nil)), http.StatusOK)
+
+	// profile
+	expectStatus(t, s.do(t, request(t, http.MethodGet, "/user/profile", owner, nil)), http.StatusOK)
+	expectStatus(t, s.do(t, request(t, http.MethodPut, "/user/profile", owner, map[string]any{
+		"first_name": "Ivan",
+		"surname":    "Petrov",
+		"patronymic": "Sergeevich",
+	})), http.StatusOK)
+	expectStatus(t, s.do(t, request(t, http.MethodPatch, "/user/profile", owner, map[string]any{
+		"first_name": "Ivan",
+		"patronymic": nil,
+	})), http.StatusOK)
+
+	// email and phone changes confirmed with the codes sent to the new addresses
+	expectStatus(t, s.do(t, request(t, http.MethodPost, "/user/email", owner, map[string]any{"email": "ivan@example.com"})), http.StatusOK)
+	expectStatus(t, s.do(t, request(t, http.MethodPost, "/user/email/confirm", owner, map[string]any{
+		"email":    "ivan@example.com",
+		"otp_code": s.mail.code(t, "ivan@example.com"),
+	})), http.StatusOK)
+	expectStatus(t, s.do(t, request(t, http.MethodPost, "/user/phone", owner, map[string]any{"phone_number": "+77011234567"})), http.StatusOK)
+	expectStatus(t, s.do(t, request(t, http.MethodPost, "/user/phone/confirm", owner, map[string]any{
+		"phone_number": "+77011234567",
+		"otp_code":     s.mail.code(t, "+77011234567"),
+	})), http.StatusOK)
+
+	// advertisements
+	listing := map[string]any{
+		"title":             "Flat in the centre",
+		"description":       "Quiet street, five minutes to the metro",
+		"price":             250000,
+		"currency":          "KZT",
+		"pricePeriod":       "month",
+		"type":              "apartment",
+		"rooms":             "2",
+		"city":              "Almaty",
+		"address":           "Abay 10",
+		"latitude":          43.2389,
+		"longitude":         76.8897,
+		"square":            54.5,
+		"floor":             3,
+		"floorsTotal":       9,
+		"furnishing":        "full",
+		"petsAllowed":       true,
+		"utilitiesIncluded": false,
+		"deposit":           100000,
+		"amenities":         []string{"elevator", "parking"},
+	}
+	w := s.do(t, request(t, http.MethodPost, "/advertisements", owner, listing))
+	expectStatus(t, w, http.StatusOK)
+	ad := decode[struct {
+		ID int `json:"id"`
+	}](t, w)
+	adPath := fmt.Sprintf("/advertisements/%d", ad.ID)
+
+	expectStatus(t, s.do(t, request(t, http.MethodGet, adPath, "", nil)), http.StatusOK)
+	expectStatus(t, s.do(t, request(t, http.MethodGet, adPath, guest, nil)), http.StatusOK)
+	expectStatus(t, s.do(t, request(t, http.MethodGet, "/advertisements?city=Almaty&amenities=elevator&petsAllowed=true&minFloor=2", "", nil)), http.StatusOK)
+	expectStatus(t, s.do(t, request(t, http.MethodGet, "/advertisements/my", owner, nil)), http.StatusOK)
+	update := maps.Clone(listing)
+	update["price"] = 240000
+	update["status"] = "active"
+	expectStatus(t, s.do(t, request(t, http.MethodPut, adPath, owner, update)), http.StatusOK)
+	expectStatus(t, s.do(t, request(t, http.MethodPatch, adPath, owner, map[string]any{
+		"price":       230000,
+		"petsAllowed": nil,
+		"amenities":   []string{"elevator"},
+	})), http.StatusOK)
+	expectStatus(t, s.do(t, request(t, http.MethodPost, adPath+"/contact", "", nil)), http.StatusOK)
+	expectStatus(t, s.do(t, request(t, http.MethodGet, adPath+"/history", owner, nil)), http.StatusOK)
+	expectStatus(t, s.do(t, request(t, http.MethodGet, adPath+"/stats", owner, nil)), http.StatusOK)
+	expectStatus(t, s.do(t, request(t, http.MethodGet, adPath+"/similar", "", nil)), http.StatusOK)
+	expectStatus(t, s.do(t, request(t, http.MethodGet, "/user/recently-viewed", guest, nil)), http.StatusOK)
+	if err := s.store.AnalyticsService.RefreshCityStats(ctx); err != nil {
+		t.Fatalf("refresh city stats: %v", err)
+	}
+	expectStatus(t, s.do(t, request(t, http.MethodGet, "/analytics/cities/Almaty", "", nil)), http.StatusOK)
+
+	// favorites
+	expectStatus(t, s.do(t, request(t, http.MethodPost, adPath+"/favorite", guest, nil)), http.StatusOK)
+	expectStatus(t, s.do(t, request(t, http.MethodGet, "/advertisements/favorites", guest, nil)), http.StatusOK)
+	expectStatus(t, s.do(t, request(t, http.MethodDelete, adPath+"/favorite", guest, nil)), http.StatusOK)
+
+	// direct uploads
+	photo := pngImage(t)
+	w = s.do(t, request(t, http.MethodPost, adPath+"/images/uploads", owner, map[string]any{
+		"files": []map[string]any{{"contentType": "image/png", "size": len(photo)}},
+	}))
+	expectStatus(t, w, http.StatusCreated)
+	session := decode[struct {
+		CommitURL string `json:"commitUrl"`
+		Uploads   []struct {
+			UploadURL string `json:"uploadUrl"`
+		} `json:"uploads"`
+	}](t, w)
+	upload := request(t, http.MethodPut, strings.TrimPrefix(session.Uploads[0].UploadURL, httpserver.APIPrefix), "", string(photo))
+	upload.Header.Set("Content-Type", "image/png")
+	expectStatus(t, s.do(t, upload), http.StatusNoContent)
+	commitPath := strings.TrimPrefix(session.CommitURL, httpserver.APIPrefix)
+	expectStatus(t, s.do(t, request(t, http.MethodPost, commitPath, owner, nil)), http.StatusOK)
+	expectStatus(t, s.do(t, request(t, http.MethodPost, commitPath, owner, nil)), http.StatusGone)
+
+	// photos uploaded as a form, reordered, captioned and removed
+	expectStatus(t, s.do(t, formRequest(t, adPath+"/images", owner, nil, formFile{"images", "second.png", otherPNGImage(t)})), http.StatusOK)
+	w = s.do(t, request(t, http.MethodGet, adPath, owner, nil))
+	expectStatus(t, w, http.StatusOK)
+	images := decode[struct {
+		ImageURLs []struct {
+			ImageID int `json:"imageId"`
+		} `json:"imageUrls"`
+	}](t, w).ImageURLs
+	if len(images) != 2 {
+		t.Fatalf("advertisement has %d photos, want 2", len(images))
+	}
+	first, second := images[0].ImageID, images[1].ImageID
+	expectStatus(t, s.do(t, request(t, http.MethodPut, adPath+"/images/order", owner, map[string]any{"imageIds": []int{second, first}})), http.StatusOK)
+	expectStatus(t, s.do(t, request(t, http.MethodPut, fmt.Sprintf("%s/images/%d/cover", adPath, second), owner, nil)), http.StatusOK)
+	// photos are updated with a plain JSON body, not a merge patch
+	caption := request(t, http.MethodPatch, fmt.Sprintf("%s/images/%d", adPath, second), owner, map[string]any{"caption": "Kitchen"})
+	caption.Header.Set("Content-Type", "application/json")
+	expectStatus(t, s.do(t, caption), http.StatusOK)
+	expectStatus(t, s.do(t, request(t, http.MethodDelete, fmt.Sprintf("%s/images/%d", adPath, second), owner, nil)), http.StatusOK)
+
+	// imports: small files are imported at once, files with photos by a background job
+	w = s.do(t, formRequest(t, "/advertisements/imports", owner, map[string]string{"dryRun": "true"},
+		formFile{"file", "listings.csv", []byte("externalId,title,price,type,rooms,city,address,square\nA1,Imported flat,150000,apartment,1,Almaty,Abay 12,40\n")}))
+	expectStatus(t, w, http.StatusOK)
+	w = s.do(t, formRequest(t, "/advertisements/imports", owner, nil,
+		formFile{"file", "listings.json", []byte(`[{"externalId":"A1","title":"Imported flat","price":150000,"type":"apartment","rooms":"1","city":"Almaty","address":"Abay 12","square":40,"photos":["https://example.com/1.jpg"]}]`)}))
+	expectStatus(t, w, http.StatusAccepted)
+	expectStatus(t, s.do(t, request(t, http.MethodGet, strings.TrimPrefix(w.Header().Get("Location"), httpserver.APIPrefix), owner, nil)), http.StatusOK)
+
+	// exports
+	for _, format := range []string{"csv", "json", "xml"} {
+		expectStatus(t, s.do(t, request(t, http.MethodGet, "/advertisements/my/export?format="+format, owner, nil)), http.StatusOK)
+	}
+	w = s.do(t, request(t, http.MethodGet, "/user/profile", owner, nil))
+	profile := decode[struct {
+		UserID int `json:"user_id"`
+	}](t, w)
+	expectStatus(t, s.do(t, request(t, http.MethodGet, fmt.Sprintf("/landlords/%d/feed.xml", profile.UserID), "", nil)), http.StatusOK)
+
+	w = s.do(t, request(t, http.MethodPost, "/user/exports", owner, nil))
+	expectStatus(t, w, http.StatusAccepted)
+	archivePath := strings.TrimPrefix(w.Header().Get("Location"), httpserver.APIPrefix)
+	if err := s.store.ExportService.ProcessQueuedArchives(ctx); err != nil {
+		t.Fatalf("process archives: %v", err)
+	}
+	expectStatus(t, s.do(t, request(t, http.MethodGet, archivePath, owner, nil)), http.StatusOK)
+	expectStatus(t, s.do(t, request(t, http.MethodGet, archivePath+"/download", owner, nil)), http.StatusOK)
+
+	// moderation: the guest lists a flat with the photo of the owner
+	w = s.do(t, request(t, http.MethodPost, "/advertisements", guest, listing))
+	expectStatus(t, w, http.StatusOK)
+	copyPath := fmt.Sprintf("/advertisements/%d", decode[struct {
+		ID int `json:"id"`
+	}](t, w).ID)
+	expectStatus(t, s.do(t, formRequest(t, copyPath+"/images", guest, nil, formFile{"images", "copy.png", photo})), http.StatusOK)
+	expectStatus(t, s.do(t, request(t, http.MethodGet, "/moderation/queue", guest, nil)), http.StatusForbidden)
+	w = s.do(t, request(t, http.MethodGet, "/moderation/queue", admin, nil))
+	expectStatus(t, w, http.StatusOK)
+	queue := decode[struct {
+		Items []struct {
+			ID int `json:"id"`
+		} `json:"items"`
+	}](t, w)
+	if len(queue.Items) == 0 {
+		t.Fatal("the copied photo was not flagged")
+	}
+	expectStatus(t, s.do(t, request(t, http.MethodPost, fmt.Sprintf("/moderation/queue/%d/resolve", queue.Items[0].ID), admin, map[string]any{
+		"status": "confirmed",
+	})), http.StatusOK)
+	expectStatus(t, s.do(t, request(t, http.MethodGet, "/moderation"+copyPath+"/duplicates", admin, nil)), http.StatusOK)
+
+	// deletion and undo, by the owner and by an administrator
+	expectStatus(t, s.do(t, request(t, http.MethodDelete, adPath, guest, nil)), http.StatusForbidden)
+	expectStatus(t, s.do(t, request(t, http.MethodDelete, adPath, owner, nil)), http.StatusOK)
+	expectStatus(t, s.do(t, request(t, http.MethodGet, adPath, "", nil)), http.StatusNotFound)
+	expectStatus(t, s.do(t, request(t, http.MethodPost, adPath+"/restore", owner, nil)), http.StatusOK)
+	expectStatus(t, s.do(t, request(t, http.MethodDelete, adPath, owner, nil)), http.StatusOK)
+	expectStatus(t, s.do(t, request(t, http.MethodPost, "/admin"+adPath+"/restore", admin, nil)), http.StatusOK)
+
+	guestUser, err := s.store.UserService.GetUserByEmail(ctx, "guest@example.com")
+	if err != nil {
+		t.Fatalf("get guest: %v", err)
+	}
+	guestPath := fmt.Sprintf("/admin/users/%d", guestUser.UserID)
+	expectStatus(t, s.do(t, request(t, http.MethodDelete, guestPath, admin, nil)), http.StatusOK)
+	expectStatus(t, s.do(t, request(t, http.MethodPost, guestPath+"/restore", admin, nil)), http.StatusOK)
+
+	s.expectAllRoutesServed(t)
+}
+
+// TestAPIContractErrors checks that error responses are problem documents the contract describes
+func TestAPIContractErrors(t *testing.T) {
+	s := newTestServer(t)
+	owner := s.login(t, "owner@example.com")
+
+	expectStatus(t, s.do(t, request(t, http.MethodGet, "/advertisements/999", "", nil)), http.StatusNotFound)
+	expectStatus(t, s.do(t, request(t, http.MethodGet, "/user/profile", "", nil)), http.StatusUnauthorized)
+	expectStatus(t, s.do(t, request(t, http.MethodGet, "/advertisements?amenities=pool", "", nil)), http.StatusBadRequest)
+
+	// every invalid field is reported in the problem
+	w := s.doInvalid(t, request(t, http.MethodPost, "/advertisements", owner, map[string]any{
+		"title": "No price",
+		"type":  "castle",
+		"rooms": "2",
+		"city":  "",
+	}))
+	expectStatus(t, w, http.StatusBadRequest)
+	if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("Content-Type = %q, want application/problem+json", ct)
+	}
+	p := decode[struct {
+		Status int    `json:"status"`
+		Code   string `json:"code"`
+		Errors []struct {
+			Field string `json:"field"`
+			Code  string `json:"code"`
+		} `json:"errors"`
+	}](t, w)
+	var fields []string
+	for _, fe := range p.Errors {
+		fields = append(fields, fe.Field+":"+fe.Code)
+	}
+	if want := []string{"type:one_of", "city:required", "address:required"}; p.Status != http.StatusBadRequest ||
+		p.Code != "validation_failed" || !slices.Equal(fields, want) {
+		t.Errorf("problem %+v, want validation_failed with the fields %q", p, want)
+	}
+
+	expectStatus(t, s.doInvalid(t, request(t, http.MethodPost, "/advertisements", owner, "{not json")), http.StatusBadRequest)
+}
+
+// formFile is a file of a multipart form
+type formFile struct {
+	field, name string
+	content     []byte
+}
+
+// formRequest builds a multipart/form-data POST request to the API with fields and files
+func formRequest(t *testing.T, path, token string, fields map[string]string, files ...formFile) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for name, value := range fields {
+		if err := mw.WriteField(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range files {
+		part, err := mw.CreateFormFile(f.field, f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := part.Write(f.content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := request(t, http.MethodPost, path, token, nil)
+	r.Body = io.NopCloser(&body)
+	r.ContentLength = int64(body.Len())
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+// signIn signs in with the code sent to email and returns the access and refresh tokens
+func (s *testServer) signIn(t *testing.T, email string) (access, refresh string) {
+	t.Helper()
+	expectStatus(t, s.do(t, request(t, http.MethodPost, "/auth/send-otp", "", map[string]any{"email": email})), http.StatusOK)
+	w := s.do(t, request(t, http.MethodPost, "/auth/verify-otp", "", map[string]any{
+		"email":    email,
+		"otp_code": s.mail.code(t, email),
+	}))
+	expectStatus(t, w, http.StatusOK)
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "refresh_token" {
+			refresh = c.Value
+		}
+	}
+	return decode[struct {
+		AccessToken string `json:"access_token"`
+	}](t, w).AccessToken, refresh
+}
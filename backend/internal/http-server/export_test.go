@@ -0,0 +1,241 @@
+package httpserver_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"rentor/internal/config"
+	httpserver "rentor/internal/http-server"
+	"rentor/internal/models"
+	"rentor/internal/repository"
+)
+
+// seedAdvertisements creates n advertisements of userID with long descriptions
+func (s *testServer) seedAdvertisements(t *testing.T, userID, n int) {
+	t.Helper()
+	description := strings.Repeat("Bright flat with a view of the mountains. ", 25)
+	db := repository.NewDB(s.db.DB, s.db.Read, repository.Dialect(s.cfg.StorageDriver))
+	err := repository.NewTxManager(db).WithinTx(context.Background(), func(repos *repository.Repositories) error {
+		for i := range n {
+			_, err := repos.Advertisement.CreateAdvertisement(context.Background(), userID, &models.CreateAdvertisementInput{
+				Title:       fmt.Sprintf("Seeded flat %d", i),
+				Description: &description,
+				Price:       20000000,
+				Currency:    "KZT",
+				PricePeriod: "month",
+				Type:        "apartment",
+				Rooms:       "2",
+				City:        "Almaty",
+				Address:     fmt.Sprintf("Abay %d", i),
+				Square:      50,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("seed advertisements: %v", err)
+	}
+}
+
+// writeSizes records the writes that reach the connection of the client
+type writeSizes struct {
+	http.ResponseWriter
+	count, max int
+}
+
+func (w *writeSizes) Write(b []byte) (int, error) {
+	w.count++
+	w.max = max(w.max, len(b))
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *writeSizes) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// TestExportStreamsLargeResponses reads large exports from a running server chunk by chunk and checks that
+// no layer between the export writer and the connection (the contract middleware included) holds the whole body
+func TestExportStreamsLargeResponses(t *testing.T) {
+	const listings = 2000
+
+	s := newTestServer(t)
+	token := s.login(t, "owner@example.com")
+	owner, err := s.store.UserService.GetUserByEmail(context.Background(), "owner@example.com")
+	if err != nil {
+		t.Fatalf("get user: %v", err)
+	}
+	s.seedAdvertisements(t, owner.UserID, listings)
+
+	served := make(chan *writeSizes, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sizes := &writeSizes{ResponseWriter: w}
+		s.router.ServeHTTP(sizes, r)
+		served <- sizes
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name, path string
+	}{
+		{"csv", "/advertisements/my/export?format=csv"},
+		{"json", "/advertisements/my/export?format=json"},
+		{"xml", "/advertisements/my/export?format=xml"},
+		{"feed", fmt.Sprintf("/landlords/%d/feed.xml", owner.UserID)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, srv.URL+httpserver.APIPrefix+tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.AddCookie(&http.Cookie{Name: "access_token", Value: token})
+
+			resp, err := srv.Client().Do(req)
+			if err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d", resp.StatusCode)
+			}
+
+			var body bytes.Buffer
+			chunk := make([]byte, 16<<10)
+			reads := 0
+			for {
+				n, err := resp.Body.Read(chunk)
+				if n > 0 {
+					reads++
+					body.Write(chunk[:n])
+				}
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("read after %d bytes: %v", body.Len(), err)
+				}
+			}
+
+			if n := strings.Count(body.String(), "Seeded flat"); n != listings {
+				t.Errorf("export has %d listings, want %d", n, listings)
+			}
+			if body.Len() < 1<<20 {
+				t.Errorf("export is %d bytes, the test needs more than 1 MiB", body.Len())
+			}
+			if reads < 2 {
+				t.Errorf("export was read in %d chunks", reads)
+			}
+			sizes := <-served
+			// every listing is written on its own, a layer that buffers the response writes it at once
+			if sizes.count < listings || sizes.max > 64<<10 {
+				t.Errorf("export reached the connection in %d writes of up to %d bytes, want a write per listing",
+					sizes.count, sizes.max)
+			}
+		})
+	}
+}
+
+// socketBuffer is the size of the socket buffers of TestExportOutlivesServerTimeouts. Loopback connections
+// buffer megabytes, a response that fits would be sent before any deadline however slow the client is.
+const socketBuffer = 32 << 10
+
+// smallBuffers accepts connections with socketBuffer sized send buffers
+type smallBuffers struct {
+	net.Listener
+}
+
+func (l smallBuffers) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return conn, conn.(*net.TCPConn).SetWriteBuffer(socketBuffer)
+}
+
+// TestExportOutlivesServerTimeouts serves the API with the timeouts of the real server set far below the time
+// a slow client needs to read a large export: the export route lifts them, so the whole export arrives
+func TestExportOutlivesServerTimeouts(t *testing.T) {
+	const (
+		listings = 2000
+		timeout  = 200 * time.Millisecond
+	)
+
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.HTTPServer.TimeoutSeconds = timeout
+	})
+	token := s.login(t, "owner@example.com")
+	owner, err := s.store.UserService.GetUserByEmail(context.Background(), "owner@example.com")
+	if err != nil {
+		t.Fatalf("get user: %v", err)
+	}
+	s.seedAdvertisements(t, owner.UserID, listings)
+
+	srv := httptest.NewUnstartedServer(s.router)
+	srv.Config = httpserver.NewServer(s.cfg, s.router)
+	srv.Listener = smallBuffers{srv.Listener}
+	srv.Start()
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+httpserver.APIPrefix+"/advertisements/my/export?format=csv", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.AddCookie(&http.Cookie{Name: "access_token", Value: token})
+
+	// the client receives through a small buffer as well
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := (&net.Dialer{}).DialContext(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			return conn, conn.(*net.TCPConn).SetReadBuffer(socketBuffer)
+		},
+	}}
+	defer client.CloseIdleConnections()
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+
+	// the client reads slowly, the server blocks on the connection until the write deadline passes
+	var body bytes.Buffer
+	chunk := make([]byte, 16<<10)
+	for {
+		n, err := resp.Body.Read(chunk)
+		body.Write(chunk[:n])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read after %d bytes and %s: %v", body.Len(), time.Since(start), err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if elapsed := time.Since(start); elapsed < 2*timeout {
+		t.Fatalf("the export was read in %s, the test needs longer than the server timeout of %s", elapsed, timeout)
+	}
+	if n := strings.Count(body.String(), "Seeded flat"); n != listings {
+		t.Errorf("export has %d of %d listings after %s", n, listings, time.Since(start))
+	}
+}
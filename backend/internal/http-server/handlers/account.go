@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"rentor/internal/apperr"
+	"rentor/internal/http-server/middleware"
+	"rentor/internal/logger"
+	"rentor/internal/models"
+	"rentor/internal/service"
+)
+
+// AccountHandler handles deleting accounts at their owners' request
+type AccountHandler struct {
+	userService    service.UserService
+	otpService     service.OTPService
+	accountService service.AccountService
+}
+
+// NewAccountHandler creates a new instance of AccountHandler
+func NewAccountHandler(userSvc service.UserService, otpSvc service.OTPService, accountSvc service.AccountService) *AccountHandler {
+	return &AccountHandler{
+		userService:    userSvc,
+		otpService:     otpSvc,
+		accountService: accountSvc,
+	}
+}
+
+// ===========================
+// POST /user/account/deletion-code
+// ===========================
+func (h *AccountHandler) SendDeletionCode(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.otpService.GenerateAndStoreOTP(r.Context(), user.UserID, models.OTPPurposeAccountDeletion, user.Email)
+	if err != nil {
+		logger.Error("failed to send account deletion code", logger.Field("error", err.Error()), logger.Field("user_id", user.UserID))
+		writeProblem(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"message": "OTP sent to email"})
+}
+
+// ===========================
+// DELETE /user/account
+// ===========================
+func (h *AccountHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	var req models.DeleteAccountInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+	if !validInput(w, r, &req) {
+		return
+	}
+
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	// the session alone is not enough, the owner confirms with a fresh code from the email
+	codeUserID, err := h.otpService.VerifyOTP(r.Context(), models.OTPPurposeAccountDeletion, user.Email, req.OtpCode)
+	if err != nil {
+		logger.Warn("account deletion not confirmed", logger.Field("error", err.Error()), logger.Field("user_id", user.UserID))
+		writeProblem(w, r, err)
+		return
+	}
+	if codeUserID != user.UserID {
+		writeProblem(w, r, apperr.Unauthorized("invalid_otp", "invalid OTP"))
+		return
+	}
+
+	deletion, err := h.accountService.DeleteAccount(r.Context(), user.UserID)
+	if err != nil {
+		logger.Error("failed to delete account", logger.Field("error", err.Error()), logger.Field("user_id", user.UserID))
+		writeProblem(w, r, err)
+		return
+	}
+
+	clearAuthCookies(w)
+	writeJSON(w, http.StatusAccepted, deletion)
+}
+
+// currentUser loads the signed-in user, codes are sent to their email
+func (h *AccountHandler) currentUser(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "user not authenticated")
+		return nil, false
+	}
+
+	user, err := h.userService.GetUser(r.Context(), userID)
+	if err != nil {
+		logger.Error("failed to get user", logger.Field("error", err.Error()), logger.Field("user_id", userID))
+		writeProblem(w, r, err)
+		return nil, false
+	}
+	if user.Email == "" {
+		writeProblem(w, r, apperr.Conflict("email_required", "the account has no email to send the code to"))
+		return nil, false
+	}
+
+	return user, true
+}
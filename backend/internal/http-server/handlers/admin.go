@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"rentor/internal/logger"
+	"rentor/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// AdminHandler handles account and listing administration endpoints (admin only)
+type AdminHandler struct {
+	adService   service.AdvertisementService
+	userService service.UserService
+}
+
+// NewAdminHandler creates a new instance of AdminHandler
+func NewAdminHandler(adService service.AdvertisementService, userService service.UserService) *AdminHandler {
+	return &AdminHandler{
+		adService:   adService,
+		userService: userService,
+	}
+}
+
+// ===========================
+// POST /admin/advertisements/{id}/restore
+// ===========================
+func (h *AdminHandler) RestoreAdvertisement(w http.ResponseWriter, r *http.Request) {
+	adID, _ := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err := h.adService.AdminRestoreAdvertisement(r.Context(), adID); err != nil {
+		logger.Error("admin restore ad failed", logger.Field("error", err.Error()), logger.Field("advertisement_id", adID))
+		writeProblem(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "restored"})
+}
+
+// ===========================
+// DELETE /admin/users/{id}
+// ===========================
+func (h *AdminHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	userID, _ := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err := h.userService.DeleteUser(r.Context(), userID); err != nil {
+		logger.Error("admin delete user failed", logger.Field("error", err.Error()), logger.Field("user_id", userID))
+		writeProblem(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// ===========================
+// POST /admin/users/{id}/restore
+// ===========================
+func (h *AdminHandler) RestoreUser(w http.ResponseWriter, r *http.Request) {
+	userID, _ := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err := h.userService.RestoreUser(r.Context(), userID); err != nil {
+		logger.Error("admin restore user failed", logger.Field("error", err.Error()), logger.Field("user_id", userID))
+		writeProblem(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "restored"})
+}
@@ -4,24 +4,32 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"rentor/internal/http-server/middleware"
 	"rentor/internal/logger"
 	"rentor/internal/models"
+	"rentor/internal/money"
 	"rentor/internal/service"
 
 	"github.com/go-chi/chi/v5"
 )
 
 type AdvertisementHandlers struct {
-	adService service.AdvertisementService
-	imageSvc  service.ImageService
+	adService   service.AdvertisementService
+	imageSvc    service.ImageService
+	userService service.UserService
+	statsSvc    service.StatsService
+	recSvc      service.RecommendationService
 }
 
-func NewAdvertisementHandlers(adService service.AdvertisementService, imageSvc service.ImageService) *AdvertisementHandlers {
+func NewAdvertisementHandlers(adService service.AdvertisementService, imageSvc service.ImageService, userService service.UserService, statsSvc service.StatsService, recSvc service.RecommendationService) *AdvertisementHandlers {
 	return &AdvertisementHandlers{
-		adService: adService,
-		imageSvc:  imageSvc,
+		adService:   adService,
+		imageSvc:    imageSvc,
+		userService: userService,
+		statsSvc:    statsSvc,
+		recSvc:      recSvc,
 	}
 }
 
@@ -32,21 +40,24 @@ func (h *AdvertisementHandlers) CreateAdvertisement(w http.ResponseWriter, r *ht
 	userID, err := middleware.GetUserIDFromContext(r)
 	if err != nil {
 		logger.Error("create ad failed", logger.Field("error", err.Error()))
-		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		writeError(w, http.StatusUnauthorized, "unauthorized")
 		return
 	}
 
 	var input models.CreateAdvertisementInput
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
 		logger.Error("create ad failed", logger.Field("error", err.Error()))
-		http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if !validInput(w, r, &input) {
 		return
 	}
 
-	res, err := h.adService.CreateAdvertisement(userID, &input)
+	res, err := h.adService.CreateAdvertisement(r.Context(), userID, &input)
 	if err != nil {
 		logger.Error("create ad failed", logger.Field("error", err.Error()))
-		http.Error(w, `{"error":"cannot create advertisement"}`, http.StatusInternalServerError)
+		writeProblem(w, r, err)
 		return
 	}
 
@@ -60,13 +71,22 @@ func (h *AdvertisementHandlers) GetAdvertisement(w http.ResponseWriter, r *http.
 	idStr := chi.URLParam(r, "id")
 	id, _ := strconv.Atoi(idStr)
 
-	ad, err := h.adService.GetAdvertisement(id)
+	ad, err := h.adService.GetAdvertisement(r.Context(), id)
 	if err != nil {
 		logger.Error("get ad failed", logger.Field("error", err.Error()))
-		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		writeProblem(w, r, err)
 		return
 	}
 
+	h.statsSvc.RecordView(ad.ID)
+	// the recently viewed list is best effort, the advertisement is returned anyway
+	if viewer := viewerKey(w, r, true); viewer != "" {
+		if err := h.recSvc.RecordView(r.Context(), viewer, ad.ID); err != nil {
+			logger.Warn("record recently viewed failed", logger.Field("error", err.Error()), logger.Field("advertisement_id", ad.ID))
+		}
+	}
+
+	setETag(w, ad.Version)
 	writeJSON(w, http.StatusOK, ad)
 }
 
@@ -82,13 +102,19 @@ func (h *AdvertisementHandlers) ListAdvertisements(w http.ResponseWriter, r *htt
 	}
 
 	if v := q.Get("minPrice"); v != "" {
-		val := parseFloatPointer(v)
+		val := parseAmountPointer(v)
 		filters.MinPrice = val
 	}
 	if v := q.Get("maxPrice"); v != "" {
-		val := parseFloatPointer(v)
+		val := parseAmountPointer(v)
 		filters.MaxPrice = val
 	}
+	if v := q.Get("currency"); v != "" {
+		filters.Currency = &v
+	}
+	if v := q.Get("pricePeriod"); v != "" {
+		filters.PricePeriod = &v
+	}
 	if v := q.Get("type"); v != "" {
 		filters.Type = &v
 	}
@@ -101,17 +127,43 @@ func (h *AdvertisementHandlers) ListAdvertisements(w http.ResponseWriter, r *htt
 	if v := q.Get("keywords"); v != "" {
 		filters.Keywords = &v
 	}
+	if v := q.Get("amenities"); v != "" {
+		filters.Amenities = splitList(v)
+	}
+	filters.PetsAllowed = parseBoolPointer(q.Get("petsAllowed"))
+	filters.UtilitiesIncluded = parseBoolPointer(q.Get("utilitiesIncluded"))
+	if v := q.Get("furnishing"); v != "" {
+		filters.Furnishing = &v
+	}
+	filters.MinFloor = parseIntPointer(q.Get("minFloor"))
+	filters.MaxFloor = parseIntPointer(q.Get("maxFloor"))
 
-	list, err := h.adService.GetAdvertisementsPaged(filters)
+	list, err := h.adService.GetAdvertisementsPaged(r.Context(), filters)
 	if err != nil {
 		logger.Error("list ads failed", logger.Field("error", err.Error()))
-		http.Error(w, `{"error":"failed to fetch advertisements"}`, http.StatusInternalServerError)
+		writeProblem(w, r, err)
 		return
 	}
 
+	h.statsSvc.RecordImpressions(previewIDs(list.Items))
+
 	writeJSON(w, http.StatusOK, list)
 }
 
+// ===========================
+// GET /amenities
+// ===========================
+func (h *AdvertisementHandlers) GetAmenities(w http.ResponseWriter, r *http.Request) {
+	catalog, err := h.adService.GetAmenities(r.Context())
+	if err != nil {
+		logger.Error("get amenities failed", logger.Field("error", err.Error()))
+		writeProblem(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, catalog)
+}
+
 // ===========================
 // /advertisements/my
 // ===========================
@@ -119,7 +171,7 @@ func (h *AdvertisementHandlers) GetMyAdvertisements(w http.ResponseWriter, r *ht
 	userID, err := middleware.GetUserIDFromContext(r)
 	if err != nil {
 		logger.Error("get my ads failed", logger.Field("error", err.Error()))
-		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		writeError(w, http.StatusUnauthorized, "unauthorized")
 		return
 	}
 
@@ -127,16 +179,165 @@ func (h *AdvertisementHandlers) GetMyAdvertisements(w http.ResponseWriter, r *ht
 	page := parseIntDefault(q.Get("page"), 1)
 	limit := parseIntDefault(q.Get("limit"), 20)
 
-	list, err := h.adService.GetMyAdvertisements(userID, page, limit)
+	list, err := h.adService.GetMyAdvertisements(r.Context(), userID, page, limit)
 	if err != nil {
 		logger.Error("get my ads failed", logger.Field("error", err.Error()))
-		http.Error(w, `{"error":"failed to fetch my ads"}`, http.StatusInternalServerError)
+		writeProblem(w, r, err)
+		return
+	}
+
+	// the dashboard is an addition to the list, the list is returned even if the stats can't be read
+	if err := h.attachDashboard(r, userID, list); err != nil {
+		logger.Error("get landlord dashboard failed", logger.Field("error", err.Error()), logger.Field("user_id", userID))
+	}
+
+	writeJSON(w, http.StatusOK, list)
+}
+
+// attachDashboard adds the landlord summary and the counters of each listing on the page to list
+func (h *AdvertisementHandlers) attachDashboard(r *http.Request, userID int, list *models.GetAdPreviewsList) error {
+	summary, err := h.statsSvc.GetDashboard(r.Context(), userID)
+	if err != nil {
+		return err
+	}
+
+	counters, err := h.statsSvc.GetDashboardCounters(r.Context(), previewIDs(list.Items))
+	if err != nil {
+		return err
+	}
+
+	list.Summary = summary
+	for i := range list.Items {
+		list.Items[i].Stats = counters[list.Items[i].ID]
+	}
+	return nil
+}
+
+// ===========================
+// GET /advertisements/{id}/stats?days=30
+// ===========================
+func (h *AdvertisementHandlers) GetAdvertisementStats(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		logger.Error("get ad stats failed", logger.Field("error", err.Error()))
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	adID, _ := strconv.Atoi(chi.URLParam(r, "id"))
+
+	days := parseIntDefault(r.URL.Query().Get("days"), 30)
+	if days < 1 || days > maxAnalyticsDays {
+		writeError(w, http.StatusBadRequest, "days must be between 1 and 365")
+		return
+	}
+
+	stats, err := h.statsSvc.GetAdvertisementStats(r.Context(), userID, adID, days)
+	if err != nil {
+		logger.Error("get ad stats failed", logger.Field("error", err.Error()), logger.Field("advertisement_id", adID))
+		writeProblem(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// ===========================
+// FAVORITES
+// ===========================
+func (h *AdvertisementHandlers) AddFavorite(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		logger.Error("add favorite failed", logger.Field("error", err.Error()))
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	adID, _ := strconv.Atoi(chi.URLParam(r, "id"))
+
+	added, err := h.adService.AddFavorite(r.Context(), userID, adID)
+	if err != nil {
+		logger.Error("add favorite failed", logger.Field("error", err.Error()), logger.Field("advertisement_id", adID))
+		writeProblem(w, r, err)
+		return
+	}
+
+	// repeated requests are not counted again
+	if added {
+		h.statsSvc.RecordFavorite(adID)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "favorited"})
+}
+
+func (h *AdvertisementHandlers) RemoveFavorite(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		logger.Error("remove favorite failed", logger.Field("error", err.Error()))
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	adID, _ := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err := h.adService.RemoveFavorite(r.Context(), userID, adID); err != nil {
+		logger.Error("remove favorite failed", logger.Field("error", err.Error()), logger.Field("advertisement_id", adID))
+		writeProblem(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "removed"})
+}
+
+func (h *AdvertisementHandlers) GetFavorites(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		logger.Error("get favorites failed", logger.Field("error", err.Error()))
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	q := r.URL.Query()
+	page := parseIntDefault(q.Get("page"), 1)
+	limit := parseIntDefault(q.Get("limit"), 20)
+
+	list, err := h.adService.GetFavorites(r.Context(), userID, page, limit)
+	if err != nil {
+		logger.Error("get favorites failed", logger.Field("error", err.Error()))
+		writeProblem(w, r, err)
 		return
 	}
 
 	writeJSON(w, http.StatusOK, list)
 }
 
+// ===========================
+// POST /advertisements/{id}/contact
+// ===========================
+func (h *AdvertisementHandlers) RevealContacts(w http.ResponseWriter, r *http.Request) {
+	adID, _ := strconv.Atoi(chi.URLParam(r, "id"))
+
+	contacts, err := h.adService.GetLandlordContacts(r.Context(), adID)
+	if err != nil {
+		logger.Error("reveal contacts failed", logger.Field("error", err.Error()), logger.Field("advertisement_id", adID))
+		writeProblem(w, r, err)
+		return
+	}
+
+	h.statsSvc.RecordContact(adID)
+
+	writeJSON(w, http.StatusOK, contacts)
+}
+
+// previewIDs returns the ids of the listed advertisements
+func previewIDs(items []models.AdPreview) []int {
+	ids := make([]int, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+	}
+	return ids
+}
+
 // ===========================
 // UPDATE
 // ===========================
@@ -144,29 +345,108 @@ func (h *AdvertisementHandlers) UpdateAdvertisement(w http.ResponseWriter, r *ht
 	userID, err := middleware.GetUserIDFromContext(r)
 	if err != nil {
 		logger.Error("update ad failed", logger.Field("error", err.Error()))
-		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		writeError(w, http.StatusUnauthorized, "unauthorized")
 		return
 	}
 
 	adID, _ := strconv.Atoi(chi.URLParam(r, "id"))
 
+	ifMatch, err := ifMatchVersion(r)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
 	var input models.UpdateAdvertisementInput
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
 		logger.Error("update ad failed", logger.Field("error", err.Error()))
-		http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if !validInput(w, r, &input) {
 		return
 	}
 
-	err = h.adService.UpdateAdvertisement(adID, userID, &input)
+	version, err := h.adService.UpdateAdvertisement(r.Context(), userID, adID, &input, ifMatch)
 	if err != nil {
 		logger.Error("update ad failed", logger.Field("error", err.Error()))
-		http.Error(w, `{"error":"cannot update advertisement"}`, http.StatusForbidden)
+		writeProblem(w, r, err)
 		return
 	}
 
+	setETag(w, version)
 	writeJSON(w, http.StatusOK, map[string]string{"status": "updated"})
 }
 
+// ===========================
+// PATCH /advertisements/{id} (JSON Merge Patch)
+// ===========================
+func (h *AdvertisementHandlers) PatchAdvertisement(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		logger.Error("patch ad failed", logger.Field("error", err.Error()))
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	adID, _ := strconv.Atoi(chi.URLParam(r, "id"))
+
+	ifMatch, err := ifMatchVersion(r)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	patch, ok := readMergePatch(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := h.adService.PatchAdvertisement(r.Context(), userID, adID, patch, ifMatch); err != nil {
+		logger.Error("patch ad failed", logger.Field("error", err.Error()))
+		writeProblem(w, r, err)
+		return
+	}
+
+	ad, err := h.adService.GetAdvertisement(r.Context(), adID)
+	if err != nil {
+		logger.Error("patch ad failed", logger.Field("error", err.Error()))
+		writeProblem(w, r, err)
+		return
+	}
+
+	setETag(w, ad.Version)
+	writeJSON(w, http.StatusOK, ad)
+}
+
+// ===========================
+// HISTORY
+// ===========================
+func (h *AdvertisementHandlers) GetAdvertisementHistory(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		logger.Error("get ad history failed", logger.Field("error", err.Error()))
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	adID, _ := strconv.Atoi(chi.URLParam(r, "id"))
+
+	isAdmin := false
+	if user, err := h.userService.GetUser(r.Context(), userID); err == nil {
+		isAdmin = user.Role == models.RoleAdmin
+	}
+
+	history, err := h.adService.GetAdvertisementHistory(r.Context(), userID, adID, isAdmin)
+	if err != nil {
+		logger.Error("get ad history failed", logger.Field("error", err.Error()), logger.Field("advertisement_id", adID))
+		writeProblem(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, history)
+}
+
 // ===========================
 // DELETE
 // ===========================
@@ -174,20 +454,49 @@ func (h *AdvertisementHandlers) DeleteAdvertisement(w http.ResponseWriter, r *ht
 	userID, err := middleware.GetUserIDFromContext(r)
 	if err != nil {
 		logger.Error("delete ad failed", logger.Field("error", err.Error()))
-		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		writeError(w, http.StatusUnauthorized, "unauthorized")
 		return
 	}
 
 	adID, _ := strconv.Atoi(chi.URLParam(r, "id"))
 
-	err = h.adService.DeleteAdvertisement(userID, adID)
+	res, err := h.adService.DeleteAdvertisement(r.Context(), userID, adID)
 	if err != nil {
 		logger.Error("delete ad failed", logger.Field("error", err.Error()))
-		http.Error(w, `{"error":"cannot delete"}`, http.StatusForbidden)
+		writeProblem(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+// ===========================
+// RESTORE (UNDO DELETE)
+// ===========================
+func (h *AdvertisementHandlers) RestoreAdvertisement(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		logger.Error("restore ad failed", logger.Field("error", err.Error()))
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	adID, _ := strconv.Atoi(chi.URLParam(r, "id"))
+
+	err = h.adService.RestoreAdvertisement(r.Context(), userID, adID)
+	if err != nil {
+		logger.Error("restore ad failed", logger.Field("error", err.Error()), logger.Field("advertisement_id", adID))
+		writeProblem(w, r, err)
+		return
+	}
+
+	ad, err := h.adService.GetAdvertisement(r.Context(), adID)
+	if err != nil {
+		writeProblem(w, r, err)
 		return
 	}
 
-	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+	writeJSON(w, http.StatusOK, ad)
 }
 
 // ===========================
@@ -197,7 +506,7 @@ func (h *AdvertisementHandlers) AddAdImages(w http.ResponseWriter, r *http.Reque
 	userID, err := middleware.GetUserIDFromContext(r)
 	if err != nil {
 		logger.Error("add images failed", logger.Field("error", err.Error()))
-		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		writeError(w, http.StatusUnauthorized, "unauthorized")
 		return
 	}
 
@@ -206,38 +515,38 @@ func (h *AdvertisementHandlers) AddAdImages(w http.ResponseWriter, r *http.Reque
 	// Parse multipart form safely
 	if err := r.ParseMultipartForm(30 << 20); err != nil {
 		logger.Error("add images failed", logger.Field("error", err.Error()))
-		http.Error(w, `{"error":"failed to parse multipart form"}`, http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "failed to parse multipart form")
 		return
 	}
 	if r.MultipartForm == nil || r.MultipartForm.File == nil {
 		logger.Error("add images failed", logger.Field("error", "no files uploaded"))
-		http.Error(w, `{"error":"no files uploaded"}`, http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "no files uploaded")
 		return
 	}
 
 	files, ok := r.MultipartForm.File["images"]
 	if !ok || len(files) == 0 {
 		logger.Error("add images failed", logger.Field("error", "no images provided"))
-		http.Error(w, `{"error":"no images provided"}`, http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, "no images provided")
 		return
 	}
 
 	// Сохраняем изображения через ImageService
-	urls, err := h.imageSvc.SaveAdvertisementImages(adID, files)
+	images, err := h.imageSvc.SaveAdvertisementImages(adID, files)
 	if err != nil {
 		logger.Error("add images failed", logger.Field("error", err.Error()))
-		http.Error(w, `{"error":"cannot save images "}`, http.StatusInternalServerError)
+		writeProblem(w, r, err)
 		return
 	}
 
 	// Передаём в AdvertisementService для сохранения URL в БД
-	resp, err := h.adService.AddImages(userID, adID, urls)
+	resp, err := h.adService.AddImages(r.Context(), userID, adID, images)
 	if err != nil {
-		for i := range urls {
-			_ = h.imageSvc.DeleteImage(urls[i])
+		for i := range images {
+			_ = h.imageSvc.DeleteImage(images[i].URL)
 		}
 		logger.Error("add images failed", logger.Field("error", err.Error()))
-		http.Error(w, `{"error":"cannot link images"}`, http.StatusForbidden)
+		writeProblem(w, r, err)
 		return
 	}
 
@@ -251,37 +560,135 @@ func (h *AdvertisementHandlers) DeleteAdImage(w http.ResponseWriter, r *http.Req
 	userID, err := middleware.GetUserIDFromContext(r)
 	if err != nil {
 		logger.Error("delete image failed", logger.Field("error", err.Error()))
-		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		writeError(w, http.StatusUnauthorized, "unauthorized")
 		return
 	}
 
 	adID, _ := strconv.Atoi(chi.URLParam(r, "ad_id"))
 	imgID, _ := strconv.Atoi(chi.URLParam(r, "image_id"))
 
-	imgPath, err := h.adService.GetImagePath(adID, imgID)
+	imgPath, err := h.adService.GetImagePath(r.Context(), adID, imgID)
 	if err != nil {
 		logger.Error("delete image failed", logger.Field("error", err.Error()))
-		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		writeProblem(w, r, err)
 		return
 	}
 
-	err = h.adService.DeleteImage(userID, adID, imgID)
+	err = h.adService.DeleteImage(r.Context(), userID, adID, imgID)
 	if err != nil {
 		logger.Error("delete image failed", logger.Field("error", err.Error()))
-		http.Error(w, `{"error":"delete failed"}`, http.StatusForbidden)
+		writeProblem(w, r, err)
 		return
 	}
 
 	err = h.imageSvc.DeleteImage(imgPath)
 	if err != nil {
 		logger.Error("delete image failed", logger.Field("error", err.Error()))
-		http.Error(w, `{"error":"delete failed"}`, http.StatusForbidden)
+		writeProblem(w, r, err)
 		return
 	}
 
 	writeJSON(w, http.StatusOK, map[string]string{"status": "image deleted"})
 }
 
+// ===========================
+// REORDER IMAGES
+// ===========================
+func (h *AdvertisementHandlers) ReorderAdImages(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		logger.Error("reorder images failed", logger.Field("error", err.Error()))
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	adID, _ := strconv.Atoi(chi.URLParam(r, "id"))
+
+	var input models.ReorderImagesInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		logger.Error("reorder images failed", logger.Field("error", err.Error()))
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if !validInput(w, r, &input) {
+		return
+	}
+
+	err = h.adService.ReorderImages(r.Context(), userID, adID, input.ImageIDs)
+	if err != nil {
+		logger.Error("reorder images failed", logger.Field("error", err.Error()))
+		writeProblem(w, r, err)
+		return
+	}
+
+	ad, err := h.adService.GetAdvertisement(r.Context(), adID)
+	if err != nil {
+		logger.Error("reorder images failed", logger.Field("error", err.Error()))
+		writeProblem(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ad.ImageUrls)
+}
+
+// ===========================
+// SET COVER IMAGE
+// ===========================
+func (h *AdvertisementHandlers) SetAdCoverImage(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		logger.Error("set cover image failed", logger.Field("error", err.Error()))
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	adID, _ := strconv.Atoi(chi.URLParam(r, "ad_id"))
+	imgID, _ := strconv.Atoi(chi.URLParam(r, "image_id"))
+
+	err = h.adService.SetCoverImage(r.Context(), userID, adID, imgID)
+	if err != nil {
+		logger.Error("set cover image failed", logger.Field("error", err.Error()))
+		writeProblem(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "cover updated"})
+}
+
+// ===========================
+// UPDATE IMAGE (CAPTION)
+// ===========================
+func (h *AdvertisementHandlers) UpdateAdImage(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		logger.Error("update image failed", logger.Field("error", err.Error()))
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	adID, _ := strconv.Atoi(chi.URLParam(r, "ad_id"))
+	imgID, _ := strconv.Atoi(chi.URLParam(r, "image_id"))
+
+	var input models.UpdateImageInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		logger.Error("update image failed", logger.Field("error", err.Error()))
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if !validInput(w, r, &input) {
+		return
+	}
+
+	err = h.adService.UpdateImageCaption(r.Context(), userID, adID, imgID, input.Caption)
+	if err != nil {
+		logger.Error("update image failed", logger.Field("error", err.Error()))
+		writeProblem(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "image updated"})
+}
+
 // ===========================
 // Helpers
 // ===========================
@@ -292,8 +699,35 @@ func parseIntDefault(s string, def int) int {
 	return def
 }
 
-func parseFloatPointer(s string) *float64 {
-	val, err := strconv.ParseFloat(s, 64)
+func parseIntPointer(s string) *int {
+	val, err := strconv.Atoi(s)
+	if err != nil {
+		return nil
+	}
+	return &val
+}
+
+func parseBoolPointer(s string) *bool {
+	val, err := strconv.ParseBool(s)
+	if err != nil {
+		return nil
+	}
+	return &val
+}
+
+// splitList splits a comma separated query value, empty items are dropped
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func parseAmountPointer(s string) *money.Amount {
+	val, err := money.Parse(s)
 	if err != nil {
 		return nil
 	}
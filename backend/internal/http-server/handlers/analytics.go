@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+
+	"rentor/internal/logger"
+	"rentor/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maxAnalyticsDays limits the history returned by the city analytics endpoint
+const maxAnalyticsDays = 365
+
+// AnalyticsHandler handles the public rent statistics endpoints
+type AnalyticsHandler struct {
+	analyticsSvc service.AnalyticsService
+}
+
+// NewAnalyticsHandler creates a new instance of AnalyticsHandler
+func NewAnalyticsHandler(analyticsSvc service.AnalyticsService) *AnalyticsHandler {
+	return &AnalyticsHandler{
+		analyticsSvc: analyticsSvc,
+	}
+}
+
+// ===========================
+// GET /analytics/cities/{city}?days=30
+// ===========================
+func (h *AnalyticsHandler) GetCityAnalytics(w http.ResponseWriter, r *http.Request) {
+	city, err := url.PathUnescape(chi.URLParam(r, "city"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid city")
+		return
+	}
+
+	days := parseIntDefault(r.URL.Query().Get("days"), 30)
+	if days < 1 || days > maxAnalyticsDays {
+		writeError(w, http.StatusBadRequest, "days must be between 1 and 365")
+		return
+	}
+
+	analytics, err := h.analyticsSvc.GetCityAnalytics(r.Context(), city, days)
+	if err != nil {
+		logger.Error("get city analytics failed", logger.Field("error", err.Error()), logger.Field("city", city))
+		writeProblem(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, analytics)
+}
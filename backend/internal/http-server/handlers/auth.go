@@ -2,11 +2,10 @@ package handlers
 
 import (
 	"encoding/json"
-	"fmt"
 	"net/http"
-	"net/mail"
 	"time"
 
+	"rentor/internal/http-server/problem"
 	"rentor/internal/logger"
 	"rentor/internal/models"
 	"rentor/internal/service"
@@ -17,21 +16,16 @@ type AuthHandler struct {
 	userService    service.UserService
 	otpService     service.OTPService
 	jwtService     service.JWTService
-	otpLength      int
-	otpExpMin      int
-	otpMaxAttempts int
+	accountService service.AccountService
 }
 
 // NewAuthHandler creates a new instance of AuthHandler
-func NewAuthHandler(userSvc service.UserService, otpSvc service.OTPService, jwtSvc service.JWTService,
-	otpLen int, otpExpMin int, otpMaxAttempts int) *AuthHandler {
+func NewAuthHandler(userSvc service.UserService, otpSvc service.OTPService, jwtSvc service.JWTService, accountSvc service.AccountService) *AuthHandler {
 	return &AuthHandler{
 		userService:    userSvc,
 		otpService:     otpSvc,
 		jwtService:     jwtSvc,
-		otpLength:      otpLen,
-		otpExpMin:      otpExpMin,
-		otpMaxAttempts: otpMaxAttempts,
+		accountService: accountSvc,
 	}
 }
 
@@ -42,30 +36,23 @@ func (h *AuthHandler) SendOTP(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "invalid request payload")
 		return
 	}
-
-	if req.Email == "" {
-		writeError(w, http.StatusBadRequest, "email is required")
-		return
-	}
-
-	if _, err := mail.ParseAddress(req.Email); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid email format")
+	if !validInput(w, r, &req) {
 		return
 	}
 
 	logger.Info("SendOTP called", logger.Field("email", req.Email))
 
-	user, err := h.userService.FindOrCreateUserByEmail(req.Email)
+	user, err := h.userService.FindOrCreateUserByEmail(r.Context(), req.Email)
 	if err != nil {
 		logger.Error("failed to find/create user", logger.Field("error", err.Error()))
-		writeError(w, http.StatusInternalServerError, "failed to process request")
+		writeProblem(w, r, err)
 		return
 	}
 
-	err = h.otpService.GenerateAndStoreOTP(user.UserID, req.Email, h.otpLength, h.otpExpMin, h.otpMaxAttempts)
+	err = h.otpService.GenerateAndStoreOTP(r.Context(), user.UserID, models.OTPPurposeLogin, req.Email)
 	if err != nil {
 		logger.Error("failed to generate OTP", logger.Field("error", err.Error()))
-		writeError(w, http.StatusInternalServerError, "failed to send OTP")
+		writeProblem(w, r, err)
 		return
 	}
 
@@ -79,25 +66,37 @@ func (h *AuthHandler) VerifyOTP(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "invalid request payload")
 		return
 	}
-
-	if req.Email == "" || req.OtpCode == "" {
-		writeError(w, http.StatusBadRequest, "email and otp_code are required")
+	if !validInput(w, r, &req) {
 		return
 	}
 
 	logger.Info("VerifyOTP called", logger.Field("email", req.Email))
 
-	userID, err := h.otpService.VerifyOTP(req.Email, req.OtpCode, h.otpMaxAttempts)
+	userID, err := h.otpService.VerifyOTP(r.Context(), models.OTPPurposeLogin, req.Email, req.OtpCode)
 	if err != nil {
 		logger.Warn("OTP verification failed", logger.Field("error", err.Error()), logger.Field("email", req.Email))
-		writeError(w, http.StatusUnauthorized, fmt.Sprintf("invalid OTP: %s", err.Error()))
+		writeProblem(w, r, err)
+		return
+	}
+
+	// Signing in to an account deleted by its owner cancels the deletion
+	if _, err := h.accountService.CancelDeletion(r.Context(), userID); err != nil {
+		logger.Error("failed to cancel account deletion", logger.Field("error", err.Error()), logger.Field("user_id", userID))
+		writeProblem(w, r, err)
+		return
+	}
+
+	// The code reached the email, so the email is confirmed
+	if err := h.userService.MarkEmailVerified(r.Context(), userID); err != nil {
+		logger.Error("failed to mark email verified", logger.Field("error", err.Error()), logger.Field("user_id", userID))
+		writeProblem(w, r, err)
 		return
 	}
 
-	user, err := h.userService.GetUser(userID)
+	user, err := h.userService.GetUser(r.Context(), userID)
 	if err != nil {
 		logger.Error("failed to get user", logger.Field("error", err.Error()), logger.Field("user_id", userID))
-		writeError(w, http.StatusInternalServerError, "failed to authenticate")
+		writeProblem(w, r, err)
 		return
 	}
 
@@ -105,14 +104,14 @@ func (h *AuthHandler) VerifyOTP(w http.ResponseWriter, r *http.Request) {
 	accessToken, err := h.jwtService.GenerateAccessToken(user.UserID, user.Email)
 	if err != nil {
 		logger.Error("failed to generate access token", logger.Field("error", err.Error()))
-		writeError(w, http.StatusInternalServerError, "failed to authenticate")
+		writeProblem(w, r, err)
 		return
 	}
 
 	refreshToken, err := h.jwtService.GenerateRefreshToken(user.UserID, user.Email)
 	if err != nil {
 		logger.Error("failed to generate refresh token", logger.Field("error", err.Error()))
-		writeError(w, http.StatusInternalServerError, "failed to authenticate")
+		writeProblem(w, r, err)
 		return
 	}
 
@@ -132,10 +131,12 @@ func (h *AuthHandler) VerifyOTP(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]interface{}{
 		"access_token": accessToken,
 		"user": map[string]interface{}{
-			"id":         user.UserID,
-			"email":      user.Email,
-			"phone":      user.Phone,
-			"created_at": user.CreatedAt,
+			"id":                user.UserID,
+			"email":             user.Email,
+			"phone":             user.Phone,
+			"email_verified_at": user.EmailVerifiedAt,
+			"phone_verified_at": user.PhoneVerifiedAt,
+			"created_at":        user.CreatedAt,
 		},
 	})
 }
@@ -145,14 +146,14 @@ func (h *AuthHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
 	cookie, err := r.Cookie("refresh_token")
 	if err != nil || cookie.Value == "" {
 		logger.Warn("refresh token missing")
-		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "refresh token required"})
+		writeError(w, http.StatusUnauthorized, "refresh token required")
 		return
 	}
 
 	claims, err := h.jwtService.ValidateRefreshToken(cookie.Value)
 	if err != nil {
 		logger.Warn("invalid refresh token", logger.Field("error", err.Error()))
-		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid or expired token"})
+		writeError(w, http.StatusUnauthorized, "invalid or expired token")
 		return
 	}
 
@@ -162,7 +163,7 @@ func (h *AuthHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
 	accessToken, err := h.jwtService.GenerateAccessToken(userID, userEmail)
 	if err != nil {
 		logger.Error("failed to generate access token", logger.Field("error", err.Error()))
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to generate access token"})
+		writeProblem(w, r, err)
 		return
 	}
 
@@ -187,6 +188,14 @@ func (h *AuthHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
 
 // Logout clears refresh token
 func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	clearAuthCookies(w)
+
+	logger.Info("user logged out")
+	writeJSON(w, http.StatusOK, map[string]string{"message": "logged out"})
+}
+
+// clearAuthCookies removes the access and refresh tokens from the browser
+func clearAuthCookies(w http.ResponseWriter) {
 	// Clear refresh_token
 	http.SetCookie(w, &http.Cookie{
 		Name:     "refresh_token",
@@ -208,9 +217,6 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 		SameSite: http.SameSiteLaxMode,
 		Expires:  time.Unix(0, 0),
 	})
-
-	logger.Info("user logged out")
-	writeJSON(w, http.StatusOK, map[string]string{"message": "logged out"})
 }
 
 // --- helpers ---
@@ -220,6 +226,12 @@ func writeJSON(w http.ResponseWriter, status int, v interface{}) {
 	_ = json.NewEncoder(w).Encode(v)
 }
 
+// writeError writes a problem for failures detected by the handler itself, e.g. a malformed request
 func writeError(w http.ResponseWriter, status int, message string) {
-	writeJSON(w, status, map[string]interface{}{"error": message})
+	problem.Write(w, status, problem.CodeOf(status), message)
+}
+
+// writeProblem writes a problem for an error returned by a service
+func writeProblem(w http.ResponseWriter, r *http.Request, err error) {
+	problem.Error(w, r, err)
 }
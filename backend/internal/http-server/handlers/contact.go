@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"rentor/internal/http-server/middleware"
+	"rentor/internal/logger"
+	"rentor/internal/models"
+	"rentor/internal/service"
+)
+
+// ContactHandler handles changing the email and phone of the signed-in user
+type ContactHandler struct {
+	contactService service.ContactService
+}
+
+// NewContactHandler creates a new instance of ContactHandler
+func NewContactHandler(contactSvc service.ContactService) *ContactHandler {
+	return &ContactHandler{
+		contactService: contactSvc,
+	}
+}
+
+// ===========================
+// POST /user/email
+// ===========================
+func (h *ContactHandler) RequestEmailChange(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "user not authenticated")
+		return
+	}
+
+	var req models.ChangeEmailInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+	if !validInput(w, r, &req) {
+		return
+	}
+
+	if err := h.contactService.RequestEmailChange(r.Context(), userID, req.Email); err != nil {
+		logger.Warn("email change not started", logger.Field("error", err.Error()), logger.Field("user_id", userID))
+		writeProblem(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"message": "OTP sent to email"})
+}
+
+// ===========================
+// POST /user/email/confirm
+// ===========================
+func (h *ContactHandler) ConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "user not authenticated")
+		return
+	}
+
+	var req models.ConfirmEmailChangeInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+	if !validInput(w, r, &req) {
+		return
+	}
+
+	user, err := h.contactService.ConfirmEmailChange(r.Context(), userID, req.Email, req.OtpCode)
+	if err != nil {
+		logger.Warn("email change not confirmed", logger.Field("error", err.Error()), logger.Field("user_id", userID))
+		writeProblem(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, user)
+}
+
+// ===========================
+// POST /user/phone
+// ===========================
+func (h *ContactHandler) RequestPhoneChange(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "user not authenticated")
+		return
+	}
+
+	var req models.ChangePhoneInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+	if !validInput(w, r, &req) {
+		return
+	}
+
+	if err := h.contactService.RequestPhoneChange(r.Context(), userID, req.Phone); err != nil {
+		logger.Warn("phone change not started", logger.Field("error", err.Error()), logger.Field("user_id", userID))
+		writeProblem(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"message": "OTP sent by SMS"})
+}
+
+// ===========================
+// POST /user/phone/confirm
+// ===========================
+func (h *ContactHandler) ConfirmPhoneChange(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "user not authenticated")
+		return
+	}
+
+	var req models.ConfirmPhoneChangeInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+	if !validInput(w, r, &req) {
+		return
+	}
+
+	user, err := h.contactService.ConfirmPhoneChange(r.Context(), userID, req.Phone, req.OtpCode)
+	if err != nil {
+		logger.Warn("phone change not confirmed", logger.Field("error", err.Error()), logger.Field("user_id", userID))
+		writeProblem(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, user)
+}
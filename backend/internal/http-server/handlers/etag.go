@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"rentor/internal/apperr"
+	"rentor/internal/mergepatch"
+)
+
+// maxPatchSize limits the body of PATCH requests
+const maxPatchSize = 1 << 20
+
+// setETag sends the version of a resource as its entity tag
+func setETag(w http.ResponseWriter, version int) {
+	w.Header().Set("ETag", `"`+strconv.Itoa(version)+`"`)
+}
+
+// ifMatchVersion returns the version from the If-Match header, nil if the header is missing or "*".
+// Entity tags that can't match a version (weak or foreign ones) fail the precondition.
+func ifMatchVersion(r *http.Request) (*int, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+	if strings.Contains(header, ",") {
+		return nil, apperr.Invalid("invalid_if_match", "If-Match must contain a single entity tag")
+	}
+
+	tag, ok := strings.CutPrefix(header, `"`)
+	if ok {
+		tag, ok = strings.CutSuffix(tag, `"`)
+	}
+	version, err := strconv.Atoi(tag)
+	if !ok || err != nil {
+		return nil, apperr.ErrVersionMismatch
+	}
+	return &version, nil
+}
+
+// readMergePatch reads a JSON merge patch body. Both application/merge-patch+json and
+// application/json are accepted, the latter for clients that can't set a custom type.
+func readMergePatch(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mediaType, _, err := mime.ParseMediaType(ct)
+		if err != nil || mediaType != mergepatch.ContentType && mediaType != "application/json" {
+			writeError(w, http.StatusUnsupportedMediaType, "content type must be "+mergepatch.ContentType)
+			return nil, false
+		}
+	}
+
+	patch, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPatchSize))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "cannot read request body")
+		return nil, false
+	}
+	return patch, true
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"rentor/internal/feed"
+	"rentor/internal/http-server/middleware"
+	"rentor/internal/logger"
+	"rentor/internal/models"
+	"rentor/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ExportHandler handles listing exports, public feeds and personal data archives
+type ExportHandler struct {
+	exportSvc service.ExportService
+	apiPrefix string // archive URLs are returned from the root
+}
+
+// NewExportHandler creates a new instance of ExportHandler
+func NewExportHandler(exportSvc service.ExportService, apiPrefix string) *ExportHandler {
+	return &ExportHandler{
+		exportSvc: exportSvc,
+		apiPrefix: apiPrefix,
+	}
+}
+
+// ===========================
+// GET /advertisements/my/export?format=csv|json|xml
+// ===========================
+func (h *ExportHandler) ExportMyAdvertisements(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		logger.Error("export failed", logger.Field("error", err.Error()))
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = feed.FormatCSV
+	}
+	if !slices.Contains(feed.Formats, format) {
+		writeProblem(w, r, feed.ErrUnsupportedFormat)
+		return
+	}
+
+	w.Header().Set("Content-Type", feed.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="advertisements.%s"`, format))
+
+	sw := &startedWriter{ResponseWriter: w}
+	if err := h.exportSvc.ExportListings(r.Context(), userID, format, sw); err != nil {
+		logger.Error("export failed", logger.Field("error", err.Error()), logger.Field("user_id", userID))
+		if !sw.started {
+			writeProblem(w, r, err)
+		}
+	}
+}
+
+// ===========================
+// GET /landlords/{id}/feed.xml
+// ===========================
+func (h *ExportHandler) GetLandlordFeed(w http.ResponseWriter, r *http.Request) {
+	landlordID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid landlord id")
+		return
+	}
+
+	w.Header().Set("Content-Type", feed.ContentType(feed.FormatXML))
+
+	sw := &startedWriter{ResponseWriter: w}
+	if err := h.exportSvc.PublicFeed(r.Context(), landlordID, sw); err != nil {
+		logger.Error("landlord feed failed", logger.Field("error", err.Error()), logger.Field("landlord_id", landlordID))
+		if !sw.started {
+			writeProblem(w, r, err)
+		}
+	}
+}
+
+// ===========================
+// POST /user/exports
+// ===========================
+func (h *ExportHandler) RequestArchive(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		logger.Error("request export archive failed", logger.Field("error", err.Error()))
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	archive, err := h.exportSvc.RequestArchive(r.Context(), userID)
+	if err != nil {
+		logger.Error("request export archive failed", logger.Field("error", err.Error()))
+		writeProblem(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s/user/exports/%d", h.apiPrefix, archive.ID))
+	writeJSON(w, http.StatusAccepted, archive)
+}
+
+// ===========================
+// GET /user/exports/{id}
+// ===========================
+func (h *ExportHandler) GetArchive(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		logger.Error("get export archive failed", logger.Field("error", err.Error()))
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	archiveID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid archive id")
+		return
+	}
+
+	archive, err := h.exportSvc.GetArchive(r.Context(), userID, archiveID)
+	if err != nil {
+		logger.Error("get export archive failed", logger.Field("error", err.Error()))
+		writeProblem(w, r, err)
+		return
+	}
+
+	if archive.Status == models.ExportArchiveCompleted {
+		archive.DownloadURL = fmt.Sprintf("%s/user/exports/%d/download", h.apiPrefix, archive.ID)
+	}
+	writeJSON(w, http.StatusOK, archive)
+}
+
+// ===========================
+// GET /user/exports/{id}/download
+// ===========================
+func (h *ExportHandler) DownloadArchive(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		logger.Error("download export archive failed", logger.Field("error", err.Error()))
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	archiveID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid archive id")
+		return
+	}
+
+	f, archive, err := h.exportSvc.OpenArchive(r.Context(), userID, archiveID)
+	if err != nil {
+		logger.Error("download export archive failed", logger.Field("error", err.Error()))
+		writeProblem(w, r, err)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="rentor-data.zip"`)
+	http.ServeContent(w, r, "rentor-data.zip", *archive.FinishedAt, f)
+}
+
+// startedWriter remembers whether a streamed response has started, after that errors can only be logged
+type startedWriter struct {
+	http.ResponseWriter
+	started bool
+}
+
+func (w *startedWriter) Write(b []byte) (int, error) {
+	w.started = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController flush the underlying writer
+func (w *startedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"rentor/internal/http-server/middleware"
+	"rentor/internal/logger"
+	"rentor/internal/models"
+	"rentor/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// importFormOverhead is the room left in an import request for the form fields next to the file
+const importFormOverhead = 1 << 20
+
+// ImportHandler handles bulk imports of advertisements
+type ImportHandler struct {
+	importSvc   service.ImportService
+	maxFileSize int64
+	apiPrefix   string // job URLs are returned from the root
+}
+
+// NewImportHandler creates a new instance of ImportHandler
+func NewImportHandler(importSvc service.ImportService, maxFileSize int64, apiPrefix string) *ImportHandler {
+	return &ImportHandler{
+		importSvc:   importSvc,
+		maxFileSize: maxFileSize,
+		apiPrefix:   apiPrefix,
+	}
+}
+
+// ===========================
+// POST /advertisements/imports
+// ===========================
+func (h *ImportHandler) CreateImport(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		logger.Error("import failed", logger.Field("error", err.Error()))
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxFileSize+importFormOverhead)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		logger.Error("import failed", logger.Field("error", err.Error()))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, "the file is too large")
+			return
+		}
+		writeError(w, http.StatusBadRequest, "failed to parse multipart form")
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		logger.Error("import failed", logger.Field("error", err.Error()))
+		writeError(w, http.StatusBadRequest, "no file uploaded")
+		return
+	}
+	defer file.Close()
+
+	input := models.ImportInput{Format: r.FormValue("format")}
+	if mapping := r.FormValue("mapping"); mapping != "" {
+		if err := json.Unmarshal([]byte(mapping), &input.Mapping); err != nil {
+			logger.Error("import failed", logger.Field("error", err.Error()))
+			writeError(w, http.StatusBadRequest, "mapping must be a JSON object of field names")
+			return
+		}
+	}
+	if dryRun := r.FormValue("dryRun"); dryRun != "" {
+		if input.DryRun, err = strconv.ParseBool(dryRun); err != nil {
+			writeError(w, http.StatusBadRequest, "dryRun must be true or false")
+			return
+		}
+	}
+
+	report, job, err := h.importSvc.Import(r.Context(), userID, &input, header.Filename, file)
+	if err != nil {
+		logger.Error("import failed", logger.Field("error", err.Error()))
+		writeProblem(w, r, err)
+		return
+	}
+
+	// large files are imported in the background, the client polls the job
+	if job != nil {
+		w.Header().Set("Location", fmt.Sprintf("%s/advertisements/imports/%d", h.apiPrefix, job.ID))
+		writeJSON(w, http.StatusAccepted, job)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+// ===========================
+// GET /advertisements/imports/{id}
+// ===========================
+func (h *ImportHandler) GetImport(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		logger.Error("get import failed", logger.Field("error", err.Error()))
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	jobID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid import id")
+		return
+	}
+
+	job, err := h.importSvc.GetJob(r.Context(), userID, jobID)
+	if err != nil {
+		logger.Error("get import failed", logger.Field("error", err.Error()))
+		writeProblem(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, job)
+}
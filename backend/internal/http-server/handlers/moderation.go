@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"rentor/internal/http-server/middleware"
+	"rentor/internal/logger"
+	"rentor/internal/models"
+	"rentor/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ModerationHandler handles moderation queue endpoints (admin only)
+type ModerationHandler struct {
+	moderationSvc service.ModerationService
+}
+
+// NewModerationHandler creates a new instance of ModerationHandler
+func NewModerationHandler(moderationSvc service.ModerationService) *ModerationHandler {
+	return &ModerationHandler{
+		moderationSvc: moderationSvc,
+	}
+}
+
+// ===========================
+// GET /moderation/queue
+// ===========================
+func (h *ModerationHandler) GetQueue(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	page := parseIntDefault(q.Get("page"), 1)
+	limit := parseIntDefault(q.Get("limit"), 20)
+
+	list, err := h.moderationSvc.GetQueue(r.Context(), q.Get("status"), page, limit)
+	if err != nil {
+		logger.Error("get moderation queue failed", logger.Field("error", err.Error()))
+		writeProblem(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, list)
+}
+
+// ===========================
+// POST /moderation/queue/{id}/resolve
+// ===========================
+func (h *ModerationHandler) ResolveItem(w http.ResponseWriter, r *http.Request) {
+	moderatorID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	itemID, _ := strconv.Atoi(chi.URLParam(r, "id"))
+
+	var input models.ResolveModerationItemInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if !validInput(w, r, &input) {
+		return
+	}
+
+	item, err := h.moderationSvc.ResolveItem(r.Context(), moderatorID, itemID, input.Status)
+	if err != nil {
+		logger.Error("resolve moderation item failed", logger.Field("error", err.Error()), logger.Field("item_id", itemID))
+		writeProblem(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, item)
+}
+
+// ===========================
+// GET /moderation/advertisements/{id}/duplicates
+// ===========================
+func (h *ModerationHandler) GetDuplicateCandidates(w http.ResponseWriter, r *http.Request) {
+	adID, _ := strconv.Atoi(chi.URLParam(r, "id"))
+
+	candidates, err := h.moderationSvc.DetectDuplicateListings(r.Context(), adID)
+	if err != nil {
+		logger.Error("detect duplicates failed", logger.Field("error", err.Error()), logger.Field("advertisement_id", adID))
+		writeProblem(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": candidates})
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+
+	"rentor/internal/http-server/middleware"
+	"rentor/internal/logger"
+	"rentor/internal/models"
+	"rentor/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// visitorCookie identifies anonymous visitors for the recently viewed list
+	visitorCookie = "visitor_id"
+	// visitorCookieTTL is the lifetime of the visitor cookie
+	visitorCookieTTL = 365 * 24 * time.Hour
+	// maxSimilarLimit limits the ?limit of the similar listings endpoint
+	maxSimilarLimit = 50
+)
+
+// RecommendationHandler handles the similar listings and recently viewed endpoints
+type RecommendationHandler struct {
+	recSvc       service.RecommendationService
+	similarLimit int
+}
+
+// NewRecommendationHandler creates a new instance of RecommendationHandler.
+// similarLimit is the number of similar listings returned when the request doesn't set ?limit.
+func NewRecommendationHandler(recSvc service.RecommendationService, similarLimit int) *RecommendationHandler {
+	return &RecommendationHandler{
+		recSvc:       recSvc,
+		similarLimit: similarLimit,
+	}
+}
+
+// ===========================
+// GET /advertisements/{id}/similar?limit=10
+// ===========================
+func (h *RecommendationHandler) GetSimilar(w http.ResponseWriter, r *http.Request) {
+	adID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid advertisement id")
+		return
+	}
+
+	limit := parseIntDefault(r.URL.Query().Get("limit"), h.similarLimit)
+	if limit < 1 || limit > maxSimilarLimit {
+		writeError(w, http.StatusBadRequest, "limit must be between 1 and 50")
+		return
+	}
+
+	list, err := h.recSvc.GetSimilar(r.Context(), adID, limit)
+	if err != nil {
+		logger.Error("get similar ads failed", logger.Field("error", err.Error()), logger.Field("advertisement_id", adID))
+		writeProblem(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, list)
+}
+
+// ===========================
+// GET /user/recently-viewed
+// ===========================
+func (h *RecommendationHandler) GetRecentlyViewed(w http.ResponseWriter, r *http.Request) {
+	viewer := viewerKey(w, r, false)
+	if viewer == "" {
+		// an anonymous visitor without the cookie hasn't viewed anything yet
+		writeJSON(w, http.StatusOK, &models.RecentlyViewedList{Items: []models.AdPreview{}})
+		return
+	}
+
+	list, err := h.recSvc.GetRecentlyViewed(r.Context(), viewer)
+	if err != nil {
+		logger.Error("get recently viewed failed", logger.Field("error", err.Error()))
+		writeProblem(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, list)
+}
+
+// viewerKey identifies whose recently viewed list the request works with: the signed-in user
+// (route wrapped in middleware.OptionalAuth) or the anonymous visitor cookie. With create set
+// a visitor without the cookie gets a new one; otherwise "" is returned for such a visitor.
+func viewerKey(w http.ResponseWriter, r *http.Request, create bool) string {
+	if userID, err := middleware.GetUserIDFromContext(r); err == nil {
+		return models.UserViewerKey(userID)
+	}
+
+	if c, err := r.Cookie(visitorCookie); err == nil && validVisitorID(c.Value) {
+		return "visitor:" + c.Value
+	}
+	if !create {
+		return ""
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		logger.Warn("failed to generate visitor id", logger.Field("error", err.Error()))
+		return ""
+	}
+	id := hex.EncodeToString(b)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     visitorCookie,
+		Value:    id,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   false,
+		SameSite: http.SameSiteLaxMode,
+		Expires:  time.Now().Add(visitorCookieTTL),
+	})
+	return "visitor:" + id
+}
+
+// validVisitorID reports whether v looks like an id issued by viewerKey
+func validVisitorID(v string) bool {
+	if len(v) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(v)
+	return err == nil
+}
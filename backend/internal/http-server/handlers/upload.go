@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"rentor/internal/http-server/middleware"
+	"rentor/internal/logger"
+	"rentor/internal/models"
+	"rentor/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// UploadHandler handles direct-to-storage image uploads
+type UploadHandler struct {
+	uploadSvc service.UploadService
+	apiPrefix string // the service returns URLs relative to the API, clients need them from the root
+}
+
+// NewUploadHandler creates a new instance of UploadHandler
+func NewUploadHandler(uploadSvc service.UploadService, apiPrefix string) *UploadHandler {
+	return &UploadHandler{
+		uploadSvc: uploadSvc,
+		apiPrefix: apiPrefix,
+	}
+}
+
+// ===========================
+// POST /advertisements/{id}/images/uploads
+// ===========================
+func (h *UploadHandler) CreateUploadSession(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		logger.Error("create upload session failed", logger.Field("error", err.Error()))
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	adID, _ := strconv.Atoi(chi.URLParam(r, "id"))
+
+	var input models.CreateUploadSessionInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		logger.Error("create upload session failed", logger.Field("error", err.Error()))
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if !validInput(w, r, &input) {
+		return
+	}
+
+	resp, err := h.uploadSvc.CreateSession(r.Context(), userID, adID, &input)
+	if err != nil {
+		logger.Error("create upload session failed", logger.Field("error", err.Error()))
+		writeProblem(w, r, err)
+		return
+	}
+
+	resp.CommitURL = h.apiPrefix + resp.CommitURL
+	for i := range resp.Uploads {
+		resp.Uploads[i].UploadURL = h.apiPrefix + resp.Uploads[i].UploadURL
+	}
+
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+// ===========================
+// PUT /uploads/{token}
+// ===========================
+func (h *UploadHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+
+	err := h.uploadSvc.Upload(r.Context(), token, r.Header.Get("Content-Type"), r.Body)
+	if err != nil {
+		logger.Error("upload failed", logger.Field("error", err.Error()))
+		writeProblem(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ===========================
+// POST /advertisements/{id}/images/uploads/{session_id}/commit
+// ===========================
+func (h *UploadHandler) CommitUploadSession(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		logger.Error("commit upload session failed", logger.Field("error", err.Error()))
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	adID, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	sessionID, _ := strconv.Atoi(chi.URLParam(r, "session_id"))
+
+	resp, err := h.uploadSvc.CommitSession(r.Context(), userID, adID, sessionID)
+	if err != nil {
+		logger.Error("commit upload session failed", logger.Field("error", err.Error()))
+		writeProblem(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
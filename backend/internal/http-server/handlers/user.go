@@ -30,30 +30,8 @@ func (h *UserProfileHandler) GetUserProfile(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	user, err := h.userService.GetUser(userID)
-	if err != nil {
-		logger.Error("failed to get user", logger.Field("error", err.Error()), logger.Field("user_id", userID))
-		writeError(w, http.StatusNotFound, "user not found")
-		return
-	}
-
-	profile, err := h.userProfileService.GetUserProfile(userID)
-	if err != nil {
-		logger.Error("failed to get user profile", logger.Field("error", err.Error()), logger.Field("user_id", userID))
-		writeError(w, http.StatusNotFound, "profile not found")
-		return
-	}
-
 	logger.Info("GetUserProfile called", logger.Field("user_id", userID))
-	writeJSON(w, http.StatusOK, &models.GetUserProfileOutput{
-		UserID:     user.UserID,
-		Email:      user.Email,
-		Phone:      user.Phone,
-		FirstName:  profile.FirstName,
-		Surname:    profile.Surname,
-		Patronymic: profile.Patronymic,
-		CreatedAt:  profile.CreatedAt,
-	})
+	h.writeProfile(w, r, userID)
 }
 
 // UpdateUserProfile handles PUT /user/profile
@@ -64,41 +42,87 @@ func (h *UserProfileHandler) UpdateUserProfile(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	ifMatch, err := ifMatchVersion(r)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
 	var req models.UpdateUserProfileInput
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid request payload")
 		return
 	}
+	if !validInput(w, r, &req) {
+		return
+	}
 
-	err = h.userProfileService.UpdateUserProfile(userID, &req)
+	_, err = h.userProfileService.UpdateUserProfile(r.Context(), userID, &req, ifMatch)
 	if err != nil {
 		logger.Error("failed to update user profile", logger.Field("error", err.Error()), logger.Field("user_id", userID))
-		writeError(w, http.StatusInternalServerError, "failed to update profile")
+		writeProblem(w, r, err)
+		return
+	}
+
+	logger.Info("UpdateUserProfile called", logger.Field("user_id", userID))
+	h.writeProfile(w, r, userID)
+}
+
+// PatchUserProfile handles PATCH /user/profile with a JSON merge patch
+func (h *UserProfileHandler) PatchUserProfile(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "user not authenticated")
+		return
+	}
+
+	ifMatch, err := ifMatchVersion(r)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	patch, ok := readMergePatch(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := h.userProfileService.PatchUserProfile(r.Context(), userID, patch, ifMatch); err != nil {
+		logger.Error("failed to patch user profile", logger.Field("error", err.Error()), logger.Field("user_id", userID))
+		writeProblem(w, r, err)
 		return
 	}
 
-	user, err := h.userService.GetUser(userID)
+	logger.Info("PatchUserProfile called", logger.Field("user_id", userID))
+	h.writeProfile(w, r, userID)
+}
+
+// writeProfile responds with the current profile of the user and its version as the ETag
+func (h *UserProfileHandler) writeProfile(w http.ResponseWriter, r *http.Request, userID int) {
+	user, err := h.userService.GetUser(r.Context(), userID)
 	if err != nil {
 		logger.Error("failed to get user", logger.Field("error", err.Error()), logger.Field("user_id", userID))
-		writeError(w, http.StatusNotFound, "user not found")
+		writeProblem(w, r, err)
 		return
 	}
 
-	profile, err := h.userProfileService.GetUserProfile(userID)
+	profile, err := h.userProfileService.GetUserProfile(r.Context(), userID)
 	if err != nil {
 		logger.Error("failed to get user profile", logger.Field("error", err.Error()), logger.Field("user_id", userID))
-		writeError(w, http.StatusNotFound, "profile not found")
+		writeProblem(w, r, err)
 		return
 	}
 
-	logger.Info("UpdateUserProfile called", logger.Field("user_id", userID))
+	setETag(w, profile.Version)
 	writeJSON(w, http.StatusOK, &models.GetUserProfileOutput{
-		UserID:     user.UserID,
-		Email:      user.Email,
-		Phone:      user.Phone,
-		FirstName:  profile.FirstName,
-		Surname:    profile.Surname,
-		Patronymic: profile.Patronymic,
-		CreatedAt:  profile.CreatedAt,
+		UserID:          user.UserID,
+		Email:           user.Email,
+		Phone:           user.Phone,
+		EmailVerifiedAt: user.EmailVerifiedAt,
+		PhoneVerifiedAt: user.PhoneVerifiedAt,
+		FirstName:       profile.FirstName,
+		Surname:         profile.Surname,
+		Patronymic:      profile.Patronymic,
+		CreatedAt:       profile.CreatedAt,
 	})
 }
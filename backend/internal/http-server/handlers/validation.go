@@ -0,0 +1,17 @@
+package handlers
+
+import (
+	"net/http"
+
+	"rentor/internal/validation"
+)
+
+// validInput validates a decoded request body. If it is invalid, a 400 problem with the
+// field errors is written and false is returned.
+func validInput(w http.ResponseWriter, r *http.Request, input any) bool {
+	if err := validation.Validate(input); err != nil {
+		writeProblem(w, r, err)
+		return false
+	}
+	return true
+}
@@ -5,7 +5,9 @@ import (
 	"errors"
 	"net/http"
 
+	"rentor/internal/http-server/problem"
 	"rentor/internal/logger"
+	"rentor/internal/models"
 	"rentor/internal/service"
 )
 
@@ -27,7 +29,7 @@ func AuthMiddlewareWithRefresh(jwtService service.JWTService, cookieAccessName,
 				userID, newAccess, err := refreshAccessFromCookie(jwtService, r, cookieRefreshName)
 				if err != nil {
 					logger.Warn("no valid token", logger.Field("error", err.Error()))
-					http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+					problem.Write(w, http.StatusUnauthorized, "unauthorized", "authentication required")
 					return
 				}
 
@@ -53,7 +55,7 @@ func AuthMiddlewareWithRefresh(jwtService service.JWTService, cookieAccessName,
 				userID, newAccess, err := refreshAccessFromCookie(jwtService, r, cookieRefreshName)
 				if err != nil {
 					logger.Warn("invalid access token and refresh failed", logger.Field("error", err.Error()))
-					http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+					problem.Write(w, http.StatusUnauthorized, "unauthorized", "authentication required")
 					return
 				}
 
@@ -79,6 +81,29 @@ func AuthMiddlewareWithRefresh(jwtService service.JWTService, cookieAccessName,
 	}
 }
 
+// OptionalAuth puts the user ID into the context when the request carries a valid access token.
+// Requests without one are passed on anonymously, so public routes can personalize their response.
+func OptionalAuth(jwtService service.JWTService, cookieAccessName string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			accessCookie, err := r.Cookie(cookieAccessName)
+			if err != nil || accessCookie.Value == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			claims, err := jwtService.ValidateAccessToken(accessCookie.Value)
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), UserIDKey, claims.UserID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
 // refreshAccessFromCookie проверяет refresh token и создаёт новый access token
 func refreshAccessFromCookie(jwtService service.JWTService, r *http.Request, cookieRefreshName string) (userID int, newAccess string, err error) {
 	refreshCookie, err := r.Cookie(cookieRefreshName)
@@ -101,6 +126,29 @@ func refreshAccessFromCookie(jwtService service.JWTService, r *http.Request, coo
 
 }
 
+// RequireAdmin allows the request only for users with the admin role.
+// Must be used after AuthMiddlewareWithRefresh.
+func RequireAdmin(userService service.UserService) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, err := GetUserIDFromContext(r)
+			if err != nil {
+				problem.Write(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+				return
+			}
+
+			user, err := userService.GetUser(r.Context(), userID)
+			if err != nil || user.Role != models.RoleAdmin {
+				logger.Warn("admin access denied", logger.Field("user_id", userID))
+				problem.Write(w, http.StatusForbidden, "forbidden", "insufficient role")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // GetUserIDFromContext достаёт userID из контекста
 func GetUserIDFromContext(r *http.Request) (int, error) {
 	userID, ok := r.Context().Value(UserIDKey).(int)
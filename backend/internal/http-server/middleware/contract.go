@@ -0,0 +1,224 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"rentor/internal/logger"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/go-chi/chi/middleware"
+)
+
+// Contract validation modes
+const (
+	ContractOff = "off" // requests and responses are not checked
+	ContractLog = "log" // violations are logged, traffic is left untouched
+)
+
+// maxCheckedBody is the largest response body the middleware keeps a copy of,
+// the bodies of larger responses are not checked
+const maxCheckedBody = 1 << 20
+
+// ContractMiddleware checks requests and responses of the API mounted at prefix against
+// the OpenAPI document spec and logs violations, so drift from the published contract shows up in production.
+// Responses are passed through as they are written, the check runs on a copy after the handler returns.
+// Streaming operations and operations the document doesn't describe are not checked.
+// The contract is enforced by the tests of the router, see Contract.
+func ContractMiddleware(spec []byte, prefix, mode string) (func(next http.Handler) http.Handler, error) {
+	switch mode {
+	case "", ContractOff:
+		return func(next http.Handler) http.Handler { return next }, nil
+	case ContractLog:
+	default:
+		return nil, fmt.Errorf("ContractMiddleware: unsupported mode %q (off|log)", mode)
+	}
+
+	contract, err := NewContract(spec, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("ContractMiddleware: %w", err)
+	}
+
+	return func(next http.Handler) http.Handler {
+		log := logger.With(
+			logger.Field("component", "middleware/contract"),
+			logger.Field("mode", mode),
+		)
+
+		log.Info("contract middleware enabled")
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			op, ok := contract.Match(r)
+			if !ok || op.Streaming() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if err := op.ValidateRequest(r.Context()); err != nil {
+				log.Warn("request violates the contract",
+					logger.Field("method", r.Method),
+					logger.Field("path", r.URL.Path),
+					logger.Field("error", err.Error()),
+				)
+			}
+
+			body := &cappedBuffer{max: maxCheckedBody}
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			ww.Tee(body)
+			next.ServeHTTP(ww, r)
+
+			if body.overflow {
+				return
+			}
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			// The handler may have run into the request deadline, the check itself must still finish
+			if err := op.ValidateResponse(context.WithoutCancel(r.Context()), status, ww.Header(), body.Bytes()); err != nil {
+				log.Error("response violates the contract",
+					logger.Field("method", r.Method),
+					logger.Field("path", r.URL.Path),
+					logger.Field("status", status),
+					logger.Field("error", err.Error()),
+				)
+			}
+		}
+
+		return http.HandlerFunc(fn)
+	}, nil
+}
+
+// Contract checks HTTP traffic against an OpenAPI document.
+// Authentication is left to the auth middlewares: security requirements are not checked.
+type Contract struct {
+	router  routers.Router
+	options *openapi3filter.Options
+}
+
+// NewContract loads the OpenAPI document spec of the API mounted at prefix
+func NewContract(spec []byte, prefix string) (*Contract, error) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(spec)
+	if err != nil {
+		return nil, fmt.Errorf("NewContract: load spec: %w", err)
+	}
+	if err := doc.Validate(loader.Context); err != nil {
+		return nil, fmt.Errorf("NewContract: invalid spec: %w", err)
+	}
+	// Match on the path only, the servers of the document name the public hosts
+	doc.Servers = openapi3.Servers{{URL: prefix}}
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, fmt.Errorf("NewContract: build router: %w", err)
+	}
+
+	options := &openapi3filter.Options{
+		AuthenticationFunc:    openapi3filter.NoopAuthenticationFunc,
+		IncludeResponseStatus: true,
+		MultiError:            true,
+		SkipSettingDefaults:   true,
+	}
+	// Report where the document was violated instead of dumping the whole schema and value
+	options.WithCustomSchemaErrorFunc(func(err *openapi3.SchemaError) string {
+		return "/" + strings.Join(err.JSONPointer(), "/") + ": " + err.Reason
+	})
+
+	return &Contract{router: router, options: options}, nil
+}
+
+// Match finds the operation of r in the document, ok is false if the document doesn't describe it
+func (c *Contract) Match(r *http.Request) (op *ContractOperation, ok bool) {
+	route, params, err := c.router.FindRoute(r)
+	if err != nil {
+		return nil, false
+	}
+	return &ContractOperation{
+		options: c.options,
+		input: &openapi3filter.RequestValidationInput{
+			Request:    r,
+			PathParams: params,
+			Route:      route,
+			Options:    requestOptions(c.options, r),
+		},
+	}, true
+}
+
+// ContractOperation is a request matched to an operation of the document
+type ContractOperation struct {
+	options *openapi3filter.Options
+	input   *openapi3filter.RequestValidationInput
+}
+
+// Streaming reports whether the operation is marked with x-streaming: its response is written
+// incrementally and may be too large to keep in memory, so it is not checked by the middleware
+func (o *ContractOperation) Streaming() bool {
+	streaming, _ := o.input.Route.Operation.Extensions["x-streaming"].(bool)
+	return streaming
+}
+
+// ValidateRequest checks the request, its body is read and restored
+func (o *ContractOperation) ValidateRequest(ctx context.Context) error {
+	return openapi3filter.ValidateRequest(ctx, o.input)
+}
+
+// ValidateResponse checks the response written for the request
+func (o *ContractOperation) ValidateResponse(ctx context.Context, status int, header http.Header, body []byte) error {
+	response := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: o.input,
+		Status:                 status,
+		Header:                 header,
+		Options:                responseOptions(o.options, header),
+	}
+	response.SetBodyBytes(body)
+	return openapi3filter.ValidateResponse(ctx, response)
+}
+
+// requestOptions skips body validation for media types the validator can't decode (image uploads),
+// they are checked by the upload handlers.
+func requestOptions(options *openapi3filter.Options, r *http.Request) *openapi3filter.Options {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || openapi3filter.RegisteredBodyDecoder(mediaType) != nil {
+		return options
+	}
+	skipBody := *options
+	skipBody.ExcludeRequestBody = true
+	return &skipBody
+}
+
+// responseOptions skips body validation for responses the validator can't decode (XML feeds),
+// their status and headers are still checked.
+func responseOptions(options *openapi3filter.Options, header http.Header) *openapi3filter.Options {
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil || openapi3filter.RegisteredBodyDecoder(mediaType) != nil {
+		return options
+	}
+	skipBody := *options
+	skipBody.ExcludeResponseBody = true
+	return &skipBody
+}
+
+// cappedBuffer keeps a copy of a response body up to max bytes, overflow is set when the body is larger
+type cappedBuffer struct {
+	bytes.Buffer
+	max      int
+	overflow bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if b.overflow {
+		return len(p), nil
+	}
+	if b.Len()+len(p) > b.max {
+		b.overflow = true
+		b.Reset()
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
@@ -0,0 +1,119 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"rentor/internal/http-server/middleware"
+	"rentor/internal/logger"
+)
+
+const testSpec = `
+openapi: 3.0.3
+info:
+  title: test
+  version: "1"
+paths:
+  /items:
+    get:
+      responses:
+        '200':
+          description: items
+          content:
+            application/json:
+              schema:
+                type: object
+                required: [items]
+                properties:
+                  items:
+                    type: array
+                    items:
+                      type: integer
+  /items/export:
+    get:
+      x-streaming: true
+      responses:
+        '200':
+          description: export
+          content:
+            text/csv:
+              schema:
+                type: string
+`
+
+func TestContractMiddlewareLogPassesResponsesThrough(t *testing.T) {
+	logger.InitNop()
+	mw, err := middleware.ContractMiddleware([]byte(testSpec), "/v1", middleware.ContractLog)
+	if err != nil {
+		t.Fatalf("ContractMiddleware: %v", err)
+	}
+
+	for _, path := range []string{"/v1/items", "/v1/items/export", "/v1/unknown"} {
+		var flushed bool
+		handler := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTeapot)
+			// violates the contract: the status is not described and items is not an array
+			_, _ = w.Write([]byte(`{"items":`))
+			f, ok := w.(http.Flusher)
+			if !ok {
+				t.Errorf("%s: the response writer is not an http.Flusher", path)
+				return
+			}
+			f.Flush()
+			flushed = true
+			_, _ = w.Write([]byte(`"none"}`))
+		}))
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		if !flushed || !w.Flushed {
+			t.Errorf("%s: the flush did not reach the client", path)
+		}
+		if w.Code != http.StatusTeapot || w.Body.String() != `{"items":"none"}` {
+			t.Errorf("%s: response was changed: %d %s", path, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestContractMiddlewareModes(t *testing.T) {
+	logger.InitNop()
+	for _, mode := range []string{"", middleware.ContractOff, middleware.ContractLog} {
+		if _, err := middleware.ContractMiddleware([]byte(testSpec), "/v1", mode); err != nil {
+			t.Errorf("mode %q: %v", mode, err)
+		}
+	}
+	if _, err := middleware.ContractMiddleware([]byte(testSpec), "/v1", "strict"); err == nil {
+		t.Error("mode strict is accepted, the contract is enforced by tests only")
+	}
+}
+
+func TestContractStreaming(t *testing.T) {
+	contract, err := middleware.NewContract([]byte(testSpec), "/v1")
+	if err != nil {
+		t.Fatalf("NewContract: %v", err)
+	}
+
+	tests := []struct {
+		path      string
+		match     bool
+		streaming bool
+	}{
+		{"/v1/items", true, false},
+		{"/v1/items/export", true, true},
+		{"/v1/unknown", false, false},
+		{"/items", false, false},
+	}
+	for _, tt := range tests {
+		op, ok := contract.Match(httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if ok != tt.match {
+			t.Errorf("%s: match = %v, want %v", tt.path, ok, tt.match)
+			continue
+		}
+		if ok && op.Streaming() != tt.streaming {
+			t.Errorf("%s: streaming = %v, want %v", tt.path, op.Streaming(), tt.streaming)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"rentor/internal/logger"
+	"time"
+)
+
+// TimeoutMiddleware sets a deadline on the request context.
+// Services and repositories run their queries with this context, so work is cancelled
+// when the deadline passes or the client disconnects. A non-positive timeout disables the deadline.
+func TimeoutMiddleware(timeout time.Duration) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if timeout <= 0 {
+			logger.Warn("request timeout middleware disabled: timeout is not set")
+			return next
+		}
+
+		logger.Info("request timeout middleware enabled", logger.Field("timeout", timeout.String()))
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// StreamingMiddleware is the TimeoutMiddleware of routes that stream large bodies (exports, feeds, uploads).
+// The read and write deadlines the server sets on the connection from its ReadTimeout and WriteTimeout
+// would cut such bodies off, so they are moved along with the context deadline to timeout from the start
+// of the request. A non-positive timeout lifts the deadlines.
+func StreamingMiddleware(timeout time.Duration) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		log := logger.With(logger.Field("component", "middleware/streaming"))
+
+		if timeout <= 0 {
+			log.Warn("streaming routes have no deadline: timeout is not set")
+		} else {
+			log.Info("streaming timeout middleware enabled", logger.Field("timeout", timeout.String()))
+		}
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			var deadline time.Time
+			ctx := r.Context()
+			if timeout > 0 {
+				deadline = time.Now().Add(timeout)
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithDeadline(ctx, deadline)
+				defer cancel()
+			}
+
+			// writers that don't reach a connection (tests) have no deadlines to move
+			rc := http.NewResponseController(w)
+			if err := rc.SetReadDeadline(deadline); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				log.Warn("failed to move the read deadline", logger.Field("path", r.URL.Path), logger.Field("error", err.Error()))
+			}
+			if err := rc.SetWriteDeadline(deadline); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				log.Warn("failed to move the write deadline", logger.Field("path", r.URL.Path), logger.Field("error", err.Error()))
+			}
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,115 @@
+package httpserver_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// TestPatchIfMatch sends merge patches of the profile with every kind of If-Match header
+func TestPatchIfMatch(t *testing.T) {
+	s := newTestServer(t)
+	owner := s.login(t, "owner@example.com")
+
+	// version is the ETag the profile has before each step
+	version := s.do(t, request(t, http.MethodGet, "/user/profile", owner, nil)).Header().Get("ETag")
+	if version != `"1"` {
+		t.Fatalf("ETag = %s, want \"1\"", version)
+	}
+	tests := []struct {
+		name    string
+		ifMatch func(version string) string
+		status  int
+		code    string
+	}{
+		{"current version", func(v string) string { return v }, http.StatusOK, ""},
+		{"missing header updates unconditionally", func(string) string { return "" }, http.StatusOK, ""},
+		{"any version", func(string) string { return "*" }, http.StatusOK, ""},
+		{"stale version", func(string) string { return `"1"` }, http.StatusPreconditionFailed, "version_mismatch"},
+		{"weak tag never matches", func(v string) string { return "W/" + v }, http.StatusPreconditionFailed, "version_mismatch"},
+		{"unquoted version", func(v string) string { return v[1 : len(v)-1] }, http.StatusPreconditionFailed, "version_mismatch"},
+		{"foreign tag", func(string) string { return `"abc"` }, http.StatusPreconditionFailed, "version_mismatch"},
+		{"empty tag", func(string) string { return `""` }, http.StatusPreconditionFailed, "version_mismatch"},
+		{"list of tags", func(v string) string { return v + `, "1"` }, http.StatusBadRequest, "invalid_if_match"},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := request(t, http.MethodPatch, "/user/profile", owner, map[string]any{"first_name": fmt.Sprintf("Ivan %d", i)})
+			if header := tt.ifMatch(version); header != "" {
+				r.Header.Set("If-Match", header)
+			}
+			w := s.do(t, r)
+			expectStatus(t, w, tt.status)
+			if tt.status == http.StatusOK {
+				next := w.Header().Get("ETag")
+				if next == version {
+					t.Errorf("ETag %s was not changed by the update", next)
+				}
+				version = next
+				return
+			}
+			if p := decode[struct {
+				Code string `json:"code"`
+			}](t, w); p.Code != tt.code {
+				t.Errorf("code = %q, want %q", p.Code, tt.code)
+			}
+		})
+	}
+	if version != `"4"` {
+		t.Errorf("ETag = %s after three updates, want \"4\"", version)
+	}
+}
+
+// TestUpdateAdvertisementIfMatch checks that both kinds of advertisement update refuse a stale version
+func TestUpdateAdvertisementIfMatch(t *testing.T) {
+	s := newTestServer(t)
+	owner := s.login(t, "owner@example.com")
+
+	listing := map[string]any{
+		"title":       "Flat in the centre",
+		"price":       250000,
+		"currency":    "KZT",
+		"pricePeriod": "month",
+		"type":        "apartment",
+		"rooms":       "2",
+		"city":        "Almaty",
+		"address":     "Abay 10",
+		"square":      54.5,
+		"status":      "active",
+	}
+	w := s.do(t, request(t, http.MethodPost, "/advertisements", owner, listing))
+	expectStatus(t, w, http.StatusOK)
+	adPath := fmt.Sprintf("/advertisements/%d", decode[struct {
+		ID int `json:"id"`
+	}](t, w).ID)
+	stale := s.do(t, request(t, http.MethodGet, adPath, owner, nil)).Header().Get("ETag")
+
+	r := request(t, http.MethodPatch, adPath, owner, map[string]any{"price": 240000})
+	r.Header.Set("If-Match", stale)
+	w = s.do(t, r)
+	expectStatus(t, w, http.StatusOK)
+	current := w.Header().Get("ETag")
+	if current == stale {
+		t.Fatalf("ETag %s was not changed by the patch", current)
+	}
+
+	r = request(t, http.MethodPatch, adPath, owner, map[string]any{"price": 230000})
+	r.Header.Set("If-Match", stale)
+	expectStatus(t, s.do(t, r), http.StatusPreconditionFailed)
+	r = request(t, http.MethodPut, adPath, owner, listing)
+	r.Header.Set("If-Match", stale)
+	expectStatus(t, s.do(t, r), http.StatusPreconditionFailed)
+
+	// the refused updates changed nothing
+	w = s.do(t, request(t, http.MethodGet, adPath, owner, nil))
+	ad := decode[struct {
+		Price float64 `json:"price"`
+	}](t, w)
+	if ad.Price != 240000 || w.Header().Get("ETag") != current {
+		t.Errorf("price %v with ETag %s, want 240000 with %s", ad.Price, w.Header().Get("ETag"), current)
+	}
+
+	r = request(t, http.MethodPut, adPath, owner, listing)
+	r.Header.Set("If-Match", current)
+	expectStatus(t, s.do(t, r), http.StatusOK)
+}
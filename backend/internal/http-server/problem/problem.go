@@ -0,0 +1,166 @@
+// Package problem writes error responses as RFC 7807 problem details (application/problem+json).
+// It is the one place where errors are mapped to HTTP statuses, for handlers and middleware alike.
+package problem
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"rentor/internal/apperr"
+	"rentor/internal/logger"
+	"rentor/internal/validation"
+)
+
+// ContentType is the media type of problem responses
+const ContentType = "application/problem+json"
+
+// typePrefix makes the problem type URI from the error code
+const typePrefix = "urn:rentor:problem:"
+
+// Details is an RFC 7807 problem object extended with the stable error code and the invalid fields
+type Details struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Detail   string            `json:"detail,omitempty"`
+	Instance string            `json:"instance,omitempty"`
+	Code     string            `json:"code"`
+	Errors   validation.Errors `json:"errors,omitempty"` // invalid fields of validation failures
+}
+
+// statuses maps domain error kinds to HTTP statuses
+var statuses = map[apperr.Kind]int{
+	apperr.KindNotFound:        http.StatusNotFound,
+	apperr.KindUnauthorized:    http.StatusUnauthorized,
+	apperr.KindForbidden:       http.StatusForbidden,
+	apperr.KindConflict:        http.StatusConflict,
+	apperr.KindPrecondition:    http.StatusPreconditionFailed,
+	apperr.KindGone:            http.StatusGone,
+	apperr.KindValidation:      http.StatusBadRequest,
+	apperr.KindRateLimited:     http.StatusTooManyRequests,
+	apperr.KindTooLarge:        http.StatusRequestEntityTooLarge,
+	apperr.KindUnsupportedType: http.StatusUnsupportedMediaType,
+}
+
+// Error writes err as a problem. Domain errors keep their code and message; errors without a kind
+// are logged and answered with a generic 500, so internal details never reach the client.
+func Error(w http.ResponseWriter, r *http.Request, err error) {
+	p := fromError(err)
+	if p.Status >= http.StatusInternalServerError {
+		logger.Error("request failed",
+			logger.Field("error", err.Error()),
+			logger.Field("method", r.Method),
+			logger.Field("path", r.URL.Path),
+		)
+	}
+	p.Instance = r.URL.Path
+	write(w, p)
+}
+
+// Write writes a problem with an explicit status and code, for failures detected by the HTTP layer itself
+func Write(w http.ResponseWriter, status int, code, detail string) {
+	write(w, &Details{
+		Type:   typePrefix + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	})
+}
+
+// CodeOf returns the default code of a status, used when a handler reports an error without one
+func CodeOf(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "bad_request"
+	case http.StatusUnauthorized:
+		return "unauthorized"
+	case http.StatusForbidden:
+		return "forbidden"
+	case http.StatusNotFound:
+		return "not_found"
+	case http.StatusMethodNotAllowed:
+		return "method_not_allowed"
+	case http.StatusConflict:
+		return "conflict"
+	case http.StatusPreconditionFailed:
+		return "precondition_failed"
+	case http.StatusUnsupportedMediaType:
+		return "unsupported_media_type"
+	case http.StatusTooManyRequests:
+		return "rate_limited"
+	default:
+		if status >= http.StatusInternalServerError {
+			return "internal"
+		}
+		return "error"
+	}
+}
+
+func fromError(err error) *Details {
+	if fields, ok := validation.AsErrors(err); ok {
+		return &Details{
+			Type:   typePrefix + "validation_failed",
+			Title:  http.StatusText(http.StatusBadRequest),
+			Status: http.StatusBadRequest,
+			Detail: "the request has invalid fields",
+			Code:   "validation_failed",
+			Errors: fields,
+		}
+	}
+
+	var domainErr *apperr.Error
+	if errors.As(err, &domainErr) {
+		status, ok := statuses[domainErr.Kind]
+		if !ok {
+			status = http.StatusInternalServerError
+		}
+		code := domainErr.Code
+		if code == "" {
+			code = string(domainErr.Kind)
+		}
+		return &Details{
+			Type:   typePrefix + code,
+			Title:  http.StatusText(status),
+			Status: status,
+			Detail: domainErr.Message,
+			Code:   code,
+		}
+	}
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		// a repository that doesn't report its own not found error
+		return &Details{
+			Type:   typePrefix + "not_found",
+			Title:  http.StatusText(http.StatusNotFound),
+			Status: http.StatusNotFound,
+			Code:   "not_found",
+		}
+	case errors.Is(err, context.DeadlineExceeded):
+		return &Details{
+			Type:   typePrefix + "timeout",
+			Title:  http.StatusText(http.StatusGatewayTimeout),
+			Status: http.StatusGatewayTimeout,
+			Detail: "the request took too long",
+			Code:   "timeout",
+		}
+	}
+
+	return &Details{
+		Type:   typePrefix + "internal",
+		Title:  http.StatusText(http.StatusInternalServerError),
+		Status: http.StatusInternalServerError,
+		Detail: "internal error",
+		Code:   "internal",
+	}
+}
+
+func write(w http.ResponseWriter, p *Details) {
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
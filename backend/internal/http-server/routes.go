@@ -2,21 +2,69 @@ package httpserver
 
 import (
 	"net/http"
+	"rentor/api"
 	"rentor/internal/config"
 	"rentor/internal/http-server/handlers"
 	"rentor/internal/http-server/middleware"
+	"rentor/internal/http-server/problem"
 	"rentor/internal/logger"
 	"rentor/internal/store"
 
 	"github.com/go-chi/chi/v5"
 )
 
+// APIPrefix is the path the current version of the API is mounted at.
+// Breaking changes go to a new version mounted next to it.
+const APIPrefix = "/v1"
+
 // RegisterRoutes registers HTTP routes.
-func RegisterRoutes(router chi.Router, dataStore *store.Store, cfg *config.Config) {
+// The API is mounted under APIPrefix, static files stay at cfg.BaseURL.
+func RegisterRoutes(router chi.Router, dataStore *store.Store, cfg *config.Config) error {
 	log := logger.With(logger.Field("component", "http-server"))
 
+	// Unknown routes get problem responses like every other error
+	router.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		problem.Write(w, http.StatusNotFound, "route_not_found", "no route for "+r.URL.Path)
+	})
+	router.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		problem.Write(w, http.StatusMethodNotAllowed, "method_not_allowed", r.Method+" is not allowed for "+r.URL.Path)
+	})
+
+	contractMiddleware, err := middleware.ContractMiddleware(api.Spec, APIPrefix, cfg.API.ContractValidation)
+	if err != nil {
+		return err
+	}
+
+	router.Route(APIPrefix, func(router chi.Router) {
+		router.Use(contractMiddleware)
+		registerAPIRoutes(router, dataStore, cfg)
+	})
+
+	// static
+	router.Handle(cfg.BaseURL+"*", http.StripPrefix(cfg.BaseURL, http.FileServer(http.Dir(cfg.ImageStoragePath))))
+	log.Info("registered static route", logger.Field("path", cfg.BaseURL+"*"), logger.Field("method", "GET"))
+
+	return nil
+}
+
+// registerAPIRoutes registers the routes of the API relative to its version prefix.
+func registerAPIRoutes(router chi.Router, dataStore *store.Store, cfg *config.Config) {
+	log := logger.With(logger.Field("component", "http-server"), logger.Field("prefix", APIPrefix))
+
+	// Requests get the timeout of the server. Streaming routes (exports, feeds and direct uploads) send or
+	// receive bodies that may take longer, they get a deadline of their own instead.
+	streaming := router.With(middleware.StreamingMiddleware(cfg.HTTPServer.StreamTimeout))
+	router = router.With(middleware.TimeoutMiddleware(cfg.HTTPServer.TimeoutSeconds))
+
+	// Contract
+	router.Get("/openapi.yaml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/yaml")
+		_, _ = w.Write(api.Spec)
+	})
+	log.Info("registered route", logger.Field("path", "/openapi.yaml"), logger.Field("method", "GET"))
+
 	// Authentication (no middleware required)
-	authHandler := handlers.NewAuthHandler(dataStore.UserService, dataStore.OTPService, dataStore.JWTService, cfg.Auth.OTPLength, cfg.Auth.OTPExpirationMinutes, cfg.Auth.OTPMaxAttempts)
+	authHandler := handlers.NewAuthHandler(dataStore.UserService, dataStore.OTPService, dataStore.JWTService, dataStore.AccountService)
 	router.Post("/auth/send-otp", authHandler.SendOTP)
 	log.Info("registered route", logger.Field("path", "/auth/send-otp"), logger.Field("method", "POST"))
 	router.Post("/auth/verify-otp", authHandler.VerifyOTP)
@@ -37,28 +85,130 @@ func RegisterRoutes(router chi.Router, dataStore *store.Store, cfg *config.Confi
 	log.Info("registered route", logger.Field("path", "/user/profile"), logger.Field("method", "GET"))
 	router.With(authMiddleware).Put("/user/profile", userProfileHandler.UpdateUserProfile)
 	log.Info("registered route", logger.Field("path", "/user/profile"), logger.Field("method", "PUT"))
+	router.With(authMiddleware).Patch("/user/profile", userProfileHandler.PatchUserProfile)
+	log.Info("registered route", logger.Field("path", "/user/profile"), logger.Field("method", "PATCH"))
+
+	// Email and phone changes (confirmed with a code sent to the new address)
+	contactHandler := handlers.NewContactHandler(dataStore.ContactService)
+	router.With(authMiddleware).Post("/user/email", contactHandler.RequestEmailChange)
+	log.Info("registered route", logger.Field("path", "/user/email"), logger.Field("method", "POST"))
+	router.With(authMiddleware).Post("/user/email/confirm", contactHandler.ConfirmEmailChange)
+	log.Info("registered route", logger.Field("path", "/user/email/confirm"), logger.Field("method", "POST"))
+	router.With(authMiddleware).Post("/user/phone", contactHandler.RequestPhoneChange)
+	log.Info("registered route", logger.Field("path", "/user/phone"), logger.Field("method", "POST"))
+	router.With(authMiddleware).Post("/user/phone/confirm", contactHandler.ConfirmPhoneChange)
+	log.Info("registered route", logger.Field("path", "/user/phone/confirm"), logger.Field("method", "POST"))
+
+	// Account deletion (confirmed with a code sent to the email, erased after a grace period)
+	accountHandler := handlers.NewAccountHandler(dataStore.UserService, dataStore.OTPService, dataStore.AccountService)
+	router.With(authMiddleware).Post("/user/account/deletion-code", accountHandler.SendDeletionCode)
+	log.Info("registered route", logger.Field("path", "/user/account/deletion-code"), logger.Field("method", "POST"))
+	router.With(authMiddleware).Delete("/user/account", accountHandler.DeleteAccount)
+	log.Info("registered route", logger.Field("path", "/user/account"), logger.Field("method", "DELETE"))
+
+	// Public routes that personalize the response for signed-in users
+	optionalAuthMiddleware := middleware.OptionalAuth(dataStore.JWTService, "access_token")
 
 	// Advertisements
-	adsHandler := handlers.NewAdvertisementHandlers(dataStore.AdService, dataStore.ImageService)
+	adsHandler := handlers.NewAdvertisementHandlers(dataStore.AdService, dataStore.ImageService, dataStore.UserService, dataStore.StatsService, dataStore.RecommendationService)
 	router.Get("/advertisements", adsHandler.ListAdvertisements)
 	log.Info("registered route", logger.Field("path", "/advertisements"), logger.Field("method", "GET"))
+	router.Get("/amenities", adsHandler.GetAmenities)
+	log.Info("registered route", logger.Field("path", "/amenities"), logger.Field("method", "GET"))
 	router.With(authMiddleware).Post("/advertisements", adsHandler.CreateAdvertisement)
 	log.Info("registered route", logger.Field("path", "/advertisements"), logger.Field("method", "POST"))
-	router.Get("/advertisements/{id}", adsHandler.GetAdvertisement)
+	router.With(optionalAuthMiddleware).Get("/advertisements/{id}", adsHandler.GetAdvertisement)
 	log.Info("registered route", logger.Field("path", "/advertisements/{id}"), logger.Field("method", "GET"))
 	router.With(authMiddleware).Put("/advertisements/{id}", adsHandler.UpdateAdvertisement)
 	log.Info("registered route", logger.Field("path", "/advertisements/{id}"), logger.Field("method", "PUT"))
+	router.With(authMiddleware).Patch("/advertisements/{id}", adsHandler.PatchAdvertisement)
+	log.Info("registered route", logger.Field("path", "/advertisements/{id}"), logger.Field("method", "PATCH"))
+	router.With(authMiddleware).Get("/advertisements/{id}/history", adsHandler.GetAdvertisementHistory)
+	log.Info("registered route", logger.Field("path", "/advertisements/{id}/history"), logger.Field("method", "GET"))
 	router.With(authMiddleware).Delete("/advertisements/{id}", adsHandler.DeleteAdvertisement)
 	log.Info("registered route", logger.Field("path", "/advertisements/{id}"), logger.Field("method", "DELETE"))
+	router.With(authMiddleware).Post("/advertisements/{id}/restore", adsHandler.RestoreAdvertisement)
+	log.Info("registered route", logger.Field("path", "/advertisements/{id}/restore"), logger.Field("method", "POST"))
 	router.With(authMiddleware).Post("/advertisements/{id}/images", adsHandler.AddAdImages)
 	log.Info("registered route", logger.Field("path", "/advertisements/{id}/images"), logger.Field("method", "POST"))
 	router.With(authMiddleware).Delete("/advertisements/{ad_id}/images/{image_id}", adsHandler.DeleteAdImage)
 	log.Info("registered route", logger.Field("path", "/advertisements/{ad_id}/images/{image_id}"), logger.Field("method", "DELETE"))
+	router.With(authMiddleware).Put("/advertisements/{id}/images/order", adsHandler.ReorderAdImages)
+	log.Info("registered route", logger.Field("path", "/advertisements/{id}/images/order"), logger.Field("method", "PUT"))
+	router.With(authMiddleware).Put("/advertisements/{ad_id}/images/{image_id}/cover", adsHandler.SetAdCoverImage)
+	log.Info("registered route", logger.Field("path", "/advertisements/{ad_id}/images/{image_id}/cover"), logger.Field("method", "PUT"))
+	router.With(authMiddleware).Patch("/advertisements/{ad_id}/images/{image_id}", adsHandler.UpdateAdImage)
+	log.Info("registered route", logger.Field("path", "/advertisements/{ad_id}/images/{image_id}"), logger.Field("method", "PATCH"))
 	router.With(authMiddleware).Get("/advertisements/my", adsHandler.GetMyAdvertisements)
 	log.Info("registered route", logger.Field("path", "/advertisements/my"), logger.Field("method", "GET"))
+	router.With(authMiddleware).Get("/advertisements/{id}/stats", adsHandler.GetAdvertisementStats)
+	log.Info("registered route", logger.Field("path", "/advertisements/{id}/stats"), logger.Field("method", "GET"))
+	router.With(authMiddleware).Get("/advertisements/favorites", adsHandler.GetFavorites)
+	log.Info("registered route", logger.Field("path", "/advertisements/favorites"), logger.Field("method", "GET"))
+	router.With(authMiddleware).Post("/advertisements/{id}/favorite", adsHandler.AddFavorite)
+	log.Info("registered route", logger.Field("path", "/advertisements/{id}/favorite"), logger.Field("method", "POST"))
+	router.With(authMiddleware).Delete("/advertisements/{id}/favorite", adsHandler.RemoveFavorite)
+	log.Info("registered route", logger.Field("path", "/advertisements/{id}/favorite"), logger.Field("method", "DELETE"))
+	router.Post("/advertisements/{id}/contact", adsHandler.RevealContacts)
+	log.Info("registered route", logger.Field("path", "/advertisements/{id}/contact"), logger.Field("method", "POST"))
 
-	// static
-	router.Handle(cfg.BaseURL+"*", http.StripPrefix(cfg.BaseURL, http.FileServer(http.Dir(cfg.ImageStoragePath))))
-	log.Info("registered static route", logger.Field("path", cfg.BaseURL+"*"), logger.Field("method", "GET"))
+	// Direct uploads (upload URLs are authorized by their token, not by cookie)
+	uploadHandler := handlers.NewUploadHandler(dataStore.UploadService, APIPrefix)
+	router.With(authMiddleware).Post("/advertisements/{id}/images/uploads", uploadHandler.CreateUploadSession)
+	log.Info("registered route", logger.Field("path", "/advertisements/{id}/images/uploads"), logger.Field("method", "POST"))
+	router.With(authMiddleware).Post("/advertisements/{id}/images/uploads/{session_id}/commit", uploadHandler.CommitUploadSession)
+	log.Info("registered route", logger.Field("path", "/advertisements/{id}/images/uploads/{session_id}/commit"), logger.Field("method", "POST"))
+	streaming.Put("/uploads/{token}", uploadHandler.Upload)
+	log.Info("registered route", logger.Field("path", "/uploads/{token}"), logger.Field("method", "PUT"))
+
+	// Bulk imports (large files are imported by a background job the owner polls)
+	importHandler := handlers.NewImportHandler(dataStore.ImportService, cfg.Imports.MaxFileSize, APIPrefix)
+	router.With(authMiddleware).Post("/advertisements/imports", importHandler.CreateImport)
+	log.Info("registered route", logger.Field("path", "/advertisements/imports"), logger.Field("method", "POST"))
+	router.With(authMiddleware).Get("/advertisements/imports/{id}", importHandler.GetImport)
+	log.Info("registered route", logger.Field("path", "/advertisements/imports/{id}"), logger.Field("method", "GET"))
+
+	// Exports (listing files, public landlord feeds and personal data archives generated in the background)
+	exportHandler := handlers.NewExportHandler(dataStore.ExportService, APIPrefix)
+	streaming.With(authMiddleware).Get("/advertisements/my/export", exportHandler.ExportMyAdvertisements)
+	log.Info("registered route", logger.Field("path", "/advertisements/my/export"), logger.Field("method", "GET"))
+	streaming.Get("/landlords/{id}/feed.xml", exportHandler.GetLandlordFeed)
+	log.Info("registered route", logger.Field("path", "/landlords/{id}/feed.xml"), logger.Field("method", "GET"))
+	router.With(authMiddleware).Post("/user/exports", exportHandler.RequestArchive)
+	log.Info("registered route", logger.Field("path", "/user/exports"), logger.Field("method", "POST"))
+	router.With(authMiddleware).Get("/user/exports/{id}", exportHandler.GetArchive)
+	log.Info("registered route", logger.Field("path", "/user/exports/{id}"), logger.Field("method", "GET"))
+	streaming.With(authMiddleware).Get("/user/exports/{id}/download", exportHandler.DownloadArchive)
+	log.Info("registered route", logger.Field("path", "/user/exports/{id}/download"), logger.Field("method", "GET"))
+
+	// Recommendations (public, recently viewed is kept per user or anonymous visitor)
+	recommendationHandler := handlers.NewRecommendationHandler(dataStore.RecommendationService, cfg.Recommendations.SimilarLimit)
+	router.Get("/advertisements/{id}/similar", recommendationHandler.GetSimilar)
+	log.Info("registered route", logger.Field("path", "/advertisements/{id}/similar"), logger.Field("method", "GET"))
+	router.With(optionalAuthMiddleware).Get("/user/recently-viewed", recommendationHandler.GetRecentlyViewed)
+	log.Info("registered route", logger.Field("path", "/user/recently-viewed"), logger.Field("method", "GET"))
+
+	// Analytics (public)
+	analyticsHandler := handlers.NewAnalyticsHandler(dataStore.AnalyticsService)
+	router.Get("/analytics/cities/{city}", analyticsHandler.GetCityAnalytics)
+	log.Info("registered route", logger.Field("path", "/analytics/cities/{city}"), logger.Field("method", "GET"))
+
+	// Moderation (admin only)
+	adminMiddleware := middleware.RequireAdmin(dataStore.UserService)
+	moderationHandler := handlers.NewModerationHandler(dataStore.ModerationService)
+	router.With(authMiddleware, adminMiddleware).Get("/moderation/queue", moderationHandler.GetQueue)
+	log.Info("registered route", logger.Field("path", "/moderation/queue"), logger.Field("method", "GET"))
+	router.With(authMiddleware, adminMiddleware).Post("/moderation/queue/{id}/resolve", moderationHandler.ResolveItem)
+	log.Info("registered route", logger.Field("path", "/moderation/queue/{id}/resolve"), logger.Field("method", "POST"))
+	router.With(authMiddleware, adminMiddleware).Get("/moderation/advertisements/{id}/duplicates", moderationHandler.GetDuplicateCandidates)
+	log.Info("registered route", logger.Field("path", "/moderation/advertisements/{id}/duplicates"), logger.Field("method", "GET"))
 
+	// Administration (admin only)
+	adminHandler := handlers.NewAdminHandler(dataStore.AdService, dataStore.UserService)
+	router.With(authMiddleware, adminMiddleware).Post("/admin/advertisements/{id}/restore", adminHandler.RestoreAdvertisement)
+	log.Info("registered route", logger.Field("path", "/admin/advertisements/{id}/restore"), logger.Field("method", "POST"))
+	router.With(authMiddleware, adminMiddleware).Delete("/admin/users/{id}", adminHandler.DeleteUser)
+	log.Info("registered route", logger.Field("path", "/admin/users/{id}"), logger.Field("method", "DELETE"))
+	router.With(authMiddleware, adminMiddleware).Post("/admin/users/{id}/restore", adminHandler.RestoreUser)
+	log.Info("registered route", logger.Field("path", "/admin/users/{id}/restore"), logger.Field("method", "POST"))
 }
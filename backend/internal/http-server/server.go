@@ -0,0 +1,18 @@
+package httpserver
+
+import (
+	"net/http"
+	"rentor/internal/config"
+)
+
+// NewServer creates the HTTP server of handler with the address and timeouts of cfg.
+// ReadTimeout and WriteTimeout bound ordinary requests, streaming routes move them, see registerAPIRoutes.
+func NewServer(cfg *config.Config, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:         cfg.HTTPServer.Host + ":" + cfg.HTTPServer.Port,
+		Handler:      handler,
+		ReadTimeout:  cfg.HTTPServer.TimeoutSeconds,
+		WriteTimeout: cfg.HTTPServer.TimeoutSeconds,
+		IdleTimeout:  cfg.HTTPServer.IdleTimeoutSeconds,
+	}
+}
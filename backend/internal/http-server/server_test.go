@@ -0,0 +1,373 @@
+package httpserver_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"rentor/api"
+	"rentor/internal/config"
+	httpserver "rentor/internal/http-server"
+	"rentor/internal/http-server/middleware"
+	"rentor/internal/storage"
+	"rentor/internal/storage/storagetest"
+	"rentor/internal/store"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// testServer is the router of the API, with the contract middleware logging, on top of a migrated SQLite database.
+// Every request sent through it is checked against api/swagger.yaml.
+type testServer struct {
+	router   *chi.Mux
+	db       *storage.DB
+	store    *store.Store
+	cfg      *config.Config
+	contract *middleware.Contract
+	mail     *mailbox
+	served   map[string]bool // "METHOD pattern" of the routes requests were sent to
+}
+
+// configure functions change the loaded configuration before the routes are registered.
+func newTestServer(t *testing.T, configure ...func(cfg *config.Config)) *testServer {
+	t.Helper()
+	cfg := testConfig(t)
+	mail := newMailbox(t, cfg)
+	for _, fn := range configure {
+		fn(cfg)
+	}
+	db := storagetest.OpenSQLite(t, cfg.SQLite)
+	dataStore := store.NewStore(db, cfg)
+
+	router := chi.NewRouter()
+	if err := httpserver.RegisterRoutes(router, dataStore, cfg); err != nil {
+		t.Fatalf("register routes: %v", err)
+	}
+
+	contract, err := middleware.NewContract(api.Spec, httpserver.APIPrefix)
+	if err != nil {
+		t.Fatalf("load contract: %v", err)
+	}
+
+	return &testServer{router: router, db: db, store: dataStore, cfg: cfg, contract: contract, mail: mail, served: map[string]bool{}}
+}
+
+// testConfig loads a configuration with the defaults of LoadConfig and every path in a temporary directory
+func testConfig(t *testing.T) *config.Config {
+	t.Helper()
+	dir := t.TempDir()
+	yaml := fmt.Sprintf(`
+env: "local"
+storage_path: %[1]q
+image_storage_path: %[2]q
+base_url: "/static/"
+api:
+  contract_validation: "log"
+auth:
+  jwt_secret: "test-secret-test-secret-test-secret-test"
+  access_token_ttl: 15m
+  refresh_token_ttl: 168h
+  otp_length: 6
+  otp_expiration_minutes: 10
+  otp_max_attempts: 5
+smtp:
+  smtp_from: "noreply@example.com"
+  smtp_password: "password"
+  smtp_host: "localhost"
+  smtp_port: "1025"
+uploads:
+  staging_path: %[3]q
+exports:
+  path: %[4]q
+  site_url: "https://rentor.example.com"
+`,
+		filepath.Join(dir, "storage.db"),
+		filepath.Join(dir, "images"),
+		filepath.Join(dir, "uploads"),
+		filepath.Join(dir, "exports"),
+	)
+
+	// the image directory is created on deployment
+	if err := os.Mkdir(filepath.Join(dir, "images"), 0o755); err != nil {
+		t.Fatalf("create image directory: %v", err)
+	}
+
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	t.Setenv("CONFIG_PATH", path)
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	return cfg
+}
+
+// login creates a user with email and returns their access token
+func (s *testServer) login(t *testing.T, email string) string {
+	t.Helper()
+	user, err := s.store.UserService.FindOrCreateUserByEmail(context.Background(), email)
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	token, err := s.store.JWTService.GenerateAccessToken(user.UserID, email)
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+	return token
+}
+
+// request builds a request to the API, body is sent as JSON (merge patch for PATCH) unless it is a string
+func request(t *testing.T, method, path, token string, body any) *http.Request {
+	t.Helper()
+	var reader io.Reader
+	contentType := "application/json"
+	switch b := body.(type) {
+	case nil:
+	case string:
+		reader = bytes.NewBufferString(b)
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			t.Fatalf("marshal body: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	r := httptest.NewRequest(method, httpserver.APIPrefix+path, reader)
+	if reader != nil {
+		if method == http.MethodPatch {
+			contentType = "application/merge-patch+json"
+		}
+		r.Header.Set("Content-Type", contentType)
+	}
+	if token != "" {
+		r.AddCookie(&http.Cookie{Name: "access_token", Value: token})
+	}
+	return r
+}
+
+// do sends r through the router and fails the test if the request or the response violates the contract
+func (s *testServer) do(t *testing.T, r *http.Request) *httptest.ResponseRecorder {
+	t.Helper()
+	return s.serve(t, r, true)
+}
+
+// doInvalid sends r, which the contract must reject, and checks that the response still matches it
+func (s *testServer) doInvalid(t *testing.T, r *http.Request) *httptest.ResponseRecorder {
+	t.Helper()
+	return s.serve(t, r, false)
+}
+
+func (s *testServer) serve(t *testing.T, r *http.Request, valid bool) *httptest.ResponseRecorder {
+	t.Helper()
+	op, ok := s.contract.Match(r)
+	if !ok {
+		t.Errorf("%s %s is not described by api/swagger.yaml", r.Method, r.URL.Path)
+	} else {
+		err := op.ValidateRequest(r.Context())
+		switch {
+		case valid && err != nil:
+			t.Errorf("%s %s: request violates the contract: %v", r.Method, r.URL.Path, err)
+		case !valid && err == nil:
+			t.Errorf("%s %s: the contract accepts an invalid request", r.Method, r.URL.Path)
+		}
+	}
+
+	s.served[r.Method+" "+s.router.Find(chi.NewRouteContext(), r.Method, r.URL.Path)] = true
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, r)
+
+	if ok {
+		if err := op.ValidateResponse(context.Background(), w.Code, w.Header(), w.Body.Bytes()); err != nil {
+			t.Errorf("%s %s: %d response violates the contract: %v\n%s", r.Method, r.URL.Path, w.Code, err, w.Body.String())
+		}
+	}
+	return w
+}
+
+// expectAllRoutesServed fails the test for every route of the API no request was sent to through do or doInvalid
+func (s *testServer) expectAllRoutesServed(t *testing.T) {
+	t.Helper()
+	err := chi.Walk(s.router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if strings.HasPrefix(route, httpserver.APIPrefix+"/") && !s.served[method+" "+route] {
+			t.Errorf("%s %s is not checked against the contract", method, route)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk routes: %v", err)
+	}
+}
+
+// mailbox receives the messages the API sends: emails through a fake SMTP server and text messages
+// through a fake SMS gateway, both configured in cfg
+type mailbox struct {
+	mu       sync.Mutex
+	messages map[string]string // recipient -> last message
+}
+
+func newMailbox(t *testing.T, cfg *config.Config) *mailbox {
+	t.Helper()
+	m := &mailbox{messages: map[string]string{}}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go m.serveSMTP(conn)
+		}
+	}()
+	cfg.SMTP.SMTPHost = "127.0.0.1"
+	cfg.SMTP.SMTPPort = strconv.Itoa(ln.Addr().(*net.TCPAddr).Port)
+
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var sms struct{ To, Text string }
+		if err := json.NewDecoder(r.Body).Decode(&sms); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		m.deliver(sms.To, sms.Text)
+	}))
+	t.Cleanup(gateway.Close)
+	cfg.SMS.GatewayURL = gateway.URL
+
+	return m
+}
+
+// serveSMTP accepts the messages of one SMTP session, just enough of the protocol for net/smtp
+func (m *mailbox) serveSMTP(conn net.Conn) {
+	tp := textproto.NewConn(conn)
+	defer tp.Close()
+
+	_ = tp.PrintfLine("220 localhost")
+	var recipients []string
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			_ = tp.PrintfLine("250-localhost\r\n250 AUTH PLAIN")
+		case "AUTH":
+			_ = tp.PrintfLine("235 authenticated")
+		case "MAIL":
+			recipients = nil
+			_ = tp.PrintfLine("250 ok")
+		case "RCPT":
+			recipients = append(recipients, strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>"))
+			_ = tp.PrintfLine("250 ok")
+		case "DATA":
+			_ = tp.PrintfLine("354 go ahead")
+			body, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			for _, to := range recipients {
+				m.deliver(to, string(body))
+			}
+			_ = tp.PrintfLine("250 ok")
+		case "QUIT":
+			_ = tp.PrintfLine("221 bye")
+			return
+		default:
+			_ = tp.PrintfLine("250 ok")
+		}
+	}
+}
+
+func (m *mailbox) deliver(to, message string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages[to] = message
+}
+
+// otpPattern matches the codes of the test configuration (auth.otp_length)
+var otpPattern = regexp.MustCompile(`\b\d{6}\b`)
+
+// code returns the code of the last message sent to recipient
+func (m *mailbox) code(t *testing.T, recipient string) string {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	code := otpPattern.FindString(m.messages[recipient])
+	if code == "" {
+		t.Fatalf("no code was sent to %s", recipient)
+	}
+	return code
+}
+
+// pngImage encodes a small PNG image
+func pngImage(t *testing.T) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for x := range 16 {
+		img.Set(x, x, color.White)
+	}
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return b.Bytes()
+}
+
+// otherPNGImage encodes a small PNG image that doesn't look like pngImage
+func otherPNGImage(t *testing.T) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for y := range 16 {
+		for x := range 8 {
+			img.Set(x, y, color.White)
+		}
+	}
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return b.Bytes()
+}
+
+// expectStatus fails the test if w has another status
+func expectStatus(t *testing.T, w *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("status = %d, want %d\n%s", w.Code, status, w.Body.String())
+	}
+}
+
+// decode decodes the JSON body of w
+func decode[T any](t *testing.T, w *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+		t.Fatalf("decode response: %v\n%s", err, w.Body.String())
+	}
+	return v
+}
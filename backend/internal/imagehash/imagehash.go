@@ -0,0 +1,99 @@
+package imagehash
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"math/bits"
+	"strconv"
+
+	// decoders for the image formats accepted by uploads
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+)
+
+// hashWidth x hashHeight is the size of the grayscale thumbnail used by dHash
+const (
+	hashWidth  = 9
+	hashHeight = 8
+)
+
+// DHash computes a 64-bit difference hash of the image read from r.
+// Visually similar images (re-encoded, resized, slightly recolored) get hashes
+// with a small Hamming distance.
+func DHash(r io.Reader) (uint64, error) {
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return 0, fmt.Errorf("DHash: failed to decode image: %w", err)
+	}
+
+	gray := thumbnail(img)
+
+	var hash uint64
+	for y := 0; y < hashHeight; y++ {
+		for x := 0; x < hashWidth-1; x++ {
+			hash <<= 1
+			if gray[y][x] < gray[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash, nil
+}
+
+// Distance returns the Hamming distance between two hashes
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// Format encodes a hash as a fixed-width hex string for storage
+func Format(hash uint64) string {
+	return fmt.Sprintf("%016x", hash)
+}
+
+// Parse decodes a hash produced by Format
+func Parse(s string) (uint64, error) {
+	return strconv.ParseUint(s, 16, 64)
+}
+
+// thumbnail shrinks img to hashWidth x hashHeight grayscale pixels by averaging source blocks
+func thumbnail(img image.Image) [hashHeight][hashWidth]float64 {
+	var out [hashHeight][hashWidth]float64
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	for ty := 0; ty < hashHeight; ty++ {
+		y0 := b.Min.Y + ty*h/hashHeight
+		y1 := b.Min.Y + (ty+1)*h/hashHeight
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for tx := 0; tx < hashWidth; tx++ {
+			x0 := b.Min.X + tx*w/hashWidth
+			x1 := b.Min.X + (tx+1)*w/hashWidth
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var sum float64
+			var n int
+			for y := y0; y < y1 && y < b.Max.Y; y++ {
+				for x := x0; x < x1 && x < b.Max.X; x++ {
+					r, g, bl, _ := img.At(x, y).RGBA()
+					// ITU-R 601 luma
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)
+					n++
+				}
+			}
+			if n > 0 {
+				out[ty][tx] = sum / float64(n)
+			}
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,162 @@
+package imagehash_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/draw"
+
+	"rentor/internal/imagehash"
+)
+
+// maxDistance is the default moderation.photo_max_distance
+const maxDistance = 6
+
+// scene draws a 320x240 picture with a gradient and a few rectangles placed by seed
+func scene(seed int64) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 320, 240))
+	for y := range 240 {
+		for x := range 320 {
+			img.Set(x, y, color.RGBA{R: uint8(x * 255 / 320), G: uint8(y * 255 / 240), B: 128, A: 255})
+		}
+	}
+
+	rnd := rand.New(rand.NewSource(seed))
+	for range 6 {
+		x, y := rnd.Intn(260), rnd.Intn(180)
+		r := image.Rect(x, y, x+20+rnd.Intn(100), y+20+rnd.Intn(80))
+		c := color.RGBA{R: uint8(rnd.Intn(256)), G: uint8(rnd.Intn(256)), B: uint8(rnd.Intn(256)), A: 255}
+		draw.Draw(img, r, image.NewUniform(c), image.Point{}, draw.Src)
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return b.Bytes()
+}
+
+func encodeJPEG(t *testing.T, img image.Image, quality int) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	if err := jpeg.Encode(&b, img, &jpeg.Options{Quality: quality}); err != nil {
+		t.Fatalf("encode jpeg: %v", err)
+	}
+	return b.Bytes()
+}
+
+func resize(img image.Image, w, h int) image.Image {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Src, nil)
+	return dst
+}
+
+// brighten adds delta to every channel
+func brighten(img *image.RGBA, delta uint8) image.Image {
+	out := image.NewRGBA(img.Bounds())
+	for i, v := range img.Pix {
+		if i%4 == 3 {
+			out.Pix[i] = v
+			continue
+		}
+		out.Pix[i] = uint8(min(int(v)+int(delta), 255))
+	}
+	return out
+}
+
+// mirror flips img horizontally
+func mirror(img image.Image) image.Image {
+	b := img.Bounds()
+	out := image.NewRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			out.Set(b.Max.X-1-(x-b.Min.X), y, img.At(x, y))
+		}
+	}
+	return out
+}
+
+func TestDHash(t *testing.T) {
+	photo := scene(1)
+	original := encodePNG(t, photo)
+
+	tests := []struct {
+		name    string
+		image   []byte
+		similar bool
+	}{
+		{"identical", encodePNG(t, photo), true},
+		{"re-encoded as JPEG", encodeJPEG(t, photo, 75), true},
+		{"re-encoded as low quality JPEG", encodeJPEG(t, photo, 30), true},
+		{"resized down", encodePNG(t, resize(photo, 160, 120)), true},
+		{"resized up", encodeJPEG(t, resize(photo, 640, 480), 90), true},
+		{"thumbnail", encodeJPEG(t, resize(photo, 80, 60), 80), true},
+		{"brightened", encodePNG(t, brighten(photo, 20)), true},
+		{"different photo", encodePNG(t, scene(2)), false},
+		{"another different photo", encodeJPEG(t, scene(3), 75), false},
+		{"mirrored", encodePNG(t, mirror(photo)), false},
+	}
+
+	want, err := imagehash.DHash(bytes.NewReader(original))
+	if err != nil {
+		t.Fatalf("DHash of the original: %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := imagehash.DHash(bytes.NewReader(tt.image))
+			if err != nil {
+				t.Fatalf("DHash: %v", err)
+			}
+			d := imagehash.Distance(want, got)
+			if tt.similar && d > maxDistance {
+				t.Errorf("distance = %d, want at most %d", d, maxDistance)
+			}
+			if !tt.similar && d <= maxDistance {
+				t.Errorf("distance = %d, want more than %d", d, maxDistance)
+			}
+		})
+	}
+}
+
+func TestDHashIdenticalImagesHashEqual(t *testing.T) {
+	a, err := imagehash.DHash(bytes.NewReader(encodePNG(t, scene(1))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := imagehash.DHash(bytes.NewReader(encodePNG(t, scene(1))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != b {
+		t.Errorf("hashes of identical images differ: %s and %s", imagehash.Format(a), imagehash.Format(b))
+	}
+}
+
+func TestDHashRejectsNonImages(t *testing.T) {
+	if _, err := imagehash.DHash(strings.NewReader("not an image")); err == nil {
+		t.Error("DHash accepted a text file")
+	}
+}
+
+func TestFormatParse(t *testing.T) {
+	for _, hash := range []uint64{0, 1, 0xdeadbeef, 1<<64 - 1} {
+		s := imagehash.Format(hash)
+		if len(s) != 16 {
+			t.Errorf("Format(%d) = %q, want 16 hex digits", hash, s)
+		}
+		got, err := imagehash.Parse(s)
+		if err != nil || got != hash {
+			t.Errorf("Parse(%q) = %d, %v, want %d", s, got, err, hash)
+		}
+	}
+}
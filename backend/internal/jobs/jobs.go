@@ -0,0 +1,38 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"rentor/internal/logger"
+)
+
+// Every runs fn in the background every interval until ctx is cancelled.
+// fn receives ctx, so a run in progress is cancelled on shutdown.
+// A non-positive interval disables the job.
+func Every(ctx context.Context, name string, interval time.Duration, fn func(ctx context.Context) error) {
+	log := logger.With(logger.Field("component", "jobs"), logger.Field("job", name))
+
+	if interval <= 0 {
+		log.Warn("job disabled: interval is not set")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := fn(ctx); err != nil {
+					log.Error("job failed", logger.Field("error", err.Error()))
+				}
+			}
+		}
+	}()
+
+	log.Info("job scheduled", logger.Field("interval", interval.String()))
+}
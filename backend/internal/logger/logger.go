@@ -34,6 +34,11 @@ func InitLogger(env string) error {
 	return nil
 }
 
+// InitNop initializes a logger that discards everything, used by tests
+func InitNop() {
+	logger = zap.NewNop()
+}
+
 // Logging functions
 func Info(msg string, fields ...zap.Field) {
 	logger.Info(msg, fields...)
@@ -0,0 +1,89 @@
+// Package mergepatch applies JSON Merge Patch documents (RFC 7396) to Go values.
+//
+// A patch is a JSON object with the members to change: a member replaces the current value,
+// null removes it and a nested object is merged recursively. Members that are left out keep
+// their current values.
+package mergepatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+
+	"rentor/internal/apperr"
+)
+
+// ContentType is the media type of merge patch request bodies
+const ContentType = "application/merge-patch+json"
+
+// ErrInvalidPatch is returned for patches that are not a JSON object or don't fit the patched value
+var ErrInvalidPatch = apperr.Invalid("invalid_patch", "the patch is not a valid JSON merge patch for this resource")
+
+// Apply applies patch to target, a pointer to a struct. Removed members get the zero value of their field,
+// members that don't match a field of target are rejected.
+func Apply(target any, patch []byte) error {
+	p, err := decode(patch)
+	if err != nil {
+		return ErrInvalidPatch.Wrap(err)
+	}
+	if _, ok := p.(map[string]any); !ok {
+		return ErrInvalidPatch
+	}
+
+	current, err := json.Marshal(target)
+	if err != nil {
+		return err
+	}
+	doc, err := decode(current)
+	if err != nil {
+		return err
+	}
+
+	merged, err := json.Marshal(merge(doc, p))
+	if err != nil {
+		return err
+	}
+
+	// the target is decoded from scratch, so removed members don't keep their old values
+	v := reflect.ValueOf(target).Elem()
+	v.Set(reflect.Zero(v.Type()))
+
+	dec := json.NewDecoder(bytes.NewReader(merged))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(target); err != nil {
+		return ErrInvalidPatch.Wrap(err)
+	}
+	return nil
+}
+
+// merge is the MergePatch function of RFC 7396
+func merge(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = make(map[string]any, len(p))
+	}
+	for name, value := range p {
+		if value == nil {
+			delete(t, name)
+			continue
+		}
+		t[name] = merge(t[name], value)
+	}
+	return t
+}
+
+// decode unmarshals JSON keeping numbers as written, so large integers survive the round trip
+func decode(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
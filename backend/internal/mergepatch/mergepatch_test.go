@@ -0,0 +1,125 @@
+package mergepatch_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"rentor/internal/mergepatch"
+	"rentor/internal/models"
+)
+
+type address struct {
+	City   string  `json:"city"`
+	Street *string `json:"street,omitempty"`
+}
+
+type listing struct {
+	Title     string   `json:"title"`
+	Note      *string  `json:"note"`
+	Price     int64    `json:"price"`
+	Tags      []string `json:"tags"`
+	Address   address  `json:"address"`
+	Published bool     `json:"published"`
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func current() listing {
+	return listing{
+		Title:   "Flat",
+		Note:    strPtr("quiet"),
+		Price:   25000000,
+		Tags:    []string{"elevator", "parking"},
+		Address: address{City: "Almaty", Street: strPtr("Abay 10")},
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+		want  func(l *listing)
+	}{
+		{"empty patch keeps everything", `{}`, func(*listing) {}},
+		{"member replaces the value", `{"title": "House", "published": true}`, func(l *listing) {
+			l.Title, l.Published = "House", true
+		}},
+		{"null removes a field", `{"note": null, "tags": null}`, func(l *listing) {
+			l.Note, l.Tags = nil, nil
+		}},
+		{"nested object is merged", `{"address": {"street": "Dostyk 5"}}`, func(l *listing) {
+			l.Address.Street = strPtr("Dostyk 5")
+		}},
+		{"null removes a nested field", `{"address": {"street": null}}`, func(l *listing) {
+			l.Address.Street = nil
+		}},
+		{"arrays are replaced whole", `{"tags": ["pool"]}`, func(l *listing) {
+			l.Tags = []string{"pool"}
+		}},
+		{"empty array clears the list", `{"tags": []}`, func(l *listing) {
+			l.Tags = []string{}
+		}},
+		{"large integers are exact", `{"price": 9007199254740993}`, func(l *listing) {
+			l.Price = 9007199254740993
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := current()
+			if err := mergepatch.Apply(&got, []byte(tt.patch)); err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			want := current()
+			tt.want(&want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestApplyRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+	}{
+		{"unknown field", `{"colour": "red"}`},
+		{"unknown nested field", `{"address": {"zip": "050000"}}`},
+		{"wrong type", `{"price": "free"}`},
+		{"fraction for an integer", `{"price": 1.5}`},
+		{"object for an array", `{"tags": {"a": 1}}`},
+		{"array document", `[{"title": "House"}]`},
+		{"null document", `null`},
+		{"string document", `"House"`},
+		{"malformed JSON", `{"title": `},
+		{"empty body", ``},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := current()
+			err := mergepatch.Apply(&got, []byte(tt.patch))
+			if !errors.Is(err, mergepatch.ErrInvalidPatch) {
+				t.Errorf("Apply: err = %v, want %v", err, mergepatch.ErrInvalidPatch)
+			}
+		})
+	}
+}
+
+// TestApplyRejectsImmutableFields checks that fields the API returns but doesn't let clients change can't be patched
+func TestApplyRejectsImmutableFields(t *testing.T) {
+	for _, patch := range []string{`{"id": 7}`, `{"userId": 2}`, `{"version": 9}`, `{"createdAt": "2026-01-01T00:00:00Z"}`} {
+		ad := models.UpdateAdvertisementInput{Title: "Flat"}
+		if err := mergepatch.Apply(&ad, []byte(patch)); !errors.Is(err, mergepatch.ErrInvalidPatch) {
+			t.Errorf("advertisement %s: err = %v, want %v", patch, err, mergepatch.ErrInvalidPatch)
+		}
+	}
+	for _, patch := range []string{`{"user_id": 2}`, `{"email": "other@example.com"}`, `{"version": 9}`} {
+		profile := models.UpdateUserProfileInput{FirstName: strPtr("Ivan")}
+		if err := mergepatch.Apply(&profile, []byte(patch)); !errors.Is(err, mergepatch.ErrInvalidPatch) {
+			t.Errorf("profile %s: err = %v, want %v", patch, err, mergepatch.ErrInvalidPatch)
+		}
+	}
+}
@@ -1,90 +1,174 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"rentor/internal/money"
+)
+
+// Periods a rent price is set for
+const (
+	PricePeriodDay   = "day"
+	PricePeriodMonth = "month"
+)
+
+// Furnishing of a property
+const (
+	FurnishingNone    = "none"
+	FurnishingPartial = "partial"
+	FurnishingFull    = "full"
+)
+
+// Categories of the amenity catalog
+const (
+	AmenityCategoryBuilding   = "building"   // elevator, parking, concierge
+	AmenityCategoryComfort    = "comfort"    // internet, air conditioning, balcony
+	AmenityCategoryAppliances = "appliances" // washing machine, fridge, dishwasher
+)
+
+// Amenity is an entry of the amenity catalog, advertisements refer to amenities by code
+type Amenity struct {
+	Code     string `json:"code"`
+	Category string `json:"category"`
+	Title    string `json:"title"`
+}
+
+// AmenityCatalog lists all amenities in display order
+type AmenityCatalog struct {
+	Items []Amenity `json:"items"`
+}
+
+// AdAttributes describes the property beyond its type and size, every attribute is optional
+type AdAttributes struct {
+	Floor             *int          `json:"floor"`
+	FloorsTotal       *int          `json:"floorsTotal"`
+	Furnishing        *string       `json:"furnishing"`        // none|partial|full
+	PetsAllowed       *bool         `json:"petsAllowed"`       // nil if the landlord didn't say
+	UtilitiesIncluded *bool         `json:"utilitiesIncluded"` // utilities are included in the price
+	Deposit           *money.Amount `json:"deposit"`           // security deposit in the currency of the price
+	Amenities         []string      `json:"amenities"`         // codes of the amenity catalog
+}
 
 // Advertisement represents an advertisement in the system
 type Advertisement struct {
-	ID          int       `json:"id"`
-	UserID      int       `json:"user_id"`
-	Title       string    `json:"title"`
-	Description *string   `json:"description"`
-	Price       float64   `json:"price"`
-	Type        string    `json:"type"`
-	Rooms       string    `json:"rooms"`
-	City        string    `json:"city"`
-	Address     string    `json:"address"`
-	Latitude    *float64  `json:"latitude"`
-	Longitude   *float64  `json:"longitude"`
-	Square      float64   `json:"square"`
-	Status      string    `json:"status"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          int          `json:"id"`
+	UserID      int          `json:"user_id"`
+	Title       string       `json:"title"`
+	Description *string      `json:"description"`
+	Price       money.Amount `json:"price"`
+	Currency    string       `json:"currency"`    // ISO 4217
+	PricePeriod string       `json:"pricePeriod"` // day|month
+	Type        string       `json:"type"`
+	Rooms       string       `json:"rooms"`
+	City        string       `json:"city"`
+	Address     string       `json:"address"`
+	Latitude    *float64     `json:"latitude"`
+	Longitude   *float64     `json:"longitude"`
+	Square      float64      `json:"square"`
+	Status      string       `json:"status"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
 }
 
 // CreateAdvertisementInput input data for creating an advertisement
 type CreateAdvertisementInput struct {
-	Title       string   `json:"title"`
-	Description *string  `json:"description"`
-	Price       float64  `json:"price"`
-	Type        string   `json:"type"`
-	Rooms       string   `json:"rooms"`
-	City        string   `json:"city"`
-	Address     string   `json:"address"`
-	Latitude    *float64 `json:"latitude"`
-	Longitude   *float64 `json:"longitude"`
-	Square      float64  `json:"square"`
+	Title       string       `json:"title"`
+	Description *string      `json:"description"`
+	Price       money.Amount `json:"price"`
+	Currency    string       `json:"currency"`    // ISO 4217, the default currency if empty
+	PricePeriod string       `json:"pricePeriod"` // day|month, month if empty
+	Type        string       `json:"type"`
+	Rooms       string       `json:"rooms"`
+	City        string       `json:"city"`
+	Address     string       `json:"address"`
+	Latitude    *float64     `json:"latitude"`
+	Longitude   *float64     `json:"longitude"`
+	Square      float64      `json:"square"`
+	AdAttributes
+
+	ExternalID *string `json:"-"` // ID in the system the listing was imported from
 }
 
 // UpdateAdvertisementInput input data for updating an advertisement
 type UpdateAdvertisementInput struct {
-	Title       string   `json:"title"`
-	Description *string  `json:"description"`
-	Price       float64  `json:"price"`
-	Type        string   `json:"type"`
-	Rooms       string   `json:"rooms"`
-	City        string   `json:"city"`
-	Address     string   `json:"address"`
-	Latitude    *float64 `json:"latitude"`
-	Longitude   *float64 `json:"longitude"`
-	Square      float64  `json:"square"`
-	Status      string   `json:"status"`
+	Title       string       `json:"title"`
+	Description *string      `json:"description"`
+	Price       money.Amount `json:"price"`
+	Currency    string       `json:"currency"`    // ISO 4217, the current currency if empty
+	PricePeriod string       `json:"pricePeriod"` // day|month, the current period if empty
+	Type        string       `json:"type"`
+	Rooms       string       `json:"rooms"`
+	City        string       `json:"city"`
+	Address     string       `json:"address"`
+	Latitude    *float64     `json:"latitude"`
+	Longitude   *float64     `json:"longitude"`
+	Square      float64      `json:"square"`
+	AdAttributes
+	Status string `json:"status"`
+}
+
+// AdvertisementState is the editable state of an advertisement together with its version
+type AdvertisementState struct {
+	UpdateAdvertisementInput
+	Version int
 }
 
 type GetAd struct {
-	ID          int      `json:"id"`
-	Title       string   `json:"title"`
-	Description *string  `json:"description"`
-	Price       float64  `json:"price"`
-	Type        string   `json:"type"`
-	Rooms       string   `json:"rooms"`
-	City        string   `json:"city"`
-	Address     string   `json:"address"`
-	Latitude    *float64 `json:"latitude"`
-	Longitude   *float64 `json:"longitude"`
-	Square      float64  `json:"square"`
-	Status      string   `json:"status"`
+	ID          int          `json:"id"`
+	Title       string       `json:"title"`
+	Description *string      `json:"description"`
+	Price       money.Amount `json:"price"`
+	Currency    string       `json:"currency"`
+	PricePeriod string       `json:"pricePeriod"`
+	Type        string       `json:"type"`
+	Rooms       string       `json:"rooms"`
+	City        string       `json:"city"`
+	Address     string       `json:"address"`
+	Latitude    *float64     `json:"latitude"`
+	Longitude   *float64     `json:"longitude"`
+	Square      float64      `json:"square"`
+	Status      string       `json:"status"`
+	AdAttributes
+
+	// последнее изменение цены и бейдж снижения цены
+	PreviousPrice  *money.Amount `json:"previousPrice,omitempty"`
+	PriceChangedAt *time.Time    `json:"priceChangedAt,omitempty"`
+	PriceDropped   bool          `json:"priceDropped"`
 
 	LandlordName  *string `json:"landlordName"`
 	LandlordEmail string  `json:"landlordEmail"`
 	LandlordPhone *string `json:"landlordPhone"`
 
 	ImageUrls []*ImageUrl `json:"imageUrls"`
+
+	Version int `json:"-"` // sent as the ETag header
 }
 
 type ImageUrl struct {
-	ImageId  int    `json:"imageId"`
-	ImageUrl string `json:"imageUrl"`
+	ImageId  int     `json:"imageId"`
+	ImageUrl string  `json:"imageUrl"`
+	Position int     `json:"position"`
+	IsCover  bool    `json:"isCover"`
+	Caption  *string `json:"caption"`
 }
 
 type AdPreview struct {
-	ID       int       `json:"id"`
-	Title    string    `json:"title"`
-	City     string    `json:"city"`
-	Price    float64   `json:"price"`
-	Type     string    `json:"type"`
-	Rooms    string    `json:"rooms"`
-	Square   float64   `json:"square"`
-	ImageUrl *ImageUrl `json:"imageUrl"` // первое фото
+	ID          int          `json:"id"`
+	Title       string       `json:"title"`
+	City        string       `json:"city"`
+	Price       money.Amount `json:"price"`
+	Currency    string       `json:"currency"`
+	PricePeriod string       `json:"pricePeriod"`
+	Type        string       `json:"type"`
+	Rooms       string       `json:"rooms"`
+	Square      float64      `json:"square"`
+	ImageUrl    *ImageUrl    `json:"imageUrl"` // обложка объявления
+
+	PreviousPrice  *money.Amount `json:"previousPrice,omitempty"`  // цена до последнего изменения
+	PriceChangedAt *time.Time    `json:"priceChangedAt,omitempty"` // когда цена менялась последний раз
+	PriceDropped   bool          `json:"priceDropped"`             // цена недавно снижена (бейдж)
+
+	Stats *AdvertisementCounters `json:"stats,omitempty"` // статистика за период дашборда, только в /advertisements/my
 }
 
 type GetAdPreviewsList struct {
@@ -92,21 +176,90 @@ type GetAdPreviewsList struct {
 	Page  int         `json:"page"`
 	Limit int         `json:"limit"`
 	Items []AdPreview `json:"items"`
+
+	Summary *LandlordDashboard `json:"summary,omitempty"` // сводка по всем объявлениям, только в /advertisements/my
 }
 
 type AdFilters struct {
-	Page     int      `json:"page"`
-	Limit    int      `json:"limit"`
-	MinPrice *float64 `json:"minPrice,omitempty"`
-	MaxPrice *float64 `json:"maxPrice,omitempty"`
-	Type     *string  `json:"type,omitempty"`
-	Rooms    *string  `json:"rooms,omitempty"`
-	City     *string  `json:"city,omitempty"`
-	Keywords *string  `json:"keywords,omitempty"`
-	UserID   *int     `json:"userId,omitempty"` // нужно для /advertisements/my
+	Page        int           `json:"page"`
+	Limit       int           `json:"limit"`
+	MinPrice    *money.Amount `json:"minPrice,omitempty"`
+	MaxPrice    *money.Amount `json:"maxPrice,omitempty"`
+	Currency    *string       `json:"currency,omitempty"`    // валюта minPrice/maxPrice, по умолчанию — основная
+	PricePeriod *string       `json:"pricePeriod,omitempty"` // day|month
+	Type        *string       `json:"type,omitempty"`
+	Rooms       *string       `json:"rooms,omitempty"`
+	City        *string       `json:"city,omitempty"`
+	Keywords    *string       `json:"keywords,omitempty"`
+
+	// характеристики: объявление должно иметь все перечисленные удобства
+	Amenities         []string `json:"amenities,omitempty"`
+	PetsAllowed       *bool    `json:"petsAllowed,omitempty"`
+	UtilitiesIncluded *bool    `json:"utilitiesIncluded,omitempty"`
+	Furnishing        *string  `json:"furnishing,omitempty"`
+	MinFloor          *int     `json:"minFloor,omitempty"`
+	MaxFloor          *int     `json:"maxFloor,omitempty"`
+
+	UserID      *int `json:"userId,omitempty"` // нужно для /advertisements/my
+	FavoritesOf *int `json:"-"`                // только избранное пользователя (/advertisements/favorites)
+
+	// границы цены в валютах объявлений, считает сервис по курсам из конфига
+	PriceBounds []PriceBound `json:"-"`
+}
+
+// PriceBound границы цены фильтра, пересчитанные в одну валюту объявлений
+type PriceBound struct {
+	Currency string
+	Min      *money.Amount
+	Max      *money.Amount
+}
+
+// ReorderImagesInput new order of advertisement photos (all photo IDs of the advertisement)
+type ReorderImagesInput struct {
+	ImageIDs []int `json:"imageIds"`
+}
+
+// UpdateImageInput input data for updating a single advertisement photo
+type UpdateImageInput struct {
+	Caption *string `json:"caption"`
 }
 
 type ImagesUploadResponse struct {
 	Uploaded []string `json:"uploaded"`
 	Count    int      `json:"count"`
 }
+
+// DeletedAdvertisement is a soft-deleted advertisement waiting to be restored or purged
+type DeletedAdvertisement struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"userId"`
+	DeletedAt time.Time `json:"deletedAt"`
+}
+
+// DeleteAdvertisementResponse is returned when an advertisement is deleted,
+// the owner can undo the deletion until RestoreUntil
+type DeleteAdvertisementResponse struct {
+	Status       string    `json:"status"`
+	RestoreUntil time.Time `json:"restoreUntil"`
+}
+
+// AdvertisementFieldChange is one changed field in a version of an advertisement
+type AdvertisementFieldChange struct {
+	Field    string  `json:"field"`
+	OldValue *string `json:"oldValue"`
+	NewValue *string `json:"newValue"`
+}
+
+// AdvertisementVersion is one update of an advertisement
+type AdvertisementVersion struct {
+	Version   int                        `json:"version"`
+	ChangedBy *int                       `json:"changedBy"` // nil if the user has been purged
+	ChangedAt time.Time                  `json:"changedAt"`
+	Changes   []AdvertisementFieldChange `json:"changes"`
+}
+
+// AdvertisementHistory is the change history of an advertisement, newest version first
+type AdvertisementHistory struct {
+	AdvertisementID int                     `json:"advertisementId"`
+	Versions        []*AdvertisementVersion `json:"versions"`
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"rentor/internal/money"
+)
+
+// ListingPrice fields of an active advertisement used by the city analytics
+type ListingPrice struct {
+	City        string
+	Type        string
+	Rooms       string
+	Price       money.Amount
+	Currency    string
+	PricePeriod string
+	Square      float64
+	CreatedAt   time.Time
+}
+
+// CityPriceStats precomputed price rollup of active listings in a city.
+// Type and Rooms are empty in the rollups over all types and all room counts.
+type CityPriceStats struct {
+	City             string    `json:"-"`
+	Type             string    `json:"type,omitempty"`
+	Rooms            string    `json:"rooms,omitempty"`
+	Listings         int       `json:"listings"`
+	PriceP25         float64   `json:"priceP25"`
+	PriceMedian      float64   `json:"priceMedian"`
+	PriceP75         float64   `json:"priceP75"`
+	PriceP90         float64   `json:"priceP90"`
+	PricePerM2Median *float64  `json:"pricePerM2Median"` // nil if no listing in the group has a square
+	ComputedAt       time.Time `json:"-"`
+}
+
+// CityDailyStats daily snapshot of the listings in a city
+type CityDailyStats struct {
+	City           string   `json:"-"`
+	Day            string   `json:"day"` // UTC date, YYYY-MM-DD
+	ActiveListings int      `json:"activeListings"`
+	NewListings    int      `json:"newListings"`
+	PriceMedian    *float64 `json:"priceMedian"`
+}
+
+// CityAnalytics rent statistics of a city. Prices are monthly rents in Currency,
+// listings in other currencies are converted and listings priced per day are left out.
+type CityAnalytics struct {
+	City       string            `json:"city"`
+	Currency   string            `json:"currency"`
+	ComputedAt time.Time         `json:"computedAt"`
+	Overall    *CityPriceStats   `json:"overall"`
+	ByRooms    []*CityPriceStats `json:"byRooms"`
+	ByType     []*CityPriceStats `json:"byType"`
+	History    []*CityDailyStats `json:"history"` // oldest day first
+}
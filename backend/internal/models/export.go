@@ -0,0 +1,78 @@
+package models
+
+import (
+	"time"
+
+	"rentor/internal/money"
+)
+
+// Personal data archive statuses
+const (
+	ExportArchiveQueued    = "queued"
+	ExportArchiveCompleted = "completed"
+	ExportArchiveFailed    = "failed"
+)
+
+// ExportAdvertisement is an advertisement with its photos as written to export files and feeds
+type ExportAdvertisement struct {
+	ID          int           `json:"id"`
+	ExternalID  *string       `json:"externalId"` // ID of the listing in the system it was imported from
+	Title       string        `json:"title"`
+	Description *string       `json:"description"`
+	Price       money.Amount  `json:"price"`
+	Currency    string        `json:"currency"`
+	PricePeriod string        `json:"pricePeriod"`
+	Type        string        `json:"type"`
+	Rooms       string        `json:"rooms"`
+	City        string        `json:"city"`
+	Address     string        `json:"address"`
+	Latitude    *float64      `json:"latitude"`
+	Longitude   *float64      `json:"longitude"`
+	Square      float64       `json:"square"`
+	Status      string        `json:"status"`
+	Photos      []ExportPhoto `json:"photos"`
+	CreatedAt   time.Time     `json:"createdAt"`
+	UpdatedAt   time.Time     `json:"updatedAt"`
+	DeletedAt   *time.Time    `json:"deletedAt,omitempty"` // only deleted advertisements of the personal data archive
+}
+
+// ExportPhoto is a photo of an exported advertisement, in display order
+type ExportPhoto struct {
+	URL       string  `json:"url"`
+	SourceURL *string `json:"sourceUrl,omitempty"` // URL the photo was imported from
+	Caption   *string `json:"caption"`
+	IsCover   bool    `json:"isCover"`
+	File      string  `json:"file,omitempty"` // image file inside the personal data archive
+}
+
+// ExportFilter selects a page of the advertisements of a user, ordered by ID
+type ExportFilter struct {
+	UserID      int
+	AfterID     int  // the page starts after this advertisement
+	Limit       int  // page size
+	ActiveOnly  bool // only active advertisements (public feeds)
+	WithDeleted bool // include soft-deleted advertisements (personal data archive)
+}
+
+// ExportArchive is a personal data archive (zip with JSON and images) generated in the background
+type ExportArchive struct {
+	ID          int        `json:"id"`
+	UserID      int        `json:"-"`
+	Status      string     `json:"status"` // queued|completed|failed
+	Size        *int64     `json:"size,omitempty"`
+	Error       *string    `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	FinishedAt  *time.Time `json:"finishedAt,omitempty"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`   // the archive is deleted after this time
+	DownloadURL string     `json:"downloadUrl,omitempty"` // set once the archive is ready
+
+	Path string `json:"-"` // archive file in the export directory
+}
+
+// PersonalDataExport is the JSON part of the personal data archive
+type PersonalDataExport struct {
+	ExportedAt     time.Time              `json:"exportedAt"`
+	User           *User                  `json:"user"`
+	Profile        *UserProfile           `json:"profile"`
+	Advertisements []*ExportAdvertisement `json:"advertisements"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// StoredImage is a row of advertisement_photos as seen by storage maintenance
+type StoredImage struct {
+	ID              int    `json:"id"`
+	AdvertisementID int    `json:"advertisement_id"`
+	URL             string `json:"url"`
+}
+
+// ReconcileOptions controls the image storage consistency check
+type ReconcileOptions struct {
+	DryRun      bool          // only report, do not delete anything
+	GracePeriod time.Duration // files younger than this are never treated as orphans
+}
+
+// ReconcileReport is the result of the image storage consistency check
+type ReconcileReport struct {
+	DryRun       bool           `json:"dry_run"`
+	CheckedFiles int            `json:"checked_files"`
+	CheckedRows  int            `json:"checked_rows"`
+	OrphanFiles  []string       `json:"orphan_files"`  // files in storage without a DB row
+	MissingFiles []*StoredImage `json:"missing_files"` // DB rows without a file in storage
+	RemovedFiles int            `json:"removed_files"`
+	RemovedRows  int            `json:"removed_rows"`
+}
+
+// SavedImage is an image written to storage, ready to be linked to an advertisement
+type SavedImage struct {
+	URL   string  `json:"url"`
+	PHash *string `json:"phash"` // nil if the file could not be decoded
+
+	SourceURL *string `json:"-"` // URL the photo was imported from
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"time"
+
+	v "rentor/internal/validation"
+)
+
+// Import job statuses
+const (
+	ImportJobQueued    = "queued"
+	ImportJobRunning   = "running"
+	ImportJobCompleted = "completed"
+	ImportJobFailed    = "failed"
+)
+
+// What happened to an imported row
+const (
+	ImportRowCreated   = "created"
+	ImportRowUpdated   = "updated"
+	ImportRowUnchanged = "unchanged"
+	ImportRowFailed    = "failed"
+)
+
+// ImportInput describes how a file of listings is imported
+type ImportInput struct {
+	Format  string            `json:"format"`  // csv|json|xml
+	Mapping map[string]string `json:"mapping"` // advertisement field -> CSV column or JSON key, unmapped fields use their own name
+	DryRun  bool              `json:"dryRun"`  // only report what would change
+}
+
+// ImportRow is a listing of an imported file converted to advertisement fields
+type ImportRow struct {
+	Line       int    `json:"line"` // line (CSV), position (JSON) or offer number (XML) in the file
+	ExternalID string `json:"externalId"`
+	CreateAdvertisementInput
+	Status string         `json:"status,omitempty"` // empty keeps the status of an existing advertisement, new ones are active
+	Photos []string       `json:"photos,omitempty"` // photo URLs, fetched into the image storage
+	Errors []v.FieldError `json:"errors,omitempty"` // values that could not be read from the file
+}
+
+// ImportRowResult is the outcome of one row
+type ImportRowResult struct {
+	Line            int            `json:"line"`
+	ExternalID      string         `json:"externalId,omitempty"`
+	Action          string         `json:"action"` // created|updated|unchanged|failed
+	AdvertisementID *int           `json:"advertisementId,omitempty"`
+	PhotosAdded     int            `json:"photosAdded,omitempty"`
+	PhotosRemoved   int            `json:"photosRemoved,omitempty"`
+	Errors          []v.FieldError `json:"errors,omitempty"`   // why the row was not imported
+	Warnings        []v.FieldError `json:"warnings,omitempty"` // photos that could not be fetched
+}
+
+// ImportReport summarizes an import, in a dry run it tells what would happen
+type ImportReport struct {
+	DryRun    bool              `json:"dryRun"`
+	Total     int               `json:"total"`
+	Created   int               `json:"created"`
+	Updated   int               `json:"updated"`
+	Unchanged int               `json:"unchanged"`
+	Failed    int               `json:"failed"`
+	Rows      []ImportRowResult `json:"rows"`
+}
+
+// Add records the result of a row
+func (r *ImportReport) Add(result ImportRowResult) {
+	switch result.Action {
+	case ImportRowCreated:
+		r.Created++
+	case ImportRowUpdated:
+		r.Updated++
+	case ImportRowUnchanged:
+		r.Unchanged++
+	case ImportRowFailed:
+		r.Failed++
+	}
+	r.Rows = append(r.Rows, result)
+}
+
+// ImportJob is an import processed in the background, clients poll it for progress
+type ImportJob struct {
+	ID         int           `json:"id"`
+	UserID     int           `json:"-"`
+	Format     string        `json:"format"`
+	Status     string        `json:"status"` // queued|running|completed|failed
+	Total      int           `json:"total"`
+	Processed  int           `json:"processed"`
+	Report     *ImportReport `json:"report,omitempty"` // results of the processed rows
+	Error      *string       `json:"error,omitempty"`
+	CreatedAt  time.Time     `json:"createdAt"`
+	StartedAt  *time.Time    `json:"startedAt,omitempty"`
+	FinishedAt *time.Time    `json:"finishedAt,omitempty"`
+
+	Rows []ImportRow `json:"-"` // rows waiting to be imported
+}
@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+const (
+	ModerationReasonDuplicatePhoto   = "duplicate_photo"
+	ModerationReasonDuplicateListing = "duplicate_listing"
+
+	ModerationStatusOpen      = "open"
+	ModerationStatusConfirmed = "confirmed"
+	ModerationStatusDismissed = "dismissed"
+)
+
+// ModerationItem represents an advertisement flagged for manual review
+type ModerationItem struct {
+	ID                     int        `json:"id"`
+	AdvertisementID        int        `json:"advertisementId"`
+	RelatedAdvertisementID int        `json:"relatedAdvertisementId"`
+	Reason                 string     `json:"reason"` // duplicate_photo|duplicate_listing
+	Score                  float64    `json:"score"`
+	Details                *string    `json:"details"`
+	Status                 string     `json:"status"` // open|confirmed|dismissed
+	ResolvedBy             *int       `json:"resolvedBy"`
+	CreatedAt              time.Time  `json:"createdAt"`
+	ResolvedAt             *time.Time `json:"resolvedAt"`
+}
+
+// ModerationItemsList paged list of moderation items
+type ModerationItemsList struct {
+	Total int               `json:"total"`
+	Page  int               `json:"page"`
+	Limit int               `json:"limit"`
+	Items []*ModerationItem `json:"items"`
+}
+
+// ResolveModerationItemInput moderator decision on a flagged advertisement
+type ResolveModerationItemInput struct {
+	Status string `json:"status"` // confirmed|dismissed
+}
+
+// PhotoHash perceptual hash of a stored photo together with its owner
+type PhotoHash struct {
+	ImageID         int    `json:"imageId"`
+	AdvertisementID int    `json:"advertisementId"`
+	UserID          int    `json:"userId"`
+	PHash           string `json:"phash"`
+}
+
+// ListingSummary fields of an advertisement used for duplicate detection
+type ListingSummary struct {
+	ID      int    `json:"id"`
+	UserID  int    `json:"userId"`
+	Title   string `json:"title"`
+	City    string `json:"city"`
+	Address string `json:"address"`
+}
+
+// DuplicateCandidate advertisement scored as a possible duplicate
+type DuplicateCandidate struct {
+	AdvertisementID   int     `json:"advertisementId"`
+	Score             float64 `json:"score"`
+	PhotoOverlap      float64 `json:"photoOverlap"`
+	TitleSimilarity   float64 `json:"titleSimilarity"`
+	AddressSimilarity float64 `json:"addressSimilarity"`
+}
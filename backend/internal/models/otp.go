@@ -2,12 +2,21 @@ package models
 
 import "time"
 
+// Purposes OTP codes are sent for, a code only confirms the purpose it was sent for
+const (
+	OTPPurposeLogin           = "login"
+	OTPPurposeAccountDeletion = "account_deletion"
+	OTPPurposeEmailChange     = "email_change"
+	OTPPurposePhoneChange     = "phone_change" // the only purpose sent by SMS, the others go to an email
+)
+
 // OTPCode represents an OTP record in the system
 type OTPCode struct {
 	ID          int       `json:"id"`
 	UserID      int       `json:"user_id"`
-	Email       string    `json:"email"`
-	CodeHash    string    `json:"-"` // never expose hash
+	Purpose     string    `json:"purpose"`
+	Target      string    `json:"target"` // email or phone the code was sent to
+	CodeHash    string    `json:"-"`      // never expose hash
 	Attempts    int       `json:"attempts"`
 	MaxAttempts int       `json:"max_attempts"`
 	ExpiresAt   time.Time `json:"expires_at"`
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"strconv"
+
+	"rentor/internal/money"
+)
+
+// UserViewerKey is the recently viewed key of a signed-in user, visitors are keyed by their cookie
+func UserViewerKey(userID int) string {
+	return "user:" + strconv.Itoa(userID)
+}
+
+// ListingFeatures fields of an advertisement compared by the similar listings recommender
+type ListingFeatures struct {
+	ID          int
+	City        string
+	Type        string
+	Rooms       string
+	Price       money.Amount
+	Currency    string
+	PricePeriod string
+	Square      float64
+	Latitude    *float64
+	Longitude   *float64
+}
+
+// SimilarAdvertisement advertisement recommended as similar to another one
+type SimilarAdvertisement struct {
+	AdPreview
+	Score      float64  `json:"score"`                // 0..1, higher is more similar
+	DistanceKm *float64 `json:"distanceKm,omitempty"` // nil if one of the advertisements has no coordinates
+}
+
+// SimilarAdvertisementsList similar listings of an advertisement, most similar first
+type SimilarAdvertisementsList struct {
+	AdvertisementID int                     `json:"advertisementId"`
+	Items           []*SimilarAdvertisement `json:"items"`
+}
+
+// RecentlyViewedList advertisements recently opened by a user or visitor, last viewed first
+type RecentlyViewedList struct {
+	Items []AdPreview `json:"items"`
+}
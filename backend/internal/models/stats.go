@@ -0,0 +1,72 @@
+package models
+
+// Events counted for the landlord statistics
+const (
+	StatsEventImpression = "impression" // the advertisement appeared in search results
+	StatsEventView       = "view"       // the detail page was opened
+	StatsEventFavorite   = "favorite"   // the advertisement was added to favorites
+	StatsEventContact    = "contact"    // the landlord contacts were revealed
+)
+
+// AdvertisementCounters event counters of an advertisement (or a sum over advertisements)
+type AdvertisementCounters struct {
+	Impressions int `json:"impressions"`
+	Views       int `json:"views"`
+	Favorites   int `json:"favorites"`
+	Contacts    int `json:"contacts"`
+}
+
+// Add adds the counter of event, unknown events are ignored
+func (c *AdvertisementCounters) Add(event string, n int) {
+	switch event {
+	case StatsEventImpression:
+		c.Impressions += n
+	case StatsEventView:
+		c.Views += n
+	case StatsEventFavorite:
+		c.Favorites += n
+	case StatsEventContact:
+		c.Contacts += n
+	}
+}
+
+// Merge adds all counters of other
+func (c *AdvertisementCounters) Merge(other *AdvertisementCounters) {
+	c.Impressions += other.Impressions
+	c.Views += other.Views
+	c.Favorites += other.Favorites
+	c.Contacts += other.Contacts
+}
+
+// AdvertisementDailyCounters counters of an advertisement on one day
+type AdvertisementDailyCounters struct {
+	AdvertisementID int    `json:"-"`
+	Day             string `json:"day"` // UTC date (YYYY-MM-DD)
+	AdvertisementCounters
+}
+
+// AdvertisementStats statistics of one advertisement for its owner
+type AdvertisementStats struct {
+	AdvertisementID int                           `json:"advertisementId"`
+	Days            int                           `json:"days"`
+	Totals          AdvertisementCounters         `json:"totals"`
+	FavoritedBy     int                           `json:"favoritedBy"` // users who have it in favorites now
+	ViewRate        float64                       `json:"viewRate"`    // views per impression
+	ContactRate     float64                       `json:"contactRate"` // contact reveals per view
+	Daily           []*AdvertisementDailyCounters `json:"daily"`       // oldest first, days without events are omitted
+}
+
+// LandlordDashboard summary over all advertisements of a landlord
+type LandlordDashboard struct {
+	Days        int                   `json:"days"`
+	Totals      AdvertisementCounters `json:"totals"`
+	ViewRate    float64               `json:"viewRate"`
+	ContactRate float64               `json:"contactRate"`
+}
+
+// LandlordContacts contacts of the landlord of an advertisement
+type LandlordContacts struct {
+	Name  *string `json:"name"`
+	Email string  `json:"email"`
+	Phone *string `json:"phone"`
+}
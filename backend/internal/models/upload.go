@@ -0,0 +1,66 @@
+package models
+
+import "time"
+
+const (
+	UploadSessionPending   = "pending"
+	UploadSessionCommitted = "committed"
+)
+
+// UploadSession represents a direct-to-storage upload session for advertisement images
+type UploadSession struct {
+	ID              int            `json:"id"`
+	AdvertisementID int            `json:"advertisement_id"`
+	UserID          int            `json:"user_id"`
+	Status          string         `json:"status"` // pending|committed
+	ExpiresAt       time.Time      `json:"expires_at"`
+	CreatedAt       time.Time      `json:"created_at"`
+	CommittedAt     *time.Time     `json:"committed_at"`
+	Objects         []UploadObject `json:"objects"`
+}
+
+// UploadObject represents a single upload slot inside a session
+type UploadObject struct {
+	ID          int        `json:"id"`
+	SessionID   int        `json:"session_id"`
+	Token       string     `json:"-"` // capability token, only exposed through the upload URL
+	Filename    string     `json:"filename"`
+	ContentType string     `json:"content_type"`
+	MaxSize     int64      `json:"max_size"`
+	Size        *int64     `json:"size"`
+	UploadedAt  *time.Time `json:"uploaded_at"`
+}
+
+// UploadFileSpec describes a file the client is going to upload
+type UploadFileSpec struct {
+	ContentType string `json:"contentType"`
+	Size        int64  `json:"size"`
+}
+
+// CreateUploadSessionInput input data for opening an upload session
+type CreateUploadSessionInput struct {
+	Files []UploadFileSpec `json:"files"`
+}
+
+// UploadPolicy tells the client which files the upload URL accepts
+type UploadPolicy struct {
+	MaxSize      int64    `json:"maxSize"`
+	AllowedTypes []string `json:"allowedTypes"`
+}
+
+// UploadTarget is a single pre-authorized upload URL
+type UploadTarget struct {
+	UploadID  int               `json:"uploadId"`
+	UploadURL string            `json:"uploadUrl"`
+	Method    string            `json:"method"`
+	Headers   map[string]string `json:"headers"`
+	Policy    UploadPolicy      `json:"policy"`
+}
+
+// UploadSessionResponse is returned when an upload session is opened
+type UploadSessionResponse struct {
+	SessionID int            `json:"sessionId"`
+	ExpiresAt time.Time      `json:"expiresAt"`
+	CommitURL string         `json:"commitUrl"`
+	Uploads   []UploadTarget `json:"uploads"`
+}
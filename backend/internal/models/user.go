@@ -2,13 +2,21 @@ package models
 
 import "time"
 
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 // User represents a user in the system (without profile details)
 type User struct {
-	UserID    int       `json:"user_id"`
-	Phone     *string   `json:"phone_number"`
-	Email     string    `json:"email"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	UserID          int        `json:"user_id"`
+	Phone           *string    `json:"phone_number"`
+	Email           string     `json:"email"`
+	Role            string     `json:"role"`              // user|admin
+	EmailVerifiedAt *time.Time `json:"email_verified_at"` // last confirmed with an OTP code, nil until then
+	PhoneVerifiedAt *time.Time `json:"phone_verified_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
 }
 
 // CreateUserInput input data for creating a user
@@ -23,3 +31,56 @@ type UpdateUserInput struct {
 	Phone  *string `json:"phone_number"`
 	Email  string  `json:"email"`
 }
+
+// ChangeEmailInput asks to move the account to a new email, a code is sent there
+type ChangeEmailInput struct {
+	Email string `json:"email"`
+}
+
+// ConfirmEmailChangeInput confirms the new email with the code sent to it
+type ConfirmEmailChangeInput struct {
+	Email   string `json:"email"`
+	OtpCode string `json:"otp_code"`
+}
+
+// ChangePhoneInput asks to set a new phone number, a code is sent there by SMS
+type ChangePhoneInput struct {
+	Phone string `json:"phone_number"`
+}
+
+// ConfirmPhoneChangeInput confirms the new phone number with the code sent to it
+type ConfirmPhoneChangeInput struct {
+	Phone   string `json:"phone_number"`
+	OtpCode string `json:"otp_code"`
+}
+
+// DeletedUser is a soft-deleted user waiting to be restored or purged
+type DeletedUser struct {
+	ID         int        `json:"user_id"`
+	DeletedAt  time.Time  `json:"deleted_at"`
+	EraseAfter *time.Time `json:"erase_after,omitempty"` // set when the owner deleted the account
+}
+
+// Events of the account deletion audit
+const (
+	AccountDeletionRequested = "requested"
+	AccountDeletionCancelled = "cancelled"
+	AccountDeletionErased    = "erased"
+)
+
+// DeleteAccountInput confirms deleting the account with the code sent to its email
+type DeleteAccountInput struct {
+	OtpCode string `json:"otp_code"`
+}
+
+// AccountDeletion tells the owner until when a deleted account can still be restored
+type AccountDeletion struct {
+	EraseAfter time.Time `json:"erase_after"` // signing in before this time cancels the deletion
+}
+
+// AccountErasure files left behind by an erased account, the rows are already gone
+type AccountErasure struct {
+	Photos  []string // photo URLs of the advertisements
+	Exports []string // personal data archives in the export directory
+	Uploads []string // staged files of unfinished upload sessions
+}
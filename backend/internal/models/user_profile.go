@@ -11,6 +11,7 @@ type UserProfile struct {
 	Patronymic *string   `json:"patronymic"`
 	CreatedAt  time.Time `json:"created_at"`
 	UpdatedAt  time.Time `json:"updated_at"`
+	Version    int       `json:"-"` // sent as the ETag header
 }
 
 // CreateUserProfileInput input data for creating a user profile
@@ -27,11 +28,13 @@ type UpdateUserProfileInput struct {
 }
 
 type GetUserProfileOutput struct {
-	UserID     int       `json:"user_id"`
-	Email      string    `json:"email"`
-	Phone      *string   `json:"phone_number"`
-	FirstName  *string   `json:"first_name"`
-	Surname    *string   `json:"surname"`
-	Patronymic *string   `json:"patronymic"`
-	CreatedAt  time.Time `json:"created_at"`
+	UserID          int        `json:"user_id"`
+	Email           string     `json:"email"`
+	Phone           *string    `json:"phone_number"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	PhoneVerifiedAt *time.Time `json:"phone_verified_at"`
+	FirstName       *string    `json:"first_name"`
+	Surname         *string    `json:"surname"`
+	Patronymic      *string    `json:"patronymic"`
+	CreatedAt       time.Time  `json:"created_at"`
 }
@@ -0,0 +1,212 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+
+	"rentor/internal/money"
+	v "rentor/internal/validation"
+)
+
+// Allowed values of the advertisement fields
+var (
+	AdvertisementTypes    = []string{"apartment", "house", "room"}
+	AdvertisementRooms    = []string{"studio", "1", "2", "3", "4", "5", "6+"}
+	AdvertisementStatuses = []string{"active", "paused"}
+	PricePeriods          = []string{PricePeriodDay, PricePeriodMonth}
+	Furnishings           = []string{FurnishingNone, FurnishingPartial, FurnishingFull}
+)
+
+// MaxAmenities is the number of amenities an advertisement can list
+const MaxAmenities = 50
+
+// photoURLPattern accepts the photo URLs an import can fetch
+var photoURLPattern = regexp.MustCompile(`^https?://[^\s/?#]+[^\s]*$`)
+
+// phonePattern is the phone format accepted by the user repository: digits, spaces and dashes with an optional +
+var phonePattern = regexp.MustCompile(`^\+?\d[\d\s\-]{7,14}\d$`)
+
+// Validate checks the fields of a new advertisement
+func (in *CreateAdvertisementInput) Validate() error {
+	return v.Check(in.fieldErrors()...)
+}
+
+func (in *CreateAdvertisementInput) fieldErrors() []*v.FieldError {
+	errs := []*v.FieldError{
+		v.Field("title", in.Title, v.Required(), v.MaxLength(200)),
+		v.OptionalField("description", in.Description, v.MaxLength(5000)),
+		v.Field("price", in.Price, v.Min[money.Amount](0), v.Max(money.FromMajor(1e9))),
+		v.Field("type", in.Type, v.Required(), v.OneOf(AdvertisementTypes...)),
+		v.Field("rooms", in.Rooms, v.Required(), v.OneOf(AdvertisementRooms...)),
+		v.Field("city", in.City, v.Required(), v.MaxLength(100)),
+		v.Field("address", in.Address, v.Required(), v.MaxLength(300)),
+		v.OptionalField("latitude", in.Latitude, v.Min(-90.0), v.Max(90.0)),
+		v.OptionalField("longitude", in.Longitude, v.Min(-180.0), v.Max(180.0)),
+		v.Field("square", in.Square, v.Min(0.0), v.Max(100000.0)),
+	}
+	// currency and period may be left out, the service fills in the defaults
+	if in.Currency != "" {
+		errs = append(errs, v.Field("currency", in.Currency, v.OneOf(money.Currencies...)))
+	}
+	if in.PricePeriod != "" {
+		errs = append(errs, v.Field("pricePeriod", in.PricePeriod, v.OneOf(PricePeriods...)))
+	}
+	errs = append(errs, in.AdAttributes.fieldErrors()...)
+	if (in.Latitude == nil) != (in.Longitude == nil) {
+		field := "longitude"
+		if in.Latitude == nil {
+			field = "latitude"
+		}
+		errs = append(errs, v.NewError(field, "required_with", "latitude and longitude must be set together"))
+	}
+	return errs
+}
+
+// fieldErrors checks the attribute values; amenity codes are checked against the catalog by the service
+func (in *AdAttributes) fieldErrors() []*v.FieldError {
+	errs := []*v.FieldError{
+		v.OptionalField("floor", in.Floor, v.Min(-5), v.Max(200)),
+		v.OptionalField("floorsTotal", in.FloorsTotal, v.Min(1), v.Max(200)),
+		v.OptionalField("furnishing", in.Furnishing, v.OneOf(Furnishings...)),
+		v.OptionalField("deposit", in.Deposit, v.Min[money.Amount](0), v.Max(money.FromMajor(1e9))),
+		v.Field("amenities", in.Amenities, v.MaxItems[string](MaxAmenities)),
+	}
+	if in.Floor != nil && in.FloorsTotal != nil && *in.Floor > *in.FloorsTotal {
+		errs = append(errs, v.NewError("floor", "above_floors_total", "must not be above floorsTotal"))
+	}
+	return errs
+}
+
+// Validate checks the new state of an advertisement: the same rules as on creation plus the status
+func (in *UpdateAdvertisementInput) Validate() error {
+	fields := &CreateAdvertisementInput{
+		Title:        in.Title,
+		Description:  in.Description,
+		Price:        in.Price,
+		Currency:     in.Currency,
+		PricePeriod:  in.PricePeriod,
+		Type:         in.Type,
+		Rooms:        in.Rooms,
+		City:         in.City,
+		Address:      in.Address,
+		Latitude:     in.Latitude,
+		Longitude:    in.Longitude,
+		Square:       in.Square,
+		AdAttributes: in.AdAttributes,
+	}
+	return v.Check(append(
+		fields.fieldErrors(),
+		v.Field("status", in.Status, v.Required(), v.OneOf(AdvertisementStatuses...)),
+	)...)
+}
+
+// Validate checks an imported listing: the rules of a new advertisement plus the import fields.
+// A field that could not be read from the file is reported once, with the reason it couldn't be read.
+func (in *ImportRow) Validate() error {
+	unreadable := make(map[string]bool, len(in.Errors))
+	errs := make([]*v.FieldError, 0, len(in.Errors))
+	for i := range in.Errors {
+		unreadable[in.Errors[i].Field] = true
+		errs = append(errs, &in.Errors[i])
+	}
+
+	fields := append([]*v.FieldError{
+		v.Field("externalId", in.ExternalID, v.Required(), v.MaxLength(100)),
+	}, in.CreateAdvertisementInput.fieldErrors()...)
+	if in.Status != "" {
+		fields = append(fields, v.Field("status", in.Status, v.OneOf(AdvertisementStatuses...)))
+	}
+	for i, url := range in.Photos {
+		fields = append(fields, v.Field(fmt.Sprintf("photos[%d]", i), url, v.Matches(photoURLPattern, "an http(s) URL")))
+	}
+	for _, fe := range fields {
+		if fe != nil && !unreadable[fe.Field] {
+			errs = append(errs, fe)
+		}
+	}
+
+	return v.Check(errs...)
+}
+
+// Validate checks the new order of advertisement photos
+func (in *ReorderImagesInput) Validate() error {
+	return v.Check(
+		v.Field("imageIds", in.ImageIDs, v.NotEmpty[int]()),
+	)
+}
+
+// Validate checks the new photo caption
+func (in *UpdateImageInput) Validate() error {
+	return v.Check(
+		v.OptionalField("caption", in.Caption, v.MaxLength(500)),
+	)
+}
+
+// Validate checks the profile fields; absent fields are cleared, so all of them are optional
+func (in *UpdateUserProfileInput) Validate() error {
+	return v.Check(
+		v.OptionalField("first_name", in.FirstName, v.MaxLength(100)),
+		v.OptionalField("surname", in.Surname, v.MaxLength(100)),
+		v.OptionalField("patronymic", in.Patronymic, v.MaxLength(100)),
+		v.OptionalField("phone_number", in.Phone, v.Matches(phonePattern, "a phone number of 9-16 digits, spaces or dashes with an optional leading +")),
+	)
+}
+
+// Validate checks the email the OTP is sent to
+func (in *OTPRequest) Validate() error {
+	return v.Check(
+		v.Field("email", in.Email, v.Required(), v.Email()),
+	)
+}
+
+// Validate checks the OTP verification request
+func (in *OTPVerifyRequest) Validate() error {
+	return v.Check(
+		v.Field("email", in.Email, v.Required()),
+		v.Field("otp_code", in.OtpCode, v.Required()),
+	)
+}
+
+// Validate checks the account deletion confirmation
+func (in *DeleteAccountInput) Validate() error {
+	return v.Check(
+		v.Field("otp_code", in.OtpCode, v.Required()),
+	)
+}
+
+// Validate checks the new email
+func (in *ChangeEmailInput) Validate() error {
+	return v.Check(
+		v.Field("email", in.Email, v.Required(), v.Email()),
+	)
+}
+
+// Validate checks the confirmation of the new email
+func (in *ConfirmEmailChangeInput) Validate() error {
+	return v.Check(
+		v.Field("email", in.Email, v.Required(), v.Email()),
+		v.Field("otp_code", in.OtpCode, v.Required()),
+	)
+}
+
+// Validate checks the new phone number
+func (in *ChangePhoneInput) Validate() error {
+	return v.Check(
+		v.Field("phone_number", in.Phone, v.Required(), v.Matches(phonePattern, "a phone number of 9-16 digits, spaces or dashes with an optional leading +")),
+	)
+}
+
+// Validate checks the confirmation of the new phone number
+func (in *ConfirmPhoneChangeInput) Validate() error {
+	return v.Check(
+		v.Field("phone_number", in.Phone, v.Required(), v.Matches(phonePattern, "a phone number of 9-16 digits, spaces or dashes with an optional leading +")),
+		v.Field("otp_code", in.OtpCode, v.Required()),
+	)
+}
+
+// Validate checks the moderator decision
+func (in *ResolveModerationItemInput) Validate() error {
+	return v.Check(
+		v.Field("status", in.Status, v.Required(), v.OneOf(ModerationStatusConfirmed, ModerationStatusDismissed)),
+	)
+}
@@ -0,0 +1,197 @@
+package models_test
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"rentor/internal/models"
+	"rentor/internal/money"
+	"rentor/internal/validation"
+)
+
+// failures returns the "field:code" pairs of a validation error
+func failures(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var fields validation.Errors
+	if !errors.As(err, &fields) {
+		t.Fatalf("Validate returned %v, want validation.Errors", err)
+	}
+	got := make([]string, len(fields))
+	for i, fe := range fields {
+		got[i] = fe.Field + ":" + fe.Code
+	}
+	return got
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// validAdvertisement is a new advertisement that passes every rule
+func validAdvertisement() models.CreateAdvertisementInput {
+	return models.CreateAdvertisementInput{
+		Title:       "Flat in the centre",
+		Price:       money.FromMajor(250000),
+		Currency:    "KZT",
+		PricePeriod: "month",
+		Type:        "apartment",
+		Rooms:       "2",
+		City:        "Almaty",
+		Address:     "Abay 10",
+		Latitude:    ptr(43.2389),
+		Longitude:   ptr(76.8897),
+		Square:      54.5,
+		AdAttributes: models.AdAttributes{
+			Floor:       ptr(3),
+			FloorsTotal: ptr(9),
+			Furnishing:  ptr("full"),
+			Deposit:     ptr(money.FromMajor(100000)),
+		},
+	}
+}
+
+func TestCreateAdvertisementInputValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(in *models.CreateAdvertisementInput)
+		want   []string
+	}{
+		{"valid", func(*models.CreateAdvertisementInput) {}, nil},
+		{"defaults left out", func(in *models.CreateAdvertisementInput) {
+			in.Currency, in.PricePeriod = "", ""
+		}, nil},
+		{"every type", func(in *models.CreateAdvertisementInput) { in.Type = "room" }, nil},
+		{"unknown type", func(in *models.CreateAdvertisementInput) { in.Type = "castle" }, []string{"type:one_of"}},
+		{"missing type", func(in *models.CreateAdvertisementInput) { in.Type = "" }, []string{"type:required"}},
+		{"studio", func(in *models.CreateAdvertisementInput) { in.Rooms = "studio" }, nil},
+		{"six or more rooms", func(in *models.CreateAdvertisementInput) { in.Rooms = "6+" }, nil},
+		{"rooms as a number", func(in *models.CreateAdvertisementInput) { in.Rooms = "7" }, []string{"rooms:one_of"}},
+		{"missing rooms", func(in *models.CreateAdvertisementInput) { in.Rooms = " " }, []string{"rooms:required"}},
+		{"title at the length bound", func(in *models.CreateAdvertisementInput) { in.Title = strings.Repeat("я", 200) }, nil},
+		{"title too long", func(in *models.CreateAdvertisementInput) { in.Title = strings.Repeat("я", 201) }, []string{"title:max_length"}},
+		{"description too long", func(in *models.CreateAdvertisementInput) {
+			in.Description = ptr(strings.Repeat("a", 5001))
+		}, []string{"description:max_length"}},
+		{"city too long", func(in *models.CreateAdvertisementInput) { in.City = strings.Repeat("a", 101) }, []string{"city:max_length"}},
+		{"address too long", func(in *models.CreateAdvertisementInput) { in.Address = strings.Repeat("a", 301) }, []string{"address:max_length"}},
+		{"negative price", func(in *models.CreateAdvertisementInput) { in.Price = -1 }, []string{"price:min"}},
+		{"price too high", func(in *models.CreateAdvertisementInput) { in.Price = money.FromMajor(1e9) + 1 }, []string{"price:max"}},
+		{"unknown currency", func(in *models.CreateAdvertisementInput) { in.Currency = "XXX" }, []string{"currency:one_of"}},
+		{"unknown period", func(in *models.CreateAdvertisementInput) { in.PricePeriod = "week" }, []string{"pricePeriod:one_of"}},
+		{"latitude out of range", func(in *models.CreateAdvertisementInput) { in.Latitude = ptr(90.5) }, []string{"latitude:max"}},
+		{"longitude without latitude", func(in *models.CreateAdvertisementInput) { in.Latitude = nil }, []string{"latitude:required_with"}},
+		{"negative square", func(in *models.CreateAdvertisementInput) { in.Square = -1 }, []string{"square:min"}},
+		{"floor above the building", func(in *models.CreateAdvertisementInput) { in.Floor = ptr(10) }, []string{"floor:above_floors_total"}},
+		{"unknown furnishing", func(in *models.CreateAdvertisementInput) { in.Furnishing = ptr("some") }, []string{"furnishing:one_of"}},
+		{"too many amenities", func(in *models.CreateAdvertisementInput) {
+			in.Amenities = make([]string, models.MaxAmenities+1)
+		}, []string{"amenities:max_items"}},
+		{"every failing field is reported", func(in *models.CreateAdvertisementInput) {
+			in.Title, in.Type, in.Rooms = "", "castle", "7"
+		}, []string{"title:required", "type:one_of", "rooms:one_of"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := validAdvertisement()
+			tt.change(&in)
+			if got := failures(t, in.Validate()); !slices.Equal(got, tt.want) {
+				t.Errorf("failures = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateAdvertisementInputValidate(t *testing.T) {
+	in := validAdvertisement()
+	update := models.UpdateAdvertisementInput{
+		Title: in.Title, Price: in.Price, Type: in.Type, Rooms: in.Rooms,
+		City: in.City, Address: in.Address, Square: in.Square, Status: "paused",
+	}
+	if got := failures(t, update.Validate()); got != nil {
+		t.Errorf("failures = %q, want none", got)
+	}
+	for status, want := range map[string]string{"": "status:required", "deleted": "status:one_of"} {
+		update.Status = status
+		if got := failures(t, update.Validate()); !slices.Equal(got, []string{want}) {
+			t.Errorf("status %q: failures = %q, want %q", status, got, want)
+		}
+	}
+}
+
+func TestPhoneValidate(t *testing.T) {
+	tests := []struct {
+		phone string
+		valid bool
+	}{
+		{"+77011234567", true},
+		{"87011234567", true},
+		{"+7 701 123-45-67", true},
+		{"123456789", true},         // 9 digits
+		{"+1234567890123456", true}, // 16 digits
+		{"12345678", false},
+		{"+12345678901234567", false},
+		{"", false},
+		{"+", false},
+		{"++77011234567", false},
+		{"+7 (701) 1234567", false},
+		{"7701123456a", false},
+		{" 77011234567", false},
+		{"+7701123456-", false},
+	}
+	for _, tt := range tests {
+		want := []string{"phone_number:format"}
+		if tt.phone == "" {
+			want = []string{"phone_number:required"}
+		}
+		if tt.valid {
+			want = nil
+		}
+		in := models.ChangePhoneInput{Phone: tt.phone}
+		if got := failures(t, in.Validate()); !slices.Equal(got, want) {
+			t.Errorf("%q: failures = %q, want %q", tt.phone, got, want)
+		}
+		// the profile only checks the format of a phone that is kept
+		profile := models.UpdateUserProfileInput{Phone: &tt.phone}
+		if got := failures(t, profile.Validate()); (got == nil) != tt.valid {
+			t.Errorf("profile with %q: failures = %q", tt.phone, got)
+		}
+	}
+}
+
+func TestUpdateUserProfileInputValidate(t *testing.T) {
+	long := strings.Repeat("я", 101)
+	tests := []struct {
+		name string
+		in   models.UpdateUserProfileInput
+		want []string
+	}{
+		{"everything cleared", models.UpdateUserProfileInput{}, nil},
+		{"at the length bound", models.UpdateUserProfileInput{FirstName: ptr(long[2:])}, nil},
+		{"names too long", models.UpdateUserProfileInput{FirstName: &long, Surname: &long, Patronymic: &long},
+			[]string{"first_name:max_length", "surname:max_length", "patronymic:max_length"}},
+	}
+	for _, tt := range tests {
+		if got := failures(t, tt.in.Validate()); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: failures = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEmailValidate(t *testing.T) {
+	for email, want := range map[string][]string{
+		"owner@example.com":         nil,
+		"":                          {"email:required"},
+		"owner":                     {"email:format"},
+		"Owner <owner@example.com>": {"email:format"},
+	} {
+		in := models.OTPRequest{Email: email}
+		if got := failures(t, in.Validate()); !slices.Equal(got, want) {
+			t.Errorf("%q: failures = %q, want %q", email, got, want)
+		}
+	}
+}
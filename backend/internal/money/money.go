@@ -0,0 +1,112 @@
+// Package money keeps prices exact: amounts are integer minor units of an ISO 4217 currency,
+// and a locally configured table of exchange rates converts them between currencies.
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Currencies are the ISO 4217 codes prices can be set in. All of them have two minor digits,
+// so an Amount is always in hundredths of the currency unit.
+var Currencies = []string{"KZT", "RUB", "USD", "EUR", "KGS", "UZS", "CNY", "GBP", "TRY", "AED", "GEL", "AZN", "BYN", "AMD"}
+
+// minorPerUnit is the number of minor units in a currency unit
+const minorPerUnit = 100
+
+// ErrInvalidAmount is returned for amounts that are not a number or have more than two decimal places
+var ErrInvalidAmount = errors.New("money: amount must be a number with at most two decimal places")
+
+// Amount is a sum of money in minor units. It is written to JSON and text as a decimal number
+// in currency units (150000.5 for 15000050 minor units), so clients see the usual price.
+type Amount int64
+
+// FromMajor converts a number of currency units to an Amount, rounding to the nearest minor unit
+func FromMajor(units float64) Amount {
+	return Amount(math.Round(units * minorPerUnit))
+}
+
+// Major returns the amount in currency units
+func (a Amount) Major() float64 {
+	return float64(a) / minorPerUnit
+}
+
+// String formats the amount in currency units without trailing zeros, e.g. "150000" or "99.5"
+func (a Amount) String() string {
+	sign := ""
+	n := int64(a)
+	if n < 0 {
+		sign, n = "-", -n
+	}
+	units, minor := n/minorPerUnit, n%minorPerUnit
+	if minor == 0 {
+		return sign + strconv.FormatInt(units, 10)
+	}
+	return strings.TrimRight(fmt.Sprintf("%s%d.%02d", sign, units, minor), "0")
+}
+
+// Parse reads a decimal number of currency units exactly, e.g. "150000" or "99.95"
+func Parse(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	units, fraction, _ := strings.Cut(strings.TrimPrefix(s, "-"), ".")
+	if units == "" || len(fraction) > 2 || !digits(units) || !digits(fraction) {
+		// exponents and trailing zeros beyond the minor digits are still valid JSON numbers
+		f, err := strconv.ParseFloat(s, 64)
+		// out of range float to int conversions are platform-dependent, so the range is checked first
+		if err != nil || math.IsNaN(f) || math.Abs(f) >= math.MaxInt64/minorPerUnit || FromMajor(f).Major() != f {
+			return 0, ErrInvalidAmount
+		}
+		return FromMajor(f), nil
+	}
+
+	n, err := strconv.ParseInt(units, 10, 64)
+	if err != nil || n > math.MaxInt64/minorPerUnit-1 {
+		return 0, ErrInvalidAmount
+	}
+	n *= minorPerUnit
+	if fraction != "" {
+		minor, _ := strconv.ParseInt((fraction + "0")[:2], 10, 64)
+		n += minor
+	}
+	if strings.HasPrefix(s, "-") {
+		n = -n
+	}
+	return Amount(n), nil
+}
+
+// MarshalJSON writes the amount as a JSON number of currency units
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON reads a JSON number of currency units
+func (a *Amount) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+	parsed, err := Parse(string(b))
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+// digits reports whether s only has ASCII digits
+func digits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// IsCurrency reports whether code is a supported currency
+func IsCurrency(code string) bool {
+	return slices.Contains(Currencies, code)
+}
@@ -0,0 +1,200 @@
+package money_test
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"slices"
+	"testing"
+
+	"rentor/internal/money"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want money.Amount
+		err  bool
+	}{
+		{in: "150000", want: 15000000},
+		{in: "99.95", want: 9995},
+		{in: "99.5", want: 9950},
+		{in: "0.05", want: 5},
+		{in: " 42 ", want: 4200},
+		{in: "-12.3", want: -1230},
+		{in: "-0.05", want: -5},
+		{in: "1e3", want: 100000},
+		{in: "1.5E2", want: 15000},
+		{in: "150000.500", want: 15000050},
+		{in: "92233720368547757.99", want: math.MaxInt64 - 8},
+		{in: "-92233720368547757.99", want: -(math.MaxInt64 - 8)},
+
+		{in: "1.234", err: true},
+		{in: "1e-3", err: true},
+		{in: "", err: true},
+		{in: " ", err: true},
+		{in: "-", err: true},
+		{in: ".5", want: 50},
+		{in: "abc", err: true},
+		{in: "1.2.3", err: true},
+		{in: "1,5", err: true},
+		{in: "92233720368547758", err: true},
+		{in: "99999999999999999999", err: true},
+		{in: "1e17", err: true},
+		{in: "1e30", err: true},
+		{in: "NaN", err: true},
+		{in: "Inf", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := money.Parse(tt.in)
+			if tt.err {
+				if !errors.Is(err, money.ErrInvalidAmount) {
+					t.Errorf("Parse(%q) = %d, %v, want %v", tt.in, got, err, money.ErrInvalidAmount)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("Parse(%q) = %d, %v, want %d", tt.in, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromMajor(t *testing.T) {
+	tests := []struct {
+		units float64
+		want  money.Amount
+	}{
+		{0, 0},
+		{1, 100},
+		{99.95, 9995},
+		{1.005, 100}, // 1.005 is 1.00499999... as a float64
+		{0.125, 13},
+		{-12.3, -1230},
+		{480.004, 48000},
+	}
+	for _, tt := range tests {
+		if got := money.FromMajor(tt.units); got != tt.want {
+			t.Errorf("FromMajor(%v) = %d, want %d", tt.units, got, tt.want)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		in   money.Amount
+		want string
+	}{
+		{0, "0"},
+		{15000000, "150000"},
+		{15000050, "150000.5"},
+		{9995, "99.95"},
+		{5, "0.05"},
+		{-5, "-0.05"},
+		{-1230, "-12.3"},
+		{math.MaxInt64, "92233720368547758.07"},
+	}
+	for _, tt := range tests {
+		got := tt.in.String()
+		if got != tt.want {
+			t.Errorf("String(%d) = %q, want %q", tt.in, got, tt.want)
+		}
+		// every amount Parse accepts is read back unchanged
+		if tt.in > math.MaxInt64-100 {
+			continue
+		}
+		if back, err := money.Parse(got); err != nil || back != tt.in {
+			t.Errorf("Parse(%q) = %d, %v, want %d", got, back, err, tt.in)
+		}
+	}
+}
+
+func TestAmountJSON(t *testing.T) {
+	var v struct {
+		Price    money.Amount  `json:"price"`
+		Deposit  *money.Amount `json:"deposit"`
+		Discount money.Amount  `json:"discount"`
+	}
+	if err := json.Unmarshal([]byte(`{"price": 150000.5, "deposit": 1e3, "discount": null}`), &v); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if v.Price != 15000050 || v.Deposit == nil || *v.Deposit != 100000 || v.Discount != 0 {
+		t.Errorf("unmarshaled %+v", v)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if want := `{"price":150000.5,"deposit":1000,"discount":0}`; string(b) != want {
+		t.Errorf("marshaled %s, want %s", b, want)
+	}
+
+	if err := json.Unmarshal([]byte(`{"price": 1.234}`), &v); !errors.Is(err, money.ErrInvalidAmount) {
+		t.Errorf("unmarshal of 1.234: err = %v, want %v", err, money.ErrInvalidAmount)
+	}
+}
+
+func TestNewRates(t *testing.T) {
+	tests := []struct {
+		name  string
+		base  string
+		rates map[string]float64
+	}{
+		{"unsupported base", "XXX", nil},
+		{"unsupported currency", "KZT", map[string]float64{"XXX": 1}},
+		{"zero rate", "KZT", map[string]float64{"USD": 0}},
+		{"negative rate", "KZT", map[string]float64{"USD": -480}},
+		{"infinite rate", "KZT", map[string]float64{"USD": math.Inf(1)}},
+		{"NaN rate", "KZT", map[string]float64{"USD": math.NaN()}},
+	}
+	for _, tt := range tests {
+		if _, err := money.NewRates(tt.base, tt.rates); err == nil {
+			t.Errorf("%s: NewRates succeeded", tt.name)
+		}
+	}
+
+	rates, err := money.NewRates("kzt", map[string]float64{"eur": 520, "USD": 480, "KZT": 2})
+	if err != nil {
+		t.Fatalf("NewRates: %v", err)
+	}
+	if rates.Base() != "KZT" {
+		t.Errorf("Base() = %q, want KZT", rates.Base())
+	}
+	// the list order of money.Currencies, the rate of the base currency is always 1
+	if got, want := rates.Currencies(), []string{"KZT", "USD", "EUR"}; !slices.Equal(got, want) {
+		t.Errorf("Currencies() = %q, want %q", got, want)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	rates, err := money.NewRates("KZT", map[string]float64{"USD": 480.004, "EUR": 520})
+	if err != nil {
+		t.Fatalf("NewRates: %v", err)
+	}
+	tests := []struct {
+		name     string
+		amount   money.Amount
+		from, to string
+		want     money.Amount
+		ok       bool
+	}{
+		{"to the base", 100, "USD", "KZT", 48000, true},
+		{"rounded to the base", 101, "USD", "KZT", 48480, true},
+		{"from the base", 48000, "KZT", "USD", 100, true},
+		{"rounded from the base", 100, "KZT", "USD", 0, true},
+		{"rounded up from the base", 241, "KZT", "USD", 1, true},
+		{"between two rates", 100, "EUR", "USD", 108, true},
+		{"negative", -100, "USD", "KZT", -48000, true},
+		{"same currency", 12345, "USD", "USD", 12345, true},
+		{"same currency without a rate", 12345, "RUB", "RUB", 0, false},
+		{"from a currency without a rate", 100, "RUB", "KZT", 0, false},
+		{"to a currency without a rate", 100, "KZT", "RUB", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := rates.Convert(tt.amount, tt.from, tt.to)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%s: Convert(%d, %s, %s) = %d, %v, want %d, %v", tt.name, tt.amount, tt.from, tt.to, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package money
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Rates is a table of exchange rates to a base currency
+type Rates struct {
+	base  string
+	rates map[string]float64 // currency -> units of the base currency for one unit
+}
+
+// NewRates creates a table from rates given in units of base for one unit of each currency,
+// e.g. base KZT with {"USD": 480} means 1 USD = 480 KZT. Currency codes are case-insensitive.
+// Currencies without a rate can't be converted.
+func NewRates(base string, rates map[string]float64) (*Rates, error) {
+	base = strings.ToUpper(base)
+	if !IsCurrency(base) {
+		return nil, fmt.Errorf("money: unsupported base currency %q", base)
+	}
+
+	r := &Rates{base: base, rates: map[string]float64{base: 1}}
+	for code, rate := range rates {
+		code = strings.ToUpper(code)
+		if !IsCurrency(code) {
+			return nil, fmt.Errorf("money: unsupported currency %q", code)
+		}
+		if rate <= 0 || math.IsInf(rate, 0) || math.IsNaN(rate) {
+			return nil, fmt.Errorf("money: rate of %s must be positive", code)
+		}
+		if code != base {
+			r.rates[code] = rate
+		}
+	}
+	return r, nil
+}
+
+// Base returns the base currency, prices without a currency are in it
+func (r *Rates) Base() string {
+	return r.base
+}
+
+// Currencies returns the currencies that can be converted
+func (r *Rates) Currencies() []string {
+	codes := make([]string, 0, len(r.rates))
+	for _, code := range Currencies {
+		if _, ok := r.rates[code]; ok {
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
+
+// Rate returns how many units of to one unit of from is worth, false if a currency has no rate
+func (r *Rates) Rate(from, to string) (float64, bool) {
+	fromRate, ok := r.rates[from]
+	if !ok {
+		return 0, false
+	}
+	toRate, ok := r.rates[to]
+	if !ok {
+		return 0, false
+	}
+	return fromRate / toRate, true
+}
+
+// Convert converts an amount between currencies, rounding to the nearest minor unit
+func (r *Rates) Convert(a Amount, from, to string) (Amount, bool) {
+	rate, ok := r.Rate(from, to)
+	if !ok {
+		return 0, false
+	}
+	if from == to {
+		return a, true
+	}
+	return Amount(math.Round(float64(a) * rate)), true
+}
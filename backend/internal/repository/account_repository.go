@@ -0,0 +1,235 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"rentor/internal/apperr"
+	"rentor/internal/models"
+)
+
+// accountRepository implements AccountRepository
+type accountRepository struct {
+	db DBTX
+}
+
+// NewAccountRepository creates a new account repository
+func NewAccountRepository(db DBTX) AccountRepository {
+	return &accountRepository{db: db}
+}
+
+// ScheduleAccountDeletion soft-deletes the user and their advertisements like DeleteUserByID, marks the
+// account for erasure after eraseAfter and drops its OTP codes and the imports and archives still waiting to run.
+// The request goes to the audit under reference.
+func (r *accountRepository) ScheduleAccountDeletion(ctx context.Context, userID int, reference string, deletedAt, eraseAfter time.Time) error {
+	return withTx(ctx, r.db, func(tx DBTX) error {
+		res, err := tx.ExecContext(ctx, `
+            UPDATE "user" SET deleted_at = ?, erase_after = ?, deletion_reference = ?
+            WHERE id = ? AND deleted_at IS NULL
+        `, deletedAt, eraseAfter, reference, userID)
+		if err != nil {
+			return err
+		}
+		if err := expectAffected(res, apperr.ErrUserNotFound); err != nil {
+			return err
+		}
+
+		res, err = tx.ExecContext(ctx, "UPDATE advertisement SET deleted_at = ? WHERE user_id = ? AND deleted_at IS NULL", deletedAt, userID)
+		if err != nil {
+			return err
+		}
+		ads, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM otp_codes WHERE user_id = ?", userID); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, "DELETE FROM import_job WHERE user_id = ? AND status IN (?, ?)", userID, models.ImportJobQueued, models.ImportJobRunning)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM export_archive WHERE user_id = ? AND status = ?", userID, models.ExportArchiveQueued); err != nil {
+			return err
+		}
+
+		return insertDeletionAudit(ctx, tx, reference, models.AccountDeletionRequested, ads, 0, deletedAt)
+	})
+}
+
+// CancelAccountDeletion restores an account deleted by its owner together with the advertisements deleted
+// with it. Returns false if the account is not waiting for erasure.
+func (r *accountRepository) CancelAccountDeletion(ctx context.Context, userID int, now time.Time) (bool, error) {
+	cancelled := false
+	err := withTx(ctx, r.db, func(tx DBTX) error {
+		var reference string
+		var deletedAt time.Time
+		err := tx.QueryRowContext(ctx, `
+            SELECT deletion_reference, deleted_at FROM "user"
+            WHERE id = ? AND deleted_at IS NOT NULL AND erase_after IS NOT NULL
+        `, userID).Scan(&reference, &deletedAt)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return nil
+			}
+			return err
+		}
+
+		res, err := tx.ExecContext(ctx, "UPDATE advertisement SET deleted_at = NULL WHERE user_id = ? AND deleted_at = ?", userID, deletedAt)
+		if err != nil {
+			return err
+		}
+		ads, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+            UPDATE "user" SET deleted_at = NULL, erase_after = NULL, deletion_reference = NULL
+            WHERE id = ?
+        `, userID)
+		if err != nil {
+			return err
+		}
+
+		cancelled = true
+		return insertDeletionAudit(ctx, tx, reference, models.AccountDeletionCancelled, ads, 0, now)
+	})
+	return cancelled, err
+}
+
+// GetAccountsDueForErasure retrieves accounts deleted by their owners whose grace period has passed
+func (r *accountRepository) GetAccountsDueForErasure(ctx context.Context, now time.Time) ([]*models.DeletedUser, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT id, deleted_at, erase_after FROM "user"
+        WHERE erase_after IS NOT NULL AND erase_after < ?
+        ORDER BY erase_after
+    `, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*models.DeletedUser
+	for rows.Next() {
+		user := &models.DeletedUser{}
+		if err := rows.Scan(&user.ID, &user.DeletedAt, &user.EraseAfter); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+// eraseAccountRows remove everything that references an erased account, children before parents.
+// The schema declares the same with ON DELETE CASCADE and SET NULL, but SQLite enforces foreign keys only
+// when _foreign_keys is on, and an erasure must not leave personal data behind either way.
+// Every ? is the id of the user.
+var eraseAccountRows = []string{
+	// moderation decisions and history entries made by the user stay without the author
+	"UPDATE moderation_queue SET resolved_by = NULL WHERE resolved_by = ?",
+	"UPDATE advertisement_history SET changed_by = NULL WHERE changed_by = ?",
+
+	"DELETE FROM moderation_queue WHERE advertisement_id IN (" + userAdvertisements + ") OR related_advertisement_id IN (" + userAdvertisements + ")",
+	"DELETE FROM upload_object WHERE session_id IN (SELECT id FROM upload_session WHERE user_id = ? OR advertisement_id IN (" + userAdvertisements + "))",
+	"DELETE FROM upload_session WHERE user_id = ? OR advertisement_id IN (" + userAdvertisements + ")",
+	"DELETE FROM favorite WHERE user_id = ? OR advertisement_id IN (" + userAdvertisements + ")",
+	"DELETE FROM recently_viewed WHERE advertisement_id IN (" + userAdvertisements + ")",
+	"DELETE FROM advertisement_photos WHERE advertisement_id IN (" + userAdvertisements + ")",
+	"DELETE FROM advertisement_amenity WHERE advertisement_id IN (" + userAdvertisements + ")",
+	"DELETE FROM advertisement_history WHERE advertisement_id IN (" + userAdvertisements + ")",
+	"DELETE FROM advertisement_daily_stats WHERE advertisement_id IN (" + userAdvertisements + ")",
+	"DELETE FROM advertisement WHERE user_id = ?",
+
+	"DELETE FROM user_profile WHERE user_id = ?",
+	"DELETE FROM otp_codes WHERE user_id = ?",
+	"DELETE FROM import_job WHERE user_id = ?",
+	"DELETE FROM export_archive WHERE user_id = ?",
+}
+
+// userAdvertisements selects the ids of all advertisements of a user
+const userAdvertisements = "SELECT id FROM advertisement WHERE user_id = ?"
+
+// EraseAccount permanently deletes an account whose grace period has passed together with its profile,
+// OTP codes, advertisements with their photos and history, upload sessions, imports, archives, counters,
+// favorites and recently viewed list (see eraseAccountRows). The files of the account are returned
+// so the caller can remove them.
+func (r *accountRepository) EraseAccount(ctx context.Context, userID int, now time.Time) (*models.AccountErasure, error) {
+	erasure := &models.AccountErasure{}
+	err := withTx(ctx, r.db, func(tx DBTX) error {
+		var reference string
+		err := tx.QueryRowContext(ctx, `
+            SELECT deletion_reference FROM "user"
+            WHERE id = ? AND erase_after IS NOT NULL AND erase_after < ?
+        `, userID, now).Scan(&reference)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return apperr.ErrDeletedUserNotFound
+			}
+			return err
+		}
+
+		var ads int64
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM advertisement WHERE user_id = ?", userID).Scan(&ads); err != nil {
+			return err
+		}
+
+		erasure.Photos, err = queryStrings(ctx, tx, `
+            SELECT p.photo_url
+            FROM advertisement_photos p
+            JOIN advertisement a ON a.id = p.advertisement_id
+            WHERE a.user_id = ?
+        `, userID)
+		if err != nil {
+			return err
+		}
+		erasure.Exports, err = queryStrings(ctx, tx, "SELECT path FROM export_archive WHERE user_id = ? AND path IS NOT NULL", userID)
+		if err != nil {
+			return err
+		}
+		erasure.Uploads, err = queryStrings(ctx, tx, `
+            SELECT o.filename
+            FROM upload_object o
+            JOIN upload_session s ON s.id = o.session_id
+            WHERE s.user_id = ? AND s.status = ? AND o.uploaded_at IS NOT NULL
+        `, userID, models.UploadSessionPending)
+		if err != nil {
+			return err
+		}
+
+		for _, query := range eraseAccountRows {
+			args := make([]any, strings.Count(query, "?"))
+			for i := range args {
+				args[i] = userID
+			}
+			if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM recently_viewed WHERE viewer = ?", models.UserViewerKey(userID)); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM "user" WHERE id = ?`, userID); err != nil {
+			return err
+		}
+
+		return insertDeletionAudit(ctx, tx, reference, models.AccountDeletionErased, ads, int64(len(erasure.Photos)), now)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return erasure, nil
+}
+
+// insertDeletionAudit records a step of an account deletion, nothing identifying the person is stored
+func insertDeletionAudit(ctx context.Context, tx DBTX, reference, event string, ads, photos int64, at time.Time) error {
+	_, err := tx.ExecContext(ctx, `
+        INSERT INTO account_deletion_audit (reference, event, advertisements, photos, created_at)
+        VALUES (?, ?, ?, ?, ?)
+    `, reference, event, ads, photos, at)
+	return err
+}
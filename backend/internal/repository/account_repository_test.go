@@ -0,0 +1,163 @@
+package repository_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"rentor/internal/models"
+	"rentor/internal/repository"
+	"rentor/internal/storage"
+	"rentor/internal/storage/storagetest"
+)
+
+// seedAccount creates rows of userID in every table that references a user or an advertisement,
+// otherAdID is an advertisement of another user the account interacts with
+func seedAccount(t *testing.T, repos *repository.Repositories, userID, otherAdID int) int {
+	t.Helper()
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	adID := seedAdvertisement(t, repos, userID)
+	value := "old"
+	if _, err := repos.Advertisement.CreateAdvertisementVersion(ctx, adID, userID,
+		[]models.AdvertisementFieldChange{{Field: "title", OldValue: &value}}, now); err != nil {
+		t.Fatalf("create version: %v", err)
+	}
+	if _, err := repos.Advertisement.CreateAdvertisementVersion(ctx, otherAdID, userID,
+		[]models.AdvertisementFieldChange{{Field: "title", NewValue: &value}}, now); err != nil {
+		t.Fatalf("create version of another advertisement: %v", err)
+	}
+	if _, err := repos.Advertisement.AddFavorite(ctx, userID, otherAdID, now); err != nil {
+		t.Fatalf("add favorite: %v", err)
+	}
+	if err := repos.Stats.IncrementAdvertisementStats(ctx, []*models.AdvertisementDailyCounters{
+		{AdvertisementID: adID, Day: now.Format(time.DateOnly), AdvertisementCounters: models.AdvertisementCounters{Views: 3}},
+	}); err != nil {
+		t.Fatalf("increment stats: %v", err)
+	}
+	if err := repos.Recommendation.RecordRecentlyViewed(ctx, models.UserViewerKey(userID), otherAdID, now, 10); err != nil {
+		t.Fatalf("record view: %v", err)
+	}
+	if err := repos.Moderation.CreateModerationItem(ctx, &models.ModerationItem{
+		AdvertisementID: adID, RelatedAdvertisementID: otherAdID, Reason: "duplicate_photo", Score: 1, Status: "open",
+	}); err != nil {
+		t.Fatalf("create moderation item: %v", err)
+	}
+	if _, err := repos.Upload.CreateUploadSession(ctx, &models.UploadSession{
+		AdvertisementID: adID, UserID: userID, Status: models.UploadSessionPending, ExpiresAt: now.Add(time.Hour),
+		Objects: []models.UploadObject{{Token: "token", Filename: "1.png", ContentType: "image/png", MaxSize: 1 << 20}},
+	}); err != nil {
+		t.Fatalf("create upload session: %v", err)
+	}
+	return adID
+}
+
+func TestEraseAccountDeletesDependentRows(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		foreignKeys bool
+	}{
+		{"foreign keys on", true},
+		{"foreign keys off", false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := storagetest.SQLiteOptions()
+			opts.ForeignKeys = tt.foreignKeys
+			conn := newDB(storagetest.OpenSQLite(t, opts), storage.DriverSQLite)
+			repos := repository.NewRepositories(conn)
+			ctx := context.Background()
+			now := time.Now().UTC()
+
+			userID := createUser(t, repos, 1)
+			otherID := createUser(t, repos, 2)
+			otherAdID := seedAdvertisement(t, repos, otherID)
+			adID := seedAccount(t, repos, userID, otherAdID)
+			if _, err := repos.Advertisement.AddFavorite(ctx, otherID, adID, now); err != nil {
+				t.Fatalf("add favorite: %v", err)
+			}
+
+			// the user resolves a moderation item about advertisements of another user
+			if err := repos.Moderation.CreateModerationItem(ctx, &models.ModerationItem{
+				AdvertisementID: otherAdID, RelatedAdvertisementID: otherAdID, Reason: "duplicate_listing", Score: 1, Status: "open",
+			}); err != nil {
+				t.Fatalf("create moderation item: %v", err)
+			}
+			items, _, err := repos.Moderation.GetPageModerationItems(ctx, "open", 0, 10)
+			if err != nil {
+				t.Fatalf("get moderation items: %v", err)
+			}
+			for _, item := range items {
+				if item.AdvertisementID == otherAdID {
+					if err := repos.Moderation.ResolveModerationItem(ctx, item.ID, "dismissed", userID, now); err != nil {
+						t.Fatalf("resolve moderation item: %v", err)
+					}
+				}
+			}
+
+			if err := repos.Account.ScheduleAccountDeletion(ctx, userID, "ref", now, now.Add(time.Minute)); err != nil {
+				t.Fatalf("schedule deletion: %v", err)
+			}
+			// rows created after the deletion was requested are erased too
+			if err := repos.OTP.CreateOTP(ctx, userID, "login", "user1@example.com", "hash", 3, now.Add(time.Hour)); err != nil {
+				t.Fatalf("create otp: %v", err)
+			}
+			if _, err := repos.Import.CreateImportJob(ctx, &models.ImportJob{UserID: userID, Format: "csv", Status: "completed", CreatedAt: now}); err != nil {
+				t.Fatalf("create import job: %v", err)
+			}
+			if _, err := repos.Export.CreateExportArchive(ctx, &models.ExportArchive{UserID: userID, Status: "completed", CreatedAt: now}); err != nil {
+				t.Fatalf("create archive: %v", err)
+			}
+
+			erasure, err := repos.Account.EraseAccount(ctx, userID, now.Add(time.Hour))
+			if err != nil {
+				t.Fatalf("erase account: %v", err)
+			}
+			if len(erasure.Photos) != 2 {
+				t.Errorf("erasure returned %d photos, want 2", len(erasure.Photos))
+			}
+
+			byUser := []string{"user_profile", "otp_codes", "advertisement", "upload_session", "favorite", "import_job", "export_archive"}
+			for _, table := range byUser {
+				if n := count(t, conn, "SELECT COUNT(*) FROM "+table+" WHERE user_id = ?", userID); n != 0 {
+					t.Errorf("%s has %d rows of the erased user", table, n)
+				}
+			}
+			byAd := slices.Concat(adChildren, []string{"advertisement_history", "advertisement_daily_stats", "favorite", "upload_session"})
+			for _, table := range byAd {
+				if n := count(t, conn, "SELECT COUNT(*) FROM "+table+" WHERE advertisement_id = ?", adID); n != 0 {
+					t.Errorf("%s has %d rows of the erased advertisement", table, n)
+				}
+			}
+			checks := []struct {
+				query string
+				args  []any
+				want  int
+			}{
+				{`SELECT COUNT(*) FROM "user" WHERE id = ?`, []any{userID}, 0},
+				{"SELECT COUNT(*) FROM upload_object", nil, 0},
+				{"SELECT COUNT(*) FROM recently_viewed WHERE viewer = ?", []any{models.UserViewerKey(userID)}, 0},
+				{"SELECT COUNT(*) FROM moderation_queue WHERE advertisement_id = ? OR related_advertisement_id = ?", []any{adID, adID}, 0},
+				// decisions and changes of the user on other advertisements stay without the author
+				{"SELECT COUNT(*) FROM moderation_queue WHERE resolved_by = ?", []any{userID}, 0},
+				{"SELECT COUNT(*) FROM moderation_queue WHERE resolved_by IS NULL AND status = 'dismissed'", nil, 1},
+				{"SELECT COUNT(*) FROM advertisement_history WHERE changed_by = ?", []any{userID}, 0},
+				{"SELECT COUNT(*) FROM advertisement_history WHERE changed_by IS NULL AND advertisement_id = ?", []any{otherAdID}, 1},
+			}
+			for _, c := range checks {
+				if n := count(t, conn, c.query, c.args...); n != c.want {
+					t.Errorf("%s: %d rows, want %d", c.query, n, c.want)
+				}
+			}
+
+			// the other user keeps their data
+			if n := count(t, conn, "SELECT COUNT(*) FROM advertisement_photos WHERE advertisement_id = ?", otherAdID); n != 2 {
+				t.Errorf("the other advertisement has %d photos, want 2", n)
+			}
+			if n := count(t, conn, "SELECT COUNT(*) FROM user_profile WHERE user_id = ?", otherID); n != 1 {
+				t.Errorf("the other user has %d profiles, want 1", n)
+			}
+		})
+	}
+}
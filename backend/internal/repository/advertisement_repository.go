@@ -1,18 +1,20 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
-	"errors"
 	"fmt"
+	"rentor/internal/apperr"
 	"rentor/internal/models"
 	"strings"
+	"time"
 )
 
 type AdRepository struct {
-	db *sql.DB
+	db DBTX
 }
 
-func NewAdRepository(db *sql.DB) AdRepository {
+func NewAdRepository(db DBTX) AdRepository {
 	return AdRepository{db: db}
 }
 
@@ -22,61 +24,137 @@ func NewAdRepository(db *sql.DB) AdRepository {
 // ============================
 //
 
-func (r *AdRepository) CreateAdvertisement(userID int, ad *models.CreateAdvertisementInput) (int, error) {
-	if ad.Title == "" {
-		return 0, errors.New("title is required")
+func (r *AdRepository) CreateAdvertisement(ctx context.Context, userID int, ad *models.CreateAdvertisementInput) (int, error) {
+	var id int
+	err := withTx(ctx, r.db, func(tx DBTX) error {
+		var err error
+		id, err = insertID(ctx, tx, `
+            INSERT INTO advertisement 
+            (user_id, title, description, price, currency, price_period, type, rooms, city, address, latitude, longitude, square,
+             floor, floors_total, furnishing, pets_allowed, utilities_included, deposit, status, external_id)
+            VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+        `,
+			userID,
+			ad.Title,
+			ad.Description,
+			ad.Price,
+			ad.Currency,
+			ad.PricePeriod,
+			ad.Type,
+			ad.Rooms,
+			ad.City,
+			ad.Address,
+			ad.Latitude,
+			ad.Longitude,
+			ad.Square,
+			ad.Floor,
+			ad.FloorsTotal,
+			ad.Furnishing,
+			ad.PetsAllowed,
+			ad.UtilitiesIncluded,
+			ad.Deposit,
+			"active",
+			ad.ExternalID,
+		)
+		if err != nil {
+			return err
+		}
+		return replaceAmenities(ctx, tx, id, ad.Amenities)
+	})
+	if err != nil {
+		return 0, err
 	}
-	if ad.Price < 0 {
-		return 0, errors.New("price must be >= 0")
+	return id, nil
+}
+
+// replaceAmenities заменяет удобства объявления переданным списком
+func replaceAmenities(ctx context.Context, tx DBTX, adID int, codes []string) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM advertisement_amenity WHERE advertisement_id = ?", adID); err != nil {
+		return err
 	}
-	res, err := r.db.Exec(`
-        INSERT INTO advertisement 
-        (user_id, title, description, price, type, rooms, city, address, latitude, longitude, square, status)
-        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-    `,
-		userID,
-		ad.Title,
-		ad.Description,
-		ad.Price,
-		ad.Type,
-		ad.Rooms,
-		ad.City,
-		ad.Address,
-		ad.Latitude,
-		ad.Longitude,
-		ad.Square,
-		"active",
-	)
+	for _, code := range codes {
+		_, err := tx.ExecContext(ctx, "INSERT INTO advertisement_amenity (advertisement_id, amenity_code) VALUES (?, ?)", adID, code)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queryAmenities возвращает коды удобств объявления, пустой список, если их нет
+func queryAmenities(ctx context.Context, db DBTX, adID int) ([]string, error) {
+	codes, err := queryStrings(ctx, db, `
+        SELECT amenity_code
+        FROM advertisement_amenity
+        WHERE advertisement_id = ?
+        ORDER BY amenity_code
+    `, adID)
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+	if codes == nil {
+		codes = []string{}
 	}
+	return codes, nil
+}
 
-	id64, err := res.LastInsertId()
+// GetAmenities возвращает каталог удобств в порядке показа
+func (r *AdRepository) GetAmenities(ctx context.Context) ([]models.Amenity, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT code, category, title FROM amenity ORDER BY position, code")
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
+	defer rows.Close()
 
-	return int(id64), nil
+	amenities := []models.Amenity{}
+	for rows.Next() {
+		var a models.Amenity
+		if err := rows.Scan(&a.Code, &a.Category, &a.Title); err != nil {
+			return nil, err
+		}
+		amenities = append(amenities, a)
+	}
+	return amenities, rows.Err()
 }
 
-func (r *AdRepository) CreateAdvertisementImages(adID int, urls []string) error {
-	if len(urls) == 0 {
+// CreateAdvertisementImages добавляет фото в конец списка одной транзакцией:
+// либо привязываются все фото, либо ни одного
+func (r *AdRepository) CreateAdvertisementImages(ctx context.Context, adID int, images []models.SavedImage) error {
+	if len(images) == 0 {
 		return nil
 	}
 
-	for _, url := range urls {
-		if url == "" {
-			continue
-		}
-		_, err := r.db.Exec(`
-            INSERT INTO advertisement_photos (advertisement_id, photo_url)
-            VALUES (?, ?)
-        `, adID, url)
+	return withTx(ctx, r.db, func(tx DBTX) error {
+		// первое фото объявления становится обложкой
+		var position, covers int
+		err := tx.QueryRowContext(ctx, `
+            SELECT COALESCE(MAX(position) + 1, 0), COALESCE(SUM(CASE WHEN is_cover THEN 1 ELSE 0 END), 0)
+            FROM advertisement_photos
+            WHERE advertisement_id = ?
+        `, adID).Scan(&position, &covers)
 		if err != nil {
 			return err
 		}
-	}
-	return nil
+
+		for _, image := range images {
+			if image.URL == "" {
+				continue
+			}
+			isCover := covers == 0
+			_, err := tx.ExecContext(ctx, `
+                INSERT INTO advertisement_photos (advertisement_id, photo_url, position, is_cover, phash, source_url)
+                VALUES (?, ?, ?, ?, ?, ?)
+            `, adID, image.URL, position, isCover, image.PHash, image.SourceURL)
+			if err != nil {
+				return err
+			}
+			position++
+			if isCover {
+				covers++
+			}
+		}
+		return nil
+	})
 }
 
 //
@@ -85,24 +163,45 @@ func (r *AdRepository) CreateAdvertisementImages(adID int, urls []string) error
 // ============================
 //
 
-func (r *AdRepository) GetUserID(id int) (int, error) {
+// GetAdvertisementIDByExternalID ищет объявление пользователя, импортированное под externalID.
+// deleted сообщает, что объявление удалено: повторный импорт его не восстанавливает.
+func (r *AdRepository) GetAdvertisementIDByExternalID(ctx context.Context, userID int, externalID string) (id int, deleted bool, err error) {
+	err = r.db.QueryRowContext(
+		ctx,
+		"SELECT id, deleted_at IS NOT NULL FROM advertisement WHERE user_id = ? AND external_id = ?",
+		userID, externalID,
+	).Scan(&id, &deleted)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, false, apperr.ErrAdvertisementNotFound
+		}
+		return 0, false, err
+	}
+	return id, deleted, nil
+}
+
+func (r *AdRepository) GetUserID(ctx context.Context, id int) (int, error) {
 	var userID int
-	err := r.db.QueryRow("SELECT user_id FROM advertisement WHERE id = ?", id).Scan(&userID)
+	err := r.db.QueryRowContext(ctx, "SELECT user_id FROM advertisement WHERE id = ? AND deleted_at IS NULL", id).Scan(&userID)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, apperr.ErrAdvertisementNotFound
+		}
 		return 0, err
 	}
 	return userID, nil
 }
 
-func (r *AdRepository) GetAdvertisement(id int) (*models.GetAd, error) {
+func (r *AdRepository) GetAdvertisement(ctx context.Context, id int) (*models.GetAd, error) {
 	ad := &models.GetAd{}
 	var userID int
 
-	err := r.db.QueryRow(`
-        SELECT id, user_id, title, description, price, type, rooms, city, address,
-               latitude, longitude, square, status
+	err := r.db.QueryRowContext(ctx, `
+        SELECT id, user_id, title, description, price, currency, price_period, type, rooms, city, address,
+               latitude, longitude, square, floor, floors_total, furnishing, pets_allowed, utilities_included, deposit,
+               status, previous_price, price_changed_at, version
         FROM advertisement
-        WHERE id = ?
+        WHERE id = ? AND deleted_at IS NULL
     `,
 		id,
 	).Scan(
@@ -111,6 +210,8 @@ func (r *AdRepository) GetAdvertisement(id int) (*models.GetAd, error) {
 		&ad.Title,
 		&ad.Description,
 		&ad.Price,
+		&ad.Currency,
+		&ad.PricePeriod,
 		&ad.Type,
 		&ad.Rooms,
 		&ad.City,
@@ -118,43 +219,58 @@ func (r *AdRepository) GetAdvertisement(id int) (*models.GetAd, error) {
 		&ad.Latitude,
 		&ad.Longitude,
 		&ad.Square,
+		&ad.Floor,
+		&ad.FloorsTotal,
+		&ad.Furnishing,
+		&ad.PetsAllowed,
+		&ad.UtilitiesIncluded,
+		&ad.Deposit,
 		&ad.Status,
+		&ad.PreviousPrice,
+		&ad.PriceChangedAt,
+		&ad.Version,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, errors.New("advertisement not found")
+			return nil, apperr.ErrAdvertisementNotFound
 		}
 		return nil, err
 	}
 
+	ad.Amenities, err = queryAmenities(ctx, r.db, ad.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	// вытаскиваем user_profile
-	err = r.db.QueryRow(`
+	err = r.db.QueryRowContext(ctx, `
         SELECT first_name
         FROM user_profile
         WHERE user_id = ?
     `, userID).Scan(&ad.LandlordName)
 	if err != nil {
-		return nil, errors.New("user profile not found")
+		return nil, apperr.ErrProfileNotFound
 	}
 
 	// вытаскиваем user
-	err = r.db.QueryRow(`
+	err = r.db.QueryRowContext(ctx, `
         SELECT email, phone_number
-        FROM user
+        FROM "user"
         WHERE id = ?
     `, userID).Scan(&ad.LandlordEmail, &ad.LandlordPhone)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, errors.New("user not found")
+			return nil, apperr.ErrUserNotFound
 		}
 		return nil, err
 	}
 
 	// фото
-	rows, err := r.db.Query(`
-        SELECT id, photo_url 
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT id, photo_url, position, is_cover, caption
         FROM advertisement_photos
         WHERE advertisement_id = ?
+        ORDER BY position, id
     `, ad.ID)
 	if err != nil {
 		return nil, err
@@ -164,7 +280,7 @@ func (r *AdRepository) GetAdvertisement(id int) (*models.GetAd, error) {
 	var images []*models.ImageUrl
 	for rows.Next() {
 		image_url := &models.ImageUrl{}
-		if err := rows.Scan(&image_url.ImageId, &image_url.ImageUrl); err != nil {
+		if err := rows.Scan(&image_url.ImageId, &image_url.ImageUrl, &image_url.Position, &image_url.IsCover, &image_url.Caption); err != nil {
 			return nil, err
 		}
 		images = append(images, image_url)
@@ -180,47 +296,97 @@ func (r *AdRepository) GetAdvertisement(id int) (*models.GetAd, error) {
 // ============================
 //
 
-func (r *AdRepository) GetAdvertisementsPaged(filters *models.AdFilters) (*models.GetAdPreviewsList, error) {
+func (r *AdRepository) GetAdvertisementsPaged(ctx context.Context, filters *models.AdFilters) (*models.GetAdPreviewsList, error) {
 	page := filters.Page
 	limit := filters.Limit
 
 	offset := (page - 1) * limit
 
-	where := []string{"1=1"}
+	where := []string{"deleted_at IS NULL"}
 	args := []any{}
 
-	if filters.MinPrice != nil {
-		where = append(where, "price >= ?")
-		args = append(args, filters.MinPrice)
+	// границы цены пересчитаны в валюту каждого объявления: условие на валюту с ценой использует индекс
+	if len(filters.PriceBounds) > 0 {
+		bounds := make([]string, 0, len(filters.PriceBounds))
+		for _, bound := range filters.PriceBounds {
+			cond := []string{"currency = ?"}
+			args = append(args, bound.Currency)
+			if bound.Min != nil {
+				cond = append(cond, "price >= ?")
+				args = append(args, *bound.Min)
+			}
+			if bound.Max != nil {
+				cond = append(cond, "price <= ?")
+				args = append(args, *bound.Max)
+			}
+			bounds = append(bounds, "("+strings.Join(cond, " AND ")+")")
+		}
+		where = append(where, "("+strings.Join(bounds, " OR ")+")")
 	}
-	if filters.MaxPrice != nil {
-		where = append(where, "price <= ?")
-		args = append(args, filters.MaxPrice)
+	if filters.PricePeriod != nil {
+		where = append(where, "price_period = ?")
+		args = append(args, *filters.PricePeriod)
 	}
 	if filters.Type != nil {
-		where = append(where, "type LIKE ?")
+		where = append(where, "LOWER(type) LIKE LOWER(?)")
 		args = append(args, "%"+*filters.Type+"%")
 	}
 	if filters.Rooms != nil {
-		where = append(where, "rooms LIKE ?")
+		where = append(where, "LOWER(rooms) LIKE LOWER(?)")
 		args = append(args, "%"+*filters.Rooms+"%")
 	}
 	if filters.City != nil {
-		where = append(where, "city LIKE ?")
+		where = append(where, "LOWER(city) LIKE LOWER(?)")
 		args = append(args, "%"+*filters.City+"%")
 	}
 	if filters.Keywords != nil {
-		where = append(where, "(title LIKE ? OR description LIKE ?)")
+		where = append(where, "(LOWER(title) LIKE LOWER(?) OR LOWER(description) LIKE LOWER(?))")
 		kw := "%" + *filters.Keywords + "%"
 		args = append(args, kw, kw)
 	}
+	if len(filters.Amenities) > 0 {
+		// объявление должно иметь все удобства: поиск идёт по индексу удобства
+		where = append(where, `id IN (
+            SELECT advertisement_id FROM advertisement_amenity
+            WHERE amenity_code IN (`+placeholders(len(filters.Amenities))+`)
+            GROUP BY advertisement_id
+            HAVING COUNT(*) = ?)`)
+		for _, code := range filters.Amenities {
+			args = append(args, code)
+		}
+		args = append(args, len(filters.Amenities))
+	}
+	if filters.PetsAllowed != nil {
+		where = append(where, "pets_allowed = ?")
+		args = append(args, *filters.PetsAllowed)
+	}
+	if filters.UtilitiesIncluded != nil {
+		where = append(where, "utilities_included = ?")
+		args = append(args, *filters.UtilitiesIncluded)
+	}
+	if filters.Furnishing != nil {
+		where = append(where, "furnishing = ?")
+		args = append(args, *filters.Furnishing)
+	}
+	if filters.MinFloor != nil {
+		where = append(where, "floor >= ?")
+		args = append(args, *filters.MinFloor)
+	}
+	if filters.MaxFloor != nil {
+		where = append(where, "floor <= ?")
+		args = append(args, *filters.MaxFloor)
+	}
 	if filters.UserID != nil {
 		where = append(where, "user_id = ?")
 		args = append(args, *filters.UserID)
 	}
+	if filters.FavoritesOf != nil {
+		where = append(where, "id IN (SELECT advertisement_id FROM favorite WHERE user_id = ?)")
+		args = append(args, *filters.FavoritesOf)
+	}
 
 	query := fmt.Sprintf(`
-        SELECT id, title, price, city, type, rooms
+        SELECT id, title, price, currency, price_period, city, type, rooms, previous_price, price_changed_at
         FROM advertisement
         WHERE %s
         ORDER BY created_at DESC
@@ -229,7 +395,7 @@ func (r *AdRepository) GetAdvertisementsPaged(filters *models.AdFilters) (*model
 
 	args = append(args, limit, offset)
 
-	rows, err := r.db.Query(query, args...)
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -242,35 +408,32 @@ func (r *AdRepository) GetAdvertisementsPaged(filters *models.AdFilters) (*model
 			&item.ID,
 			&item.Title,
 			&item.Price,
+			&item.Currency,
+			&item.PricePeriod,
 			&item.City,
 			&item.Type,
 			&item.Rooms,
+			&item.PreviousPrice,
+			&item.PriceChangedAt,
 		); err != nil {
 			return nil, err
 		}
 
-		ImageUrl := &models.ImageUrl{ImageId: -1, ImageUrl: ""}
-
-		// first photo
-		_ = r.db.QueryRow(`
-            SELECT id, photo_url
-            FROM advertisement_photos
-            WHERE advertisement_id = ?
-            LIMIT 1
-        `, item.ID).Scan(&ImageUrl.ImageId, &ImageUrl.ImageUrl)
-
-		if ImageUrl.ImageUrl != "" && ImageUrl.ImageId != -1 {
-			item.ImageUrl = ImageUrl
-		} else {
-			item.ImageUrl = nil
-		}
-
 		list.Items = append(list.Items, item)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// курсор закрываем до запросов обложек, чтобы не держать два соединения из пула
+	rows.Close()
+
+	for i := range list.Items {
+		list.Items[i].ImageUrl = queryCoverImage(ctx, r.db, list.Items[i].ID)
+	}
 
 	// count
 	var total int
-	_ = r.db.QueryRow(`
+	_ = r.db.QueryRowContext(ctx, `
         SELECT COUNT(*) FROM advertisement
         WHERE `+strings.Join(where, " AND "),
 		args[:len(args)-2]...,
@@ -283,57 +446,715 @@ func (r *AdRepository) GetAdvertisementsPaged(filters *models.AdFilters) (*model
 	return list, nil
 }
 
+// GetExportAdvertisements возвращает страницу объявлений пользователя с фото для экспорта.
+// Страницы идут по возрастанию ID (keyset), поэтому экспорт не держит открытый курсор между страницами.
+func (r *AdRepository) GetExportAdvertisements(ctx context.Context, filter *models.ExportFilter) ([]*models.ExportAdvertisement, error) {
+	where := []string{"user_id = ?", "id > ?"}
+	args := []any{filter.UserID, filter.AfterID}
+	if !filter.WithDeleted {
+		where = append(where, "deleted_at IS NULL")
+	}
+	if filter.ActiveOnly {
+		where = append(where, "status = ?")
+		args = append(args, "active")
+	}
+	args = append(args, filter.Limit)
+
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT id, external_id, title, description, price, currency, price_period, type, rooms, city, address,
+               latitude, longitude, square, status, created_at, updated_at, deleted_at
+        FROM advertisement
+        WHERE `+strings.Join(where, " AND ")+`
+        ORDER BY id
+        LIMIT ?
+    `, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ads []*models.ExportAdvertisement
+	byID := make(map[int]*models.ExportAdvertisement)
+	for rows.Next() {
+		ad := &models.ExportAdvertisement{}
+		if err := rows.Scan(
+			&ad.ID,
+			&ad.ExternalID,
+			&ad.Title,
+			&ad.Description,
+			&ad.Price,
+			&ad.Currency,
+			&ad.PricePeriod,
+			&ad.Type,
+			&ad.Rooms,
+			&ad.City,
+			&ad.Address,
+			&ad.Latitude,
+			&ad.Longitude,
+			&ad.Square,
+			&ad.Status,
+			&ad.CreatedAt,
+			&ad.UpdatedAt,
+			&ad.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		ad.Photos = []models.ExportPhoto{}
+		ads = append(ads, ad)
+		byID[ad.ID] = ad
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if len(ads) == 0 {
+		return nil, nil
+	}
+
+	// фото всей страницы одним запросом
+	ids := make([]any, len(ads))
+	for i, ad := range ads {
+		ids[i] = ad.ID
+	}
+	photoRows, err := r.db.QueryContext(ctx, `
+        SELECT advertisement_id, photo_url, source_url, caption, is_cover
+        FROM advertisement_photos
+        WHERE advertisement_id IN (`+placeholders(len(ids))+`)
+        ORDER BY advertisement_id, position, id
+    `, ids...)
+	if err != nil {
+		return nil, err
+	}
+	defer photoRows.Close()
+
+	for photoRows.Next() {
+		var adID int
+		var photo models.ExportPhoto
+		if err := photoRows.Scan(&adID, &photo.URL, &photo.SourceURL, &photo.Caption, &photo.IsCover); err != nil {
+			return nil, err
+		}
+		byID[adID].Photos = append(byID[adID].Photos, photo)
+	}
+
+	return ads, photoRows.Err()
+}
+
+// GetAdPreviewsByIDs возвращает превью указанных объявлений (удалённые пропускаются), порядок не гарантирован
+func (r *AdRepository) GetAdPreviewsByIDs(ctx context.Context, ids []int) ([]models.AdPreview, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT id, title, price, currency, price_period, city, type, rooms, square, previous_price, price_changed_at
+        FROM advertisement
+        WHERE id IN (`+placeholders(len(ids))+`) AND deleted_at IS NULL
+    `, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.AdPreview
+	for rows.Next() {
+		var item models.AdPreview
+		if err := rows.Scan(
+			&item.ID,
+			&item.Title,
+			&item.Price,
+			&item.Currency,
+			&item.PricePeriod,
+			&item.City,
+			&item.Type,
+			&item.Rooms,
+			&item.Square,
+			&item.PreviousPrice,
+			&item.PriceChangedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for i := range items {
+		items[i].ImageUrl = queryCoverImage(ctx, r.db, items[i].ID)
+	}
+
+	return items, nil
+}
+
 //
 // ============================
 // UPDATE
 // ============================
 //
 
-func (r *AdRepository) UpdateAdvertisement(id int, ad *models.UpdateAdvertisementInput) error {
-	_, err := r.db.Exec(`
-        UPDATE advertisement
-        SET title = ?, description = ?, price = ?, type = ?, rooms = ?, city = ?, 
-            address = ?, latitude = ?, longitude = ?, square = ?, status = ?, 
-            updated_at = CURRENT_TIMESTAMP
-        WHERE id = ?
-    `,
-		ad.Title,
-		ad.Description,
-		ad.Price,
-		ad.Type,
-		ad.Rooms,
-		ad.City,
-		ad.Address,
-		ad.Latitude,
-		ad.Longitude,
-		ad.Square,
-		ad.Status,
-		id,
+// UpdateAdvertisement перезаписывает поля объявления, если его версия всё ещё равна version,
+// и увеличивает версию. Если объявление успели изменить, возвращается apperr.ErrVersionMismatch.
+// При изменении цены старая цена и время изменения сохраняются для индикатора снижения цены.
+// Если сменились валюта или период, старую цену сравнивать не с чем, и она сбрасывается.
+// Удобства объявления заменяются списком из ad.
+func (r *AdRepository) UpdateAdvertisement(ctx context.Context, id int, ad *models.UpdateAdvertisementInput, version int, updatedAt time.Time) error {
+	return withTx(ctx, r.db, func(tx DBTX) error {
+		res, err := tx.ExecContext(ctx, `
+            UPDATE advertisement
+            SET previous_price = CASE WHEN currency <> ? OR price_period <> ? THEN NULL
+                                      WHEN price <> ? THEN price ELSE previous_price END,
+                price_changed_at = CASE WHEN price <> ? OR currency <> ? OR price_period <> ? THEN ? ELSE price_changed_at END,
+                title = ?, description = ?, price = ?, currency = ?, price_period = ?, type = ?, rooms = ?, city = ?, 
+                address = ?, latitude = ?, longitude = ?, square = ?, floor = ?, floors_total = ?, furnishing = ?,
+                pets_allowed = ?, utilities_included = ?, deposit = ?, status = ?, 
+                updated_at = CURRENT_TIMESTAMP, version = version + 1
+            WHERE id = ? AND version = ? AND deleted_at IS NULL
+        `,
+			ad.Currency,
+			ad.PricePeriod,
+			ad.Price,
+			ad.Price,
+			ad.Currency,
+			ad.PricePeriod,
+			updatedAt,
+			ad.Title,
+			ad.Description,
+			ad.Price,
+			ad.Currency,
+			ad.PricePeriod,
+			ad.Type,
+			ad.Rooms,
+			ad.City,
+			ad.Address,
+			ad.Latitude,
+			ad.Longitude,
+			ad.Square,
+			ad.Floor,
+			ad.FloorsTotal,
+			ad.Furnishing,
+			ad.PetsAllowed,
+			ad.UtilitiesIncluded,
+			ad.Deposit,
+			ad.Status,
+			id,
+			version,
+		)
+		if err != nil {
+			return err
+		}
+		if err := expectAffected(res, apperr.ErrVersionMismatch); err != nil {
+			return err
+		}
+		return replaceAmenities(ctx, tx, id, ad.Amenities)
+	})
+}
+
+// GetAdvertisementState возвращает текущие значения редактируемых полей и версию объявления
+// (для истории изменений и проверки If-Match)
+func (r *AdRepository) GetAdvertisementState(ctx context.Context, id int) (*models.AdvertisementState, error) {
+	ad := &models.AdvertisementState{}
+	err := r.db.QueryRowContext(ctx, `
+        SELECT title, description, price, currency, price_period, type, rooms, city, address,
+               latitude, longitude, square, floor, floors_total, furnishing, pets_allowed, utilities_included, deposit,
+               status, version
+        FROM advertisement
+        WHERE id = ? AND deleted_at IS NULL
+    `, id).Scan(
+		&ad.Title,
+		&ad.Description,
+		&ad.Price,
+		&ad.Currency,
+		&ad.PricePeriod,
+		&ad.Type,
+		&ad.Rooms,
+		&ad.City,
+		&ad.Address,
+		&ad.Latitude,
+		&ad.Longitude,
+		&ad.Square,
+		&ad.Floor,
+		&ad.FloorsTotal,
+		&ad.Furnishing,
+		&ad.PetsAllowed,
+		&ad.UtilitiesIncluded,
+		&ad.Deposit,
+		&ad.Status,
+		&ad.Version,
 	)
-	return err
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperr.ErrAdvertisementNotFound
+		}
+		return nil, err
+	}
+	ad.Amenities, err = queryAmenities(ctx, r.db, id)
+	if err != nil {
+		return nil, err
+	}
+	return ad, nil
 }
 
 //
 // ============================
-// DELETE
+// HISTORY
+// ============================
+//
+
+// CreateAdvertisementVersion записывает изменённые поля как новую версию объявления и возвращает её номер
+func (r *AdRepository) CreateAdvertisementVersion(ctx context.Context, adID, changedBy int, changes []models.AdvertisementFieldChange, changedAt time.Time) (int, error) {
+	var version int
+	err := withTx(ctx, r.db, func(tx DBTX) error {
+		err := tx.QueryRowContext(
+			ctx,
+			"SELECT COALESCE(MAX(version), 0) + 1 FROM advertisement_history WHERE advertisement_id = ?",
+			adID,
+		).Scan(&version)
+		if err != nil {
+			return err
+		}
+
+		for _, change := range changes {
+			_, err := tx.ExecContext(ctx, `
+                INSERT INTO advertisement_history
+                (advertisement_id, version, changed_by, field, old_value, new_value, changed_at)
+                VALUES (?, ?, ?, ?, ?, ?, ?)
+            `, adID, version, changedBy, change.Field, change.OldValue, change.NewValue, changedAt)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// GetAdvertisementHistory возвращает версии объявления от новых к старым
+func (r *AdRepository) GetAdvertisementHistory(ctx context.Context, adID int) ([]*models.AdvertisementVersion, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT version, changed_by, changed_at, field, old_value, new_value
+        FROM advertisement_history
+        WHERE advertisement_id = ?
+        ORDER BY version DESC, id
+    `, adID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []*models.AdvertisementVersion
+	for rows.Next() {
+		var (
+			version models.AdvertisementVersion
+			change  models.AdvertisementFieldChange
+		)
+		if err := rows.Scan(&version.Version, &version.ChangedBy, &version.ChangedAt, &change.Field, &change.OldValue, &change.NewValue); err != nil {
+			return nil, err
+		}
+
+		// строки одной версии идут подряд
+		if n := len(versions); n == 0 || versions[n-1].Version != version.Version {
+			versions = append(versions, &version)
+		}
+		last := versions[len(versions)-1]
+		last.Changes = append(last.Changes, change)
+	}
+
+	return versions, rows.Err()
+}
+
+//
+// ============================
+// FAVORITES & CONTACTS
 // ============================
 //
 
-func (r *AdRepository) DeleteAdvertisement(id int) error {
-	_, err := r.db.Exec("DELETE FROM advertisement WHERE id = ?", id)
+// AddFavorite добавляет объявление в избранное пользователя.
+// Возвращает false, если оно уже было в избранном.
+func (r *AdRepository) AddFavorite(ctx context.Context, userID, adID int, createdAt time.Time) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+        INSERT INTO favorite (user_id, advertisement_id, created_at)
+        VALUES (?, ?, ?)
+        ON CONFLICT (user_id, advertisement_id) DO NOTHING
+    `, userID, adID, createdAt)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// RemoveFavorite убирает объявление из избранного пользователя (если его там нет — не ошибка)
+func (r *AdRepository) RemoveFavorite(ctx context.Context, userID, adID int) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM favorite WHERE user_id = ? AND advertisement_id = ?", userID, adID)
 	return err
 }
 
-func (r *AdRepository) DeleteAdvertisementImage(adID, imageID int) error {
-	_, err := r.db.Exec(`
-        DELETE FROM advertisement_photos 
+// CountFavorites возвращает, у скольких пользователей объявление сейчас в избранном
+func (r *AdRepository) CountFavorites(ctx context.Context, adID int) (int, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx, `
+        SELECT COUNT(*)
+        FROM favorite f
+        JOIN "user" u ON u.id = f.user_id
+        WHERE f.advertisement_id = ? AND u.deleted_at IS NULL
+    `, adID).Scan(&n)
+	return n, err
+}
+
+// GetLandlordContacts возвращает контакты арендодателя объявления
+func (r *AdRepository) GetLandlordContacts(ctx context.Context, adID int) (*models.LandlordContacts, error) {
+	contacts := &models.LandlordContacts{}
+	err := r.db.QueryRowContext(ctx, `
+        SELECT p.first_name, u.email, u.phone_number
+        FROM advertisement a
+        JOIN "user" u ON u.id = a.user_id
+        LEFT JOIN user_profile p ON p.user_id = u.id
+        WHERE a.id = ? AND a.deleted_at IS NULL AND u.deleted_at IS NULL
+    `, adID).Scan(&contacts.Name, &contacts.Email, &contacts.Phone)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperr.ErrAdvertisementNotFound
+		}
+		return nil, err
+	}
+	return contacts, nil
+}
+
+//
+// ============================
+// PHOTOS (ORDER, COVER, CAPTION)
+// ============================
+//
+
+// ReorderAdvertisementImages атомарно задаёт новый порядок фото.
+// imageIDs должен содержать ровно все фото объявления.
+func (r *AdRepository) ReorderAdvertisementImages(ctx context.Context, adID int, imageIDs []int) error {
+	return withTx(ctx, r.db, func(tx DBTX) error {
+		rows, err := tx.QueryContext(ctx, "SELECT id FROM advertisement_photos WHERE advertisement_id = ?", adID)
+		if err != nil {
+			return err
+		}
+		existing := map[int]bool{}
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			existing[id] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if len(imageIDs) != len(existing) {
+			return apperr.Invalid("invalid_image_order", "image order must contain every image of the advertisement")
+		}
+		seen := map[int]bool{}
+		for _, id := range imageIDs {
+			if !existing[id] || seen[id] {
+				return apperr.Invalid("invalid_image_order", "image order must contain every image of the advertisement")
+			}
+			seen[id] = true
+		}
+
+		for position, id := range imageIDs {
+			_, err := tx.ExecContext(
+				ctx,
+				"UPDATE advertisement_photos SET position = ? WHERE id = ? AND advertisement_id = ?",
+				position, id, adID,
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// SetAdvertisementCover делает указанное фото обложкой объявления
+func (r *AdRepository) SetAdvertisementCover(ctx context.Context, adID, imageID int) error {
+	return withTx(ctx, r.db, func(tx DBTX) error {
+		var exists int
+		err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM advertisement_photos WHERE id = ? AND advertisement_id = ?", imageID, adID).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if exists == 0 {
+			return apperr.ErrImageNotFound
+		}
+
+		if _, err := tx.ExecContext(ctx, "UPDATE advertisement_photos SET is_cover = FALSE WHERE advertisement_id = ? AND is_cover = TRUE", adID); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE advertisement_photos SET is_cover = TRUE WHERE id = ? AND advertisement_id = ?", imageID, adID)
+		return err
+	})
+}
+
+// UpdateAdvertisementImageCaption обновляет подпись фото
+func (r *AdRepository) UpdateAdvertisementImageCaption(ctx context.Context, adID, imageID int, caption *string) error {
+	res, err := r.db.ExecContext(ctx, `
+        UPDATE advertisement_photos
+        SET caption = ?
         WHERE id = ? AND advertisement_id = ?
-    `, imageID, adID)
-	return err
+    `, caption, imageID, adID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return apperr.ErrImageNotFound
+	}
+	return nil
+}
+
+//
+// ============================
+// DELETE
+// ============================
+//
+
+// DeleteAdvertisement помечает объявление удалённым (soft delete), фото и файлы остаются до очистки
+func (r *AdRepository) DeleteAdvertisement(ctx context.Context, id int, deletedAt time.Time) error {
+	res, err := r.db.ExecContext(ctx, "UPDATE advertisement SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL", deletedAt, id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res, apperr.ErrAdvertisementNotFound)
+}
+
+// GetDeletedAdvertisement возвращает удалённое объявление (для отмены удаления)
+func (r *AdRepository) GetDeletedAdvertisement(ctx context.Context, id int) (*models.DeletedAdvertisement, error) {
+	ad := &models.DeletedAdvertisement{}
+	err := r.db.QueryRowContext(
+		ctx,
+		"SELECT id, user_id, deleted_at FROM advertisement WHERE id = ? AND deleted_at IS NOT NULL",
+		id,
+	).Scan(&ad.ID, &ad.UserID, &ad.DeletedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperr.ErrDeletedAdvertisementNotFound
+		}
+		return nil, err
+	}
+	return ad, nil
+}
+
+// RestoreAdvertisement снимает пометку об удалении.
+// Объявления удалённого пользователя восстанавливаются только вместе с ним.
+func (r *AdRepository) RestoreAdvertisement(ctx context.Context, id int) error {
+	res, err := r.db.ExecContext(ctx, `
+        UPDATE advertisement SET deleted_at = NULL
+        WHERE id = ? AND deleted_at IS NOT NULL
+          AND user_id IN (SELECT id FROM "user" WHERE deleted_at IS NULL)
+    `, id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res, apperr.ErrDeletedAdvertisementNotFound)
+}
+
+// GetAdvertisementsDeletedBefore возвращает объявления, удалённые раньше before (для окончательной очистки)
+func (r *AdRepository) GetAdvertisementsDeletedBefore(ctx context.Context, before time.Time) ([]*models.DeletedAdvertisement, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		"SELECT id, user_id, deleted_at FROM advertisement WHERE deleted_at IS NOT NULL AND deleted_at < ? ORDER BY deleted_at",
+		before,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ads []*models.DeletedAdvertisement
+	for rows.Next() {
+		ad := &models.DeletedAdvertisement{}
+		if err := rows.Scan(&ad.ID, &ad.UserID, &ad.DeletedAt); err != nil {
+			return nil, err
+		}
+		ads = append(ads, ad)
+	}
+
+	return ads, rows.Err()
+}
+
+// PurgeAdvertisement окончательно удаляет помеченное объявление вместе с фото
+// и возвращает пути фото, чтобы удалить файлы
+func (r *AdRepository) PurgeAdvertisement(ctx context.Context, id int) ([]string, error) {
+	var paths []string
+	err := withTx(ctx, r.db, func(tx DBTX) error {
+		var err error
+		paths, err = queryStrings(ctx, tx, `
+            SELECT p.photo_url
+            FROM advertisement_photos p
+            JOIN advertisement a ON a.id = p.advertisement_id
+            WHERE a.id = ? AND a.deleted_at IS NOT NULL
+        `, id)
+		if err != nil {
+			return err
+		}
+
+		res, err := tx.ExecContext(ctx, "DELETE FROM advertisement WHERE id = ? AND deleted_at IS NOT NULL", id)
+		if err != nil {
+			return err
+		}
+		return expectAffected(res, apperr.ErrDeletedAdvertisementNotFound)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+func (r *AdRepository) DeleteAdvertisementImage(ctx context.Context, adID, imageID int) error {
+	return withTx(ctx, r.db, func(tx DBTX) error {
+		_, err := tx.ExecContext(
+			ctx,
+			"DELETE FROM advertisement_photos WHERE id = ? AND advertisement_id = ?",
+			imageID, adID,
+		)
+		if err != nil {
+			return err
+		}
+
+		// если удалили обложку — обложкой становится первое оставшееся фото
+		_, err = tx.ExecContext(ctx, `
+            UPDATE advertisement_photos
+            SET is_cover = TRUE
+            WHERE id = (
+                SELECT id FROM advertisement_photos
+                WHERE advertisement_id = ?
+                ORDER BY position, id
+                LIMIT 1
+            )
+            AND NOT EXISTS (
+                SELECT 1 FROM advertisement_photos
+                WHERE advertisement_id = ? AND is_cover = TRUE
+            )
+        `, adID, adID)
+		return err
+	})
+}
+
+// GetAllImages возвращает все фото всех объявлений (для проверки хранилища).
+// Фото удалённых объявлений тоже возвращаются: их файлы нужны для восстановления.
+func (r *AdRepository) GetAllImages(ctx context.Context) ([]*models.StoredImage, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT id, advertisement_id, photo_url FROM advertisement_photos ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var images []*models.StoredImage
+	for rows.Next() {
+		image := &models.StoredImage{}
+		if err := rows.Scan(&image.ID, &image.AdvertisementID, &image.URL); err != nil {
+			return nil, err
+		}
+		images = append(images, image)
+	}
+
+	return images, rows.Err()
 }
 
-func (r *AdRepository) GetImagePath(adID, imageID int) (string, error) {
+func (r *AdRepository) GetImagePath(ctx context.Context, adID, imageID int) (string, error) {
 	var path string
-	err := r.db.QueryRow("SELECT photo_url FROM advertisement_photos WHERE id = ? AND advertisement_id = ?", imageID, adID).Scan(&path)
+	err := r.db.QueryRowContext(ctx, "SELECT photo_url FROM advertisement_photos WHERE id = ? AND advertisement_id = ?", imageID, adID).Scan(&path)
 	return path, err
 }
+
+// GetImageSources возвращает импортированные фото объявления: URL источника -> ID фото
+func (r *AdRepository) GetImageSources(ctx context.Context, adID int) (map[string]int, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		"SELECT id, source_url FROM advertisement_photos WHERE advertisement_id = ? AND source_url IS NOT NULL",
+		adID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sources := make(map[string]int)
+	for rows.Next() {
+		var id int
+		var source string
+		if err := rows.Scan(&id, &source); err != nil {
+			return nil, err
+		}
+		sources[source] = id
+	}
+
+	return sources, rows.Err()
+}
+
+// queryCoverImage возвращает обложку объявления (или первое фото), nil если фото нет
+func queryCoverImage(ctx context.Context, db DBTX, adID int) *models.ImageUrl {
+	image := &models.ImageUrl{ImageId: -1, ImageUrl: ""}
+
+	_ = db.QueryRowContext(ctx, `
+        SELECT id, photo_url, position, is_cover, caption
+        FROM advertisement_photos
+        WHERE advertisement_id = ?
+        ORDER BY is_cover DESC, position, id
+        LIMIT 1
+    `, adID).Scan(&image.ImageId, &image.ImageUrl, &image.Position, &image.IsCover, &image.Caption)
+
+	if image.ImageUrl == "" || image.ImageId == -1 {
+		return nil
+	}
+	return image
+}
+
+// queryStrings выполняет запрос с одной текстовой колонкой (photo_url, имена файлов) и возвращает её значения
+func queryStrings(ctx context.Context, db DBTX, query string, args ...any) ([]string, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+
+	return values, rows.Err()
+}
+
+// expectAffected возвращает ошибку notFound, если запрос не изменил ни одной строки
+func expectAffected(res sql.Result, notFound error) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return notFound
+	}
+	return nil
+}
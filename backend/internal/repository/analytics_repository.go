@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"rentor/internal/models"
+)
+
+// analyticsRepository implements AnalyticsRepository
+type analyticsRepository struct {
+	db DBTX
+}
+
+// NewAnalyticsRepository creates a new analytics repository
+func NewAnalyticsRepository(db DBTX) AnalyticsRepository {
+	return &analyticsRepository{db: db}
+}
+
+// GetActiveListingPrices retrieves the fields of all active advertisements used by the city rollups
+func (r *analyticsRepository) GetActiveListingPrices(ctx context.Context) ([]*models.ListingPrice, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT city, type, rooms, price, currency, price_period, square, created_at
+        FROM advertisement
+        WHERE status = 'active' AND deleted_at IS NULL
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var listings []*models.ListingPrice
+	for rows.Next() {
+		listing := &models.ListingPrice{}
+		if err := rows.Scan(&listing.City, &listing.Type, &listing.Rooms, &listing.Price, &listing.Currency, &listing.PricePeriod, &listing.Square, &listing.CreatedAt); err != nil {
+			return nil, err
+		}
+		listings = append(listings, listing)
+	}
+
+	return listings, rows.Err()
+}
+
+// ReplaceCityPriceStats replaces all price rollups with stats in one transaction,
+// so readers never see a half-built set
+func (r *analyticsRepository) ReplaceCityPriceStats(ctx context.Context, stats []*models.CityPriceStats) error {
+	return withTx(ctx, r.db, func(tx DBTX) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM city_price_stats"); err != nil {
+			return err
+		}
+
+		for _, s := range stats {
+			_, err := tx.ExecContext(ctx, `
+                INSERT INTO city_price_stats
+                (city, type, rooms, listings, price_p25, price_median, price_p75, price_p90, price_per_m2_median, computed_at)
+                VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+            `,
+				s.City,
+				s.Type,
+				s.Rooms,
+				s.Listings,
+				s.PriceP25,
+				s.PriceMedian,
+				s.PriceP75,
+				s.PriceP90,
+				s.PricePerM2Median,
+				s.ComputedAt,
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// UpsertCityDailyStats stores the daily snapshots, replacing earlier snapshots of the same day
+func (r *analyticsRepository) UpsertCityDailyStats(ctx context.Context, stats []*models.CityDailyStats) error {
+	return withTx(ctx, r.db, func(tx DBTX) error {
+		for _, s := range stats {
+			_, err := tx.ExecContext(ctx, `
+                INSERT INTO city_daily_stats (city, day, active_listings, new_listings, price_median)
+                VALUES (?, ?, ?, ?, ?)
+                ON CONFLICT (city, day) DO UPDATE SET
+                    active_listings = excluded.active_listings,
+                    new_listings = excluded.new_listings,
+                    price_median = excluded.price_median
+            `, s.City, s.Day, s.ActiveListings, s.NewListings, s.PriceMedian)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetCityPriceStats retrieves all price rollups of a city
+func (r *analyticsRepository) GetCityPriceStats(ctx context.Context, city string) ([]*models.CityPriceStats, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT city, type, rooms, listings, price_p25, price_median, price_p75, price_p90, price_per_m2_median, computed_at
+        FROM city_price_stats
+        WHERE city = ?
+        ORDER BY type, rooms
+    `, city)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []*models.CityPriceStats
+	for rows.Next() {
+		s := &models.CityPriceStats{}
+		if err := rows.Scan(&s.City, &s.Type, &s.Rooms, &s.Listings, &s.PriceP25, &s.PriceMedian, &s.PriceP75, &s.PriceP90, &s.PricePerM2Median, &s.ComputedAt); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+
+	return stats, rows.Err()
+}
+
+// GetCityDailyStats retrieves the daily snapshots of a city from the given day on, oldest first
+func (r *analyticsRepository) GetCityDailyStats(ctx context.Context, city string, since time.Time) ([]*models.CityDailyStats, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT city, day, active_listings, new_listings, price_median
+        FROM city_daily_stats
+        WHERE city = ? AND day >= ?
+        ORDER BY day
+    `, city, since.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []*models.CityDailyStats
+	for rows.Next() {
+		s := &models.CityDailyStats{}
+		if err := rows.Scan(&s.City, &s.Day, &s.ActiveListings, &s.NewListings, &s.PriceMedian); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+
+	return stats, rows.Err()
+}
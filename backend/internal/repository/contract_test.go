@@ -0,0 +1,212 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"rentor/internal/apperr"
+	"rentor/internal/models"
+	"rentor/internal/repository"
+	"rentor/internal/storage/storagetest"
+)
+
+// contractTests run on every storage driver, each on a database of its own
+var contractTests = []struct {
+	name string
+	fn   func(t *testing.T, db repository.DBTX, repos *repository.Repositories)
+}{
+	{"insert returns ids", testInsertIDs},
+	{"nested inserts in a transaction", testNestedInsertIDs},
+	{"placeholders", testPlaceholders},
+	{"unique violations", testUniqueViolations},
+	{"rollback", testRollback},
+}
+
+// TestRepositoryContract runs the same repository checks on SQLite and PostgreSQL,
+// the PostgreSQL run is skipped unless RENTOR_TEST_POSTGRES_DSN is set
+func TestRepositoryContract(t *testing.T) {
+	for _, driver := range storagetest.Drivers {
+		t.Run(driver, func(t *testing.T) {
+			for _, tt := range contractTests {
+				t.Run(tt.name, func(t *testing.T) {
+					db := newDB(storagetest.Open(t, driver), driver)
+					tt.fn(t, db, repository.NewRepositories(db))
+				})
+			}
+		})
+	}
+}
+
+func testInsertIDs(t *testing.T, db repository.DBTX, repos *repository.Repositories) {
+	ctx := context.Background()
+	phone := "+77011234567"
+	ids := map[int]bool{}
+	for _, contact := range []struct{ phone, email string }{
+		{"", "email@example.com"},
+		{phone, "phone@example.com"},
+	} {
+		id, err := repos.User.CreateUser(ctx, contact.phone, contact.email)
+		if err != nil {
+			t.Fatalf("create user %+v: %v", contact, err)
+		}
+		if id <= 0 || ids[id] {
+			t.Fatalf("create user %+v: id = %d, ids so far %v", contact, id, ids)
+		}
+		ids[id] = true
+	}
+
+	user, err := repos.User.GetUserByPhone(ctx, phone)
+	if err != nil {
+		t.Fatalf("get user: %v", err)
+	}
+	if !ids[user.UserID] {
+		t.Errorf("user id %d is not one of the returned ids %v", user.UserID, ids)
+	}
+
+	profileID, err := repos.UserProfile.CreateUserProfile(ctx, &models.UserProfile{UserID: user.UserID})
+	if err != nil {
+		t.Fatalf("create profile: %v", err)
+	}
+	profile, err := repos.UserProfile.GetUserProfileByID(ctx, profileID)
+	if err != nil {
+		t.Fatalf("get profile: %v", err)
+	}
+	if profile.UserID != user.UserID {
+		t.Errorf("profile %d belongs to user %d, want %d", profileID, profile.UserID, user.UserID)
+	}
+
+	adID := createAd(t, repos, user.UserID, "elevator")
+	ad, err := repos.Advertisement.GetAdvertisement(ctx, adID)
+	if err != nil {
+		t.Fatalf("get advertisement: %v", err)
+	}
+	if ad.ID != adID {
+		t.Errorf("advertisement id = %d, want %d", ad.ID, adID)
+	}
+
+	archiveID, err := repos.Export.CreateExportArchive(ctx, &models.ExportArchive{
+		UserID: user.UserID, Status: models.ExportArchiveQueued, CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		t.Fatalf("create archive: %v", err)
+	}
+	archive, err := repos.Export.GetExportArchiveByID(ctx, archiveID)
+	if err != nil {
+		t.Fatalf("get archive: %v", err)
+	}
+	if archive.ID != archiveID || archive.UserID != user.UserID {
+		t.Errorf("archive = %+v, want id %d of user %d", archive, archiveID, user.UserID)
+	}
+}
+
+func testNestedInsertIDs(t *testing.T, db repository.DBTX, repos *repository.Repositories) {
+	ctx := context.Background()
+	userID := createUser(t, repos, 1)
+	adID := createAd(t, repos, userID)
+
+	// the session and its objects are inserted in one transaction, the objects reference the session id
+	sessionID, err := repos.Upload.CreateUploadSession(ctx, &models.UploadSession{
+		AdvertisementID: adID,
+		UserID:          userID,
+		Status:          models.UploadSessionPending,
+		ExpiresAt:       time.Now().Add(time.Hour).UTC(),
+		Objects: []models.UploadObject{
+			{Token: "first", Filename: "1.png", ContentType: "image/png", MaxSize: 1 << 20},
+			{Token: "second", Filename: "2.png", ContentType: "image/png", MaxSize: 1 << 20},
+		},
+	})
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+
+	session, err := repos.Upload.GetUploadSessionByID(ctx, sessionID)
+	if err != nil {
+		t.Fatalf("get session: %v", err)
+	}
+	if len(session.Objects) != 2 {
+		t.Fatalf("session has %d objects, want 2", len(session.Objects))
+	}
+	for _, token := range []string{"first", "second"} {
+		object, err := repos.Upload.GetUploadObjectByToken(ctx, token)
+		if err != nil {
+			t.Fatalf("get object %s: %v", token, err)
+		}
+		if object.ID <= 0 || object.SessionID != sessionID {
+			t.Errorf("object %s: id = %d, session = %d, want session %d", token, object.ID, object.SessionID, sessionID)
+		}
+	}
+	if session.Objects[0].ID == session.Objects[1].ID {
+		t.Errorf("objects share id %d", session.Objects[0].ID)
+	}
+}
+
+func testPlaceholders(t *testing.T, db repository.DBTX, repos *repository.Repositories) {
+	ctx := context.Background()
+	userID := createUser(t, repos, 1)
+	adID := createAd(t, repos, userID, "elevator", "wifi")
+	createAd(t, repos, createUser(t, repos, 2))
+
+	// question marks in literals and quoted identifiers are not placeholders
+	var n int
+	err := db.QueryRowContext(ctx, `
+        SELECT COUNT(*) FROM "user" AS "who?"
+        WHERE "who?".email = ? AND 'why?' <> ? AND "who?".id = ?
+    `, "user1@example.com", "because?", userID).Scan(&n)
+	if err != nil {
+		t.Fatalf("query with quoted question marks: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("query with quoted question marks found %d users, want 1", n)
+	}
+
+	city, kind, rooms := "Moscow", "flat", "2"
+	list, err := repos.Advertisement.GetAdvertisementsPaged(ctx, &models.AdFilters{
+		Page: 1, Limit: 10, City: &city, Type: &kind, Rooms: &rooms,
+		Amenities: []string{"elevator", "wifi"}, UserID: &userID,
+	})
+	if err != nil {
+		t.Fatalf("get advertisements: %v", err)
+	}
+	if list.Total != 1 || len(list.Items) != 1 || list.Items[0].ID != adID {
+		t.Errorf("filtered list = %+v, want advertisement %d only", list, adID)
+	}
+}
+
+func testUniqueViolations(t *testing.T, db repository.DBTX, repos *repository.Repositories) {
+	ctx := context.Background()
+	createUser(t, repos, 1)
+	userID := createUser(t, repos, 2)
+
+	err := repos.User.UpdateUserEmail(ctx, userID, "user1@example.com", time.Now().UTC())
+	if !errors.Is(err, apperr.ErrConflict) {
+		t.Errorf("taking the email of another user: err = %v, want a conflict", err)
+	}
+}
+
+func testRollback(t *testing.T, db repository.DBTX, repos *repository.Repositories) {
+	ctx := context.Background()
+	errRollback := errors.New("rollback")
+
+	err := repository.NewTxManager(db).WithinTx(ctx, func(repos *repository.Repositories) error {
+		userID := createUser(t, repos, 1)
+		createAd(t, repos, userID)
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("WithinTx: err = %v, want %v", err, errRollback)
+	}
+
+	if n := count(t, db, `SELECT COUNT(*) FROM "user"`); n != 0 {
+		t.Errorf("%d users are left after the rollback", n)
+	}
+	if n := count(t, db, `SELECT COUNT(*) FROM advertisement`); n != 0 {
+		t.Errorf("%d advertisements are left after the rollback", n)
+	}
+
+	// the sequences keep working after the rollback
+	if id := createUser(t, repos, 2); id <= 0 {
+		t.Errorf("user id after the rollback = %d", id)
+	}
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mattn/go-sqlite3"
+)
+
+// Dialect is the SQL flavour of the database behind the repositories
+type Dialect string
+
+const (
+	DialectSQLite   Dialect = "sqlite"
+	DialectPostgres Dialect = "postgres"
+)
+
+// conn is a DBTX that adapts the queries written by repositories (SQLite style, with ? placeholders)
+// to the dialect of the database and sends reads outside of transactions to the read pool
+type conn struct {
+	db      DBTX // *sql.DB or *sql.Tx
+	read    DBTX // read-only pool, nil inside transactions or when there is none
+	dialect Dialect
+}
+
+// NewDB binds the write pool and the optional read pool (may be nil) to a dialect.
+// Repositories created on top of it produce queries for that dialect, including inside transactions.
+func NewDB(db, read *sql.DB, dialect Dialect) DBTX {
+	c := &conn{db: db, dialect: dialect}
+	if read != nil {
+		c.read = read
+	}
+	return c
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return c.db.ExecContext(ctx, c.rebind(query), args...)
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return c.reader().QueryContext(ctx, c.rebind(query), args...)
+}
+
+func (c *conn) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return c.reader().QueryRowContext(ctx, c.rebind(query), args...)
+}
+
+// reader returns the pool for read queries
+func (c *conn) reader() DBTX {
+	if c.read != nil {
+		return c.read
+	}
+	return c.db
+}
+
+// rebind replaces ? placeholders with $1, $2, ... for PostgreSQL.
+// Question marks inside quoted strings and identifiers are left as is.
+func (c *conn) rebind(query string) string {
+	if c.dialect != DialectPostgres || !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+
+	n := 0
+	var quote rune
+	for _, ch := range query {
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+		case ch == '?':
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(ch)
+	}
+
+	return b.String()
+}
+
+// dialectOf returns the dialect db is bound to. Plain *sql.DB and *sql.Tx are treated as SQLite.
+func dialectOf(db DBTX) Dialect {
+	if c, ok := db.(*conn); ok {
+		return c.dialect
+	}
+	return DialectSQLite
+}
+
+// insertID runs an INSERT and returns the id of the new row.
+// SQLite reports it through LastInsertId, PostgreSQL needs RETURNING id.
+func insertID(ctx context.Context, db DBTX, query string, args ...any) (int, error) {
+	if dialectOf(db) == DialectPostgres {
+		// RETURNING makes it a query, it must not go to the read pool
+		if c, ok := db.(*conn); ok {
+			db = c.db
+			query = c.rebind(query)
+		}
+		var id int
+		err := db.QueryRowContext(ctx, strings.TrimSpace(query)+" RETURNING id", args...).Scan(&id)
+		return id, err
+	}
+
+	res, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	id64, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id64), nil
+}
+
+// isUniqueViolation reports whether err is a write rejected by a UNIQUE constraint
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "23505" // unique_violation
+	}
+	return false
+}
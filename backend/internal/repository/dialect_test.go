@@ -0,0 +1,179 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"rentor/internal/repository"
+)
+
+// recorder is a database/sql driver that records the statements it is sent.
+// Every query returns one row with id 42, every exec reports last insert id 7.
+type recorder struct {
+	mu    sync.Mutex
+	execs []string
+	reads []string
+}
+
+func (r *recorder) record(list *[]string, query string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	*list = append(*list, strings.Join(strings.Fields(query), " "))
+}
+
+func (r *recorder) Connect(context.Context) (driver.Conn, error) { return recorderConn{r}, nil }
+func (r *recorder) Driver() driver.Driver                        { return nil }
+
+type recorderConn struct{ r *recorder }
+
+func (c recorderConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c recorderConn) Close() error                        { return nil }
+func (c recorderConn) Begin() (driver.Tx, error)           { return recorderTx{}, nil }
+
+func (c recorderConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.r.record(&c.r.execs, query)
+	return recorderResult{}, nil
+}
+
+func (c recorderConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.r.record(&c.r.reads, query)
+	return &recorderRows{}, nil
+}
+
+type recorderTx struct{}
+
+func (recorderTx) Commit() error   { return nil }
+func (recorderTx) Rollback() error { return nil }
+
+type recorderResult struct{}
+
+func (recorderResult) LastInsertId() (int64, error) { return 7, nil }
+func (recorderResult) RowsAffected() (int64, error) { return 1, nil }
+
+type recorderRows struct{ done bool }
+
+func (r *recorderRows) Columns() []string { return []string{"id"} }
+func (r *recorderRows) Close() error      { return nil }
+func (r *recorderRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(42)
+	return nil
+}
+
+// openRecorded binds a recorded write pool and read pool to dialect
+func openRecorded(t *testing.T, dialect repository.Dialect) (db repository.DBTX, write, read *recorder) {
+	t.Helper()
+	write, read = &recorder{}, &recorder{}
+	writePool, readPool := sql.OpenDB(write), sql.OpenDB(read)
+	t.Cleanup(func() {
+		writePool.Close()
+		readPool.Close()
+	})
+	return repository.NewDB(writePool, readPool, dialect), write, read
+}
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		query    string
+		postgres string
+	}{
+		{`SELECT id FROM advertisement WHERE id = ?`, `SELECT id FROM advertisement WHERE id = $1`},
+		{`SELECT id FROM advertisement WHERE city = ? AND price BETWEEN ? AND ? LIMIT ? OFFSET ?`,
+			`SELECT id FROM advertisement WHERE city = $1 AND price BETWEEN $2 AND $3 LIMIT $4 OFFSET $5`},
+		{`SELECT id FROM "user" WHERE email = ? AND note <> 'why?' AND "odd?" = ?`,
+			`SELECT id FROM "user" WHERE email = $1 AND note <> 'why?' AND "odd?" = $2`},
+		{`SELECT id FROM advertisement WHERE title = 'it''s ?' AND id = ?`,
+			`SELECT id FROM advertisement WHERE title = 'it''s ?' AND id = $1`},
+		{`SELECT COUNT(*) FROM advertisement`, `SELECT COUNT(*) FROM advertisement`},
+	}
+	for _, dialect := range []repository.Dialect{repository.DialectSQLite, repository.DialectPostgres} {
+		db, write, read := openRecorded(t, dialect)
+		for _, tt := range tests {
+			want := tt.query
+			if dialect == repository.DialectPostgres {
+				want = tt.postgres
+			}
+
+			var id int
+			if err := db.QueryRowContext(context.Background(), tt.query).Scan(&id); err != nil {
+				t.Fatalf("%s: query: %v", dialect, err)
+			}
+			if got := read.reads[len(read.reads)-1]; got != want {
+				t.Errorf("%s: query sent as\n%s\nwant\n%s", dialect, got, want)
+			}
+			if _, err := db.ExecContext(context.Background(), tt.query); err != nil {
+				t.Fatalf("%s: exec: %v", dialect, err)
+			}
+			if got := write.execs[len(write.execs)-1]; got != want {
+				t.Errorf("%s: exec sent as\n%s\nwant\n%s", dialect, got, want)
+			}
+		}
+	}
+}
+
+func TestInsertID(t *testing.T) {
+	tests := []struct {
+		dialect repository.Dialect
+		id      int
+		execs   []string
+		reads   []string
+	}{
+		{
+			dialect: repository.DialectSQLite,
+			id:      7,
+			execs:   []string{`INSERT INTO "user" (email) VALUES (?)`},
+		},
+		{
+			// RETURNING makes the insert a query, it still goes to the write pool
+			dialect: repository.DialectPostgres,
+			id:      42,
+			reads:   []string{`INSERT INTO "user" (email) VALUES ($1) RETURNING id`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.dialect), func(t *testing.T) {
+			run := func(t *testing.T, db repository.DBTX, write, read *recorder, create func() (int, error)) {
+				id, err := create()
+				if err != nil {
+					t.Fatalf("create user: %v", err)
+				}
+				if id != tt.id {
+					t.Errorf("id = %d, want %d", id, tt.id)
+				}
+				if strings.Join(write.execs, "\n") != strings.Join(tt.execs, "\n") ||
+					strings.Join(write.reads, "\n") != strings.Join(tt.reads, "\n") {
+					t.Errorf("write pool got execs %q and queries %q, want %q and %q", write.execs, write.reads, tt.execs, tt.reads)
+				}
+				if len(read.execs)+len(read.reads) != 0 {
+					t.Errorf("read pool got %q %q", read.execs, read.reads)
+				}
+			}
+
+			t.Run("pool", func(t *testing.T) {
+				db, write, read := openRecorded(t, tt.dialect)
+				run(t, db, write, read, func() (int, error) {
+					return repository.NewUserRepository(db).CreateUser(context.Background(), "", "user@example.com")
+				})
+			})
+			t.Run("transaction", func(t *testing.T) {
+				db, write, read := openRecorded(t, tt.dialect)
+				run(t, db, write, read, func() (id int, err error) {
+					err = repository.NewTxManager(db).WithinTx(context.Background(), func(repos *repository.Repositories) error {
+						id, err = repos.User.CreateUser(context.Background(), "", "user@example.com")
+						return err
+					})
+					return id, err
+				})
+			})
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"rentor/internal/apperr"
+	"rentor/internal/models"
+)
+
+// exportRepository implements ExportRepository
+type exportRepository struct {
+	db DBTX
+}
+
+// NewExportRepository creates a new export repository
+func NewExportRepository(db DBTX) ExportRepository {
+	return &exportRepository{db: db}
+}
+
+// CreateExportArchive stores a queued archive
+func (r *exportRepository) CreateExportArchive(ctx context.Context, archive *models.ExportArchive) (int, error) {
+	return insertID(
+		ctx,
+		r.db,
+		"INSERT INTO export_archive (user_id, status, created_at) VALUES (?, ?, ?)",
+		archive.UserID,
+		archive.Status,
+		archive.CreatedAt,
+	)
+}
+
+// GetExportArchiveByID retrieves an archive by ID
+func (r *exportRepository) GetExportArchiveByID(ctx context.Context, id int) (*models.ExportArchive, error) {
+	archive, err := scanExportArchive(r.db.QueryRowContext(ctx, `
+        SELECT id, user_id, status, path, size, error, created_at, finished_at, expires_at
+        FROM export_archive
+        WHERE id = ?
+    `, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperr.ErrExportArchiveNotFound
+		}
+		return nil, err
+	}
+	return archive, nil
+}
+
+// GetPendingExportArchive retrieves the queued archive of a user, nil if there is none
+func (r *exportRepository) GetPendingExportArchive(ctx context.Context, userID int) (*models.ExportArchive, error) {
+	archive, err := scanExportArchive(r.db.QueryRowContext(ctx, `
+        SELECT id, user_id, status, path, size, error, created_at, finished_at, expires_at
+        FROM export_archive
+        WHERE user_id = ? AND status = ?
+        ORDER BY id
+        LIMIT 1
+    `, userID, models.ExportArchiveQueued))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return archive, nil
+}
+
+// GetNextExportArchive retrieves the oldest queued archive, nil if there is none
+func (r *exportRepository) GetNextExportArchive(ctx context.Context) (*models.ExportArchive, error) {
+	archive, err := scanExportArchive(r.db.QueryRowContext(ctx, `
+        SELECT id, user_id, status, path, size, error, created_at, finished_at, expires_at
+        FROM export_archive
+        WHERE status = ?
+        ORDER BY id
+        LIMIT 1
+    `, models.ExportArchiveQueued))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return archive, nil
+}
+
+// FinishExportArchive stores the outcome of generating an archive
+func (r *exportRepository) FinishExportArchive(ctx context.Context, archive *models.ExportArchive) error {
+	_, err := r.db.ExecContext(
+		ctx,
+		"UPDATE export_archive SET status = ?, path = ?, size = ?, error = ?, finished_at = ?, expires_at = ? WHERE id = ?",
+		archive.Status,
+		nullIfEmpty(archive.Path),
+		archive.Size,
+		archive.Error,
+		archive.FinishedAt,
+		archive.ExpiresAt,
+		archive.ID,
+	)
+	return err
+}
+
+// GetExpiredExportArchives retrieves archives whose expiry has passed
+func (r *exportRepository) GetExpiredExportArchives(ctx context.Context, now time.Time) ([]*models.ExportArchive, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT id, user_id, status, path, size, error, created_at, finished_at, expires_at
+        FROM export_archive
+        WHERE expires_at < ?
+        ORDER BY id
+    `, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var archives []*models.ExportArchive
+	for rows.Next() {
+		archive, err := scanExportArchive(rows)
+		if err != nil {
+			return nil, err
+		}
+		archives = append(archives, archive)
+	}
+
+	return archives, rows.Err()
+}
+
+// DeleteExportArchiveByID deletes an archive row
+func (r *exportRepository) DeleteExportArchiveByID(ctx context.Context, id int) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM export_archive WHERE id = ?", id)
+	return err
+}
+
+// scanExportArchive scans a row of export_archive
+func scanExportArchive(row interface{ Scan(dest ...any) error }) (*models.ExportArchive, error) {
+	archive := &models.ExportArchive{}
+	var path sql.NullString
+	err := row.Scan(&archive.ID, &archive.UserID, &archive.Status, &path, &archive.Size, &archive.Error, &archive.CreatedAt, &archive.FinishedAt, &archive.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	archive.Path = path.String
+	return archive, nil
+}
+
+// nullIfEmpty stores an empty string as NULL
+func nullIfEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
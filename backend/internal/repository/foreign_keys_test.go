@@ -0,0 +1,98 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"rentor/internal/models"
+	"rentor/internal/repository"
+	"rentor/internal/storage"
+	"rentor/internal/storage/storagetest"
+)
+
+// seedAdvertisement creates an advertisement of userID with rows in the tables that reference it
+func seedAdvertisement(t *testing.T, repos *repository.Repositories, userID int) int {
+	t.Helper()
+	ctx := context.Background()
+
+	adID := createAd(t, repos, userID, "elevator", "parking")
+	images := []models.SavedImage{{URL: "/images/1.jpg"}, {URL: "/images/2.jpg"}}
+	if err := repos.Advertisement.CreateAdvertisementImages(ctx, adID, images); err != nil {
+		t.Fatalf("create images: %v", err)
+	}
+	if err := repos.Recommendation.RecordRecentlyViewed(ctx, "visitor:abc", adID, time.Now(), 10); err != nil {
+		t.Fatalf("record view: %v", err)
+	}
+	return adID
+}
+
+// adChildren are the tables with rows of an advertisement, deleted with it by ON DELETE CASCADE
+var adChildren = []string{"advertisement_photos", "advertisement_amenity", "recently_viewed"}
+
+func TestPurgeAdvertisementCascades(t *testing.T) {
+	db := storagetest.OpenSQLite(t, storagetest.SQLiteOptions())
+	conn := newDB(db, storage.DriverSQLite)
+	repos := repository.NewRepositories(conn)
+	ctx := context.Background()
+
+	userID := createUser(t, repos, 1)
+	adID := seedAdvertisement(t, repos, userID)
+	otherID := seedAdvertisement(t, repos, userID)
+
+	if err := repos.Advertisement.DeleteAdvertisement(ctx, adID, time.Now()); err != nil {
+		t.Fatalf("delete advertisement: %v", err)
+	}
+	paths, err := repos.Advertisement.PurgeAdvertisement(ctx, adID)
+	if err != nil {
+		t.Fatalf("purge advertisement: %v", err)
+	}
+	if len(paths) != 2 {
+		t.Errorf("purge returned %d photo paths, want 2", len(paths))
+	}
+
+	for _, table := range adChildren {
+		if n := count(t, conn, "SELECT COUNT(*) FROM "+table+" WHERE advertisement_id = ?", adID); n != 0 {
+			t.Errorf("%s has %d rows of the purged advertisement, want 0", table, n)
+		}
+		if n := count(t, conn, "SELECT COUNT(*) FROM "+table+" WHERE advertisement_id = ?", otherID); n == 0 {
+			t.Errorf("%s lost the rows of another advertisement", table)
+		}
+	}
+}
+
+func TestPurgeUserCascades(t *testing.T) {
+	db := storagetest.OpenSQLite(t, storagetest.SQLiteOptions())
+	conn := newDB(db, storage.DriverSQLite)
+	repos := repository.NewRepositories(conn)
+	ctx := context.Background()
+
+	userID := createUser(t, repos, 1)
+	otherID := createUser(t, repos, 2)
+	seedAdvertisement(t, repos, userID)
+	seedAdvertisement(t, repos, userID)
+	seedAdvertisement(t, repos, otherID)
+
+	if err := repos.User.DeleteUserByID(ctx, userID, time.Now()); err != nil {
+		t.Fatalf("delete user: %v", err)
+	}
+	paths, err := repos.User.PurgeUserByID(ctx, userID)
+	if err != nil {
+		t.Fatalf("purge user: %v", err)
+	}
+	if len(paths) != 4 {
+		t.Errorf("purge returned %d photo paths, want 4", len(paths))
+	}
+
+	for _, table := range []string{"user_profile", "advertisement"} {
+		if n := count(t, conn, "SELECT COUNT(*) FROM "+table+" WHERE user_id = ?", userID); n != 0 {
+			t.Errorf("%s has %d rows of the purged user, want 0", table, n)
+		}
+	}
+	for _, table := range adChildren {
+		// only the advertisement of the other user is left
+		if n := count(t, conn, "SELECT COUNT(DISTINCT advertisement_id) FROM "+table); n != 1 {
+			t.Errorf("%s has rows of %d advertisements, want 1", table, n)
+		}
+	}
+}
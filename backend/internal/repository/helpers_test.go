@@ -0,0 +1,62 @@
+package repository_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"rentor/internal/models"
+	"rentor/internal/repository"
+	"rentor/internal/storage"
+)
+
+// newDB binds the pools of db the way the store does
+func newDB(db *storage.DB, driver string) repository.DBTX {
+	return repository.NewDB(db.DB, db.Read, repository.Dialect(driver))
+}
+
+// createUser creates a user with a unique email and a profile
+func createUser(t testing.TB, repos *repository.Repositories, n int) int {
+	t.Helper()
+	ctx := context.Background()
+	id, err := repos.User.CreateUser(ctx, "", fmt.Sprintf("user%d@example.com", n))
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	name := fmt.Sprintf("User %d", n)
+	if _, err := repos.UserProfile.CreateUserProfile(ctx, &models.UserProfile{UserID: id, FirstName: &name}); err != nil {
+		t.Fatalf("create profile: %v", err)
+	}
+	return id
+}
+
+// createAd creates an advertisement of userID with the given amenities
+func createAd(t testing.TB, repos *repository.Repositories, userID int, amenities ...string) int {
+	t.Helper()
+	id, err := repos.Advertisement.CreateAdvertisement(context.Background(), userID, &models.CreateAdvertisementInput{
+		Title:        "Flat in the centre",
+		Price:        5000000,
+		Currency:     "RUB",
+		PricePeriod:  "month",
+		Type:         "flat",
+		Rooms:        "2",
+		City:         "Moscow",
+		Address:      "Tverskaya 1",
+		Square:       54,
+		AdAttributes: models.AdAttributes{Amenities: amenities},
+	})
+	if err != nil {
+		t.Fatalf("create advertisement: %v", err)
+	}
+	return id
+}
+
+// count runs a SELECT COUNT(*) query
+func count(t testing.TB, db repository.DBTX, query string, args ...any) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRowContext(context.Background(), query, args...).Scan(&n); err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	return n
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"rentor/internal/apperr"
+	"rentor/internal/models"
+)
+
+// importRepository implements ImportRepository
+type importRepository struct {
+	db DBTX
+}
+
+// NewImportRepository creates a new import repository
+func NewImportRepository(db DBTX) ImportRepository {
+	return &importRepository{db: db}
+}
+
+// CreateImportJob stores a queued job together with the rows to import
+func (r *importRepository) CreateImportJob(ctx context.Context, job *models.ImportJob) (int, error) {
+	payload, err := json.Marshal(job.Rows)
+	if err != nil {
+		return 0, fmt.Errorf("CreateImportJob: failed to encode rows: %w", err)
+	}
+
+	return insertID(
+		ctx,
+		r.db,
+		"INSERT INTO import_job (user_id, format, status, total, payload, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		job.UserID,
+		job.Format,
+		job.Status,
+		job.Total,
+		string(payload),
+		job.CreatedAt,
+	)
+}
+
+// GetImportJobByID retrieves a job with its report, without the rows
+func (r *importRepository) GetImportJobByID(ctx context.Context, id int) (*models.ImportJob, error) {
+	job := &models.ImportJob{}
+	var report sql.NullString
+	err := r.db.QueryRowContext(ctx, `
+        SELECT id, user_id, format, status, total, processed, report, error, created_at, started_at, finished_at
+        FROM import_job
+        WHERE id = ?
+    `, id).Scan(&job.ID, &job.UserID, &job.Format, &job.Status, &job.Total, &job.Processed, &report, &job.Error, &job.CreatedAt, &job.StartedAt, &job.FinishedAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperr.ErrImportJobNotFound
+		}
+		return nil, err
+	}
+
+	if job.Report, err = decodeImportReport(report); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// GetNextImportJob retrieves the oldest unfinished job with its rows, nil if there is none.
+// Running jobs come back too: a job interrupted by a restart resumes from its processed rows.
+func (r *importRepository) GetNextImportJob(ctx context.Context) (*models.ImportJob, error) {
+	job := &models.ImportJob{}
+	var report, payload sql.NullString
+	err := r.db.QueryRowContext(ctx, `
+        SELECT id, user_id, format, status, total, processed, report, payload, created_at, started_at
+        FROM import_job
+        WHERE status IN (?, ?)
+        ORDER BY id
+        LIMIT 1
+    `, models.ImportJobQueued, models.ImportJobRunning).Scan(&job.ID, &job.UserID, &job.Format, &job.Status, &job.Total, &job.Processed, &report, &payload, &job.CreatedAt, &job.StartedAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if job.Report, err = decodeImportReport(report); err != nil {
+		return nil, err
+	}
+	if payload.Valid {
+		if err := json.Unmarshal([]byte(payload.String), &job.Rows); err != nil {
+			return nil, fmt.Errorf("GetNextImportJob: failed to decode rows of job %d: %w", job.ID, err)
+		}
+	}
+	return job, nil
+}
+
+// UpdateImportJobProgress marks the job running and stores the results of the rows processed so far
+func (r *importRepository) UpdateImportJobProgress(ctx context.Context, id, processed int, report *models.ImportReport, now time.Time) error {
+	encoded, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("UpdateImportJobProgress: failed to encode report: %w", err)
+	}
+
+	_, err = r.db.ExecContext(
+		ctx,
+		"UPDATE import_job SET status = ?, processed = ?, report = ?, started_at = COALESCE(started_at, ?) WHERE id = ?",
+		models.ImportJobRunning,
+		processed,
+		string(encoded),
+		now,
+		id,
+	)
+	return err
+}
+
+// FinishImportJob stores the final status and report and drops the rows
+func (r *importRepository) FinishImportJob(ctx context.Context, id int, status string, report *models.ImportReport, errMsg *string, finishedAt time.Time) error {
+	var encoded *string
+	if report != nil {
+		b, err := json.Marshal(report)
+		if err != nil {
+			return fmt.Errorf("FinishImportJob: failed to encode report: %w", err)
+		}
+		s := string(b)
+		encoded = &s
+	}
+
+	_, err := r.db.ExecContext(ctx, `
+        UPDATE import_job
+        SET status = ?, report = COALESCE(?, report), error = ?, payload = NULL,
+            processed = CASE WHEN ? = ? THEN total ELSE processed END,
+            started_at = COALESCE(started_at, ?), finished_at = ?
+        WHERE id = ?
+    `, status, encoded, errMsg, status, models.ImportJobCompleted, finishedAt, finishedAt, id)
+	return err
+}
+
+// decodeImportReport decodes the report column, nil if the job has no report yet
+func decodeImportReport(report sql.NullString) (*models.ImportReport, error) {
+	if !report.Valid {
+		return nil, nil
+	}
+	decoded := &models.ImportReport{}
+	if err := json.Unmarshal([]byte(report.String), decoded); err != nil {
+		return nil, fmt.Errorf("failed to decode import report: %w", err)
+	}
+	return decoded, nil
+}
@@ -1,55 +1,144 @@
 package repository
 
 import (
+	"context"
 	"rentor/internal/models"
+	"rentor/internal/money"
 	"time"
 )
 
 // UserRepository interface for working with users in the DB
 type UserRepository interface {
-	CreateUser(phone string, email string) (int, error)     // creates a new user with phone and email (one or both required)
-	GetUserByID(id int) (*models.User, error)               // retrieves a user by their ID
-	GetUserByEmail(email string) (*models.User, error)      // retrieves a user by their email
-	GetUserByPhone(phone string) (*models.User, error)      // retrieves a user by their phone
-	GetAllUsers() ([]*models.User, error)                   // retrieves all users
-	GetPageUsers(offset, limit int) ([]*models.User, error) // retrieves users with pagination
-	UpdateUser(id int, user *models.User) error             // updates user details
-	DeleteUserByID(id int) error                            // deletes a user by their ID
-	DeleteUserByPhone(phone string) error                   // deletes a user by their phone
-	DeleteUserByEmail(email string) error                   // deletes a user by their email
+	CreateUser(ctx context.Context, phone string, email string) (int, error)                    // creates a new user with phone and email (one or both required)
+	GetUserByID(ctx context.Context, id int) (*models.User, error)                              // retrieves a user by their ID
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)                     // retrieves a user by their email
+	GetUserByPhone(ctx context.Context, phone string) (*models.User, error)                     // retrieves a user by their phone
+	GetAllUsers(ctx context.Context) ([]*models.User, error)                                    // retrieves all users
+	GetPageUsers(ctx context.Context, offset, limit int) ([]*models.User, error)                // retrieves users with pagination
+	UpdateUser(ctx context.Context, id int, user *models.User) error                            // updates user details
+	UpdateUserRole(ctx context.Context, id int, role string) error                              // sets the role of a user (user|admin)
+	UpdateUserEmail(ctx context.Context, id int, email string, verifiedAt time.Time) error      // moves a user to a confirmed email
+	UpdateUserPhone(ctx context.Context, id int, phone string, verifiedAt time.Time) error      // sets a confirmed phone of a user
+	SetEmailVerified(ctx context.Context, id int, verifiedAt time.Time) error                   // marks the email of a user as confirmed (kept if already set)
+	DeleteUserByID(ctx context.Context, id int, deletedAt time.Time) error                      // soft-deletes a user and their advertisements by ID
+	DeleteUserByPhone(ctx context.Context, phone string, deletedAt time.Time) error             // soft-deletes a user by their phone
+	DeleteUserByEmail(ctx context.Context, email string, deletedAt time.Time) error             // soft-deletes a user by their email
+	GetDeletedUserByEmail(ctx context.Context, email string) (*models.DeletedUser, error)       // retrieves a soft-deleted user by email (nil if none)
+	GetDeletedUserByPhone(ctx context.Context, phone string) (*models.DeletedUser, error)       // retrieves a soft-deleted user by phone (nil if none)
+	RestoreUserByID(ctx context.Context, id int) error                                          // restores a soft-deleted user and the advertisements deleted with them
+	GetUsersDeletedBefore(ctx context.Context, before time.Time) ([]*models.DeletedUser, error) // retrieves users soft-deleted before the given time
+	PurgeUserByID(ctx context.Context, id int) ([]string, error)                                // permanently deletes a soft-deleted user, returns photo paths of their advertisements
 }
 
 // UserProfileRepository interface for working with user profiles in the DB
 type UserProfileRepository interface {
-	CreateUserProfile(profile *models.UserProfile) (int, error)           // creates a new user profile
-	GetUserProfileByID(id int) (*models.UserProfile, error)               // retrieves a user profile by its ID
-	GetUserProfileByUserID(userID int) (*models.UserProfile, error)       // retrieves a user profile by the associated user ID
-	GetAllUserProfiles() ([]*models.UserProfile, error)                   // retrieves all user profiles
-	GetPageUserProfiles(offset, limit int) ([]*models.UserProfile, error) // retrieves user profiles with pagination
-	UpdateUserProfile(id int, profile *models.UserProfile) error          // updates user profile details
-	DeleteUserProfileByID(id int) error                                   // deletes a user profile by its ID
+	CreateUserProfile(ctx context.Context, profile *models.UserProfile) (int, error)           // creates a new user profile
+	GetUserProfileByID(ctx context.Context, id int) (*models.UserProfile, error)               // retrieves a user profile by its ID
+	GetUserProfileByUserID(ctx context.Context, userID int) (*models.UserProfile, error)       // retrieves a user profile by the associated user ID
+	GetAllUserProfiles(ctx context.Context) ([]*models.UserProfile, error)                     // retrieves all user profiles
+	GetPageUserProfiles(ctx context.Context, offset, limit int) ([]*models.UserProfile, error) // retrieves user profiles with pagination
+	UpdateUserProfile(ctx context.Context, id int, profile *models.UserProfile) error          // updates user profile details
+	DeleteUserProfileByID(ctx context.Context, id int) error                                   // deletes a user profile by its ID
 }
 
 // OTPRepository interface for working with OTP codes in the DB
 type OTPRepository interface {
-	CreateOTP(userID int, email string, codeHash string, maxAttempts int, expiresAt time.Time) error
-	GetOTPByEmail(email string) (*models.OTPCode, error)
-	GetOTPByID(id int) (*models.OTPCode, error)
-	UpdateOTPAttempts(id int, attempts int) error
-	DeleteOTPByID(id int) error
-	DeleteOTPByEmail(email string) error
-	DeleteExpiredOTPs(now time.Time) error
+	CreateOTP(ctx context.Context, userID int, purpose, target string, codeHash string, maxAttempts int, expiresAt time.Time) error
+	GetOTP(ctx context.Context, purpose, target string) (*models.OTPCode, error)
+	GetOTPByID(ctx context.Context, id int) (*models.OTPCode, error)
+	UpdateOTPAttempts(ctx context.Context, id int, attempts int) error
+	DeleteOTPByID(ctx context.Context, id int) error
+	DeleteOTPs(ctx context.Context, purpose, target string) error
+	DeleteExpiredOTPs(ctx context.Context, now time.Time) error
 }
 
 // AdvertisementRepository interface for working with advertisements in the DB
 type AdvertisementRepository interface {
-	CreateAdvertisement(ad *models.Advertisement) (int, error)                            // creates a new advertisement
-	GetAdvertisementByID(id int) (*models.Advertisement, error)                           // retrieves an advertisement by its ID
-	GetAllAdvertisements() ([]*models.Advertisement, error)                               // retrieves all advertisements
-	GetPageAdvertisements(offset, limit int) ([]*models.Advertisement, error)             // retrieves advertisements with pagination
-	GetAllUserAdvertisements(userID int) ([]*models.Advertisement, error)                 // retrieves all advertisements for a specific user
-	GetPageUserAdvertisements(userID, offset, limit int) ([]*models.Advertisement, error) // retrieves advertisements for a specific user with pagination
-	UpdateAdvertisement(id int, ad *models.Advertisement) error                           // updates advertisement details
-	DeleteAdvertisementByID(id int) error                                                 // deletes an advertisement by its ID
-	GetImagePath(adID, imageID int) (string, error)
+	CreateAdvertisement(ctx context.Context, ad *models.Advertisement) (int, error)                            // creates a new advertisement
+	GetAdvertisementByID(ctx context.Context, id int) (*models.Advertisement, error)                           // retrieves an advertisement by its ID
+	GetAllAdvertisements(ctx context.Context) ([]*models.Advertisement, error)                                 // retrieves all advertisements
+	GetPageAdvertisements(ctx context.Context, offset, limit int) ([]*models.Advertisement, error)             // retrieves advertisements with pagination
+	GetAllUserAdvertisements(ctx context.Context, userID int) ([]*models.Advertisement, error)                 // retrieves all advertisements for a specific user
+	GetPageUserAdvertisements(ctx context.Context, userID, offset, limit int) ([]*models.Advertisement, error) // retrieves advertisements for a specific user with pagination
+	UpdateAdvertisement(ctx context.Context, id int, ad *models.Advertisement) error                           // updates advertisement details
+	DeleteAdvertisementByID(ctx context.Context, id int) error                                                 // deletes an advertisement by its ID
+	GetImagePath(ctx context.Context, adID, imageID int) (string, error)
+}
+
+// UploadRepository interface for working with image upload sessions in the DB
+type UploadRepository interface {
+	CreateUploadSession(ctx context.Context, session *models.UploadSession) (int, error)          // creates a session together with its upload objects
+	GetUploadSessionByID(ctx context.Context, id int) (*models.UploadSession, error)              // retrieves a session with its upload objects
+	GetUploadObjectByToken(ctx context.Context, token string) (*models.UploadObject, error)       // retrieves an upload object by its capability token
+	MarkUploadObjectUploaded(ctx context.Context, id int, size int64, uploadedAt time.Time) error // records that the file has been uploaded
+	MarkUploadSessionCommitted(ctx context.Context, id int, committedAt time.Time) error          // marks a pending session as committed (conflict if it is not pending)
+	GetExpiredUploadSessions(ctx context.Context, now time.Time) ([]*models.UploadSession, error) // retrieves sessions whose expiry has passed
+	DeleteUploadSessionByID(ctx context.Context, id int) error                                    // deletes a session and its upload objects
+}
+
+// ModerationRepository interface for working with the moderation queue and duplicate detection data in the DB
+type ModerationRepository interface {
+	CreateModerationItem(ctx context.Context, item *models.ModerationItem) error                                         // adds an item to the moderation queue (once per advertisement pair and reason)
+	GetModerationItemByID(ctx context.Context, id int) (*models.ModerationItem, error)                                   // retrieves a moderation item by its ID
+	GetPageModerationItems(ctx context.Context, status string, offset, limit int) ([]*models.ModerationItem, int, error) // retrieves moderation items by status with pagination and total count
+	ResolveModerationItem(ctx context.Context, id int, status string, resolvedBy int, resolvedAt time.Time) error        // stores the moderator decision
+	GetPhotoHashesByAdvertisement(ctx context.Context, adID int) ([]*models.PhotoHash, error)                            // retrieves perceptual hashes of the advertisement photos
+	GetPhotoHashesOfOtherUsers(ctx context.Context, userID int) ([]*models.PhotoHash, error)                             // retrieves perceptual hashes of photos of all other users
+	GetListingSummary(ctx context.Context, adID int) (*models.ListingSummary, error)                                     // retrieves fields used for duplicate detection
+	GetListingSummariesInCity(ctx context.Context, city string, excludeUserID int) ([]*models.ListingSummary, error)     // retrieves duplicate detection fields of other users' advertisements in the city
+}
+
+// AnalyticsRepository interface for working with the precomputed city statistics in the DB
+type AnalyticsRepository interface {
+	GetActiveListingPrices(ctx context.Context) ([]*models.ListingPrice, error)                            // retrieves price fields of all active advertisements
+	ReplaceCityPriceStats(ctx context.Context, stats []*models.CityPriceStats) error                       // replaces all price rollups at once
+	UpsertCityDailyStats(ctx context.Context, stats []*models.CityDailyStats) error                        // stores daily snapshots, replacing those of the same day
+	GetCityPriceStats(ctx context.Context, city string) ([]*models.CityPriceStats, error)                  // retrieves the price rollups of a city
+	GetCityDailyStats(ctx context.Context, city string, since time.Time) ([]*models.CityDailyStats, error) // retrieves daily snapshots of a city since the given day
+}
+
+// StatsRepository interface for working with the per-advertisement event counters in the DB
+type StatsRepository interface {
+	IncrementAdvertisementStats(ctx context.Context, counters []*models.AdvertisementDailyCounters) error                    // adds buffered counters to the daily counters
+	GetAdvertisementDailyStats(ctx context.Context, adID int, since time.Time) ([]*models.AdvertisementDailyCounters, error) // retrieves daily counters of an advertisement since the given day
+	GetAdvertisementTotals(ctx context.Context, adIDs []int, since time.Time) (map[int]*models.AdvertisementCounters, error) // retrieves counters per advertisement summed since the given day
+	GetUserTotals(ctx context.Context, userID int, since time.Time) (*models.AdvertisementCounters, error)                   // retrieves counters of all advertisements of a user summed since the given day
+}
+
+// RecommendationRepository interface for working with the similar listings and recently viewed data in the DB
+type RecommendationRepository interface {
+	GetListingFeatures(ctx context.Context, adID int) (*models.ListingFeatures, error)                                                        // retrieves the compared fields of an advertisement
+	GetSimilarCandidates(ctx context.Context, ad *models.ListingFeatures, minPrice, maxPrice money.Amount) ([]*models.ListingFeatures, error) // retrieves active advertisements in the same city within the price band
+	RecordRecentlyViewed(ctx context.Context, viewer string, adID int, viewedAt time.Time, keep int) error                                    // stores a view and trims the viewer's list to keep entries
+	GetRecentlyViewedIDs(ctx context.Context, viewer string, limit int) ([]int, error)                                                        // retrieves the last viewed advertisements of a viewer
+	DeleteRecentlyViewedBefore(ctx context.Context, before time.Time) (int64, error)                                                          // deletes views older than before
+}
+
+// ImportRepository interface for working with background import jobs in the DB
+type ImportRepository interface {
+	CreateImportJob(ctx context.Context, job *models.ImportJob) (int, error)                                                             // stores a queued job together with the rows to import
+	GetImportJobByID(ctx context.Context, id int) (*models.ImportJob, error)                                                             // retrieves a job with its report, without the rows
+	GetNextImportJob(ctx context.Context) (*models.ImportJob, error)                                                                     // retrieves the oldest unfinished job with its rows (nil if none)
+	UpdateImportJobProgress(ctx context.Context, id, processed int, report *models.ImportReport, now time.Time) error                    // marks the job running and stores the results so far
+	FinishImportJob(ctx context.Context, id int, status string, report *models.ImportReport, errMsg *string, finishedAt time.Time) error // stores the final status and report and drops the rows
+}
+
+// ExportRepository interface for working with personal data archives in the DB
+type ExportRepository interface {
+	CreateExportArchive(ctx context.Context, archive *models.ExportArchive) (int, error)          // stores a queued archive
+	GetExportArchiveByID(ctx context.Context, id int) (*models.ExportArchive, error)              // retrieves an archive by its ID
+	GetPendingExportArchive(ctx context.Context, userID int) (*models.ExportArchive, error)       // retrieves the queued archive of a user (nil if none)
+	GetNextExportArchive(ctx context.Context) (*models.ExportArchive, error)                      // retrieves the oldest queued archive (nil if none)
+	FinishExportArchive(ctx context.Context, archive *models.ExportArchive) error                 // stores the outcome of generating an archive
+	GetExpiredExportArchives(ctx context.Context, now time.Time) ([]*models.ExportArchive, error) // retrieves archives whose expiry has passed
+	DeleteExportArchiveByID(ctx context.Context, id int) error                                    // deletes an archive row
+}
+
+// AccountRepository interface for deleting accounts at the owner's request in the DB.
+// Every step is written to the deletion audit, which holds no personal data.
+type AccountRepository interface {
+	ScheduleAccountDeletion(ctx context.Context, userID int, reference string, deletedAt, eraseAfter time.Time) error // deactivates the account and its advertisements until eraseAfter and drops its OTP codes
+	CancelAccountDeletion(ctx context.Context, userID int, now time.Time) (bool, error)                               // restores an account waiting for erasure (false if it is not)
+	GetAccountsDueForErasure(ctx context.Context, now time.Time) ([]*models.DeletedUser, error)                       // retrieves accounts whose grace period has passed
+	EraseAccount(ctx context.Context, userID int, now time.Time) (*models.AccountErasure, error)                      // permanently deletes the account with its data, returns its files
 }
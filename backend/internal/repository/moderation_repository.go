@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"rentor/internal/apperr"
+	"rentor/internal/models"
+)
+
+// moderationRepository implements ModerationRepository
+type moderationRepository struct {
+	db DBTX
+}
+
+// NewModerationRepository creates a new moderation repository
+func NewModerationRepository(db DBTX) ModerationRepository {
+	return &moderationRepository{db: db}
+}
+
+// CreateModerationItem adds an item to the moderation queue.
+// An advertisement already flagged for the same pair and reason is not flagged again,
+// so dismissed items stay dismissed.
+func (r *moderationRepository) CreateModerationItem(ctx context.Context, item *models.ModerationItem) error {
+	_, err := r.db.ExecContext(ctx, `
+        INSERT INTO moderation_queue (advertisement_id, related_advertisement_id, reason, score, details, status)
+        VALUES (?, ?, ?, ?, ?, ?)
+        ON CONFLICT (advertisement_id, related_advertisement_id, reason) DO NOTHING
+    `,
+		item.AdvertisementID,
+		item.RelatedAdvertisementID,
+		item.Reason,
+		item.Score,
+		item.Details,
+		models.ModerationStatusOpen,
+	)
+	return err
+}
+
+// GetModerationItemByID retrieves a moderation item by ID
+func (r *moderationRepository) GetModerationItemByID(ctx context.Context, id int) (*models.ModerationItem, error) {
+	item := &models.ModerationItem{}
+	err := r.db.QueryRowContext(ctx, `
+        SELECT id, advertisement_id, related_advertisement_id, reason, score, details, status, resolved_by, created_at, resolved_at
+        FROM moderation_queue
+        WHERE id = ?
+    `, id).Scan(&item.ID, &item.AdvertisementID, &item.RelatedAdvertisementID, &item.Reason, &item.Score, &item.Details, &item.Status, &item.ResolvedBy, &item.CreatedAt, &item.ResolvedAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperr.ErrModerationItemNotFound
+		}
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// GetPageModerationItems retrieves moderation items with the given status, newest first
+func (r *moderationRepository) GetPageModerationItems(ctx context.Context, status string, offset, limit int) ([]*models.ModerationItem, int, error) {
+	var total int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM moderation_queue WHERE status = ?", status).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT id, advertisement_id, related_advertisement_id, reason, score, details, status, resolved_by, created_at, resolved_at
+        FROM moderation_queue
+        WHERE status = ?
+        ORDER BY created_at DESC, id DESC
+        LIMIT ? OFFSET ?
+    `, status, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var items []*models.ModerationItem
+	for rows.Next() {
+		item := &models.ModerationItem{}
+		if err := rows.Scan(&item.ID, &item.AdvertisementID, &item.RelatedAdvertisementID, &item.Reason, &item.Score, &item.Details, &item.Status, &item.ResolvedBy, &item.CreatedAt, &item.ResolvedAt); err != nil {
+			return nil, 0, err
+		}
+		items = append(items, item)
+	}
+
+	return items, total, rows.Err()
+}
+
+// ResolveModerationItem stores the moderator decision
+func (r *moderationRepository) ResolveModerationItem(ctx context.Context, id int, status string, resolvedBy int, resolvedAt time.Time) error {
+	_, err := r.db.ExecContext(
+		ctx,
+		"UPDATE moderation_queue SET status = ?, resolved_by = ?, resolved_at = ? WHERE id = ?",
+		status,
+		resolvedBy,
+		resolvedAt,
+		id,
+	)
+	return err
+}
+
+// GetPhotoHashesByAdvertisement retrieves perceptual hashes of the advertisement photos
+func (r *moderationRepository) GetPhotoHashesByAdvertisement(ctx context.Context, adID int) ([]*models.PhotoHash, error) {
+	return r.queryPhotoHashes(ctx, `
+        SELECT p.id, p.advertisement_id, a.user_id, p.phash
+        FROM advertisement_photos p
+        JOIN advertisement a ON a.id = p.advertisement_id
+        WHERE p.phash IS NOT NULL AND p.advertisement_id = ?
+    `, adID)
+}
+
+// GetPhotoHashesOfOtherUsers retrieves perceptual hashes of photos on advertisements of all other users
+func (r *moderationRepository) GetPhotoHashesOfOtherUsers(ctx context.Context, userID int) ([]*models.PhotoHash, error) {
+	return r.queryPhotoHashes(ctx, `
+        SELECT p.id, p.advertisement_id, a.user_id, p.phash
+        FROM advertisement_photos p
+        JOIN advertisement a ON a.id = p.advertisement_id
+        WHERE p.phash IS NOT NULL AND a.user_id != ? AND a.deleted_at IS NULL
+    `, userID)
+}
+
+// GetListingSummary retrieves fields of an advertisement used for duplicate detection
+func (r *moderationRepository) GetListingSummary(ctx context.Context, adID int) (*models.ListingSummary, error) {
+	summary := &models.ListingSummary{}
+	err := r.db.QueryRowContext(
+		ctx,
+		"SELECT id, user_id, title, city, address FROM advertisement WHERE id = ? AND deleted_at IS NULL",
+		adID,
+	).Scan(&summary.ID, &summary.UserID, &summary.Title, &summary.City, &summary.Address)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperr.ErrAdvertisementNotFound
+		}
+		return nil, err
+	}
+
+	return summary, nil
+}
+
+// GetListingSummariesInCity retrieves duplicate detection fields of advertisements of other users in the city
+func (r *moderationRepository) GetListingSummariesInCity(ctx context.Context, city string, excludeUserID int) ([]*models.ListingSummary, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		"SELECT id, user_id, title, city, address FROM advertisement WHERE LOWER(city) = LOWER(?) AND user_id != ? AND deleted_at IS NULL",
+		city,
+		excludeUserID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var summaries []*models.ListingSummary
+	for rows.Next() {
+		summary := &models.ListingSummary{}
+		if err := rows.Scan(&summary.ID, &summary.UserID, &summary.Title, &summary.City, &summary.Address); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, summary)
+	}
+
+	return summaries, rows.Err()
+}
+
+// queryPhotoHashes runs a query returning (image id, advertisement id, user id, phash) rows
+func (r *moderationRepository) queryPhotoHashes(ctx context.Context, query string, args ...any) ([]*models.PhotoHash, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hashes []*models.PhotoHash
+	for rows.Next() {
+		hash := &models.PhotoHash{}
+		if err := rows.Scan(&hash.ImageID, &hash.AdvertisementID, &hash.UserID, &hash.PHash); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+
+	return hashes, rows.Err()
+}
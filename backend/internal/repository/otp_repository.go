@@ -1,34 +1,37 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
-	"errors"
 	"time"
 
+	"rentor/internal/apperr"
 	"rentor/internal/models"
 )
 
 // otpRepository implements OTPRepository
 type otpRepository struct {
-	db *sql.DB
+	db DBTX
 }
 
 // NewOTPRepository creates a new OTP repository
-func NewOTPRepository(db *sql.DB) OTPRepository {
+func NewOTPRepository(db DBTX) OTPRepository {
 	return &otpRepository{db: db}
 }
 
-// CreateOTP creates a new OTP record
-func (r *otpRepository) CreateOTP(userID int, email string, codeHash string, maxAttempts int, expiresAt time.Time) error {
-	if err := validateEmail(email); err != nil {
+// CreateOTP creates a new OTP record for the purpose, sent to target (an email or a phone)
+func (r *otpRepository) CreateOTP(ctx context.Context, userID int, purpose, target string, codeHash string, maxAttempts int, expiresAt time.Time) error {
+	target, err := otpTarget(purpose, target)
+	if err != nil {
 		return err
 	}
-	email = toLowerRegister(email)
 
-	_, err := r.db.Exec(
-		"INSERT INTO otp_codes (user_id, email, code_hash, max_attempts, expires_at) VALUES (?, ?, ?, ?, ?)",
+	_, err = r.db.ExecContext(
+		ctx,
+		"INSERT INTO otp_codes (user_id, purpose, target, code_hash, max_attempts, expires_at) VALUES (?, ?, ?, ?, ?, ?)",
 		userID,
-		email,
+		purpose,
+		target,
 		codeHash,
 		maxAttempts,
 		expiresAt,
@@ -36,22 +39,24 @@ func (r *otpRepository) CreateOTP(userID int, email string, codeHash string, max
 	return err
 }
 
-// GetOTPByEmail retrieves OTP record by email
-func (r *otpRepository) GetOTPByEmail(email string) (*models.OTPCode, error) {
-	if err := validateEmail(email); err != nil {
+// GetOTP retrieves the OTP record sent to target for the purpose
+func (r *otpRepository) GetOTP(ctx context.Context, purpose, target string) (*models.OTPCode, error) {
+	target, err := otpTarget(purpose, target)
+	if err != nil {
 		return nil, err
 	}
-	email = toLowerRegister(email)
 
 	otp := &models.OTPCode{}
-	err := r.db.QueryRow(
-		"SELECT id, user_id, email, code_hash, attempts, max_attempts, expires_at, created_at FROM otp_codes WHERE email = ?",
-		email,
-	).Scan(&otp.ID, &otp.UserID, &otp.Email, &otp.CodeHash, &otp.Attempts, &otp.MaxAttempts, &otp.ExpiresAt, &otp.CreatedAt)
+	err = r.db.QueryRowContext(
+		ctx,
+		"SELECT id, user_id, purpose, target, code_hash, attempts, max_attempts, expires_at, created_at FROM otp_codes WHERE purpose = ? AND target = ?",
+		purpose,
+		target,
+	).Scan(&otp.ID, &otp.UserID, &otp.Purpose, &otp.Target, &otp.CodeHash, &otp.Attempts, &otp.MaxAttempts, &otp.ExpiresAt, &otp.CreatedAt)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, errors.New("OTP not found")
+			return nil, apperr.ErrOTPNotFound
 		}
 		return nil, err
 	}
@@ -60,16 +65,17 @@ func (r *otpRepository) GetOTPByEmail(email string) (*models.OTPCode, error) {
 }
 
 // GetOTPByID retrieves OTP record by ID
-func (r *otpRepository) GetOTPByID(id int) (*models.OTPCode, error) {
+func (r *otpRepository) GetOTPByID(ctx context.Context, id int) (*models.OTPCode, error) {
 	otp := &models.OTPCode{}
-	err := r.db.QueryRow(
-		"SELECT id, user_id, email, code_hash, attempts, max_attempts, expires_at, created_at FROM otp_codes WHERE id = ?",
+	err := r.db.QueryRowContext(
+		ctx,
+		"SELECT id, user_id, purpose, target, code_hash, attempts, max_attempts, expires_at, created_at FROM otp_codes WHERE id = ?",
 		id,
-	).Scan(&otp.ID, &otp.UserID, &otp.Email, &otp.CodeHash, &otp.Attempts, &otp.MaxAttempts, &otp.ExpiresAt, &otp.CreatedAt)
+	).Scan(&otp.ID, &otp.UserID, &otp.Purpose, &otp.Target, &otp.CodeHash, &otp.Attempts, &otp.MaxAttempts, &otp.ExpiresAt, &otp.CreatedAt)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, errors.New("OTP not found")
+			return nil, apperr.ErrOTPNotFound
 		}
 		return nil, err
 	}
@@ -78,8 +84,9 @@ func (r *otpRepository) GetOTPByID(id int) (*models.OTPCode, error) {
 }
 
 // UpdateOTPAttempts updates the attempts counter
-func (r *otpRepository) UpdateOTPAttempts(id int, attempts int) error {
-	_, err := r.db.Exec(
+func (r *otpRepository) UpdateOTPAttempts(ctx context.Context, id int, attempts int) error {
+	_, err := r.db.ExecContext(
+		ctx,
 		"UPDATE otp_codes SET attempts = ? WHERE id = ?",
 		attempts,
 		id,
@@ -88,24 +95,35 @@ func (r *otpRepository) UpdateOTPAttempts(id int, attempts int) error {
 }
 
 // DeleteOTPByID deletes OTP record by ID
-func (r *otpRepository) DeleteOTPByID(id int) error {
-	_, err := r.db.Exec("DELETE FROM otp_codes WHERE id = ?", id)
+func (r *otpRepository) DeleteOTPByID(ctx context.Context, id int) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM otp_codes WHERE id = ?", id)
 	return err
 }
 
-// DeleteOTPByEmail deletes OTP record by email
-func (r *otpRepository) DeleteOTPByEmail(email string) error {
-	if err := validateEmail(email); err != nil {
+// DeleteOTPs deletes the OTP records sent to target for the purpose
+func (r *otpRepository) DeleteOTPs(ctx context.Context, purpose, target string) error {
+	target, err := otpTarget(purpose, target)
+	if err != nil {
 		return err
 	}
-	email = toLowerRegister(email)
 
-	_, err := r.db.Exec("DELETE FROM otp_codes WHERE email = ?", email)
+	_, err = r.db.ExecContext(ctx, "DELETE FROM otp_codes WHERE purpose = ? AND target = ?", purpose, target)
 	return err
 }
 
 // DeleteExpiredOTPs deletes all expired OTP records
-func (r *otpRepository) DeleteExpiredOTPs(now time.Time) error {
-	_, err := r.db.Exec("DELETE FROM otp_codes WHERE expires_at < ?", now)
+func (r *otpRepository) DeleteExpiredOTPs(ctx context.Context, now time.Time) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM otp_codes WHERE expires_at < ?", now)
 	return err
 }
+
+// otpTarget validates the address a code of the purpose is sent to, emails are brought to lower case
+func otpTarget(purpose, target string) (string, error) {
+	if purpose == models.OTPPurposePhoneChange {
+		return target, validatePhone(target)
+	}
+	if err := validateEmail(target); err != nil {
+		return "", err
+	}
+	return toLowerRegister(target), nil
+}
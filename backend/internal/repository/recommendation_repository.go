@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"rentor/internal/apperr"
+	"rentor/internal/models"
+	"rentor/internal/money"
+)
+
+// maxSimilarCandidates limits how many listings of a city are scored by the similar listings recommender
+const maxSimilarCandidates = 500
+
+// recommendationRepository implements RecommendationRepository
+type recommendationRepository struct {
+	db DBTX
+}
+
+// NewRecommendationRepository creates a new recommendation repository
+func NewRecommendationRepository(db DBTX) RecommendationRepository {
+	return &recommendationRepository{db: db}
+}
+
+// GetListingFeatures retrieves the compared fields of an advertisement
+func (r *recommendationRepository) GetListingFeatures(ctx context.Context, adID int) (*models.ListingFeatures, error) {
+	f := &models.ListingFeatures{}
+	err := r.db.QueryRowContext(ctx, `
+        SELECT id, city, type, rooms, price, currency, price_period, square, latitude, longitude
+        FROM advertisement
+        WHERE id = ? AND deleted_at IS NULL
+    `, adID).Scan(&f.ID, &f.City, &f.Type, &f.Rooms, &f.Price, &f.Currency, &f.PricePeriod, &f.Square, &f.Latitude, &f.Longitude)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperr.ErrAdvertisementNotFound
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+// GetSimilarCandidates retrieves the newest active advertisements in the city of ad priced within [minPrice, maxPrice]
+// in the currency and for the period of ad, except ad itself
+func (r *recommendationRepository) GetSimilarCandidates(ctx context.Context, ad *models.ListingFeatures, minPrice, maxPrice money.Amount) ([]*models.ListingFeatures, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT id, city, type, rooms, price, currency, price_period, square, latitude, longitude
+        FROM advertisement
+        WHERE LOWER(city) = LOWER(?) AND id <> ? AND status = 'active' AND deleted_at IS NULL
+          AND currency = ? AND price_period = ? AND price >= ? AND price <= ?
+        ORDER BY created_at DESC
+        LIMIT ?
+    `, ad.City, ad.ID, ad.Currency, ad.PricePeriod, minPrice, maxPrice, maxSimilarCandidates)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candidates []*models.ListingFeatures
+	for rows.Next() {
+		f := &models.ListingFeatures{}
+		if err := rows.Scan(&f.ID, &f.City, &f.Type, &f.Rooms, &f.Price, &f.Currency, &f.PricePeriod, &f.Square, &f.Latitude, &f.Longitude); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, f)
+	}
+
+	return candidates, rows.Err()
+}
+
+// RecordRecentlyViewed stores that viewer opened the advertisement and keeps only the last keep advertisements of the viewer
+func (r *recommendationRepository) RecordRecentlyViewed(ctx context.Context, viewer string, adID int, viewedAt time.Time, keep int) error {
+	return withTx(ctx, r.db, func(tx DBTX) error {
+		_, err := tx.ExecContext(ctx, `
+            INSERT INTO recently_viewed (viewer, advertisement_id, viewed_at)
+            VALUES (?, ?, ?)
+            ON CONFLICT (viewer, advertisement_id) DO UPDATE SET viewed_at = excluded.viewed_at
+        `, viewer, adID, viewedAt)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+            DELETE FROM recently_viewed
+            WHERE viewer = ? AND advertisement_id NOT IN (
+                SELECT advertisement_id FROM recently_viewed
+                WHERE viewer = ?
+                ORDER BY viewed_at DESC
+                LIMIT ?
+            )
+        `, viewer, viewer, keep)
+		return err
+	})
+}
+
+// GetRecentlyViewedIDs retrieves the advertisements last opened by viewer, last viewed first
+func (r *recommendationRepository) GetRecentlyViewedIDs(ctx context.Context, viewer string, limit int) ([]int, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT advertisement_id
+        FROM recently_viewed
+        WHERE viewer = ?
+        ORDER BY viewed_at DESC
+        LIMIT ?
+    `, viewer, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// DeleteRecentlyViewedBefore deletes views older than before and returns how many were deleted
+func (r *recommendationRepository) DeleteRecentlyViewedBefore(ctx context.Context, before time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM recently_viewed WHERE viewed_at < ?", before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"rentor/internal/models"
+)
+
+// statsIDsChunk limits the number of ids bound to one IN (...) list
+const statsIDsChunk = 500
+
+// statsRepository implements StatsRepository
+type statsRepository struct {
+	db DBTX
+}
+
+// NewStatsRepository creates a new advertisement statistics repository
+func NewStatsRepository(db DBTX) StatsRepository {
+	return &statsRepository{db: db}
+}
+
+// IncrementAdvertisementStats adds the counters to the stored daily counters in one transaction.
+// Counters of advertisements that no longer exist (purged since the events) are dropped.
+func (r *statsRepository) IncrementAdvertisementStats(ctx context.Context, counters []*models.AdvertisementDailyCounters) error {
+	if len(counters) == 0 {
+		return nil
+	}
+
+	return withTx(ctx, r.db, func(tx DBTX) error {
+		ids := make([]int, 0, len(counters))
+		seen := map[int]bool{}
+		for _, c := range counters {
+			if !seen[c.AdvertisementID] {
+				seen[c.AdvertisementID] = true
+				ids = append(ids, c.AdvertisementID)
+			}
+		}
+
+		existing, err := existingAdvertisements(ctx, tx, ids)
+		if err != nil {
+			return err
+		}
+
+		for _, c := range counters {
+			if !existing[c.AdvertisementID] {
+				continue
+			}
+			_, err := tx.ExecContext(ctx, `
+                INSERT INTO advertisement_daily_stats (advertisement_id, day, impressions, views, favorites, contacts)
+                VALUES (?, ?, ?, ?, ?, ?)
+                ON CONFLICT (advertisement_id, day) DO UPDATE SET
+                    impressions = advertisement_daily_stats.impressions + excluded.impressions,
+                    views = advertisement_daily_stats.views + excluded.views,
+                    favorites = advertisement_daily_stats.favorites + excluded.favorites,
+                    contacts = advertisement_daily_stats.contacts + excluded.contacts
+            `, c.AdvertisementID, c.Day, c.Impressions, c.Views, c.Favorites, c.Contacts)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetAdvertisementDailyStats retrieves the daily counters of an advertisement from the given day on, oldest first
+func (r *statsRepository) GetAdvertisementDailyStats(ctx context.Context, adID int, since time.Time) ([]*models.AdvertisementDailyCounters, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT advertisement_id, day, impressions, views, favorites, contacts
+        FROM advertisement_daily_stats
+        WHERE advertisement_id = ? AND day >= ?
+        ORDER BY day
+    `, adID, since.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []*models.AdvertisementDailyCounters
+	for rows.Next() {
+		s := &models.AdvertisementDailyCounters{}
+		if err := rows.Scan(&s.AdvertisementID, &s.Day, &s.Impressions, &s.Views, &s.Favorites, &s.Contacts); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+
+	return stats, rows.Err()
+}
+
+// GetAdvertisementTotals retrieves the counters of each advertisement summed from the given day on.
+// Advertisements without events are missing from the result.
+func (r *statsRepository) GetAdvertisementTotals(ctx context.Context, adIDs []int, since time.Time) (map[int]*models.AdvertisementCounters, error) {
+	totals := map[int]*models.AdvertisementCounters{}
+
+	for start := 0; start < len(adIDs); start += statsIDsChunk {
+		chunk := adIDs[start:min(start+statsIDsChunk, len(adIDs))]
+
+		args := make([]any, 0, len(chunk)+1)
+		for _, id := range chunk {
+			args = append(args, id)
+		}
+		args = append(args, since.Format(time.DateOnly))
+
+		rows, err := r.db.QueryContext(ctx, `
+            SELECT advertisement_id, SUM(impressions), SUM(views), SUM(favorites), SUM(contacts)
+            FROM advertisement_daily_stats
+            WHERE advertisement_id IN (`+placeholders(len(chunk))+`) AND day >= ?
+            GROUP BY advertisement_id
+        `, args...)
+		if err != nil {
+			return nil, err
+		}
+
+		for rows.Next() {
+			var id int
+			c := &models.AdvertisementCounters{}
+			if err := rows.Scan(&id, &c.Impressions, &c.Views, &c.Favorites, &c.Contacts); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			totals[id] = c
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return totals, nil
+}
+
+// GetUserTotals retrieves the counters of all (not deleted) advertisements of a user summed from the given day on
+func (r *statsRepository) GetUserTotals(ctx context.Context, userID int, since time.Time) (*models.AdvertisementCounters, error) {
+	c := &models.AdvertisementCounters{}
+	err := r.db.QueryRowContext(ctx, `
+        SELECT COALESCE(SUM(s.impressions), 0), COALESCE(SUM(s.views), 0),
+               COALESCE(SUM(s.favorites), 0), COALESCE(SUM(s.contacts), 0)
+        FROM advertisement_daily_stats s
+        JOIN advertisement a ON a.id = s.advertisement_id
+        WHERE a.user_id = ? AND a.deleted_at IS NULL AND s.day >= ?
+    `, userID, since.Format(time.DateOnly)).Scan(&c.Impressions, &c.Views, &c.Favorites, &c.Contacts)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// existingAdvertisements returns which of ids are rows of the advertisement table (deleted ones included)
+func existingAdvertisements(ctx context.Context, db DBTX, ids []int) (map[int]bool, error) {
+	existing := map[int]bool{}
+
+	for start := 0; start < len(ids); start += statsIDsChunk {
+		chunk := ids[start:min(start+statsIDsChunk, len(ids))]
+
+		args := make([]any, len(chunk))
+		for i, id := range chunk {
+			args[i] = id
+		}
+
+		rows, err := db.QueryContext(ctx, "SELECT id FROM advertisement WHERE id IN ("+placeholders(len(chunk))+")", args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			existing[id] = true
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return existing, nil
+}
+
+// placeholders returns "?, ?, ..." with n placeholders for an IN (...) list
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
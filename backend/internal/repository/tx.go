@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// DBTX is implemented by both *sql.DB and *sql.Tx, so every repository
+// can work either on the connection pool or inside a transaction
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Repositories is a set of repositories bound to the same DBTX
+type Repositories struct {
+	User           UserRepository
+	UserProfile    UserProfileRepository
+	OTP            OTPRepository
+	Advertisement  AdRepository
+	Upload         UploadRepository
+	Moderation     ModerationRepository
+	Analytics      AnalyticsRepository
+	Stats          StatsRepository
+	Recommendation RecommendationRepository
+	Import         ImportRepository
+	Export         ExportRepository
+	Account        AccountRepository
+}
+
+// NewRepositories creates all repositories on top of db (a *sql.DB or a *sql.Tx)
+func NewRepositories(db DBTX) *Repositories {
+	return &Repositories{
+		User:           NewUserRepository(db),
+		UserProfile:    NewUserProfileRepository(db),
+		OTP:            NewOTPRepository(db),
+		Advertisement:  NewAdRepository(db),
+		Upload:         NewUploadRepository(db),
+		Moderation:     NewModerationRepository(db),
+		Analytics:      NewAnalyticsRepository(db),
+		Stats:          NewStatsRepository(db),
+		Recommendation: NewRecommendationRepository(db),
+		Import:         NewImportRepository(db),
+		Export:         NewExportRepository(db),
+		Account:        NewAccountRepository(db),
+	}
+}
+
+// TxManager runs multi-step operations as a single unit of work
+type TxManager interface {
+	// WithinTx runs fn with repositories bound to one transaction.
+	// The transaction is committed if fn returns nil and rolled back otherwise (including panics).
+	WithinTx(ctx context.Context, fn func(repos *Repositories) error) error
+}
+
+// txManager implements TxManager
+type txManager struct {
+	db DBTX
+}
+
+// NewTxManager creates a new transaction manager, db is the connection pool (see NewDB)
+func NewTxManager(db DBTX) TxManager {
+	return &txManager{db: db}
+}
+
+// WithinTx runs fn inside a transaction
+func (m *txManager) WithinTx(ctx context.Context, fn func(repos *Repositories) error) error {
+	return withTx(ctx, m.db, func(tx DBTX) error {
+		return fn(NewRepositories(tx))
+	})
+}
+
+// withTx runs fn inside a transaction bound to ctx. If db already is a transaction, fn joins it
+// and committing is left to the owner of that transaction.
+func withTx(ctx context.Context, db DBTX, fn func(tx DBTX) error) (err error) {
+	pool, ok := db.(*sql.DB)
+	c, wrapped := db.(*conn)
+	if wrapped {
+		pool, ok = c.db.(*sql.DB)
+	}
+	if !ok {
+		// already inside a transaction
+		return fn(db)
+	}
+
+	tx, err := pool.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("withTx: failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	// the transaction keeps the dialect of db
+	var txDB DBTX = tx
+	if wrapped {
+		txDB = &conn{db: tx, dialect: c.dialect}
+	}
+
+	if err = fn(txDB); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("withTx: failed to commit transaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"rentor/internal/apperr"
+	"rentor/internal/models"
+	"rentor/internal/repository"
+	"rentor/internal/storage"
+	"rentor/internal/storage/storagetest"
+)
+
+func TestWithinTxWithReadPool(t *testing.T) {
+	db := storagetest.OpenSQLite(t, storagetest.SQLiteOptions())
+	conn := newDB(db, storage.DriverSQLite)
+	pool := repository.NewRepositories(conn)
+	userID := createUser(t, pool, 1)
+
+	err := repository.NewTxManager(conn).WithinTx(context.Background(), func(repos *repository.Repositories) error {
+		adID := createAd(t, repos, userID, "elevator")
+
+		// the read pool is not blocked by the open write transaction, it sees the last commit
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if _, err := pool.Advertisement.GetAmenities(ctx); err != nil {
+			t.Errorf("pool read during the transaction: %v", err)
+		}
+		if _, err := pool.Advertisement.GetAdvertisement(ctx, adID); !errors.Is(err, apperr.ErrAdvertisementNotFound) {
+			t.Errorf("pool read sees the uncommitted advertisement, err = %v", err)
+		}
+
+		// the transaction sees its own writes
+		if _, err := repos.Advertisement.GetAdvertisement(ctx, adID); err != nil {
+			t.Errorf("read inside the transaction: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithinTx: %v", err)
+	}
+}
+
+func TestWithinTxWithoutReadPool(t *testing.T) {
+	opts := storagetest.SQLiteOptions()
+	opts.MaxReadConns = 0
+	db := storagetest.OpenSQLite(t, opts)
+	if db.Read != nil {
+		t.Fatal("read pool is opened with max_read_conns 0")
+	}
+	conn := newDB(db, storage.DriverSQLite)
+	pool := repository.NewRepositories(conn)
+	userID := createUser(t, pool, 1)
+
+	err := repository.NewTxManager(conn).WithinTx(context.Background(), func(repos *repository.Repositories) error {
+		adID := createAd(t, repos, userID, "elevator")
+
+		// reads through the transaction don't need a second connection
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if _, err := repos.Advertisement.GetAmenities(ctx); err != nil {
+			t.Errorf("read inside the transaction: %v", err)
+		}
+		if _, err := repos.Advertisement.GetAdvertisement(ctx, adID); err != nil {
+			t.Errorf("read inside the transaction: %v", err)
+		}
+
+		// the only connection of the write pool is held by the transaction:
+		// code running in WithinTx must never read through the pool
+		short, cancelShort := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancelShort()
+		if _, err := pool.Advertisement.GetAmenities(short); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("pool read during the transaction: err = %v, want %v", err, context.DeadlineExceeded)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithinTx: %v", err)
+	}
+
+	// after the commit the pool is free again
+	if n := count(t, conn, "SELECT COUNT(*) FROM advertisement_amenity"); n != 1 {
+		t.Errorf("advertisement_amenity rows = %d, want 1", n)
+	}
+}
+
+func TestWithinTxRollsBackOnError(t *testing.T) {
+	for _, maxReadConns := range []int{0, 4} {
+		opts := storagetest.SQLiteOptions()
+		opts.MaxReadConns = maxReadConns
+		db := storagetest.OpenSQLite(t, opts)
+		conn := newDB(db, storage.DriverSQLite)
+		userID := createUser(t, repository.NewRepositories(conn), 1)
+
+		errFail := errors.New("fail")
+		err := repository.NewTxManager(conn).WithinTx(context.Background(), func(repos *repository.Repositories) error {
+			adID := createAd(t, repos, userID, "elevator", "parking")
+			if err := repos.Advertisement.CreateAdvertisementImages(context.Background(), adID, []models.SavedImage{{URL: "/images/1.jpg"}}); err != nil {
+				t.Fatalf("create images: %v", err)
+			}
+			return errFail
+		})
+		if !errors.Is(err, errFail) {
+			t.Fatalf("max_read_conns %d: WithinTx err = %v, want %v", maxReadConns, err, errFail)
+		}
+
+		for _, table := range []string{"advertisement", "advertisement_amenity", "advertisement_photos"} {
+			if n := count(t, conn, "SELECT COUNT(*) FROM "+table); n != 0 {
+				t.Errorf("max_read_conns %d: %s rows = %d after rollback, want 0", maxReadConns, table, n)
+			}
+		}
+	}
+}
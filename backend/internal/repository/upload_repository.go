@@ -0,0 +1,208 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"rentor/internal/apperr"
+	"rentor/internal/models"
+)
+
+// uploadRepository implements UploadRepository
+type uploadRepository struct {
+	db DBTX
+}
+
+// NewUploadRepository creates a new upload repository
+func NewUploadRepository(db DBTX) UploadRepository {
+	return &uploadRepository{db: db}
+}
+
+// CreateUploadSession creates a session together with its upload objects
+func (r *uploadRepository) CreateUploadSession(ctx context.Context, session *models.UploadSession) (int, error) {
+	err := withTx(ctx, r.db, func(tx DBTX) error {
+		id, err := insertID(
+			ctx,
+			tx,
+			"INSERT INTO upload_session (advertisement_id, user_id, status, expires_at) VALUES (?, ?, ?, ?)",
+			session.AdvertisementID,
+			session.UserID,
+			session.Status,
+			session.ExpiresAt,
+		)
+		if err != nil {
+			return err
+		}
+		session.ID = id
+
+		for i := range session.Objects {
+			obj := &session.Objects[i]
+			objID, err := insertID(
+				ctx,
+				tx,
+				"INSERT INTO upload_object (session_id, token, filename, content_type, max_size) VALUES (?, ?, ?, ?, ?)",
+				session.ID,
+				obj.Token,
+				obj.Filename,
+				obj.ContentType,
+				obj.MaxSize,
+			)
+			if err != nil {
+				return err
+			}
+			obj.ID = objID
+			obj.SessionID = session.ID
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return session.ID, nil
+}
+
+// GetUploadSessionByID retrieves a session with its upload objects
+func (r *uploadRepository) GetUploadSessionByID(ctx context.Context, id int) (*models.UploadSession, error) {
+	session := &models.UploadSession{}
+	err := r.db.QueryRowContext(
+		ctx,
+		"SELECT id, advertisement_id, user_id, status, expires_at, created_at, committed_at FROM upload_session WHERE id = ?",
+		id,
+	).Scan(&session.ID, &session.AdvertisementID, &session.UserID, &session.Status, &session.ExpiresAt, &session.CreatedAt, &session.CommittedAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperr.ErrUploadSessionNotFound
+		}
+		return nil, err
+	}
+
+	objects, err := r.getUploadObjects(ctx, session.ID)
+	if err != nil {
+		return nil, err
+	}
+	session.Objects = objects
+
+	return session, nil
+}
+
+// GetUploadObjectByToken retrieves an upload object by its capability token
+func (r *uploadRepository) GetUploadObjectByToken(ctx context.Context, token string) (*models.UploadObject, error) {
+	obj := &models.UploadObject{}
+	err := r.db.QueryRowContext(
+		ctx,
+		"SELECT id, session_id, token, filename, content_type, max_size, size, uploaded_at FROM upload_object WHERE token = ?",
+		token,
+	).Scan(&obj.ID, &obj.SessionID, &obj.Token, &obj.Filename, &obj.ContentType, &obj.MaxSize, &obj.Size, &obj.UploadedAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperr.ErrUploadNotFound
+		}
+		return nil, err
+	}
+
+	return obj, nil
+}
+
+// MarkUploadObjectUploaded records that the file has been uploaded
+func (r *uploadRepository) MarkUploadObjectUploaded(ctx context.Context, id int, size int64, uploadedAt time.Time) error {
+	_, err := r.db.ExecContext(
+		ctx,
+		"UPDATE upload_object SET size = ?, uploaded_at = ? WHERE id = ?",
+		size,
+		uploadedAt,
+		id,
+	)
+	return err
+}
+
+// MarkUploadSessionCommitted marks a pending session as committed.
+// Only one of concurrent commits of a session succeeds, the others get ErrUploadSessionCommitted.
+func (r *uploadRepository) MarkUploadSessionCommitted(ctx context.Context, id int, committedAt time.Time) error {
+	res, err := r.db.ExecContext(
+		ctx,
+		"UPDATE upload_session SET status = ?, committed_at = ? WHERE id = ? AND status = ?",
+		models.UploadSessionCommitted,
+		committedAt,
+		id,
+		models.UploadSessionPending,
+	)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res, apperr.ErrUploadSessionCommitted)
+}
+
+// GetExpiredUploadSessions retrieves sessions whose expiry has passed
+func (r *uploadRepository) GetExpiredUploadSessions(ctx context.Context, now time.Time) ([]*models.UploadSession, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		"SELECT id, advertisement_id, user_id, status, expires_at, created_at, committed_at FROM upload_session WHERE expires_at < ?",
+		now,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*models.UploadSession
+	for rows.Next() {
+		session := &models.UploadSession{}
+		if err := rows.Scan(&session.ID, &session.AdvertisementID, &session.UserID, &session.Status, &session.ExpiresAt, &session.CreatedAt, &session.CommittedAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, session := range sessions {
+		objects, err := r.getUploadObjects(ctx, session.ID)
+		if err != nil {
+			return nil, err
+		}
+		session.Objects = objects
+	}
+
+	return sessions, nil
+}
+
+// DeleteUploadSessionByID deletes a session and its upload objects
+func (r *uploadRepository) DeleteUploadSessionByID(ctx context.Context, id int) error {
+	return withTx(ctx, r.db, func(tx DBTX) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM upload_object WHERE session_id = ?", id); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, "DELETE FROM upload_session WHERE id = ?", id)
+		return err
+	})
+}
+
+// getUploadObjects retrieves all upload objects of a session
+func (r *uploadRepository) getUploadObjects(ctx context.Context, sessionID int) ([]models.UploadObject, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		"SELECT id, session_id, token, filename, content_type, max_size, size, uploaded_at FROM upload_object WHERE session_id = ? ORDER BY id",
+		sessionID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var objects []models.UploadObject
+	for rows.Next() {
+		var obj models.UploadObject
+		if err := rows.Scan(&obj.ID, &obj.SessionID, &obj.Token, &obj.Filename, &obj.ContentType, &obj.MaxSize, &obj.Size, &obj.UploadedAt); err != nil {
+			return nil, err
+		}
+		objects = append(objects, obj)
+	}
+
+	return objects, rows.Err()
+}
@@ -0,0 +1,48 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"rentor/internal/apperr"
+	"rentor/internal/models"
+	"rentor/internal/repository"
+	"rentor/internal/storage"
+	"rentor/internal/storage/storagetest"
+)
+
+func TestMarkUploadSessionCommittedOnce(t *testing.T) {
+	db := storagetest.OpenSQLite(t, storagetest.SQLiteOptions())
+	repos := repository.NewRepositories(newDB(db, storage.DriverSQLite))
+	ctx := context.Background()
+
+	userID := createUser(t, repos, 1)
+	adID := createAd(t, repos, userID)
+	sessionID, err := repos.Upload.CreateUploadSession(ctx, &models.UploadSession{
+		AdvertisementID: adID,
+		UserID:          userID,
+		Status:          models.UploadSessionPending,
+		ExpiresAt:       time.Now().Add(time.Hour),
+		Objects:         []models.UploadObject{{Token: "token", Filename: "1.png", ContentType: "image/png", MaxSize: 1 << 20}},
+	})
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+
+	if err := repos.Upload.MarkUploadSessionCommitted(ctx, sessionID, time.Now()); err != nil {
+		t.Fatalf("first commit: %v", err)
+	}
+	if err := repos.Upload.MarkUploadSessionCommitted(ctx, sessionID, time.Now()); !errors.Is(err, apperr.ErrUploadSessionCommitted) {
+		t.Errorf("second commit: err = %v, want %v", err, apperr.ErrUploadSessionCommitted)
+	}
+
+	session, err := repos.Upload.GetUploadSessionByID(ctx, sessionID)
+	if err != nil {
+		t.Fatalf("get session: %v", err)
+	}
+	if session.Status != models.UploadSessionCommitted || session.CommittedAt == nil {
+		t.Errorf("session status = %s, committed_at = %v", session.Status, session.CommittedAt)
+	}
+}
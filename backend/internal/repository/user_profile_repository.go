@@ -1,53 +1,47 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
-	"errors"
+	"rentor/internal/apperr"
 	"rentor/internal/models"
 )
 
 // userProfileRepository implements UserProfileRepository
 type userProfileRepository struct {
-	db *sql.DB
+	db DBTX
 }
 
 // NewUserProfileRepository creates a new user profile repository
-func NewUserProfileRepository(db *sql.DB) UserProfileRepository {
+func NewUserProfileRepository(db DBTX) UserProfileRepository {
 	return &userProfileRepository{db: db}
 }
 
 // CreateUserProfile creates a new user profile
-func (r *userProfileRepository) CreateUserProfile(profile *models.UserProfile) (int, error) {
-	res, err := r.db.Exec(
+func (r *userProfileRepository) CreateUserProfile(ctx context.Context, profile *models.UserProfile) (int, error) {
+	return insertID(
+		ctx,
+		r.db,
 		"INSERT INTO user_profile (user_id, first_name, surname, patronymic) VALUES(?, ?, ?, ?)",
 		profile.UserID,
 		profile.FirstName,
 		profile.Surname,
 		profile.Patronymic,
 	)
-	if err != nil {
-		return 0, err
-	}
-
-	id64, err := res.LastInsertId()
-	if err != nil {
-		return 0, err
-	}
-
-	return int(id64), nil
 }
 
 // GetUserProfileByID retrieves a user profile by ID
-func (r *userProfileRepository) GetUserProfileByID(id int) (*models.UserProfile, error) {
+func (r *userProfileRepository) GetUserProfileByID(ctx context.Context, id int) (*models.UserProfile, error) {
 	profile := &models.UserProfile{}
-	err := r.db.QueryRow(
-		"SELECT id, user_id, first_name, surname, patronymic, created_at, updated_at FROM user_profile WHERE id = ?",
+	err := r.db.QueryRowContext(
+		ctx,
+		"SELECT id, user_id, first_name, surname, patronymic, created_at, updated_at, version FROM user_profile WHERE id = ?",
 		id,
-	).Scan(&profile.ID, &profile.UserID, &profile.FirstName, &profile.Surname, &profile.Patronymic, &profile.CreatedAt, &profile.UpdatedAt)
+	).Scan(&profile.ID, &profile.UserID, &profile.FirstName, &profile.Surname, &profile.Patronymic, &profile.CreatedAt, &profile.UpdatedAt, &profile.Version)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, errors.New("user profile not found")
+			return nil, apperr.ErrProfileNotFound
 		}
 		return nil, err
 	}
@@ -56,12 +50,13 @@ func (r *userProfileRepository) GetUserProfileByID(id int) (*models.UserProfile,
 }
 
 // GetUserProfileByUserID retrieves a user profile by user ID
-func (r *userProfileRepository) GetUserProfileByUserID(userID int) (*models.UserProfile, error) {
+func (r *userProfileRepository) GetUserProfileByUserID(ctx context.Context, userID int) (*models.UserProfile, error) {
 	profile := &models.UserProfile{}
-	err := r.db.QueryRow(
-		"SELECT id, user_id, first_name, surname, patronymic, created_at, updated_at FROM user_profile WHERE user_id = ?",
+	err := r.db.QueryRowContext(
+		ctx,
+		"SELECT id, user_id, first_name, surname, patronymic, created_at, updated_at, version FROM user_profile WHERE user_id = ?",
 		userID,
-	).Scan(&profile.ID, &profile.UserID, &profile.FirstName, &profile.Surname, &profile.Patronymic, &profile.CreatedAt, &profile.UpdatedAt)
+	).Scan(&profile.ID, &profile.UserID, &profile.FirstName, &profile.Surname, &profile.Patronymic, &profile.CreatedAt, &profile.UpdatedAt, &profile.Version)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -74,9 +69,10 @@ func (r *userProfileRepository) GetUserProfileByUserID(userID int) (*models.User
 }
 
 // GetAllUserProfiles retrieves all user profiles
-func (r *userProfileRepository) GetAllUserProfiles() ([]*models.UserProfile, error) {
-	rows, err := r.db.Query(
-		"SELECT id, user_id, first_name, surname, patronymic, created_at, updated_at FROM user_profile",
+func (r *userProfileRepository) GetAllUserProfiles(ctx context.Context) ([]*models.UserProfile, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		"SELECT id, user_id, first_name, surname, patronymic, created_at, updated_at, version FROM user_profile",
 	)
 	if err != nil {
 		return nil, err
@@ -86,7 +82,7 @@ func (r *userProfileRepository) GetAllUserProfiles() ([]*models.UserProfile, err
 	var profiles []*models.UserProfile
 	for rows.Next() {
 		profile := &models.UserProfile{}
-		if err := rows.Scan(&profile.ID, &profile.UserID, &profile.FirstName, &profile.Surname, &profile.Patronymic, &profile.CreatedAt, &profile.UpdatedAt); err != nil {
+		if err := rows.Scan(&profile.ID, &profile.UserID, &profile.FirstName, &profile.Surname, &profile.Patronymic, &profile.CreatedAt, &profile.UpdatedAt, &profile.Version); err != nil {
 			return nil, err
 		}
 		profiles = append(profiles, profile)
@@ -96,9 +92,10 @@ func (r *userProfileRepository) GetAllUserProfiles() ([]*models.UserProfile, err
 }
 
 // GetPageUserProfiles retrieves user profiles with pagination
-func (r *userProfileRepository) GetPageUserProfiles(offset, limit int) ([]*models.UserProfile, error) {
-	rows, err := r.db.Query(
-		"SELECT id, user_id, first_name, surname, patronymic, created_at, updated_at FROM user_profile LIMIT ? OFFSET ?",
+func (r *userProfileRepository) GetPageUserProfiles(ctx context.Context, offset, limit int) ([]*models.UserProfile, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		"SELECT id, user_id, first_name, surname, patronymic, created_at, updated_at, version FROM user_profile LIMIT ? OFFSET ?",
 		limit,
 		offset,
 	)
@@ -110,7 +107,7 @@ func (r *userProfileRepository) GetPageUserProfiles(offset, limit int) ([]*model
 	var profiles []*models.UserProfile
 	for rows.Next() {
 		profile := &models.UserProfile{}
-		if err := rows.Scan(&profile.ID, &profile.UserID, &profile.FirstName, &profile.Surname, &profile.Patronymic, &profile.CreatedAt, &profile.UpdatedAt); err != nil {
+		if err := rows.Scan(&profile.ID, &profile.UserID, &profile.FirstName, &profile.Surname, &profile.Patronymic, &profile.CreatedAt, &profile.UpdatedAt, &profile.Version); err != nil {
 			return nil, err
 		}
 		profiles = append(profiles, profile)
@@ -119,20 +116,26 @@ func (r *userProfileRepository) GetPageUserProfiles(offset, limit int) ([]*model
 	return profiles, rows.Err()
 }
 
-// UpdateUserProfile updates a user profile
-func (r *userProfileRepository) UpdateUserProfile(id int, profile *models.UserProfile) error {
-	_, err := r.db.Exec(
-		"UPDATE user_profile SET first_name = ?, surname = ?, patronymic = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+// UpdateUserProfile updates a user profile if its version still equals profile.Version and increments the version.
+// apperr.ErrVersionMismatch is returned if the profile was changed in the meantime.
+func (r *userProfileRepository) UpdateUserProfile(ctx context.Context, id int, profile *models.UserProfile) error {
+	res, err := r.db.ExecContext(
+		ctx,
+		"UPDATE user_profile SET first_name = ?, surname = ?, patronymic = ?, updated_at = CURRENT_TIMESTAMP, version = version + 1 WHERE id = ? AND version = ?",
 		profile.FirstName,
 		profile.Surname,
 		profile.Patronymic,
 		id,
+		profile.Version,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+	return expectAffected(res, apperr.ErrVersionMismatch)
 }
 
 // DeleteUserProfileByID deletes a user profile by ID
-func (r *userProfileRepository) DeleteUserProfileByID(id int) error {
-	_, err := r.db.Exec("DELETE FROM user_profile WHERE id = ?", id)
+func (r *userProfileRepository) DeleteUserProfileByID(ctx context.Context, id int) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM user_profile WHERE id = ?", id)
 	return err
 }
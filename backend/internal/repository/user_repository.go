@@ -1,28 +1,30 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
-	"errors"
 	"net/mail"
 	"regexp"
+	"rentor/internal/apperr"
 	"rentor/internal/models"
 	"strings"
+	"time"
 )
 
 // userRepository implements UserRepository
 type userRepository struct {
-	db *sql.DB
+	db DBTX
 }
 
 // NewUserRepository creates a new user repository
-func NewUserRepository(db *sql.DB) UserRepository {
+func NewUserRepository(db DBTX) UserRepository {
 	return &userRepository{db: db}
 }
 
 // CreateUser creates a new user
-func (r *userRepository) CreateUser(phone string, email string) (int, error) {
+func (r *userRepository) CreateUser(ctx context.Context, phone string, email string) (int, error) {
 	if phone == "" && email == "" {
-		return 0, errors.New("phone or email required")
+		return 0, apperr.Invalid("contact_required", "phone or email required")
 	}
 
 	// validate email
@@ -44,40 +46,26 @@ func (r *userRepository) CreateUser(phone string, email string) (int, error) {
 		email = toLowerRegister(email)
 	}
 
-	var res sql.Result
-	var err error
-
 	if email != "" && phone == "" {
-		res, err = r.db.Exec("INSERT INTO user (email) VALUES (?)", email)
+		return insertID(ctx, r.db, `INSERT INTO "user" (email) VALUES (?)`, email)
 	} else if phone != "" && email == "" {
-		res, err = r.db.Exec("INSERT INTO user (phone_number) VALUES (?)", phone)
-	} else {
-		res, err = r.db.Exec("INSERT INTO user (email, phone_number) VALUES (?, ?)", email, phone)
-	}
-
-	if err != nil {
-		return 0, err
+		return insertID(ctx, r.db, `INSERT INTO "user" (phone_number) VALUES (?)`, phone)
 	}
-
-	id64, err := res.LastInsertId()
-	if err != nil {
-		return 0, err
-	}
-
-	return int(id64), nil
+	return insertID(ctx, r.db, `INSERT INTO "user" (email, phone_number) VALUES (?, ?)`, email, phone)
 }
 
 // GetUserByID retrieves a user by ID
-func (r *userRepository) GetUserByID(id int) (*models.User, error) {
+func (r *userRepository) GetUserByID(ctx context.Context, id int) (*models.User, error) {
 	user := &models.User{}
-	err := r.db.QueryRow(
-		"SELECT id, email, phone_number, created_at, updated_at FROM user WHERE id = ?",
+	err := r.db.QueryRowContext(
+		ctx,
+		`SELECT id, email, phone_number, role, email_verified_at, phone_verified_at, created_at, updated_at FROM "user" WHERE id = ? AND deleted_at IS NULL`,
 		id,
-	).Scan(&user.UserID, &user.Email, &user.Phone, &user.CreatedAt, &user.UpdatedAt)
+	).Scan(&user.UserID, &user.Email, &user.Phone, &user.Role, &user.EmailVerifiedAt, &user.PhoneVerifiedAt, &user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, errors.New("user not found")
+			return nil, apperr.ErrUserNotFound
 		}
 		return nil, err
 	}
@@ -86,7 +74,7 @@ func (r *userRepository) GetUserByID(id int) (*models.User, error) {
 }
 
 // GetUserByEmail retrieves a user by email
-func (r *userRepository) GetUserByEmail(email string) (*models.User, error) {
+func (r *userRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
 	// validate email
 	err := validateEmail(email)
 	if err != nil {
@@ -95,10 +83,11 @@ func (r *userRepository) GetUserByEmail(email string) (*models.User, error) {
 	email = toLowerRegister(email)
 
 	user := &models.User{}
-	err = r.db.QueryRow(
-		"SELECT id, email, phone_number, created_at, updated_at FROM user WHERE email = ?",
+	err = r.db.QueryRowContext(
+		ctx,
+		`SELECT id, email, phone_number, role, email_verified_at, phone_verified_at, created_at, updated_at FROM "user" WHERE email = ? AND deleted_at IS NULL`,
 		email,
-	).Scan(&user.UserID, &user.Email, &user.Phone, &user.CreatedAt, &user.UpdatedAt)
+	).Scan(&user.UserID, &user.Email, &user.Phone, &user.Role, &user.EmailVerifiedAt, &user.PhoneVerifiedAt, &user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -111,7 +100,7 @@ func (r *userRepository) GetUserByEmail(email string) (*models.User, error) {
 }
 
 // GetUserByPhone retrieves a user by phone
-func (r *userRepository) GetUserByPhone(phone string) (*models.User, error) {
+func (r *userRepository) GetUserByPhone(ctx context.Context, phone string) (*models.User, error) {
 	// validate phone
 	err := validatePhone(phone)
 	if err != nil {
@@ -119,10 +108,11 @@ func (r *userRepository) GetUserByPhone(phone string) (*models.User, error) {
 	}
 
 	user := &models.User{}
-	err = r.db.QueryRow(
-		"SELECT id, email, phone_number, created_at, updated_at FROM user WHERE phone_number = ?",
+	err = r.db.QueryRowContext(
+		ctx,
+		`SELECT id, email, phone_number, role, email_verified_at, phone_verified_at, created_at, updated_at FROM "user" WHERE phone_number = ? AND deleted_at IS NULL`,
 		phone,
-	).Scan(&user.UserID, &user.Email, &user.Phone, &user.CreatedAt, &user.UpdatedAt)
+	).Scan(&user.UserID, &user.Email, &user.Phone, &user.Role, &user.EmailVerifiedAt, &user.PhoneVerifiedAt, &user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -135,8 +125,8 @@ func (r *userRepository) GetUserByPhone(phone string) (*models.User, error) {
 }
 
 // GetAllUsers retrieves all users
-func (r *userRepository) GetAllUsers() ([]*models.User, error) {
-	rows, err := r.db.Query("SELECT id, email, phone_number, created_at, updated_at FROM user")
+func (r *userRepository) GetAllUsers(ctx context.Context) ([]*models.User, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id, email, phone_number, role, email_verified_at, phone_verified_at, created_at, updated_at FROM "user" WHERE deleted_at IS NULL`)
 	if err != nil {
 		return nil, err
 	}
@@ -145,7 +135,7 @@ func (r *userRepository) GetAllUsers() ([]*models.User, error) {
 	var users []*models.User
 	for rows.Next() {
 		user := &models.User{}
-		if err := rows.Scan(&user.UserID, &user.Email, &user.Phone, &user.CreatedAt, &user.UpdatedAt); err != nil {
+		if err := rows.Scan(&user.UserID, &user.Email, &user.Phone, &user.Role, &user.EmailVerifiedAt, &user.PhoneVerifiedAt, &user.CreatedAt, &user.UpdatedAt); err != nil {
 			return nil, err
 		}
 		users = append(users, user)
@@ -155,9 +145,10 @@ func (r *userRepository) GetAllUsers() ([]*models.User, error) {
 }
 
 // GetPageUsers retrieves users with pagination
-func (r *userRepository) GetPageUsers(offset, limit int) ([]*models.User, error) {
-	rows, err := r.db.Query(
-		"SELECT id, email, phone_number, created_at, updated_at FROM user LIMIT ? OFFSET ?",
+func (r *userRepository) GetPageUsers(ctx context.Context, offset, limit int) ([]*models.User, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT id, email, phone_number, role, email_verified_at, phone_verified_at, created_at, updated_at FROM "user" WHERE deleted_at IS NULL LIMIT ? OFFSET ?`,
 		limit,
 		offset,
 	)
@@ -169,7 +160,7 @@ func (r *userRepository) GetPageUsers(offset, limit int) ([]*models.User, error)
 	var users []*models.User
 	for rows.Next() {
 		user := &models.User{}
-		if err := rows.Scan(&user.UserID, &user.Email, &user.Phone, &user.CreatedAt, &user.UpdatedAt); err != nil {
+		if err := rows.Scan(&user.UserID, &user.Email, &user.Phone, &user.Role, &user.EmailVerifiedAt, &user.PhoneVerifiedAt, &user.CreatedAt, &user.UpdatedAt); err != nil {
 			return nil, err
 		}
 		users = append(users, user)
@@ -179,7 +170,7 @@ func (r *userRepository) GetPageUsers(offset, limit int) ([]*models.User, error)
 }
 
 // UpdateUser updates a user
-func (r *userRepository) UpdateUser(id int, user *models.User) error {
+func (r *userRepository) UpdateUser(ctx context.Context, id int, user *models.User) error {
 	// validate email
 	err := validateEmail(user.Email)
 	if err != nil {
@@ -195,35 +186,100 @@ func (r *userRepository) UpdateUser(id int, user *models.User) error {
 		}
 	}
 
-	_, err = r.db.Exec(
-		"UPDATE user SET email = ?, phone_number = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+	_, err = r.db.ExecContext(
+		ctx,
+		`UPDATE "user" SET email = ?, phone_number = ?, phone_verified_at = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL`,
 		user.Email,
 		user.Phone,
+		user.PhoneVerifiedAt,
+		id,
+	)
+	return contactConflict(err, "phone_taken", "user with this phone already exists")
+}
+
+// UpdateUserEmail moves the user to a new email confirmed at verifiedAt
+func (r *userRepository) UpdateUserEmail(ctx context.Context, id int, email string, verifiedAt time.Time) error {
+	// validate email
+	err := validateEmail(email)
+	if err != nil {
+		return err
+	}
+	email = toLowerRegister(email)
+
+	res, err := r.db.ExecContext(
+		ctx,
+		`UPDATE "user" SET email = ?, email_verified_at = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL`,
+		email,
+		verifiedAt,
+		id,
+	)
+	if err != nil {
+		return contactConflict(err, "email_taken", "user with this email already exists")
+	}
+	return expectAffected(res, apperr.ErrUserNotFound)
+}
+
+// UpdateUserPhone sets the phone of the user confirmed at verifiedAt
+func (r *userRepository) UpdateUserPhone(ctx context.Context, id int, phone string, verifiedAt time.Time) error {
+	// validate phone
+	err := validatePhone(phone)
+	if err != nil {
+		return err
+	}
+
+	res, err := r.db.ExecContext(
+		ctx,
+		`UPDATE "user" SET phone_number = ?, phone_verified_at = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL`,
+		phone,
+		verifiedAt,
+		id,
+	)
+	if err != nil {
+		return contactConflict(err, "phone_taken", "user with this phone already exists")
+	}
+	return expectAffected(res, apperr.ErrUserNotFound)
+}
+
+// SetEmailVerified marks the email of the user as confirmed at verifiedAt unless it already is
+func (r *userRepository) SetEmailVerified(ctx context.Context, id int, verifiedAt time.Time) error {
+	_, err := r.db.ExecContext(
+		ctx,
+		`UPDATE "user" SET email_verified_at = ? WHERE id = ? AND email_verified_at IS NULL`,
+		verifiedAt,
 		id,
 	)
 	return err
 }
 
-// DeleteUserByID deletes a user by ID
-func (r *userRepository) DeleteUserByID(id int) error {
-	_, err := r.db.Exec("DELETE FROM user WHERE id = ?", id)
+// UpdateUserRole sets the role of a user
+func (r *userRepository) UpdateUserRole(ctx context.Context, id int, role string) error {
+	_, err := r.db.ExecContext(
+		ctx,
+		`UPDATE "user" SET role = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL`,
+		role,
+		id,
+	)
 	return err
 }
 
-// DeleteUserByPhone deletes a user by phone
-func (r *userRepository) DeleteUserByPhone(phone string) error {
+// DeleteUserByID soft-deletes a user and their advertisements
+func (r *userRepository) DeleteUserByID(ctx context.Context, id int, deletedAt time.Time) error {
+	return r.softDeleteUser(ctx, "id = ?", id, deletedAt)
+}
+
+// DeleteUserByPhone soft-deletes a user by phone
+func (r *userRepository) DeleteUserByPhone(ctx context.Context, phone string, deletedAt time.Time) error {
 	// validate phone
 	err := validatePhone(phone)
 	if err != nil {
 		return err
 	}
 
-	_, err = r.db.Exec("DELETE FROM user WHERE phone_number = ?", phone)
-	return err
+	return r.softDeleteUser(ctx, "phone_number = ?", phone, deletedAt)
 }
 
-// DeleteUserByEmail deletes a user by email
-func (r *userRepository) DeleteUserByEmail(email string) error {
+// DeleteUserByEmail soft-deletes a user by email
+func (r *userRepository) DeleteUserByEmail(ctx context.Context, email string, deletedAt time.Time) error {
 	// validate email
 	err := validateEmail(email)
 	if err != nil {
@@ -231,20 +287,161 @@ func (r *userRepository) DeleteUserByEmail(email string) error {
 	}
 	email = toLowerRegister(email)
 
-	_, err = r.db.Exec("DELETE FROM user WHERE email = ?", email)
+	return r.softDeleteUser(ctx, "email = ?", email, deletedAt)
+}
+
+// softDeleteUser marks the user matching where as deleted together with their advertisements.
+// The advertisements get the same deleted_at, so restoring the user brings back exactly them
+// and not the ones the user had deleted before.
+func (r *userRepository) softDeleteUser(ctx context.Context, where string, arg any, deletedAt time.Time) error {
+	return withTx(ctx, r.db, func(tx DBTX) error {
+		var id int
+		err := tx.QueryRowContext(ctx, `SELECT id FROM "user" WHERE `+where+` AND deleted_at IS NULL`, arg).Scan(&id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return apperr.ErrUserNotFound
+			}
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "UPDATE advertisement SET deleted_at = ? WHERE user_id = ? AND deleted_at IS NULL", deletedAt, id); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE "user" SET deleted_at = ? WHERE id = ?`, deletedAt, id)
+		return err
+	})
+}
+
+// GetDeletedUserByEmail retrieves a soft-deleted user by email
+func (r *userRepository) GetDeletedUserByEmail(ctx context.Context, email string) (*models.DeletedUser, error) {
+	user := &models.DeletedUser{}
+	err := r.db.QueryRowContext(
+		ctx,
+		`SELECT id, deleted_at, erase_after FROM "user" WHERE email = ? AND deleted_at IS NOT NULL`,
+		toLowerRegister(email),
+	).Scan(&user.ID, &user.DeletedAt, &user.EraseAfter)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // Not found, but no error
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// GetDeletedUserByPhone retrieves a soft-deleted user by phone
+func (r *userRepository) GetDeletedUserByPhone(ctx context.Context, phone string) (*models.DeletedUser, error) {
+	user := &models.DeletedUser{}
+	err := r.db.QueryRowContext(
+		ctx,
+		`SELECT id, deleted_at, erase_after FROM "user" WHERE phone_number = ? AND deleted_at IS NOT NULL`,
+		phone,
+	).Scan(&user.ID, &user.DeletedAt, &user.EraseAfter)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // Not found, but no error
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// RestoreUserByID restores a soft-deleted user and the advertisements deleted together with them.
+// Accounts deleted by their owners are left alone, only the owner can cancel that deletion.
+func (r *userRepository) RestoreUserByID(ctx context.Context, id int) error {
+	return withTx(ctx, r.db, func(tx DBTX) error {
+		_, err := tx.ExecContext(ctx, `
+            UPDATE advertisement SET deleted_at = NULL
+            WHERE user_id = ? AND deleted_at = (SELECT deleted_at FROM "user" WHERE id = ? AND erase_after IS NULL)
+        `, id, id)
+		if err != nil {
+			return err
+		}
+
+		res, err := tx.ExecContext(ctx, `UPDATE "user" SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL AND erase_after IS NULL`, id)
+		if err != nil {
+			return err
+		}
+		return expectAffected(res, apperr.ErrDeletedUserNotFound)
+	})
+}
+
+// GetUsersDeletedBefore retrieves users soft-deleted before the given time.
+// Accounts deleted by their owners are skipped, they are erased when their grace period ends.
+func (r *userRepository) GetUsersDeletedBefore(ctx context.Context, before time.Time) ([]*models.DeletedUser, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT id, deleted_at FROM "user" WHERE deleted_at IS NOT NULL AND deleted_at < ? AND erase_after IS NULL ORDER BY deleted_at`,
+		before,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*models.DeletedUser
+	for rows.Next() {
+		user := &models.DeletedUser{}
+		if err := rows.Scan(&user.ID, &user.DeletedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+// PurgeUserByID permanently deletes a soft-deleted user. The profile, OTP codes, advertisements and photos
+// go with it through ON DELETE CASCADE; the photo paths are returned so the files can be removed too.
+func (r *userRepository) PurgeUserByID(ctx context.Context, id int) ([]string, error) {
+	var paths []string
+	err := withTx(ctx, r.db, func(tx DBTX) error {
+		var err error
+		paths, err = queryStrings(ctx, tx, `
+            SELECT p.photo_url
+            FROM advertisement_photos p
+            JOIN advertisement a ON a.id = p.advertisement_id
+            WHERE a.user_id = ?
+        `, id)
+		if err != nil {
+			return err
+		}
+
+		res, err := tx.ExecContext(ctx, `DELETE FROM "user" WHERE id = ? AND deleted_at IS NOT NULL`, id)
+		if err != nil {
+			return err
+		}
+		return expectAffected(res, apperr.ErrDeletedUserNotFound)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+// contactConflict reports a write that hit the unique email or phone of another user as a conflict.
+// Services check first, this covers a concurrent change between the check and the write.
+func contactConflict(err error, code, message string) error {
+	if err != nil && isUniqueViolation(err) {
+		return apperr.Conflict(code, message)
+	}
 	return err
 }
 
 // validateEmail проверяет корректность email
 func validateEmail(email string) error {
 	if email == "" {
-		return errors.New("email is empty")
+		return apperr.Invalid("invalid_email", "email is empty")
 	}
 
 	// Используем стандартный пакет net/mail
 	_, err := mail.ParseAddress(email)
 	if err != nil {
-		return errors.New("invalid email format")
+		return apperr.Invalid("invalid_email", "invalid email format")
 	}
 
 	return nil
@@ -253,13 +450,13 @@ func validateEmail(email string) error {
 // validatePhone проверяет корректность телефона
 func validatePhone(phone string) error {
 	if phone == "" {
-		return errors.New("phone is empty")
+		return apperr.Invalid("invalid_phone", "phone is empty")
 	}
 
 	// Простая проверка: цифры, +, -, пробелы
 	re := regexp.MustCompile(`^\+?\d[\d\s\-]{7,14}\d$`)
 	if !re.MatchString(phone) {
-		return errors.New("invalid phone format")
+		return apperr.Invalid("invalid_phone", "invalid phone format")
 	}
 
 	return nil
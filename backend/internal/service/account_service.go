@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"rentor/internal/logger"
+	"rentor/internal/models"
+	"rentor/internal/repository"
+)
+
+// accountService implements AccountService
+type accountService struct {
+	accountRepo repository.AccountRepository
+	imageSvc    ImageService
+	exportPath  string
+	stagingPath string
+	gracePeriod time.Duration
+}
+
+// NewAccountService creates a new account service. Accounts deleted by their owners are erased
+// gracePeriod after the deletion, exportPath and stagingPath hold the files erased with them.
+func NewAccountService(accountRepo repository.AccountRepository, imageSvc ImageService, exportPath, stagingPath string, gracePeriod time.Duration) AccountService {
+	return &accountService{
+		accountRepo: accountRepo,
+		imageSvc:    imageSvc,
+		exportPath:  exportPath,
+		stagingPath: stagingPath,
+		gracePeriod: gracePeriod,
+	}
+}
+
+// DeleteAccount deactivates the account of the user together with their advertisements.
+// The account is erased once the grace period has passed, signing in before that cancels the deletion.
+func (s *accountService) DeleteAccount(ctx context.Context, userID int) (*models.AccountDeletion, error) {
+	token, err := generateUploadToken()
+	if err != nil {
+		return nil, err
+	}
+	reference := token[:16]
+
+	now := time.Now().UTC()
+	eraseAfter := now.Add(s.gracePeriod)
+	if err := s.accountRepo.ScheduleAccountDeletion(ctx, userID, reference, now, eraseAfter); err != nil {
+		return nil, err
+	}
+
+	logger.Info("account deletion requested", logger.Field("reference", reference), logger.Field("erase_after", eraseAfter))
+	return &models.AccountDeletion{EraseAfter: eraseAfter}, nil
+}
+
+// CancelDeletion restores the account if its owner deleted it and it has not been erased yet
+func (s *accountService) CancelDeletion(ctx context.Context, userID int) (bool, error) {
+	cancelled, err := s.accountRepo.CancelAccountDeletion(ctx, userID, time.Now().UTC())
+	if err != nil {
+		return false, err
+	}
+	if cancelled {
+		logger.Info("account deletion cancelled", logger.Field("user_id", userID))
+	}
+	return cancelled, nil
+}
+
+// EraseDueAccounts erases the accounts whose grace period has passed together with their files.
+// An account that fails to erase is retried on the next run, the others are still erased.
+func (s *accountService) EraseDueAccounts(ctx context.Context) error {
+	users, err := s.accountRepo.GetAccountsDueForErasure(ctx, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	erased := 0
+	for _, user := range users {
+		erasure, err := s.accountRepo.EraseAccount(ctx, user.ID, time.Now().UTC())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("account %d: %w", user.ID, err))
+			continue
+		}
+		erased++
+		s.removeFiles(erasure)
+	}
+
+	if erased > 0 {
+		logger.Info("deleted accounts erased", logger.Field("count", erased))
+	}
+
+	return errors.Join(errs...)
+}
+
+// removeFiles deletes the files of an erased account. The rows are already gone, so a file that can't be
+// removed is only logged; photos are then picked up by the image reconcile as orphans.
+func (s *accountService) removeFiles(erasure *models.AccountErasure) {
+	for _, path := range erasure.Photos {
+		if err := s.imageSvc.DeleteImage(path); err != nil {
+			logger.Warn("failed to remove image of erased account", logger.Field("path", path), logger.Field("error", err.Error()))
+		}
+	}
+	for _, name := range erasure.Exports {
+		removeErasedFile(filepath.Join(s.exportPath, name))
+	}
+	for _, name := range erasure.Uploads {
+		removeErasedFile(filepath.Join(s.stagingPath, name))
+	}
+}
+
+// removeErasedFile deletes a file of an erased account, a file that is already gone is fine
+func removeErasedFile(path string) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		logger.Warn("failed to remove file of erased account", logger.Field("path", path), logger.Field("error", err.Error()))
+	}
+}
@@ -1,138 +1,664 @@
 package service
 
 import (
-	"errors"
+	"context"
+	"fmt"
+	"math"
+	"rentor/internal/apperr"
+	"rentor/internal/logger"
+	"rentor/internal/mergepatch"
 	"rentor/internal/models"
+	"rentor/internal/money"
 	"rentor/internal/repository"
+	"rentor/internal/validation"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// ErrUndoWindowExpired is returned when the owner tries to restore an advertisement deleted too long ago
+var ErrUndoWindowExpired = apperr.Conflict("undo_window_expired", "undo window has expired")
+
 type advertisementService struct {
-	adRepo repository.AdRepository
+	adRepo          repository.AdRepository
+	txManager       repository.TxManager
+	moderation      ModerationService
+	undoWindow      time.Duration // как долго владелец может отменить удаление
+	priceDropPeriod time.Duration // сколько показывается бейдж снижения цены
+	rates           *money.Rates  // курсы валют для фильтра по цене, базовая валюта — валюта по умолчанию
 }
 
-func NewadvertisementService(adRepo repository.AdRepository) *advertisementService {
+func NewadvertisementService(adRepo repository.AdRepository, txManager repository.TxManager, moderation ModerationService, undoWindow, priceDropPeriod time.Duration, rates *money.Rates) *advertisementService {
 	return &advertisementService{
-		adRepo: adRepo,
+		adRepo:          adRepo,
+		txManager:       txManager,
+		moderation:      moderation,
+		undoWindow:      undoWindow,
+		priceDropPeriod: priceDropPeriod,
+		rates:           rates,
 	}
 }
 
+// ErrUnknownCurrency is returned when a price filter names a currency that is not supported
+var ErrUnknownCurrency = apperr.Invalid("unknown_currency", "unknown currency")
+
+// ErrUnknownPricePeriod is returned when a filter names a price period that is not supported
+var ErrUnknownPricePeriod = apperr.Invalid("unknown_price_period", "price period must be day or month")
+
+// ErrUnknownAmenity is returned when a filter names an amenity that is not in the catalog
+var ErrUnknownAmenity = apperr.Invalid("unknown_amenity", "unknown amenity")
+
+// ErrUnknownFurnishing is returned when a filter names a furnishing that is not supported
+var ErrUnknownFurnishing = apperr.Invalid("unknown_furnishing", "furnishing must be none, partial or full")
+
 // ==========================
 // CREATE
 // ==========================
-func (s *advertisementService) CreateAdvertisement(userID int, input *models.CreateAdvertisementInput) (*models.GetAd, error) {
-	adID, err := s.adRepo.CreateAdvertisement(userID, input)
+func (s *advertisementService) CreateAdvertisement(ctx context.Context, userID int, input *models.CreateAdvertisementInput) (*models.GetAd, error) {
+	// без валюты и периода цена считается в валюте по умолчанию за месяц
+	if input.Currency == "" {
+		input.Currency = s.rates.Base()
+	}
+	if input.PricePeriod == "" {
+		input.PricePeriod = models.PricePeriodMonth
+	}
+	amenities, err := checkAmenities(ctx, &s.adRepo, input.Amenities)
 	if err != nil {
 		return nil, err
 	}
+	input.Amenities = amenities
 
-	return s.adRepo.GetAdvertisement(adID)
+	adID, err := s.adRepo.CreateAdvertisement(ctx, userID, input)
+	if err != nil {
+		return nil, err
+	}
+
+	s.screen(ctx, adID)
+
+	return s.GetAdvertisement(ctx, adID)
 }
 
 // ==========================
 // GET BY ID
 // ==========================
-func (s *advertisementService) GetAdvertisement(id int) (*models.GetAd, error) {
-	return s.adRepo.GetAdvertisement(id)
+func (s *advertisementService) GetAdvertisement(ctx context.Context, id int) (*models.GetAd, error) {
+	ad, err := s.adRepo.GetAdvertisement(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	ad.PriceDropped = s.priceDropped(ad.Price, ad.PreviousPrice, ad.PriceChangedAt)
+	return ad, nil
 }
 
 // ==========================
 // FILTERED LIST
 // ==========================
-func (s *advertisementService) GetAdvertisementsPaged(filters *models.AdFilters) (*models.GetAdPreviewsList, error) {
-	return s.adRepo.GetAdvertisementsPaged(filters)
+func (s *advertisementService) GetAdvertisementsPaged(ctx context.Context, filters *models.AdFilters) (*models.GetAdPreviewsList, error) {
+	if err := s.priceFilters(filters); err != nil {
+		return nil, err
+	}
+	if err := s.attributeFilters(ctx, filters); err != nil {
+		return nil, err
+	}
+
+	list, err := s.adRepo.GetAdvertisementsPaged(ctx, filters)
+	if err != nil {
+		return nil, err
+	}
+	for i := range list.Items {
+		item := &list.Items[i]
+		item.PriceDropped = s.priceDropped(item.Price, item.PreviousPrice, item.PriceChangedAt)
+	}
+	return list, nil
+}
+
+// GetAdvertisementPreviews возвращает превью объявлений в порядке ids, удалённые объявления пропускаются
+func (s *advertisementService) GetAdvertisementPreviews(ctx context.Context, ids []int) ([]models.AdPreview, error) {
+	items, err := s.adRepo.GetAdPreviewsByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int]models.AdPreview, len(items))
+	for _, item := range items {
+		item.PriceDropped = s.priceDropped(item.Price, item.PreviousPrice, item.PriceChangedAt)
+		byID[item.ID] = item
+	}
+
+	previews := make([]models.AdPreview, 0, len(items))
+	for _, id := range ids {
+		if item, ok := byID[id]; ok {
+			previews = append(previews, item)
+		}
+	}
+	return previews, nil
 }
 
 // ==========================
 // GET MY ADS
 // ==========================
-func (s *advertisementService) GetMyAdvertisements(userID, page, limit int) (*models.GetAdPreviewsList, error) {
+func (s *advertisementService) GetMyAdvertisements(ctx context.Context, userID, page, limit int) (*models.GetAdPreviewsList, error) {
 	f := &models.AdFilters{
 		Page:   page,
 		Limit:  limit,
 		UserID: &userID,
 	}
-	return s.adRepo.GetAdvertisementsPaged(f)
+	return s.GetAdvertisementsPaged(ctx, f)
 }
 
 // ==========================
 // UPDATE
 // ==========================
-func (s *advertisementService) UpdateAdvertisement(userID, adID int, input *models.UpdateAdvertisementInput) error {
+// UpdateAdvertisement заменяет все редактируемые поля объявления (PUT) и возвращает новую версию.
+// ifMatch — версия, которую видел клиент (If-Match); nil — без проверки.
+func (s *advertisementService) UpdateAdvertisement(ctx context.Context, userID, adID int, input *models.UpdateAdvertisementInput, ifMatch *int) (int, error) {
+	return s.update(ctx, userID, adID, ifMatch, func(*models.UpdateAdvertisementInput) (*models.UpdateAdvertisementInput, error) {
+		return input, nil
+	})
+}
+
+// PatchAdvertisement применяет к объявлению JSON Merge Patch (PATCH): меняются только переданные поля.
+// Результат проверяется теми же правилами, что и полное обновление.
+func (s *advertisementService) PatchAdvertisement(ctx context.Context, userID, adID int, patch []byte, ifMatch *int) (int, error) {
+	return s.update(ctx, userID, adID, ifMatch, func(current *models.UpdateAdvertisementInput) (*models.UpdateAdvertisementInput, error) {
+		next := *current
+		if err := mergepatch.Apply(&next, patch); err != nil {
+			return nil, err
+		}
+		if err := validation.Validate(&next); err != nil {
+			return nil, err
+		}
+		return &next, nil
+	})
+}
+
+// update проверяет владельца и версию, строит новое состояние из текущего и сохраняет его вместе с историей
+func (s *advertisementService) update(ctx context.Context, userID, adID int, ifMatch *int,
+	build func(current *models.UpdateAdvertisementInput) (*models.UpdateAdvertisementInput, error)) (int, error) {
 	// Проверка принадлежности
-	owner, err := s.adRepo.GetUserID(adID)
+	owner, err := s.adRepo.GetUserID(ctx, adID)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	if userID != owner {
-		return errors.New("not owner")
+		return 0, apperr.ErrNotOwner
+	}
+
+	// изменения и их история пишутся в одной транзакции
+	changed := false
+	var version int
+	err = s.txManager.WithinTx(ctx, func(repos *repository.Repositories) error {
+		before, err := repos.Advertisement.GetAdvertisementState(ctx, adID)
+		if err != nil {
+			return err
+		}
+		if ifMatch != nil && *ifMatch != before.Version {
+			return apperr.ErrVersionMismatch
+		}
+		version = before.Version
+
+		input, err := build(&before.UpdateAdvertisementInput)
+		if err != nil {
+			return err
+		}
+		// клиенты, не знающие о валюте и периоде, не меняют их
+		if input.Currency == "" {
+			input.Currency = before.Currency
+		}
+		if input.PricePeriod == "" {
+			input.PricePeriod = before.PricePeriod
+		}
+		// каталог читается через транзакцию: на пуле с одним соединением чтение мимо неё ждало бы её конца
+		if input.Amenities, err = checkAmenities(ctx, &repos.Advertisement, input.Amenities); err != nil {
+			return err
+		}
+
+		changes := diffAdvertisement(&before.UpdateAdvertisementInput, input)
+		if len(changes) == 0 {
+			return nil
+		}
+		changed = true
+
+		// версия проверяется ещё раз при записи: объявление могли изменить после чтения
+		now := time.Now().UTC()
+		if err := repos.Advertisement.UpdateAdvertisement(ctx, adID, input, before.Version, now); err != nil {
+			return err
+		}
+		version = before.Version + 1
+		_, err = repos.Advertisement.CreateAdvertisementVersion(ctx, adID, userID, changes, now)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if changed {
+		s.screen(ctx, adID)
+	}
+	return version, nil
+}
+
+// ==========================
+// HISTORY
+// ==========================
+// GetAdvertisementHistory возвращает историю изменений владельцу объявления или администратору.
+// Администратор видит и историю удалённых объявлений.
+func (s *advertisementService) GetAdvertisementHistory(ctx context.Context, userID, adID int, isAdmin bool) (*models.AdvertisementHistory, error) {
+	if !isAdmin {
+		// Проверка принадлежности
+		owner, err := s.adRepo.GetUserID(ctx, adID)
+		if err != nil {
+			return nil, err
+		}
+
+		if userID != owner {
+			return nil, apperr.ErrNotOwner
+		}
 	}
 
-	return s.adRepo.UpdateAdvertisement(adID, input)
+	versions, err := s.adRepo.GetAdvertisementHistory(ctx, adID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.AdvertisementHistory{
+		AdvertisementID: adID,
+		Versions:        versions,
+	}, nil
 }
 
 // ==========================
 // DELETE
 // ==========================
-func (s *advertisementService) DeleteAdvertisement(userID, adID int) error {
+// Объявление помечается удалённым, владелец может отменить удаление в течение undoWindow,
+// администратор — до окончательной очистки (см. PurgeService)
+func (s *advertisementService) DeleteAdvertisement(ctx context.Context, userID, adID int) (*models.DeleteAdvertisementResponse, error) {
 	// Проверка принадлежности
-	owner, err := s.adRepo.GetUserID(adID)
+	owner, err := s.adRepo.GetUserID(ctx, adID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if userID != owner {
-		return errors.New("not owner")
+		return nil, apperr.ErrNotOwner
 	}
 
-	// TODO — привязать к user_profile_id, когда ты сделаешь таблицу профилей
-	// сейчас у нас нет прямой связи — значит, удаляем без проверки
+	deletedAt := time.Now().UTC()
+	if err := s.adRepo.DeleteAdvertisement(ctx, adID, deletedAt); err != nil {
+		return nil, err
+	}
 
-	return s.adRepo.DeleteAdvertisement(adID)
+	return &models.DeleteAdvertisementResponse{
+		Status:       "deleted",
+		RestoreUntil: deletedAt.Add(s.undoWindow),
+	}, nil
+}
+
+// ==========================
+// RESTORE
+// ==========================
+func (s *advertisementService) RestoreAdvertisement(ctx context.Context, userID, adID int) error {
+	ad, err := s.adRepo.GetDeletedAdvertisement(ctx, adID)
+	if err != nil {
+		return err
+	}
+
+	if userID != ad.UserID {
+		return apperr.ErrNotOwner
+	}
+
+	if time.Since(ad.DeletedAt) > s.undoWindow {
+		return ErrUndoWindowExpired
+	}
+
+	return s.adRepo.RestoreAdvertisement(ctx, adID)
+}
+
+// AdminRestoreAdvertisement восстанавливает объявление без ограничения по времени (пока оно не очищено)
+func (s *advertisementService) AdminRestoreAdvertisement(ctx context.Context, adID int) error {
+	return s.adRepo.RestoreAdvertisement(ctx, adID)
+}
+
+// ==========================
+// FAVORITES
+// ==========================
+// AddFavorite добавляет объявление в избранное. Возвращает false, если оно уже было в избранном.
+func (s *advertisementService) AddFavorite(ctx context.Context, userID, adID int) (bool, error) {
+	// объявление должно существовать и не быть удалённым
+	if _, err := s.adRepo.GetUserID(ctx, adID); err != nil {
+		return false, err
+	}
+
+	return s.adRepo.AddFavorite(ctx, userID, adID, time.Now().UTC())
+}
+
+func (s *advertisementService) RemoveFavorite(ctx context.Context, userID, adID int) error {
+	return s.adRepo.RemoveFavorite(ctx, userID, adID)
+}
+
+func (s *advertisementService) GetFavorites(ctx context.Context, userID, page, limit int) (*models.GetAdPreviewsList, error) {
+	f := &models.AdFilters{
+		Page:        page,
+		Limit:       limit,
+		FavoritesOf: &userID,
+	}
+	return s.GetAdvertisementsPaged(ctx, f)
+}
+
+// ==========================
+// CONTACTS
+// ==========================
+func (s *advertisementService) GetLandlordContacts(ctx context.Context, adID int) (*models.LandlordContacts, error) {
+	return s.adRepo.GetLandlordContacts(ctx, adID)
 }
 
 // ==========================
 // ADD IMAGES
 // ==========================
-func (s *advertisementService) AddImages(userID, adID int, urls []string) (*models.ImagesUploadResponse, error) {
+func (s *advertisementService) AddImages(ctx context.Context, userID, adID int, images []models.SavedImage) (*models.ImagesUploadResponse, error) {
 	// Проверка принадлежности
-	owner, err := s.adRepo.GetUserID(adID)
+	owner, err := s.adRepo.GetUserID(ctx, adID)
 	if err != nil {
 		return nil, err
 	}
 
 	if userID != owner {
-		return nil, errors.New("not owner")
+		return nil, apperr.ErrNotOwner
 	}
 
-	err = s.adRepo.CreateAdvertisementImages(adID, urls)
+	err = s.adRepo.CreateAdvertisementImages(ctx, adID, images)
 	if err != nil {
 		return nil, err
 	}
 
+	return s.ScreenImages(ctx, userID, adID, images), nil
+}
+
+// ScreenImages проверяет только что привязанные фото на дубликаты и возвращает ответ о загрузке.
+// Вызывается после привязки фото, в том числе теми, кто привязывает их в своей транзакции.
+func (s *advertisementService) ScreenImages(ctx context.Context, userID, adID int, images []models.SavedImage) *models.ImagesUploadResponse {
+	// проверка на повторно использованные чужие фото не должна ломать загрузку
+	if err := s.moderation.CheckNewImages(ctx, userID, adID, images); err != nil {
+		logger.Error("duplicate photo check failed", logger.Field("error", err.Error()), logger.Field("advertisement_id", adID))
+	}
+	s.screen(ctx, adID)
+
+	urls := make([]string, 0, len(images))
+	for _, image := range images {
+		urls = append(urls, image.URL)
+	}
+
 	return &models.ImagesUploadResponse{
 		Uploaded: urls,
 		Count:    len(urls),
-	}, nil
+	}
 }
 
 // ==========================
 // DELETE IMAGE
 // ==========================
-func (s *advertisementService) DeleteImage(userID, adID, imageID int) error {
+func (s *advertisementService) DeleteImage(ctx context.Context, userID, adID, imageID int) error {
+	// Проверка принадлежности
+	owner, err := s.adRepo.GetUserID(ctx, adID)
+	if err != nil {
+		return err
+	}
+
+	if userID != owner {
+		return apperr.ErrNotOwner
+	}
+
+	return s.adRepo.DeleteAdvertisementImage(ctx, adID, imageID)
+}
+
+// ==========================
+// IMAGE ORDER / COVER / CAPTION
+// ==========================
+func (s *advertisementService) ReorderImages(ctx context.Context, userID, adID int, imageIDs []int) error {
+	// Проверка принадлежности
+	owner, err := s.adRepo.GetUserID(ctx, adID)
+	if err != nil {
+		return err
+	}
+
+	if userID != owner {
+		return apperr.ErrNotOwner
+	}
+
+	return s.adRepo.ReorderAdvertisementImages(ctx, adID, imageIDs)
+}
+
+func (s *advertisementService) SetCoverImage(ctx context.Context, userID, adID, imageID int) error {
 	// Проверка принадлежности
-	owner, err := s.adRepo.GetUserID(adID)
+	owner, err := s.adRepo.GetUserID(ctx, adID)
 	if err != nil {
 		return err
 	}
 
 	if userID != owner {
-		return errors.New("not owner")
+		return apperr.ErrNotOwner
 	}
 
-	return s.adRepo.DeleteAdvertisementImage(adID, imageID)
+	return s.adRepo.SetAdvertisementCover(ctx, adID, imageID)
 }
 
-func (s *advertisementService) GetImagePath(adID, imageID int) (string, error) {
-	return s.adRepo.GetImagePath(adID, imageID)
+func (s *advertisementService) UpdateImageCaption(ctx context.Context, userID, adID, imageID int, caption *string) error {
+	// Проверка принадлежности
+	owner, err := s.adRepo.GetUserID(ctx, adID)
+	if err != nil {
+		return err
+	}
+
+	if userID != owner {
+		return apperr.ErrNotOwner
+	}
+
+	return s.adRepo.UpdateAdvertisementImageCaption(ctx, adID, imageID, caption)
+}
+
+// screen отправляет объявление на проверку дубликатов, ошибки только логируются
+func (s *advertisementService) screen(ctx context.Context, adID int) {
+	if err := s.moderation.ScreenAdvertisement(ctx, adID); err != nil {
+		logger.Error("duplicate listing check failed", logger.Field("error", err.Error()), logger.Field("advertisement_id", adID))
+	}
+}
+
+func (s *advertisementService) GetImagePath(ctx context.Context, adID, imageID int) (string, error) {
+	return s.adRepo.GetImagePath(ctx, adID, imageID)
+}
+
+// priceFilters проверяет валюту и период фильтра и пересчитывает границы цены в валюты объявлений.
+// Границы заданы в валюте фильтра (по умолчанию — базовой); объявления в валютах без курса
+// попадают в выдачу по цене, только если их валюта совпадает с валютой фильтра.
+func (s *advertisementService) priceFilters(filters *models.AdFilters) error {
+	if filters.PricePeriod != nil && !slices.Contains(models.PricePeriods, *filters.PricePeriod) {
+		return ErrUnknownPricePeriod
+	}
+
+	currency := s.rates.Base()
+	if filters.Currency != nil {
+		currency = strings.ToUpper(*filters.Currency)
+		if !money.IsCurrency(currency) {
+			return ErrUnknownCurrency
+		}
+	}
+	filters.PriceBounds = nil
+	if filters.MinPrice == nil && filters.MaxPrice == nil {
+		return nil
+	}
+
+	filters.PriceBounds = append(filters.PriceBounds, models.PriceBound{Currency: currency, Min: filters.MinPrice, Max: filters.MaxPrice})
+	for _, target := range s.rates.Currencies() {
+		rate, ok := s.rates.Rate(currency, target)
+		if !ok || target == currency {
+			continue
+		}
+		bound := models.PriceBound{Currency: target}
+		// цена объявления пересчитывается в валюту фильтра с округлением до копейки (money.Rates.Convert),
+		// поэтому границы расширяются на полкопейки и округляются наружу: объявление, цена которого
+		// после пересчёта равна границе, остаётся в выдаче
+		if filters.MinPrice != nil {
+			minPrice := money.Amount(math.Ceil((float64(*filters.MinPrice)-0.5)*rate - 1e-6))
+			bound.Min = &minPrice
+		}
+		if filters.MaxPrice != nil {
+			maxPrice := money.Amount(math.Floor((float64(*filters.MaxPrice)+0.5)*rate + 1e-6))
+			bound.Max = &maxPrice
+		}
+		filters.PriceBounds = append(filters.PriceBounds, bound)
+	}
+	return nil
+}
+
+// GetAmenities возвращает каталог удобств
+func (s *advertisementService) GetAmenities(ctx context.Context) (*models.AmenityCatalog, error) {
+	amenities, err := s.adRepo.GetAmenities(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &models.AmenityCatalog{Items: amenities}, nil
+}
+
+// checkAmenities проверяет коды удобств по каталогу и возвращает их без повторов, отсортированными,
+// чтобы история изменений не видела перестановок
+func checkAmenities(ctx context.Context, repo *repository.AdRepository, codes []string) ([]string, error) {
+	if len(codes) == 0 {
+		return []string{}, nil
+	}
+	known, err := amenityCodes(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	for i, code := range codes {
+		if !known[code] {
+			return nil, validation.Check(validation.NewError(fmt.Sprintf("amenities[%d]", i), "unknown_amenity", "must be a code of the amenity catalog"))
+		}
+	}
+	codes = slices.Clone(codes)
+	slices.Sort(codes)
+	return slices.Compact(codes), nil
+}
+
+// attributeFilters проверяет удобства и отделку в фильтре
+func (s *advertisementService) attributeFilters(ctx context.Context, filters *models.AdFilters) error {
+	if filters.Furnishing != nil && !slices.Contains(models.Furnishings, *filters.Furnishing) {
+		return ErrUnknownFurnishing
+	}
+	if len(filters.Amenities) == 0 {
+		return nil
+	}
+	known, err := amenityCodes(ctx, &s.adRepo)
+	if err != nil {
+		return err
+	}
+	for _, code := range filters.Amenities {
+		if !known[code] {
+			return ErrUnknownAmenity.Wrap(fmt.Errorf("amenity %q", code))
+		}
+	}
+	// повторы сломали бы подсчёт совпадений в запросе
+	filters.Amenities = slices.Compact(slices.Sorted(slices.Values(filters.Amenities)))
+	return nil
+}
+
+// amenityCodes возвращает множество кодов каталога удобств
+func amenityCodes(ctx context.Context, repo *repository.AdRepository) (map[string]bool, error) {
+	amenities, err := repo.GetAmenities(ctx)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool, len(amenities))
+	for _, a := range amenities {
+		known[a.Code] = true
+	}
+	return known, nil
+}
+
+// priceDropped решает, показывать ли бейдж снижения цены
+func (s *advertisementService) priceDropped(price money.Amount, previous *money.Amount, changedAt *time.Time) bool {
+	if previous == nil || changedAt == nil || *previous <= price {
+		return false
+	}
+	return s.priceDropPeriod <= 0 || time.Since(*changedAt) <= s.priceDropPeriod
+}
+
+// diffAdvertisement возвращает поля, значения которых отличаются (имена полей — как в JSON)
+func diffAdvertisement(before, after *models.UpdateAdvertisementInput) []models.AdvertisementFieldChange {
+	var changes []models.AdvertisementFieldChange
+	add := func(field string, oldValue, newValue *string) {
+		if oldValue == nil && newValue == nil || oldValue != nil && newValue != nil && *oldValue == *newValue {
+			return
+		}
+		changes = append(changes, models.AdvertisementFieldChange{Field: field, OldValue: oldValue, NewValue: newValue})
+	}
+
+	add("title", &before.Title, &after.Title)
+	add("description", before.Description, after.Description)
+	add("price", formatAmount(before.Price), formatAmount(after.Price))
+	add("currency", &before.Currency, &after.Currency)
+	add("pricePeriod", &before.PricePeriod, &after.PricePeriod)
+	add("type", &before.Type, &after.Type)
+	add("rooms", &before.Rooms, &after.Rooms)
+	add("city", &before.City, &after.City)
+	add("address", &before.Address, &after.Address)
+	add("latitude", formatFloat(before.Latitude), formatFloat(after.Latitude))
+	add("longitude", formatFloat(before.Longitude), formatFloat(after.Longitude))
+	add("square", formatFloat(&before.Square), formatFloat(&after.Square))
+	add("floor", formatInt(before.Floor), formatInt(after.Floor))
+	add("floorsTotal", formatInt(before.FloorsTotal), formatInt(after.FloorsTotal))
+	add("furnishing", before.Furnishing, after.Furnishing)
+	add("petsAllowed", formatBool(before.PetsAllowed), formatBool(after.PetsAllowed))
+	add("utilitiesIncluded", formatBool(before.UtilitiesIncluded), formatBool(after.UtilitiesIncluded))
+	add("deposit", formatOptionalAmount(before.Deposit), formatOptionalAmount(after.Deposit))
+	add("amenities", formatList(before.Amenities), formatList(after.Amenities))
+	add("status", &before.Status, &after.Status)
+
+	return changes
+}
+
+func formatAmount(v money.Amount) *string {
+	s := v.String()
+	return &s
+}
+
+func formatOptionalAmount(v *money.Amount) *string {
+	if v == nil {
+		return nil
+	}
+	return formatAmount(*v)
+}
+
+func formatInt(v *int) *string {
+	if v == nil {
+		return nil
+	}
+	s := strconv.Itoa(*v)
+	return &s
+}
+
+func formatBool(v *bool) *string {
+	if v == nil {
+		return nil
+	}
+	s := strconv.FormatBool(*v)
+	return &s
+}
+
+// formatList записывает список через запятую, пустой список — как отсутствие значения
+func formatList(v []string) *string {
+	if len(v) == 0 {
+		return nil
+	}
+	s := strings.Join(v, ",")
+	return &s
+}
+
+func formatFloat(v *float64) *string {
+	if v == nil {
+		return nil
+	}
+	s := strconv.FormatFloat(*v, 'f', -1, 64)
+	return &s
 }
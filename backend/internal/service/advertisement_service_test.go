@@ -0,0 +1,89 @@
+package service_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"rentor/internal/models"
+	"rentor/internal/money"
+	"rentor/internal/repository"
+	"rentor/internal/service"
+)
+
+// TestPriceFiltersConvertBounds lists advertisements in several currencies by a price range of one currency.
+// An advertisement matches when its price converted with money.Rates.Convert is within the range,
+// so the bounds converted to its currency must not cut off prices that round onto them.
+func TestPriceFiltersConvertBounds(t *testing.T) {
+	conn, repos := openRepositories(t)
+	ctx := context.Background()
+
+	// 1 USD = 480.004 KZT: 1.00 USD converts to 480.00 KZT, but 480 KZT is 0.999992 USD
+	rates, err := money.NewRates("KZT", map[string]float64{"USD": 480.004, "EUR": 520})
+	if err != nil {
+		t.Fatalf("rates: %v", err)
+	}
+	ads := service.NewadvertisementService(repos.Advertisement, repository.NewTxManager(conn), nil, time.Minute, time.Hour, rates)
+
+	userID, err := repos.User.CreateUser(ctx, "", "owner@example.com")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	create := func(title string, price money.Amount, currency string) {
+		t.Helper()
+		_, err := repos.Advertisement.CreateAdvertisement(ctx, userID, &models.CreateAdvertisementInput{
+			Title: title, Price: price, Currency: currency, PricePeriod: "month",
+			Type: "apartment", Rooms: "1", City: "Almaty", Address: "Abay 1", Square: 30,
+		})
+		if err != nil {
+			t.Fatalf("create %s: %v", title, err)
+		}
+	}
+	create("480 KZT", money.FromMajor(480), "KZT")
+	create("1 USD", money.FromMajor(1), "USD")       // 480.00 KZT
+	create("1.01 USD", money.FromMajor(1.01), "USD") // 484.80 KZT
+	create("1 EUR", money.FromMajor(1), "EUR")       // 520.00 KZT, 1.083324 USD
+	create("1 RUB", money.FromMajor(1), "RUB")       // no rate
+
+	amount := func(units float64) *money.Amount {
+		a := money.FromMajor(units)
+		return &a
+	}
+	tests := []struct {
+		name     string
+		min, max *money.Amount
+		currency string
+		want     []string
+	}{
+		{"at the upper bound", nil, amount(480), "", []string{"480 KZT", "1 USD"}},
+		{"at the lower bound", amount(480), nil, "", []string{"480 KZT", "1 USD", "1.01 USD", "1 EUR"}},
+		{"at both bounds", amount(480), amount(480), "", []string{"480 KZT", "1 USD"}},
+		{"just above", amount(480.01), nil, "", []string{"1.01 USD", "1 EUR"}},
+		{"just below", nil, amount(479.99), "", nil},
+		{"converted advertisement at the bound", amount(1.08), amount(1.08), "USD", []string{"1 EUR"}},
+		{"converted advertisement past the bound", nil, amount(1.07), "USD", []string{"480 KZT", "1 USD", "1.01 USD"}},
+		{"currency without a rate", amount(1), amount(1), "RUB", []string{"1 RUB"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filters := &models.AdFilters{Page: 1, Limit: 20, MinPrice: tt.min, MaxPrice: tt.max}
+			if tt.currency != "" {
+				filters.Currency = &tt.currency
+			}
+			list, err := ads.GetAdvertisementsPaged(ctx, filters)
+			if err != nil {
+				t.Fatalf("list: %v", err)
+			}
+			var got []string
+			for _, item := range list.Items {
+				got = append(got, item.Title)
+			}
+			slices.Sort(got)
+			want := slices.Sorted(slices.Values(tt.want))
+			if !slices.Equal(got, want) {
+				t.Errorf("found %q, want %q", got, want)
+			}
+		})
+	}
+}
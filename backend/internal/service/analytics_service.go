@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"rentor/internal/apperr"
+	"rentor/internal/logger"
+	"rentor/internal/models"
+	"rentor/internal/money"
+	"rentor/internal/repository"
+)
+
+// ErrCityNotFound is returned when there are no statistics for the requested city
+var ErrCityNotFound = apperr.NotFound("city_not_found", "no statistics for this city")
+
+// analyticsService precomputes and serves rent statistics per city
+type analyticsService struct {
+	repo  repository.AnalyticsRepository
+	rates *money.Rates // prices are converted to the base currency
+}
+
+// NewAnalyticsService creates a new analytics service
+func NewAnalyticsService(repo repository.AnalyticsRepository, rates *money.Rates) AnalyticsService {
+	return &analyticsService{repo: repo, rates: rates}
+}
+
+// cityGroup collects the listings of one rollup
+type cityGroup struct {
+	city, typ, rooms string
+	prices           []float64
+	pricesPerM2      []float64
+}
+
+// RefreshCityStats rebuilds the price rollups from the active listings and stores today's snapshot.
+// It is run by a background job, so GetCityAnalytics only reads precomputed rows.
+func (s *analyticsService) RefreshCityStats(ctx context.Context) error {
+	start := time.Now()
+	now := start.UTC()
+	today := now.Format(time.DateOnly)
+
+	listings, err := s.repo.GetActiveListingPrices(ctx)
+	if err != nil {
+		return err
+	}
+
+	groups := map[[3]string]*cityGroup{}
+	add := func(city, typ, rooms string, listing *models.ListingPrice, price float64) {
+		key := [3]string{city, typ, rooms}
+		g, ok := groups[key]
+		if !ok {
+			g = &cityGroup{city: city, typ: typ, rooms: rooms}
+			groups[key] = g
+		}
+		g.prices = append(g.prices, price)
+		if listing.Square > 0 {
+			g.pricesPerM2 = append(g.pricesPerM2, price/listing.Square)
+		}
+	}
+
+	newListings := map[string]int{}
+	for _, listing := range listings {
+		city := normalizeCity(listing.City)
+		if city == "" {
+			continue
+		}
+		// daily rents would skew the monthly figures, prices in currencies without a rate can't be compared
+		if listing.PricePeriod != models.PricePeriodMonth {
+			continue
+		}
+		converted, ok := s.rates.Convert(listing.Price, listing.Currency, s.rates.Base())
+		if !ok {
+			continue
+		}
+		price := converted.Major()
+
+		add(city, "", "", listing, price)
+		// an empty type or rooms would collide with the rollup over all of them
+		if listing.Type != "" {
+			add(city, listing.Type, "", listing, price)
+		}
+		if listing.Rooms != "" {
+			add(city, "", listing.Rooms, listing, price)
+		}
+
+		if listing.CreatedAt.UTC().Format(time.DateOnly) == today {
+			newListings[city]++
+		}
+	}
+
+	var stats []*models.CityPriceStats
+	var daily []*models.CityDailyStats
+	for _, g := range groups {
+		sort.Float64s(g.prices)
+		sort.Float64s(g.pricesPerM2)
+
+		stat := &models.CityPriceStats{
+			City:        g.city,
+			Type:        g.typ,
+			Rooms:       g.rooms,
+			Listings:    len(g.prices),
+			PriceP25:    percentile(g.prices, 0.25),
+			PriceMedian: percentile(g.prices, 0.5),
+			PriceP75:    percentile(g.prices, 0.75),
+			PriceP90:    percentile(g.prices, 0.9),
+			ComputedAt:  now,
+		}
+		if len(g.pricesPerM2) > 0 {
+			median := percentile(g.pricesPerM2, 0.5)
+			stat.PricePerM2Median = &median
+		}
+		stats = append(stats, stat)
+
+		if g.typ == "" && g.rooms == "" {
+			median := stat.PriceMedian
+			daily = append(daily, &models.CityDailyStats{
+				City:           g.city,
+				Day:            today,
+				ActiveListings: stat.Listings,
+				NewListings:    newListings[g.city],
+				PriceMedian:    &median,
+			})
+		}
+	}
+
+	if err := s.repo.ReplaceCityPriceStats(ctx, stats); err != nil {
+		return err
+	}
+	if err := s.repo.UpsertCityDailyStats(ctx, daily); err != nil {
+		return err
+	}
+
+	logger.Info("city statistics refreshed",
+		logger.Field("component", "analytics"),
+		logger.Field("cities", len(daily)),
+		logger.Field("listings", len(listings)),
+		logger.Field("duration", time.Since(start).String()),
+	)
+	return nil
+}
+
+// GetCityAnalytics returns the precomputed statistics of a city with the daily history of the last days
+func (s *analyticsService) GetCityAnalytics(ctx context.Context, city string, days int) (*models.CityAnalytics, error) {
+	city = normalizeCity(city)
+
+	stats, err := s.repo.GetCityPriceStats(ctx, city)
+	if err != nil {
+		return nil, err
+	}
+	if len(stats) == 0 {
+		return nil, ErrCityNotFound
+	}
+
+	since := time.Now().UTC().AddDate(0, 0, -days+1)
+	history, err := s.repo.GetCityDailyStats(ctx, city, since)
+	if err != nil {
+		return nil, err
+	}
+
+	analytics := &models.CityAnalytics{
+		City:     city,
+		Currency: s.rates.Base(),
+		ByRooms:  []*models.CityPriceStats{},
+		ByType:   []*models.CityPriceStats{},
+		History:  history,
+	}
+	for _, stat := range stats {
+		switch {
+		case stat.Type == "" && stat.Rooms == "":
+			analytics.Overall = stat
+			analytics.ComputedAt = stat.ComputedAt
+		case stat.Type == "":
+			analytics.ByRooms = append(analytics.ByRooms, stat)
+		default:
+			analytics.ByType = append(analytics.ByType, stat)
+		}
+	}
+
+	return analytics, nil
+}
+
+// normalizeCity makes the city key used by the rollups: the city is free text in advertisements
+func normalizeCity(city string) string {
+	return strings.ToLower(strings.Join(strings.Fields(city), " "))
+}
+
+// percentile returns the p-th percentile (0..1) of sorted values with linear interpolation
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := p * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	if lower == upper {
+		return sorted[lower]
+	}
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
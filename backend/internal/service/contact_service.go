@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"rentor/internal/apperr"
+	"rentor/internal/logger"
+	"rentor/internal/models"
+	"rentor/internal/repository"
+)
+
+// contactService implements ContactService
+type contactService struct {
+	userRepo     repository.UserRepository
+	otpService   OTPService
+	emailService EmailService
+	smsService   SMSService
+}
+
+// NewContactService creates a new contact service
+func NewContactService(userRepo repository.UserRepository, otpService OTPService, emailService EmailService, smsService SMSService) ContactService {
+	return &contactService{
+		userRepo:     userRepo,
+		otpService:   otpService,
+		emailService: emailService,
+		smsService:   smsService,
+	}
+}
+
+// RequestEmailChange sends a code to the new email of the user, the email changes once the code is confirmed
+func (s *contactService) RequestEmailChange(ctx context.Context, userID int, email string) error {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	email = strings.ToLower(email)
+	if email == user.Email {
+		return apperr.Invalid("email_unchanged", "this is already the email of the account")
+	}
+	if err := s.checkEmailFree(ctx, email); err != nil {
+		return err
+	}
+
+	return s.otpService.GenerateAndStoreOTP(ctx, userID, models.OTPPurposeEmailChange, email)
+}
+
+// ConfirmEmailChange moves the user to the new email if the code sent there matches.
+// Both the old and the new email are told about the change.
+func (s *contactService) ConfirmEmailChange(ctx context.Context, userID int, email, otpCode string) (*models.User, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	email = strings.ToLower(email)
+	if err := s.verifyCode(ctx, userID, models.OTPPurposeEmailChange, email, otpCode); err != nil {
+		return nil, err
+	}
+	// the email may have been taken while the code was on its way
+	if err := s.checkEmailFree(ctx, email); err != nil {
+		return nil, err
+	}
+
+	if err := s.userRepo.UpdateUserEmail(ctx, userID, email, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	logger.Info("user email changed", logger.Field("user_id", userID))
+
+	s.notifyEmail(user.Email, "Your Rentor email was changed",
+		fmt.Sprintf("The email of your Rentor account was changed to %s. If you did not do this, contact support right away.", email))
+	s.notifyEmail(email, "Your Rentor email was changed",
+		"This email is now used to sign in to your Rentor account.")
+
+	return s.userRepo.GetUserByID(ctx, userID)
+}
+
+// RequestPhoneChange sends a code by SMS to the new phone of the user, the phone is set once the code is confirmed
+func (s *contactService) RequestPhoneChange(ctx context.Context, userID int, phone string) error {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	phone = normalizePhone(phone)
+	if user.Phone != nil && normalizePhone(*user.Phone) == phone && user.PhoneVerifiedAt != nil {
+		return apperr.Invalid("phone_unchanged", "this phone is already confirmed for the account")
+	}
+	if err := s.checkPhoneFree(ctx, userID, phone); err != nil {
+		return err
+	}
+
+	return s.otpService.GenerateAndStoreOTP(ctx, userID, models.OTPPurposePhoneChange, phone)
+}
+
+// ConfirmPhoneChange sets the new phone of the user if the code sent there matches.
+// Both the old phone, if there was one, and the new phone are told about the change.
+func (s *contactService) ConfirmPhoneChange(ctx context.Context, userID int, phone, otpCode string) (*models.User, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	phone = normalizePhone(phone)
+	if err := s.verifyCode(ctx, userID, models.OTPPurposePhoneChange, phone, otpCode); err != nil {
+		return nil, err
+	}
+	// the phone may have been taken while the code was on its way
+	if err := s.checkPhoneFree(ctx, userID, phone); err != nil {
+		return nil, err
+	}
+
+	if err := s.userRepo.UpdateUserPhone(ctx, userID, phone, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	logger.Info("user phone changed", logger.Field("user_id", userID))
+
+	if user.Phone != nil && normalizePhone(*user.Phone) != phone {
+		s.notifyPhone(*user.Phone, "This number is no longer linked to your Rentor account. If you did not do this, contact support right away.")
+	}
+	s.notifyPhone(phone, "This number is now linked to your Rentor account.")
+
+	return s.userRepo.GetUserByID(ctx, userID)
+}
+
+// verifyCode checks the code sent to target was sent to this user
+func (s *contactService) verifyCode(ctx context.Context, userID int, purpose, target, otpCode string) error {
+	codeUserID, err := s.otpService.VerifyOTP(ctx, purpose, target, otpCode)
+	if err != nil {
+		return err
+	}
+	if codeUserID != userID {
+		return apperr.Unauthorized("invalid_otp", "invalid OTP")
+	}
+	return nil
+}
+
+// checkEmailFree fails if another account, also a deleted one that has not been purged yet, uses the email
+func (s *contactService) checkEmailFree(ctx context.Context, email string) error {
+	existing, err := s.userRepo.GetUserByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return apperr.Conflict("email_taken", "user with this email already exists")
+	}
+
+	deleted, err := s.userRepo.GetDeletedUserByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	if deleted != nil {
+		return apperr.Conflict("email_taken", "user with this email already exists")
+	}
+	return nil
+}
+
+// checkPhoneFree fails if another account, also a deleted one that has not been purged yet, uses the phone
+func (s *contactService) checkPhoneFree(ctx context.Context, userID int, phone string) error {
+	existing, err := s.userRepo.GetUserByPhone(ctx, phone)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.UserID != userID {
+		return apperr.Conflict("phone_taken", "user with this phone already exists")
+	}
+
+	deleted, err := s.userRepo.GetDeletedUserByPhone(ctx, phone)
+	if err != nil {
+		return err
+	}
+	if deleted != nil {
+		return apperr.Conflict("phone_taken", "user with this phone already exists")
+	}
+	return nil
+}
+
+// notifyEmail sends a notice about a contact change, the change is already saved so a failure is only logged
+func (s *contactService) notifyEmail(to, subject, body string) {
+	if err := s.emailService.SendEmail(to, subject, body); err != nil {
+		logger.Warn("failed to send contact change notice", logger.Field("error", err.Error()))
+	}
+}
+
+// notifyPhone sends a notice about a contact change by SMS, a failure is only logged
+func (s *contactService) notifyPhone(to, text string) {
+	if err := s.smsService.SendSMS(to, text); err != nil {
+		logger.Warn("failed to send contact change notice", logger.Field("error", err.Error()))
+	}
+}
+
+// normalizePhone drops the spaces and dashes the phone format allows, so one number is stored one way
+func normalizePhone(phone string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(phone)
+}
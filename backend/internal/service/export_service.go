@@ -0,0 +1,414 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"rentor/internal/apperr"
+	"rentor/internal/feed"
+	"rentor/internal/logger"
+	"rentor/internal/models"
+	"rentor/internal/repository"
+)
+
+// exportPageSize is how many advertisements an export reads from the DB at a time
+const exportPageSize = 100
+
+var (
+	ErrExportArchiveNotReady = apperr.Conflict("export_archive_not_ready", "the archive is not ready yet, poll its status")
+	ErrExportArchiveExpired  = apperr.New(apperr.KindGone, "export_archive_expired", "the archive has expired, request a new one")
+)
+
+type exportService struct {
+	exportRepo       repository.ExportRepository
+	adRepo           repository.AdRepository
+	userRepo         repository.UserRepository
+	profileRepo      repository.UserProfileRepository
+	imageStoragePath string
+	exportPath       string
+	siteURL          string
+	archiveTTL       time.Duration
+}
+
+// NewExportService creates a new export service. Archives are written to exportPath and deleted after archiveTTL.
+// siteURL makes the listing and photo URLs of exports absolute, so other portals can fetch them.
+func NewExportService(exportRepo repository.ExportRepository, adRepo repository.AdRepository, userRepo repository.UserRepository,
+	profileRepo repository.UserProfileRepository, imageStoragePath, exportPath, siteURL string, archiveTTL time.Duration) ExportService {
+	return &exportService{
+		exportRepo:       exportRepo,
+		adRepo:           adRepo,
+		userRepo:         userRepo,
+		profileRepo:      profileRepo,
+		imageStoragePath: imageStoragePath,
+		exportPath:       exportPath,
+		siteURL:          strings.TrimSuffix(siteURL, "/"),
+		archiveTTL:       archiveTTL,
+	}
+}
+
+// ExportListings writes all advertisements of the user in the given format, page by page.
+// Nothing is written when the first page fails, so the caller can still report the error.
+func (s *exportService) ExportListings(ctx context.Context, userID int, format string, w io.Writer) error {
+	if !slices.Contains(feed.Formats, format) {
+		return feed.ErrUnsupportedFormat
+	}
+	return s.writeListings(ctx, &models.ExportFilter{UserID: userID, Limit: exportPageSize}, format, w)
+}
+
+// PublicFeed writes the active advertisements of a landlord as an XML realty feed
+func (s *exportService) PublicFeed(ctx context.Context, landlordID int, w io.Writer) error {
+	if _, err := s.userRepo.GetUserByID(ctx, landlordID); err != nil {
+		return err
+	}
+	return s.writeListings(ctx, &models.ExportFilter{UserID: landlordID, Limit: exportPageSize, ActiveOnly: true}, feed.FormatXML, w)
+}
+
+// RequestArchive queues a personal data archive. A user has at most one queued archive, asking again returns it.
+func (s *exportService) RequestArchive(ctx context.Context, userID int) (*models.ExportArchive, error) {
+	pending, err := s.exportRepo.GetPendingExportArchive(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if pending != nil {
+		return pending, nil
+	}
+
+	archive := &models.ExportArchive{
+		UserID:    userID,
+		Status:    models.ExportArchiveQueued,
+		CreatedAt: time.Now(),
+	}
+	archive.ID, err = s.exportRepo.CreateExportArchive(ctx, archive)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("export archive queued", logger.Field("archive_id", archive.ID), logger.Field("user_id", userID))
+	return archive, nil
+}
+
+// GetArchive returns an archive of the user
+func (s *exportService) GetArchive(ctx context.Context, userID, archiveID int) (*models.ExportArchive, error) {
+	archive, err := s.exportRepo.GetExportArchiveByID(ctx, archiveID)
+	if err != nil {
+		return nil, err
+	}
+	// archives of other users are not revealed
+	if archive.UserID != userID {
+		return nil, apperr.ErrExportArchiveNotFound
+	}
+	return archive, nil
+}
+
+// OpenArchive opens the file of a completed archive of the user, the caller closes it
+func (s *exportService) OpenArchive(ctx context.Context, userID, archiveID int) (*os.File, *models.ExportArchive, error) {
+	archive, err := s.GetArchive(ctx, userID, archiveID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if archive.Status != models.ExportArchiveCompleted {
+		return nil, nil, ErrExportArchiveNotReady
+	}
+	if archive.ExpiresAt != nil && time.Now().After(*archive.ExpiresAt) {
+		return nil, nil, ErrExportArchiveExpired
+	}
+
+	f, err := os.Open(filepath.Join(s.exportPath, archive.Path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, ErrExportArchiveExpired.Wrap(err)
+		}
+		return nil, nil, err
+	}
+	return f, archive, nil
+}
+
+// ProcessQueuedArchives generates the queued archives one by one until none is left.
+// An archive that can't be generated is marked failed, the user can request a new one.
+func (s *exportService) ProcessQueuedArchives(ctx context.Context) error {
+	for {
+		archive, err := s.exportRepo.GetNextExportArchive(ctx)
+		if err != nil {
+			return err
+		}
+		if archive == nil {
+			return nil
+		}
+
+		archive.Path, archive.Size, err = s.buildArchive(ctx, archive)
+		if err != nil {
+			// a shutdown leaves the archive queued, it is generated again on the next run
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			logger.Error("export archive failed", logger.Field("error", err.Error()), logger.Field("archive_id", archive.ID))
+			msg := "the archive could not be generated, request a new one"
+			archive.Status = models.ExportArchiveFailed
+			archive.Error = &msg
+		} else {
+			archive.Status = models.ExportArchiveCompleted
+		}
+
+		now := time.Now()
+		expiresAt := now.Add(s.archiveTTL)
+		archive.FinishedAt = &now
+		archive.ExpiresAt = &expiresAt
+		if err := s.exportRepo.FinishExportArchive(ctx, archive); err != nil {
+			return err
+		}
+
+		if archive.Status == models.ExportArchiveCompleted {
+			logger.Info("export archive completed", logger.Field("archive_id", archive.ID), logger.Field("size", *archive.Size))
+		}
+	}
+}
+
+// CleanupExpiredArchives removes expired archives and their files
+func (s *exportService) CleanupExpiredArchives(ctx context.Context) error {
+	archives, err := s.exportRepo.GetExpiredExportArchives(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+
+	for _, archive := range archives {
+		if archive.Path != "" {
+			err := os.Remove(filepath.Join(s.exportPath, archive.Path))
+			if err != nil && !os.IsNotExist(err) {
+				logger.Warn("failed to remove export archive", logger.Field("error", err.Error()), logger.Field("file", archive.Path))
+				continue
+			}
+		}
+		if err := s.exportRepo.DeleteExportArchiveByID(ctx, archive.ID); err != nil {
+			return err
+		}
+	}
+
+	if len(archives) > 0 {
+		logger.Info("expired export archives removed", logger.Field("count", len(archives)))
+	}
+
+	return nil
+}
+
+// writeListings writes the advertisements selected by filter, reading them page by page
+func (s *exportService) writeListings(ctx context.Context, filter *models.ExportFilter, format string, w io.Writer) error {
+	// the first page is read before anything is written
+	ads, err := s.adRepo.GetExportAdvertisements(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	fw, err := feed.NewWriter(w, format)
+	if err != nil {
+		return err
+	}
+	for {
+		for _, ad := range ads {
+			if err := fw.Write(s.record(ad)); err != nil {
+				return err
+			}
+		}
+		if len(ads) < filter.Limit {
+			break
+		}
+
+		filter.AfterID = ads[len(ads)-1].ID
+		if ads, err = s.adRepo.GetExportAdvertisements(ctx, filter); err != nil {
+			return err
+		}
+	}
+
+	return fw.Close()
+}
+
+// record converts an advertisement to the fields of an export file. Advertisements that were not
+// imported are exported under their own ID, so other systems get a stable external ID.
+func (s *exportService) record(ad *models.ExportAdvertisement) *feed.Record {
+	externalID := strconv.Itoa(ad.ID)
+	if ad.ExternalID != nil {
+		externalID = *ad.ExternalID
+	}
+
+	fields := map[string]string{
+		"externalId":  externalID,
+		"title":       ad.Title,
+		"price":       ad.Price.String(),
+		"currency":    ad.Currency,
+		"pricePeriod": ad.PricePeriod,
+		"type":        ad.Type,
+		"rooms":       ad.Rooms,
+		"city":        ad.City,
+		"address":     ad.Address,
+		"square":      *formatFloat(&ad.Square),
+		"status":      ad.Status,
+	}
+	if ad.Description != nil {
+		fields["description"] = *ad.Description
+	}
+	if ad.Latitude != nil && ad.Longitude != nil {
+		fields["latitude"] = *formatFloat(ad.Latitude)
+		fields["longitude"] = *formatFloat(ad.Longitude)
+	}
+
+	// imported photos keep the URL they came from, so importing the file again leaves them alone
+	photos := make([]string, len(ad.Photos))
+	for i, photo := range ad.Photos {
+		photos[i] = s.siteURL + photo.URL
+		if photo.SourceURL != nil {
+			photos[i] = *photo.SourceURL
+		}
+	}
+
+	return &feed.Record{
+		Fields:    fields,
+		Photos:    photos,
+		URL:       fmt.Sprintf("%s/advertisement/%d", s.siteURL, ad.ID),
+		CreatedAt: ad.CreatedAt,
+		UpdatedAt: ad.UpdatedAt,
+	}
+}
+
+// buildArchive writes the zip of an archive: data.json with the user, profile and advertisements
+// (deleted ones included) and the image files of the advertisements under images/
+func (s *exportService) buildArchive(ctx context.Context, archive *models.ExportArchive) (string, *int64, error) {
+	user, err := s.userRepo.GetUserByID(ctx, archive.UserID)
+	if err != nil {
+		return "", nil, err
+	}
+	profile, err := s.profileRepo.GetUserProfileByUserID(ctx, archive.UserID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	data := &models.PersonalDataExport{
+		ExportedAt:     time.Now(),
+		User:           user,
+		Profile:        profile,
+		Advertisements: []*models.ExportAdvertisement{},
+	}
+	filter := &models.ExportFilter{UserID: archive.UserID, Limit: exportPageSize, WithDeleted: true}
+	for {
+		ads, err := s.adRepo.GetExportAdvertisements(ctx, filter)
+		if err != nil {
+			return "", nil, err
+		}
+		data.Advertisements = append(data.Advertisements, ads...)
+		if len(ads) < filter.Limit {
+			break
+		}
+		filter.AfterID = ads[len(ads)-1].ID
+	}
+
+	var images []string
+	for _, ad := range data.Advertisements {
+		for i := range ad.Photos {
+			photo := &ad.Photos[i]
+			photo.File = path.Join("images", filepath.Base(photo.URL))
+			images = append(images, filepath.Base(photo.URL))
+		}
+	}
+
+	if err := os.MkdirAll(s.exportPath, 0o755); err != nil {
+		return "", nil, err
+	}
+	token, err := generateUploadToken()
+	if err != nil {
+		return "", nil, err
+	}
+	// the random part keeps archive names unguessable should the directory ever be served
+	name := fmt.Sprintf("export_%d_%s.zip", archive.ID, token[:16])
+	tmpPath := filepath.Join(s.exportPath, name+".tmp")
+
+	if err := s.writeArchive(ctx, tmpPath, data, images); err != nil {
+		_ = os.Remove(tmpPath)
+		return "", nil, err
+	}
+
+	info, err := os.Stat(tmpPath)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := os.Rename(tmpPath, filepath.Join(s.exportPath, name)); err != nil {
+		_ = os.Remove(tmpPath)
+		return "", nil, err
+	}
+
+	size := info.Size()
+	return name, &size, nil
+}
+
+// writeArchive writes the zip file, images missing in storage are skipped
+func (s *exportService) writeArchive(ctx context.Context, dst string, data *models.PersonalDataExport, images []string) error {
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "data.json", Method: zip.Deflate, Modified: data.ExportedAt})
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(data); err != nil {
+		return err
+	}
+
+	for _, image := range images {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := addFileToZip(zw, filepath.Join(s.imageStoragePath, image), path.Join("images", image)); err != nil {
+			if os.IsNotExist(err) {
+				logger.Warn("image missing from export archive", logger.Field("file", image))
+				continue
+			}
+			return err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// addFileToZip copies a file into the archive, images are already compressed and are stored as is
+func addFileToZip(zw *zip.Writer, src, name string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	header.Method = zip.Store
+
+	w, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, in)
+	return err
+}
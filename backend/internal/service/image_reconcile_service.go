@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"rentor/internal/logger"
+	"rentor/internal/models"
+	"rentor/internal/repository"
+)
+
+// imageReconcileService keeps image files in ImageStoragePath and advertisement_photos rows consistent
+type imageReconcileService struct {
+	adRepo      repository.AdRepository
+	storagePath string
+}
+
+// NewImageReconcileService creates a new image storage consistency checker
+func NewImageReconcileService(adRepo repository.AdRepository, storagePath string) ImageReconcileService {
+	return &imageReconcileService{
+		adRepo:      adRepo,
+		storagePath: storagePath,
+	}
+}
+
+// Reconcile finds files without DB rows and rows without files, and removes them unless DryRun is set
+func (s *imageReconcileService) Reconcile(ctx context.Context, opts models.ReconcileOptions) (*models.ReconcileReport, error) {
+	report := &models.ReconcileReport{DryRun: opts.DryRun}
+
+	// files are listed before rows: a file saved and linked in between is seen on both sides,
+	// while a file saved after the listing is not checked at all
+	entries, err := os.ReadDir(s.storagePath)
+	if err != nil {
+		return nil, err
+	}
+
+	images, err := s.adRepo.GetAllImages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report.CheckedRows = len(images)
+
+	linked := make(map[string]bool, len(images))
+	for _, image := range images {
+		linked[filepath.Base(image.URL)] = true
+	}
+
+	files := make(map[string]bool, len(entries))
+	cutoff := time.Now().Add(-opts.GracePeriod)
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		report.CheckedFiles++
+		files[entry.Name()] = true
+
+		if linked[entry.Name()] {
+			continue
+		}
+
+		// images are written to disk before they are linked, give in-flight requests time to finish
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		report.OrphanFiles = append(report.OrphanFiles, entry.Name())
+	}
+
+	for _, image := range images {
+		if !files[filepath.Base(image.URL)] {
+			report.MissingFiles = append(report.MissingFiles, image)
+		}
+	}
+
+	if opts.DryRun {
+		return report, nil
+	}
+
+	for _, name := range report.OrphanFiles {
+		if err := os.Remove(filepath.Join(s.storagePath, name)); err != nil && !os.IsNotExist(err) {
+			logger.Warn("failed to remove orphaned image", logger.Field("error", err.Error()), logger.Field("file", name))
+			continue
+		}
+		report.RemovedFiles++
+	}
+
+	for _, image := range report.MissingFiles {
+		if err := s.adRepo.DeleteAdvertisementImage(ctx, image.AdvertisementID, image.ID); err != nil {
+			logger.Warn("failed to remove image row without file", logger.Field("error", err.Error()), logger.Field("image_id", image.ID))
+			continue
+		}
+		report.RemovedRows++
+	}
+
+	return report, nil
+}
+
+// RunScheduled runs Reconcile from the background job and logs the outcome
+func (s *imageReconcileService) RunScheduled(ctx context.Context, opts models.ReconcileOptions) error {
+	report, err := s.Reconcile(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	if len(report.OrphanFiles) > 0 || len(report.MissingFiles) > 0 {
+		logger.Warn("image storage is inconsistent",
+			logger.Field("dry_run", report.DryRun),
+			logger.Field("orphan_files", len(report.OrphanFiles)),
+			logger.Field("missing_files", len(report.MissingFiles)),
+			logger.Field("removed_files", report.RemovedFiles),
+			logger.Field("removed_rows", report.RemovedRows),
+		)
+	}
+
+	return nil
+}
@@ -8,6 +8,11 @@ import (
 	"path/filepath"
 	"strconv"
 	"time"
+
+	"rentor/internal/apperr"
+	"rentor/internal/imagehash"
+	"rentor/internal/logger"
+	"rentor/internal/models"
 )
 
 type imageService struct {
@@ -23,9 +28,9 @@ func NewimageService(storagePath, baseURL string) *imageService {
 	}
 }
 
-// SaveAdvertisementImages сохраняет массив файлов для объявления и возвращает URL
-func (s *imageService) SaveAdvertisementImages(adID int, files []*multipart.FileHeader) ([]string, error) {
-	var urls []string
+// SaveAdvertisementImages сохраняет массив файлов для объявления и возвращает URL и перцептивные хеши
+func (s *imageService) SaveAdvertisementImages(adID int, files []*multipart.FileHeader) ([]models.SavedImage, error) {
+	var images []models.SavedImage
 
 	for _, fileHeader := range files {
 		file, err := fileHeader.Open()
@@ -52,10 +57,36 @@ func (s *imageService) SaveAdvertisementImages(adID int, files []*multipart.File
 		}
 		dst.Close()
 
-		urls = append(urls, s.BaseURL+filename)
+		images = append(images, models.SavedImage{
+			URL:   s.BaseURL + filename,
+			PHash: hashImageFile(savePath),
+		})
 	}
 
-	return urls, nil
+	return images, nil
+}
+
+// StoreAdvertisementImage кладёт загруженный файл в хранилище изображений и возвращает URL и перцептивный хеш.
+// Исходный файл остаётся на месте (в хранилище попадает жёсткая ссылка или копия), удаляет его вызывающий,
+// поэтому при ошибке после сохранения файл можно сохранить снова.
+func (s *imageService) StoreAdvertisementImage(adID int, srcPath string) (models.SavedImage, error) {
+	timestamp := strconv.FormatInt(time.Now().UnixNano(), 10)
+	ext := filepath.Ext(srcPath)
+	filename := fmt.Sprintf("ad_%d_%s%s", adID, timestamp, ext)
+
+	savePath := filepath.Join(s.StoragePath, filename)
+
+	if err := os.Link(srcPath, savePath); err != nil {
+		// staging directory may live on another device, fall back to copy
+		if err := copyFile(srcPath, savePath); err != nil {
+			return models.SavedImage{}, err
+		}
+	}
+
+	return models.SavedImage{
+		URL:   s.BaseURL + filename,
+		PHash: hashImageFile(savePath),
+	}, nil
 }
 
 func (s *imageService) DeleteImage(path string) error {
@@ -67,7 +98,7 @@ func (s *imageService) DeleteImage(path string) error {
 
 	// Проверяем существование файла перед удалением
 	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-		return fmt.Errorf("image not found: %s", filename)
+		return apperr.ErrImageNotFound.Wrap(fmt.Errorf("file %s does not exist", filename))
 	}
 
 	// Удаляем файл
@@ -77,3 +108,43 @@ func (s *imageService) DeleteImage(path string) error {
 
 	return nil
 }
+
+// hashImageFile считает перцептивный хеш сохранённого файла (nil, если файл не удалось декодировать)
+func hashImageFile(path string) *string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	hash, err := imagehash.DHash(f)
+	if err != nil {
+		logger.Warn("failed to compute image hash", logger.Field("error", err.Error()), logger.Field("file", filepath.Base(path)))
+		return nil
+	}
+
+	formatted := imagehash.Format(hash)
+	return &formatted
+}
+
+// copyFile копирует файл src в dst
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		_ = os.Remove(dst)
+		return err
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,593 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"rentor/internal/apperr"
+	"rentor/internal/feed"
+	"rentor/internal/logger"
+	"rentor/internal/models"
+	"rentor/internal/money"
+	"rentor/internal/repository"
+	"rentor/internal/validation"
+)
+
+// importProgressEvery is how many rows a background job imports between progress updates
+const importProgressEvery = 25
+
+var (
+	errPrivateHost = errors.New("the photo URL points to a private address")
+	errPhotoStatus = errors.New("the photo URL responded with")
+)
+
+type importService struct {
+	importRepo   repository.ImportRepository
+	adRepo       repository.AdRepository
+	adService    AdvertisementService
+	imageSvc     ImageService
+	client       *http.Client
+	stagingPath  string
+	maxRows      int
+	maxSyncRows  int
+	maxPhotos    int
+	maxPhotoSize int64
+	allowedTypes []string
+}
+
+// NewImportService creates a new import service. Photos are fetched into stagingPath first and are
+// subject to the upload limits (maxPhotoSize, allowedTypes). Unless allowPrivateHosts is set photo URLs
+// may only point to public addresses, so an imported file can't make the server call the internal network.
+func NewImportService(importRepo repository.ImportRepository, adRepo repository.AdRepository, adService AdvertisementService, imageSvc ImageService,
+	stagingPath string, maxRows, maxSyncRows, maxPhotos int, maxPhotoSize int64, allowedTypes []string,
+	photoTimeout time.Duration, allowPrivateHosts bool) ImportService {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if !allowPrivateHosts {
+		dialer.Control = publicOnly
+	}
+
+	return &importService{
+		importRepo: importRepo,
+		adRepo:     adRepo,
+		adService:  adService,
+		imageSvc:   imageSvc,
+		client: &http.Client{
+			Timeout:   photoTimeout,
+			Transport: &http.Transport{DialContext: dialer.DialContext},
+		},
+		stagingPath:  stagingPath,
+		maxRows:      maxRows,
+		maxSyncRows:  maxSyncRows,
+		maxPhotos:    maxPhotos,
+		maxPhotoSize: maxPhotoSize,
+		allowedTypes: allowedTypes,
+	}
+}
+
+// Import reads the file and imports small files at once. Files with more than maxSyncRows rows
+// or with photo URLs are queued as a background job, which is returned instead of the report.
+// A dry run is always reported at once and changes nothing.
+func (s *importService) Import(ctx context.Context, userID int, input *models.ImportInput, filename string, file io.Reader) (*models.ImportReport, *models.ImportJob, error) {
+	format, rows, err := s.readRows(input, filename, file)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if input.DryRun || (len(rows) <= s.maxSyncRows && !hasPhotos(rows)) {
+		return s.importRows(ctx, userID, rows, input.DryRun), nil, nil
+	}
+
+	job := &models.ImportJob{
+		UserID:    userID,
+		Format:    format,
+		Status:    models.ImportJobQueued,
+		Total:     len(rows),
+		CreatedAt: time.Now(),
+		Rows:      rows,
+	}
+	job.ID, err = s.importRepo.CreateImportJob(ctx, job)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	logger.Info("import job queued", logger.Field("job_id", job.ID), logger.Field("user_id", userID), logger.Field("rows", job.Total))
+	return nil, job, nil
+}
+
+// ImportNow reads the file and imports all of it at once, photos included (used by the CLI)
+func (s *importService) ImportNow(ctx context.Context, userID int, input *models.ImportInput, filename string, file io.Reader) (*models.ImportReport, error) {
+	_, rows, err := s.readRows(input, filename, file)
+	if err != nil {
+		return nil, err
+	}
+	return s.importRows(ctx, userID, rows, input.DryRun), nil
+}
+
+// GetJob returns a job of the user with the results of the rows imported so far
+func (s *importService) GetJob(ctx context.Context, userID, jobID int) (*models.ImportJob, error) {
+	job, err := s.importRepo.GetImportJobByID(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	// jobs of other users are not revealed
+	if job.UserID != userID {
+		return nil, apperr.ErrImportJobNotFound
+	}
+	return job, nil
+}
+
+// ProcessQueuedJobs runs the queued jobs one by one until none is left.
+// A job interrupted by a shutdown resumes from its last saved progress on the next run.
+func (s *importService) ProcessQueuedJobs(ctx context.Context) error {
+	for {
+		job, err := s.importRepo.GetNextImportJob(ctx)
+		if err != nil {
+			return err
+		}
+		if job == nil {
+			return nil
+		}
+		if err := s.runJob(ctx, job); err != nil {
+			return fmt.Errorf("import job %d: %w", job.ID, err)
+		}
+	}
+}
+
+// runJob imports the remaining rows of a job, saving the progress every importProgressEvery rows
+func (s *importService) runJob(ctx context.Context, job *models.ImportJob) error {
+	if len(job.Rows) != job.Total {
+		msg := "the rows of the job are missing"
+		return s.importRepo.FinishImportJob(ctx, job.ID, models.ImportJobFailed, job.Report, &msg, time.Now())
+	}
+
+	report := job.Report
+	if report == nil {
+		report = &models.ImportReport{Total: job.Total}
+	}
+
+	// rows imported before an interruption still count for duplicate external IDs
+	seen := make(map[string]bool, job.Total)
+	for i := range job.Rows[:job.Processed] {
+		seen[job.Rows[i].ExternalID] = true
+	}
+
+	if err := s.importRepo.UpdateImportJobProgress(ctx, job.ID, job.Processed, report, time.Now()); err != nil {
+		return err
+	}
+	logger.Info("import job started", logger.Field("job_id", job.ID), logger.Field("processed", job.Processed), logger.Field("total", job.Total))
+
+	for i := job.Processed; i < len(job.Rows); i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		report.Add(s.importRow(ctx, job.UserID, &job.Rows[i], seen, false))
+
+		if processed := i + 1; processed%importProgressEvery == 0 && processed < len(job.Rows) {
+			if err := s.importRepo.UpdateImportJobProgress(ctx, job.ID, processed, report, time.Now()); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := s.importRepo.FinishImportJob(ctx, job.ID, models.ImportJobCompleted, report, nil, time.Now()); err != nil {
+		return err
+	}
+
+	logger.Info("import job completed",
+		logger.Field("job_id", job.ID),
+		logger.Field("created", report.Created),
+		logger.Field("updated", report.Updated),
+		logger.Field("unchanged", report.Unchanged),
+		logger.Field("failed", report.Failed),
+	)
+	return nil
+}
+
+// readRows reads the listings of the file in the given format or the one its name suggests
+func (s *importService) readRows(input *models.ImportInput, filename string, file io.Reader) (string, []models.ImportRow, error) {
+	format := strings.ToLower(strings.TrimSpace(input.Format))
+	if format == "" {
+		format = feed.DetectFormat(filename)
+	}
+	if !slices.Contains(feed.Formats, format) {
+		return "", nil, feed.ErrUnsupportedFormat
+	}
+
+	records, err := feed.Read(file, format, input.Mapping, s.maxRows)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(records) == 0 {
+		return "", nil, apperr.Invalid("empty_file", "the file has no listings")
+	}
+
+	rows := make([]models.ImportRow, len(records))
+	for i := range records {
+		rows[i] = toImportRow(&records[i])
+	}
+	return format, rows, nil
+}
+
+// importRows imports the rows in file order
+func (s *importService) importRows(ctx context.Context, userID int, rows []models.ImportRow, dryRun bool) *models.ImportReport {
+	report := &models.ImportReport{DryRun: dryRun, Total: len(rows)}
+	seen := make(map[string]bool, len(rows))
+	for i := range rows {
+		report.Add(s.importRow(ctx, userID, &rows[i], seen, dryRun))
+	}
+	return report
+}
+
+// importRow creates or updates the advertisement of a row, matched by its external ID.
+// Failures are reported in the result, they never stop the import.
+func (s *importService) importRow(ctx context.Context, userID int, row *models.ImportRow, seen map[string]bool, dryRun bool) models.ImportRowResult {
+	result := models.ImportRowResult{Line: row.Line, ExternalID: row.ExternalID}
+	fail := func(errs ...validation.FieldError) models.ImportRowResult {
+		result.Action = models.ImportRowFailed
+		result.Errors = errs
+		return result
+	}
+
+	if err := row.Validate(); err != nil {
+		var fields validation.Errors
+		if errors.As(err, &fields) {
+			return fail(fields...)
+		}
+		return fail(rowError("", err))
+	}
+	if seen[row.ExternalID] {
+		return fail(*validation.NewError("externalId", "duplicate", "the external ID appears earlier in the file"))
+	}
+	seen[row.ExternalID] = true
+	if len(row.Photos) > s.maxPhotos {
+		return fail(*validation.NewError("photos", "too_many_photos", fmt.Sprintf("at most %d photos per listing", s.maxPhotos)))
+	}
+
+	adID, deleted, err := s.adRepo.GetAdvertisementIDByExternalID(ctx, userID, row.ExternalID)
+	switch {
+	case errors.Is(err, apperr.ErrAdvertisementNotFound):
+		return s.createRow(ctx, userID, row, result, dryRun)
+	case err != nil:
+		return fail(rowError("", err))
+	case deleted:
+		return fail(*validation.NewError("externalId", "advertisement_deleted", "the advertisement with this external ID was deleted"))
+	}
+
+	result.AdvertisementID = &adID
+	return s.updateRow(ctx, userID, adID, row, result, dryRun)
+}
+
+// createRow creates the advertisement of a row that wasn't imported before
+func (s *importService) createRow(ctx context.Context, userID int, row *models.ImportRow, result models.ImportRowResult, dryRun bool) models.ImportRowResult {
+	result.Action = models.ImportRowCreated
+	if dryRun {
+		result.PhotosAdded = len(uniquePhotos(row.Photos))
+		return result
+	}
+
+	input := row.CreateAdvertisementInput
+	input.ExternalID = &row.ExternalID
+	ad, err := s.adService.CreateAdvertisement(ctx, userID, &input)
+	if err != nil {
+		result.Action = models.ImportRowFailed
+		result.Errors = []validation.FieldError{rowError("", err)}
+		return result
+	}
+	result.AdvertisementID = &ad.ID
+
+	// new advertisements are active, a paused listing is paused right after
+	if row.Status != "" && row.Status != ad.Status {
+		state := updateInput(row, &models.UpdateAdvertisementInput{Status: ad.Status, Currency: ad.Currency, PricePeriod: ad.PricePeriod})
+		state.Status = row.Status
+		if _, err := s.adService.UpdateAdvertisement(ctx, userID, ad.ID, state, nil); err != nil {
+			result.Warnings = append(result.Warnings, rowError("status", err))
+		}
+	}
+
+	s.syncPhotos(ctx, userID, ad.ID, row.Photos, &result, false)
+	return result
+}
+
+// updateRow brings an imported advertisement in line with the row, an equal one is left untouched
+func (s *importService) updateRow(ctx context.Context, userID, adID int, row *models.ImportRow, result models.ImportRowResult, dryRun bool) models.ImportRowResult {
+	state, err := s.adRepo.GetAdvertisementState(ctx, adID)
+	if err != nil {
+		result.Action = models.ImportRowFailed
+		result.Errors = []validation.FieldError{rowError("", err)}
+		return result
+	}
+
+	next := updateInput(row, &state.UpdateAdvertisementInput)
+	changed := len(diffAdvertisement(&state.UpdateAdvertisementInput, next)) > 0
+	if changed && !dryRun {
+		// the version read above guards against a concurrent edit by the owner
+		if _, err := s.adService.UpdateAdvertisement(ctx, userID, adID, next, &state.Version); err != nil {
+			result.Action = models.ImportRowFailed
+			result.Errors = []validation.FieldError{rowError("", err)}
+			return result
+		}
+	}
+
+	s.syncPhotos(ctx, userID, adID, row.Photos, &result, dryRun)
+
+	result.Action = models.ImportRowUnchanged
+	if changed || result.PhotosAdded > 0 || result.PhotosRemoved > 0 {
+		result.Action = models.ImportRowUpdated
+	}
+	return result
+}
+
+// syncPhotos fetches the photo URLs of the row that the advertisement doesn't have yet and deletes
+// imported photos whose URL is no longer listed. Uploaded photos are never touched.
+// Photos that can't be fetched are reported as warnings.
+func (s *importService) syncPhotos(ctx context.Context, userID, adID int, photos []string, result *models.ImportRowResult, dryRun bool) {
+	sources, err := s.adRepo.GetImageSources(ctx, adID)
+	if err != nil {
+		result.Warnings = append(result.Warnings, rowError("photos", err))
+		return
+	}
+
+	wanted := uniquePhotos(photos)
+	for source, imageID := range sources {
+		if slices.Contains(wanted, source) {
+			continue
+		}
+		if !dryRun {
+			if err := s.deletePhoto(ctx, userID, adID, imageID); err != nil {
+				result.Warnings = append(result.Warnings, rowError("photos", err))
+				continue
+			}
+		}
+		result.PhotosRemoved++
+	}
+
+	var images []models.SavedImage
+	for i, url := range wanted {
+		if _, ok := sources[url]; ok {
+			continue
+		}
+		if dryRun {
+			result.PhotosAdded++
+			continue
+		}
+		image, err := s.fetchPhoto(ctx, adID, url)
+		if err != nil {
+			logger.Warn("failed to fetch imported photo", logger.Field("error", err.Error()), logger.Field("url", url))
+			result.Warnings = append(result.Warnings, *validation.NewError(fmt.Sprintf("photos[%d]", i), "photo_unavailable", photoErrorMessage(err)))
+			continue
+		}
+		images = append(images, image)
+	}
+	if len(images) == 0 {
+		return
+	}
+
+	if _, err := s.adService.AddImages(ctx, userID, adID, images); err != nil {
+		for _, image := range images {
+			_ = s.imageSvc.DeleteImage(image.URL)
+		}
+		result.Warnings = append(result.Warnings, rowError("photos", err))
+		return
+	}
+	result.PhotosAdded += len(images)
+}
+
+// deletePhoto removes an imported photo and its file
+func (s *importService) deletePhoto(ctx context.Context, userID, adID, imageID int) error {
+	path, err := s.adRepo.GetImagePath(ctx, adID, imageID)
+	if err != nil {
+		return err
+	}
+	if err := s.adService.DeleteImage(ctx, userID, adID, imageID); err != nil {
+		return err
+	}
+	if err := s.imageSvc.DeleteImage(path); err != nil {
+		logger.Warn("failed to delete imported photo file", logger.Field("error", err.Error()), logger.Field("path", path))
+	}
+	return nil
+}
+
+// fetchPhoto downloads a photo into the staging directory and moves it to the image storage
+func (s *importService) fetchPhoto(ctx context.Context, adID int, url string) (models.SavedImage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return models.SavedImage{}, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return models.SavedImage{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return models.SavedImage{}, fmt.Errorf("%w %s", errPhotoStatus, resp.Status)
+	}
+	if resp.ContentLength > s.maxPhotoSize {
+		return models.SavedImage{}, ErrUploadTooLarge
+	}
+
+	// read one byte past the limit so oversized bodies can be detected
+	body, err := io.ReadAll(io.LimitReader(resp.Body, s.maxPhotoSize+1))
+	if err != nil {
+		return models.SavedImage{}, err
+	}
+	if int64(len(body)) > s.maxPhotoSize {
+		return models.SavedImage{}, ErrUploadTooLarge
+	}
+
+	// the declared content type of another server is not trusted, the content is checked instead
+	contentType := http.DetectContentType(body)
+	ext, ok := imageExtensions[contentType]
+	if !ok || !slices.Contains(s.allowedTypes, contentType) {
+		return models.SavedImage{}, ErrUploadTypeNotAllowed
+	}
+
+	if err := os.MkdirAll(s.stagingPath, 0o755); err != nil {
+		return models.SavedImage{}, err
+	}
+	token, err := generateUploadToken()
+	if err != nil {
+		return models.SavedImage{}, err
+	}
+	stagedPath := filepath.Join(s.stagingPath, "import_"+token+ext)
+	if err := os.WriteFile(stagedPath, body, 0o644); err != nil {
+		return models.SavedImage{}, err
+	}
+	defer os.Remove(stagedPath)
+
+	image, err := s.imageSvc.StoreAdvertisementImage(adID, stagedPath)
+	if err != nil {
+		return models.SavedImage{}, err
+	}
+	image.SourceURL = &url
+	return image, nil
+}
+
+// toImportRow converts the fields of a record to advertisement fields.
+// Numbers may use a decimal comma and spaces between digit groups, as spreadsheets export them.
+func toImportRow(rec *feed.Record) models.ImportRow {
+	row := models.ImportRow{
+		Line:       rec.Line,
+		ExternalID: rec.Fields["externalId"],
+		Status:     strings.ToLower(rec.Fields["status"]),
+		Photos:     rec.Photos,
+		Errors:     rec.Errors,
+	}
+
+	number := func(field string) *float64 {
+		value, ok := rec.Fields[field]
+		if !ok {
+			return nil
+		}
+		value = strings.NewReplacer(" ", "", "\u00a0", "", ",", ".").Replace(value)
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+			row.Errors = append(row.Errors, *validation.NewError(field, "invalid_number", "must be a number"))
+			return nil
+		}
+		return &n
+	}
+
+	row.Title = rec.Fields["title"]
+	if description, ok := rec.Fields["description"]; ok {
+		row.Description = &description
+	}
+	if price := number("price"); price != nil {
+		row.Price = money.FromMajor(*price)
+	}
+	row.Currency = strings.ToUpper(rec.Fields["currency"])
+	row.PricePeriod = strings.ToLower(rec.Fields["pricePeriod"])
+	row.Type = strings.ToLower(rec.Fields["type"])
+	row.Rooms = strings.ToLower(rec.Fields["rooms"])
+	row.City = rec.Fields["city"]
+	row.Address = rec.Fields["address"]
+	row.Latitude = number("latitude")
+	row.Longitude = number("longitude")
+	if square := number("square"); square != nil {
+		row.Square = *square
+	}
+
+	return row
+}
+
+// updateInput builds the new state of an advertisement from a row.
+// An empty status, currency or price period keeps the current one, files don't carry the attributes,
+// so they are kept too.
+func updateInput(row *models.ImportRow, current *models.UpdateAdvertisementInput) *models.UpdateAdvertisementInput {
+	keep := func(value, currentValue string) string {
+		if value == "" {
+			return currentValue
+		}
+		return value
+	}
+	return &models.UpdateAdvertisementInput{
+		Title:       row.Title,
+		Description: row.Description,
+		Price:       row.Price,
+		Currency:    keep(row.Currency, current.Currency),
+		PricePeriod: keep(row.PricePeriod, current.PricePeriod),
+		Type:        row.Type,
+		Rooms:       row.Rooms,
+		City:        row.City,
+		Address:     row.Address,
+		Latitude:    row.Latitude,
+		Longitude:   row.Longitude,
+		Square:      row.Square,
+		Status:      keep(row.Status, current.Status),
+
+		AdAttributes: current.AdAttributes,
+	}
+}
+
+// rowError converts an error of a row to an entry of the report, internal errors are logged and not shown
+func rowError(field string, err error) validation.FieldError {
+	var appErr *apperr.Error
+	if errors.As(err, &appErr) && appErr.Kind != apperr.KindInternal {
+		return validation.FieldError{Field: field, Code: appErr.Code, Message: appErr.Message}
+	}
+	logger.Error("import row failed", logger.Field("error", err.Error()))
+	return validation.FieldError{Field: field, Code: "internal_error", Message: "the row could not be imported, try again later"}
+}
+
+// photoErrorMessage describes why a photo could not be fetched without exposing internal details
+func photoErrorMessage(err error) string {
+	var appErr *apperr.Error
+	switch {
+	case errors.As(err, &appErr):
+		return appErr.Message
+	case errors.Is(err, errPrivateHost):
+		return errPrivateHost.Error()
+	case errors.Is(err, errPhotoStatus):
+		return err.Error()
+	case errors.Is(err, context.DeadlineExceeded), os.IsTimeout(err):
+		return "the photo URL did not respond in time"
+	}
+	return "the photo could not be downloaded"
+}
+
+// uniquePhotos returns the photo URLs without repeats, in file order
+func uniquePhotos(photos []string) []string {
+	unique := make([]string, 0, len(photos))
+	for _, url := range photos {
+		if !slices.Contains(unique, url) {
+			unique = append(unique, url)
+		}
+	}
+	return unique
+}
+
+func hasPhotos(rows []models.ImportRow) bool {
+	for i := range rows {
+		if len(rows[i].Photos) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// publicOnly refuses connections to loopback, private, link-local and unspecified addresses.
+// It runs after DNS resolution, so a public name resolving to an internal address is refused too.
+func publicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+		return errPrivateHost
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"rentor/internal/apperr"
+	"rentor/internal/feed"
+	"rentor/internal/models"
+	"rentor/internal/money"
+	"rentor/internal/repository"
+	"rentor/internal/service"
+)
+
+// newImportService creates an import service on top of the real advertisement service.
+// The imported rows have no photos, so no image service is needed.
+func newImportService(t *testing.T, conn repository.DBTX, repos *repository.Repositories) service.ImportService {
+	t.Helper()
+	rates, err := money.NewRates("KZT", nil)
+	if err != nil {
+		t.Fatalf("rates: %v", err)
+	}
+	ads := service.NewadvertisementService(repos.Advertisement, repository.NewTxManager(conn),
+		service.NewModerationService(repos.Moderation, 6, 0.8), time.Minute, time.Hour, rates)
+	return service.NewImportService(repos.Import, repos.Advertisement, ads, nil,
+		t.TempDir(), 100, 10, 5, 1<<20, []string{"image/png"}, time.Second, false)
+}
+
+// registerLandlord registers a user with a profile, advertisements are shown with the profile of the owner
+func registerLandlord(t *testing.T, conn repository.DBTX, repos *repository.Repositories) int {
+	t.Helper()
+	userID, err := service.NewUserService(repos.User, repos.UserProfile, repository.NewTxManager(conn)).
+		RegisterUser(context.Background(), &models.CreateUserInput{Email: "landlord@example.com"})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	return userID
+}
+
+// rowResult is the part of a row result the tests compare
+type rowResult struct {
+	Line       int
+	ExternalID string
+	Action     string
+	Errors     []string // field:code
+}
+
+func rowResults(report *models.ImportReport) []rowResult {
+	out := make([]rowResult, 0, len(report.Rows))
+	for _, row := range report.Rows {
+		r := rowResult{Line: row.Line, ExternalID: row.ExternalID, Action: row.Action}
+		for _, fe := range row.Errors {
+			r.Errors = append(r.Errors, fe.Field+":"+fe.Code)
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// importedAd returns the advertisement imported under externalID
+func importedAd(t *testing.T, repos *repository.Repositories, userID int, externalID string) *models.AdvertisementState {
+	t.Helper()
+	ctx := context.Background()
+	id, _, err := repos.Advertisement.GetAdvertisementIDByExternalID(ctx, userID, externalID)
+	if err != nil {
+		t.Fatalf("advertisement %s: %v", externalID, err)
+	}
+	state, err := repos.Advertisement.GetAdvertisementState(ctx, id)
+	if err != nil {
+		t.Fatalf("state of %s: %v", externalID, err)
+	}
+	return state
+}
+
+const firstImport = `externalId;title;price;type;rooms;city;address;square;status
+A1;Flat near the park;150 000;apartment;1;Almaty;Abay 10;42,5;
+A2;House;400000;house;4;Almaty;Dostyk 5;120;paused
+A3;;100;castle;1;Almaty;Abay 12;30;
+A1;Same ID again;1;apartment;1;Almaty;Abay 14;30;
+A4;Bad price;cheap;apartment;1;Almaty;Abay 16;30;
+`
+
+// secondImport is a JSON export of another system with a price key of its own
+const secondImport = `{"items": [
+	{"externalId": "A1", "title": "Flat near the park", "cost": 140000, "type": "apartment", "rooms": "1", "city": "Almaty", "address": "Abay 10", "square": 42.5},
+	{"externalId": "A2", "title": "House", "cost": "400000", "type": "house", "rooms": "4", "city": "Almaty", "address": "Dostyk 5", "square": 120},
+	{"externalId": "A5", "title": "Room", "cost": 60000, "type": "room", "rooms": "1", "city": "Almaty", "address": "Satpaev 1", "square": 15}
+]}`
+
+func TestImportNow(t *testing.T) {
+	conn, repos := openRepositories(t)
+	ctx := context.Background()
+	userID := registerLandlord(t, conn, repos)
+	imports := newImportService(t, conn, repos)
+
+	report, err := imports.ImportNow(ctx, userID, &models.ImportInput{}, "listings.csv", strings.NewReader(firstImport))
+	if err != nil {
+		t.Fatalf("first import: %v", err)
+	}
+	if report.Total != 5 || report.Created != 2 || report.Failed != 3 || report.DryRun {
+		t.Errorf("first import report = %+v, want 2 created and 3 failed of 5", report)
+	}
+	want := []rowResult{
+		{Line: 2, ExternalID: "A1", Action: models.ImportRowCreated},
+		{Line: 3, ExternalID: "A2", Action: models.ImportRowCreated},
+		{Line: 4, ExternalID: "A3", Action: models.ImportRowFailed, Errors: []string{"title:required", "type:one_of"}},
+		{Line: 5, ExternalID: "A1", Action: models.ImportRowFailed, Errors: []string{"externalId:duplicate"}},
+		{Line: 6, ExternalID: "A4", Action: models.ImportRowFailed, Errors: []string{"price:invalid_number"}},
+	}
+	if got := rowResults(report); !reflect.DeepEqual(got, want) {
+		t.Errorf("first import rows:\n got %+v\nwant %+v", got, want)
+	}
+
+	flat := importedAd(t, repos, userID, "A1")
+	if flat.Price != money.FromMajor(150000) || flat.Square != 42.5 || flat.Currency != "KZT" || flat.Status != "active" {
+		t.Errorf("A1 = %+v, want 150000 KZT, 42.5 m², active", flat.UpdateAdvertisementInput)
+	}
+	if house := importedAd(t, repos, userID, "A2"); house.Status != "paused" {
+		t.Errorf("A2 status = %s, want paused", house.Status)
+	}
+
+	// a dry run reports the changes of the second file and changes nothing
+	input := &models.ImportInput{Format: feed.FormatJSON, Mapping: map[string]string{"price": "cost"}, DryRun: true}
+	report, err = imports.ImportNow(ctx, userID, input, "export.txt", strings.NewReader(secondImport))
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	wantSecond := []rowResult{
+		{Line: 1, ExternalID: "A1", Action: models.ImportRowUpdated},
+		{Line: 2, ExternalID: "A2", Action: models.ImportRowUnchanged},
+		{Line: 3, ExternalID: "A5", Action: models.ImportRowCreated},
+	}
+	if !report.DryRun || report.Created != 1 || report.Updated != 1 || report.Unchanged != 1 || report.Failed != 0 {
+		t.Errorf("dry run report = %+v, want 1 created, 1 updated and 1 unchanged", report)
+	}
+	if got := rowResults(report); !reflect.DeepEqual(got, wantSecond) {
+		t.Errorf("dry run rows:\n got %+v\nwant %+v", got, wantSecond)
+	}
+	if n := countRows(t, conn, "SELECT COUNT(*) FROM advertisement WHERE user_id = ? AND external_id IS NOT NULL", userID); n != 2 {
+		t.Errorf("the dry run left %d imported advertisements, want 2", n)
+	}
+	if again := importedAd(t, repos, userID, "A1"); again.Price != flat.Price || again.Version != flat.Version {
+		t.Errorf("the dry run changed A1: price %s, version %d", again.Price, again.Version)
+	}
+
+	// the same file without the dry run
+	input.DryRun = false
+	report, err = imports.ImportNow(ctx, userID, input, "export.txt", strings.NewReader(secondImport))
+	if err != nil {
+		t.Fatalf("second import: %v", err)
+	}
+	if report.DryRun || report.Created != 1 || report.Updated != 1 || report.Unchanged != 1 || report.Failed != 0 {
+		t.Errorf("second import report = %+v, want 1 created, 1 updated and 1 unchanged", report)
+	}
+	if got := rowResults(report); !reflect.DeepEqual(got, wantSecond) {
+		t.Errorf("second import rows:\n got %+v\nwant %+v", got, wantSecond)
+	}
+	if updated := importedAd(t, repos, userID, "A1"); updated.Price != money.FromMajor(140000) || updated.Version != flat.Version+1 {
+		t.Errorf("A1 after the import: price %s, version %d, want 140000 and version %d", updated.Price, updated.Version, flat.Version+1)
+	}
+	// the file has no status, the paused listing stays paused
+	if house := importedAd(t, repos, userID, "A2"); house.Status != "paused" {
+		t.Errorf("A2 status = %s, want paused", house.Status)
+	}
+	importedAd(t, repos, userID, "A5")
+}
+
+func TestImportNowRejectsFiles(t *testing.T) {
+	conn, repos := openRepositories(t)
+	ctx := context.Background()
+	userID := registerLandlord(t, conn, repos)
+	imports := newImportService(t, conn, repos)
+
+	tests := []struct {
+		name     string
+		input    models.ImportInput
+		filename string
+		file     string
+		wantErr  error
+	}{
+		{"unknown extension", models.ImportInput{}, "listings.xlsx", "externalId\nA1\n", feed.ErrUnsupportedFormat},
+		{"unknown format", models.ImportInput{Format: "yaml"}, "listings.csv", "externalId\nA1\n", feed.ErrUnsupportedFormat},
+		{"unknown mapped field", models.ImportInput{Mapping: map[string]string{"colour": "Цвет"}}, "listings.csv", "externalId\nA1\n", feed.ErrInvalidMapping},
+		{"broken file", models.ImportInput{}, "listings.json", `{"items": [`, feed.ErrInvalidFile},
+		{"header only", models.ImportInput{}, "listings.csv", "externalId,title\n", apperr.ErrValidation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := imports.ImportNow(ctx, userID, &tt.input, tt.filename, strings.NewReader(tt.file))
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
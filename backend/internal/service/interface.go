@@ -1,25 +1,41 @@
 package service
 
 import (
+	"context"
+	"io"
 	"mime/multipart"
+	"os"
 	"rentor/internal/models"
 	"time"
 )
 
 // UserService interface for user business logic
 type UserService interface {
-	RegisterUser(input *models.CreateUserInput) (int, error)
-	GetUser(id int) (*models.User, error)
-	GetUserByEmail(email string) (*models.User, error)
-	GetUserByPhone(phone string) (*models.User, error)
-	FindOrCreateUserByEmail(email string) (*models.User, error)
+	RegisterUser(ctx context.Context, input *models.CreateUserInput) (int, error)
+	GetUser(ctx context.Context, id int) (*models.User, error)
+	SetUserRole(ctx context.Context, email, role string) error
+	DeleteUser(ctx context.Context, id int) error
+	RestoreUser(ctx context.Context, id int) error
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
+	GetUserByPhone(ctx context.Context, phone string) (*models.User, error)
+	FindOrCreateUserByEmail(ctx context.Context, email string) (*models.User, error)
+	MarkEmailVerified(ctx context.Context, id int) error
+}
+
+// ContactService changes the email and phone of a user after confirming them with an OTP code
+type ContactService interface {
+	RequestEmailChange(ctx context.Context, userID int, email string) error
+	ConfirmEmailChange(ctx context.Context, userID int, email, otpCode string) (*models.User, error)
+	RequestPhoneChange(ctx context.Context, userID int, phone string) error
+	ConfirmPhoneChange(ctx context.Context, userID int, phone, otpCode string) (*models.User, error)
 }
 
 // UserProfileService interface for user profile business logic
 type UserProfileService interface {
-	GetUserProfile(userID int) (*models.UserProfile, error)
-	UpdateUserProfile(userID int, input *models.UpdateUserProfileInput) error
-	CreateDefaultUserProfile(userID int) error
+	GetUserProfile(ctx context.Context, userID int) (*models.UserProfile, error)
+	UpdateUserProfile(ctx context.Context, userID int, input *models.UpdateUserProfileInput, ifMatch *int) (int, error)
+	PatchUserProfile(ctx context.Context, userID int, patch []byte, ifMatch *int) (int, error)
+	CreateDefaultUserProfile(ctx context.Context, userID int) error
 }
 
 // JWTService handles JWT token operations
@@ -33,32 +49,133 @@ type JWTService interface {
 	GetAccessTokenTTL() time.Duration
 }
 
-// OTPService handles OTP generation, storage, and verification
+// OTPService handles OTP generation, storage, and verification for sign-in and for confirming
+// sensitive actions (models.OTPPurpose*)
 type OTPService interface {
-	GenerateAndStoreOTP(userID int, email string, otpLength int, expirationMinutes int, maxAttempts int) error
-	VerifyOTP(email string, otpCode string, maxAttempts int) (int, error) // returns userID
-	CleanupExpiredOTPs() error
+	GenerateAndStoreOTP(ctx context.Context, userID int, purpose, target string) error
+	VerifyOTP(ctx context.Context, purpose, target string, otpCode string) (int, error) // returns userID
+	CleanupExpiredOTPs(ctx context.Context) error
 }
 
 type EmailService interface {
 	SendEmail(to, subject, body string) error
 }
 
+// SMSService sends text messages to phone numbers
+type SMSService interface {
+	SendSMS(to, text string) error
+}
+
 // AdvertisementService defines the interface for advertisement operations.
 type AdvertisementService interface {
-	CreateAdvertisement(userID int, input *models.CreateAdvertisementInput) (*models.GetAd, error)
-	GetAdvertisement(id int) (*models.GetAd, error)
-	GetAdvertisementsPaged(filters *models.AdFilters) (*models.GetAdPreviewsList, error)
-	GetMyAdvertisements(userID, page, limit int) (*models.GetAdPreviewsList, error)
-	UpdateAdvertisement(userID, adID int, input *models.UpdateAdvertisementInput) error
-	DeleteAdvertisement(userID, adID int) error
-	AddImages(userID, adID int, urls []string) (*models.ImagesUploadResponse, error)
-	DeleteImage(userID, adID, imageID int) error
-	GetImagePath(adID int, imageID int) (string, error)
+	CreateAdvertisement(ctx context.Context, userID int, input *models.CreateAdvertisementInput) (*models.GetAd, error)
+	GetAdvertisement(ctx context.Context, id int) (*models.GetAd, error)
+	GetAdvertisementsPaged(ctx context.Context, filters *models.AdFilters) (*models.GetAdPreviewsList, error)
+	GetMyAdvertisements(ctx context.Context, userID, page, limit int) (*models.GetAdPreviewsList, error)
+	GetAdvertisementPreviews(ctx context.Context, ids []int) ([]models.AdPreview, error)
+	UpdateAdvertisement(ctx context.Context, userID, adID int, input *models.UpdateAdvertisementInput, ifMatch *int) (int, error)
+	PatchAdvertisement(ctx context.Context, userID, adID int, patch []byte, ifMatch *int) (int, error)
+	GetAdvertisementHistory(ctx context.Context, userID, adID int, isAdmin bool) (*models.AdvertisementHistory, error)
+	DeleteAdvertisement(ctx context.Context, userID, adID int) (*models.DeleteAdvertisementResponse, error)
+	RestoreAdvertisement(ctx context.Context, userID, adID int) error
+	AdminRestoreAdvertisement(ctx context.Context, adID int) error
+	AddFavorite(ctx context.Context, userID, adID int) (bool, error)
+	RemoveFavorite(ctx context.Context, userID, adID int) error
+	GetFavorites(ctx context.Context, userID, page, limit int) (*models.GetAdPreviewsList, error)
+	GetLandlordContacts(ctx context.Context, adID int) (*models.LandlordContacts, error)
+	AddImages(ctx context.Context, userID, adID int, images []models.SavedImage) (*models.ImagesUploadResponse, error)
+	ScreenImages(ctx context.Context, userID, adID int, images []models.SavedImage) *models.ImagesUploadResponse
+	DeleteImage(ctx context.Context, userID, adID, imageID int) error
+	ReorderImages(ctx context.Context, userID, adID int, imageIDs []int) error
+	SetCoverImage(ctx context.Context, userID, adID, imageID int) error
+	UpdateImageCaption(ctx context.Context, userID, adID, imageID int, caption *string) error
+	GetImagePath(ctx context.Context, adID int, imageID int) (string, error)
+	GetAmenities(ctx context.Context) (*models.AmenityCatalog, error)
 }
 
 // ImageService интерфейс для работы с изображениями
 type ImageService interface {
-	SaveAdvertisementImages(adID int, files []*multipart.FileHeader) ([]string, error)
+	SaveAdvertisementImages(adID int, files []*multipart.FileHeader) ([]models.SavedImage, error)
+	StoreAdvertisementImage(adID int, srcPath string) (models.SavedImage, error)
 	DeleteImage(path string) error
 }
+
+// UploadService handles direct-to-storage upload sessions for advertisement images
+type UploadService interface {
+	CreateSession(ctx context.Context, userID, adID int, input *models.CreateUploadSessionInput) (*models.UploadSessionResponse, error)
+	Upload(ctx context.Context, token, contentType string, body io.Reader) error
+	CommitSession(ctx context.Context, userID, adID, sessionID int) (*models.ImagesUploadResponse, error)
+	CleanupExpiredSessions(ctx context.Context) error
+}
+
+// ImageReconcileService checks image files in storage against advertisement_photos rows
+type ImageReconcileService interface {
+	Reconcile(ctx context.Context, opts models.ReconcileOptions) (*models.ReconcileReport, error)
+	RunScheduled(ctx context.Context, opts models.ReconcileOptions) error
+}
+
+// ModerationService detects duplicate photos and listings and manages the moderation queue
+type ModerationService interface {
+	CheckNewImages(ctx context.Context, userID, adID int, images []models.SavedImage) error
+	DetectDuplicateListings(ctx context.Context, adID int) ([]models.DuplicateCandidate, error)
+	ScreenAdvertisement(ctx context.Context, adID int) error
+	GetQueue(ctx context.Context, status string, page, limit int) (*models.ModerationItemsList, error)
+	ResolveItem(ctx context.Context, moderatorID, itemID int, status string) (*models.ModerationItem, error)
+}
+
+// AnalyticsService precomputes rent statistics per city and serves them
+type AnalyticsService interface {
+	RefreshCityStats(ctx context.Context) error
+	GetCityAnalytics(ctx context.Context, city string, days int) (*models.CityAnalytics, error)
+}
+
+// StatsService buffers advertisement events in memory, flushes them in batches and serves the landlord statistics
+type StatsService interface {
+	RecordImpressions(adIDs []int)
+	RecordView(adID int)
+	RecordFavorite(adID int)
+	RecordContact(adID int)
+	Flush(ctx context.Context) error
+	GetAdvertisementStats(ctx context.Context, userID, adID, days int) (*models.AdvertisementStats, error)
+	GetDashboard(ctx context.Context, userID int) (*models.LandlordDashboard, error)
+	GetDashboardCounters(ctx context.Context, adIDs []int) (map[int]*models.AdvertisementCounters, error)
+}
+
+// RecommendationService recommends similar listings and keeps the recently viewed advertisements of users and visitors
+type RecommendationService interface {
+	GetSimilar(ctx context.Context, adID, limit int) (*models.SimilarAdvertisementsList, error)
+	RecordView(ctx context.Context, viewer string, adID int) error
+	GetRecentlyViewed(ctx context.Context, viewer string) (*models.RecentlyViewedList, error)
+	CleanupRecentlyViewed(ctx context.Context) error
+}
+
+// PurgeService permanently removes soft-deleted advertisements and users after the retention period
+type PurgeService interface {
+	PurgeDeleted(ctx context.Context) error
+}
+
+// ImportService imports advertisements from CSV, JSON and XML feed files, keyed by the external ID of each listing
+type ImportService interface {
+	Import(ctx context.Context, userID int, input *models.ImportInput, filename string, file io.Reader) (*models.ImportReport, *models.ImportJob, error)
+	ImportNow(ctx context.Context, userID int, input *models.ImportInput, filename string, file io.Reader) (*models.ImportReport, error)
+	GetJob(ctx context.Context, userID, jobID int) (*models.ImportJob, error)
+	ProcessQueuedJobs(ctx context.Context) error
+}
+
+// ExportService exports listings to files and feeds and generates personal data archives
+type ExportService interface {
+	ExportListings(ctx context.Context, userID int, format string, w io.Writer) error
+	PublicFeed(ctx context.Context, landlordID int, w io.Writer) error
+	RequestArchive(ctx context.Context, userID int) (*models.ExportArchive, error)
+	GetArchive(ctx context.Context, userID, archiveID int) (*models.ExportArchive, error)
+	OpenArchive(ctx context.Context, userID, archiveID int) (*os.File, *models.ExportArchive, error)
+	ProcessQueuedArchives(ctx context.Context) error
+	CleanupExpiredArchives(ctx context.Context) error
+}
+
+// AccountService deletes accounts at their owners' request: deactivation, a grace period and erasure
+type AccountService interface {
+	DeleteAccount(ctx context.Context, userID int) (*models.AccountDeletion, error)
+	CancelDeletion(ctx context.Context, userID int) (bool, error) // true if the account was waiting for erasure and is restored
+	EraseDueAccounts(ctx context.Context) error
+}
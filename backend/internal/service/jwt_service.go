@@ -4,6 +4,8 @@ import (
 	"errors"
 	"time"
 
+	"rentor/internal/apperr"
+
 	"github.com/golang-jwt/jwt/v4"
 )
 
@@ -89,15 +91,15 @@ func (s *jwtService) validateToken(tokenString, expectedType string) (*JWTClaims
 	})
 
 	if err != nil || !token.Valid {
-		return nil, errors.New("invalid token")
+		return nil, apperr.ErrInvalidToken.Wrap(err)
 	}
 
 	if claims.ExpiresAt.Time.Before(time.Now()) {
-		return nil, errors.New("token expired")
+		return nil, apperr.ErrInvalidToken.Wrap(errors.New("token expired"))
 	}
 
 	if claims.Type != expectedType {
-		return nil, errors.New("invalid token type")
+		return nil, apperr.ErrInvalidToken.Wrap(errors.New("invalid token type"))
 	}
 
 	return claims, nil
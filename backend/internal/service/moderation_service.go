@@ -0,0 +1,327 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"rentor/internal/apperr"
+	"rentor/internal/imagehash"
+	"rentor/internal/logger"
+	"rentor/internal/models"
+	"rentor/internal/repository"
+)
+
+// weights of the duplicate listing score components
+const (
+	photoOverlapWeight = 0.6
+	titleWeight        = 0.25
+	addressWeight      = 0.15
+)
+
+// maxDuplicateCandidates limits how many scored candidates are returned by the detector
+const maxDuplicateCandidates = 10
+
+type moderationService struct {
+	repo             repository.ModerationRepository
+	maxPhotoDistance int
+	listingThreshold float64
+}
+
+// NewModerationService creates a new moderation service.
+// maxPhotoDistance is the largest Hamming distance between two photo hashes still treated as the same photo,
+// listingThreshold is the duplicate listing score from which an advertisement is sent to the moderation queue.
+func NewModerationService(repo repository.ModerationRepository, maxPhotoDistance int, listingThreshold float64) ModerationService {
+	return &moderationService{
+		repo:             repo,
+		maxPhotoDistance: maxPhotoDistance,
+		listingThreshold: listingThreshold,
+	}
+}
+
+// CheckNewImages flags photos just added to an advertisement that match photos on other users' advertisements
+func (s *moderationService) CheckNewImages(ctx context.Context, userID, adID int, images []models.SavedImage) error {
+	var hashes []uint64
+	for _, image := range images {
+		if image.PHash == nil {
+			continue
+		}
+		hash, err := imagehash.Parse(*image.PHash)
+		if err != nil {
+			continue
+		}
+		hashes = append(hashes, hash)
+	}
+	if len(hashes) == 0 {
+		return nil
+	}
+
+	others, err := s.repo.GetPhotoHashesOfOtherUsers(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	// best distance and number of matched photos per other advertisement
+	type match struct {
+		count    int
+		distance int
+	}
+	matches := map[int]*match{}
+	for _, hash := range hashes {
+		matched := map[int]bool{}
+		for _, other := range others {
+			otherHash, err := imagehash.Parse(other.PHash)
+			if err != nil {
+				continue
+			}
+			d := imagehash.Distance(hash, otherHash)
+			if d > s.maxPhotoDistance {
+				continue
+			}
+			m, ok := matches[other.AdvertisementID]
+			if !ok {
+				m = &match{distance: d}
+				matches[other.AdvertisementID] = m
+			}
+			if !matched[other.AdvertisementID] {
+				m.count++
+				matched[other.AdvertisementID] = true
+			}
+			if d < m.distance {
+				m.distance = d
+			}
+		}
+	}
+
+	for relatedID, m := range matches {
+		details := fmt.Sprintf("%d uploaded photo(s) match photos of advertisement #%d", m.count, relatedID)
+		err := s.repo.CreateModerationItem(ctx, &models.ModerationItem{
+			AdvertisementID:        adID,
+			RelatedAdvertisementID: relatedID,
+			Reason:                 models.ModerationReasonDuplicatePhoto,
+			Score:                  1 - float64(m.distance)/64,
+			Details:                &details,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(matches) > 0 {
+		logger.Warn("uploaded photos match other users' advertisements",
+			logger.Field("advertisement_id", adID),
+			logger.Field("matched_advertisements", len(matches)),
+		)
+	}
+
+	return nil
+}
+
+// DetectDuplicateListings scores other users' advertisements as possible duplicates of adID
+func (s *moderationService) DetectDuplicateListings(ctx context.Context, adID int) ([]models.DuplicateCandidate, error) {
+	ad, err := s.repo.GetListingSummary(ctx, adID)
+	if err != nil {
+		return nil, err
+	}
+
+	ownHashes, err := s.repo.GetPhotoHashesByAdvertisement(ctx, adID)
+	if err != nil {
+		return nil, err
+	}
+	otherHashes, err := s.repo.GetPhotoHashesOfOtherUsers(ctx, ad.UserID)
+	if err != nil {
+		return nil, err
+	}
+	sameCity, err := s.repo.GetListingSummariesInCity(ctx, ad.City, ad.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	// candidates are listings in the same city and listings sharing at least one photo
+	hashesByAd := map[int][]uint64{}
+	for _, h := range otherHashes {
+		if hash, err := imagehash.Parse(h.PHash); err == nil {
+			hashesByAd[h.AdvertisementID] = append(hashesByAd[h.AdvertisementID], hash)
+		}
+	}
+	var own []uint64
+	for _, h := range ownHashes {
+		if hash, err := imagehash.Parse(h.PHash); err == nil {
+			own = append(own, hash)
+		}
+	}
+
+	candidates := map[int]*models.ListingSummary{}
+	for _, c := range sameCity {
+		candidates[c.ID] = c
+	}
+	for otherID, hashes := range hashesByAd {
+		if _, ok := candidates[otherID]; ok {
+			continue
+		}
+		if s.photoOverlap(own, hashes) > 0 {
+			summary, err := s.repo.GetListingSummary(ctx, otherID)
+			if err != nil {
+				return nil, err
+			}
+			candidates[otherID] = summary
+		}
+	}
+
+	var result []models.DuplicateCandidate
+	for id, c := range candidates {
+		candidate := models.DuplicateCandidate{
+			AdvertisementID:   id,
+			PhotoOverlap:      s.photoOverlap(own, hashesByAd[id]),
+			TitleSimilarity:   textSimilarity(ad.Title, c.Title),
+			AddressSimilarity: textSimilarity(ad.Address, c.Address),
+		}
+
+		textScore := titleWeight*candidate.TitleSimilarity + addressWeight*candidate.AddressSimilarity
+		if len(own) > 0 && len(hashesByAd[id]) > 0 {
+			candidate.Score = photoOverlapWeight*candidate.PhotoOverlap + textScore
+		} else {
+			// without photos on both sides only the text can be compared
+			candidate.Score = textScore / (titleWeight + addressWeight)
+		}
+
+		if candidate.Score > 0 {
+			result = append(result, candidate)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Score != result[j].Score {
+			return result[i].Score > result[j].Score
+		}
+		return result[i].AdvertisementID < result[j].AdvertisementID
+	})
+	if len(result) > maxDuplicateCandidates {
+		result = result[:maxDuplicateCandidates]
+	}
+
+	return result, nil
+}
+
+// ScreenAdvertisement runs the duplicate listing detector and feeds likely duplicates into the moderation queue
+func (s *moderationService) ScreenAdvertisement(ctx context.Context, adID int) error {
+	candidates, err := s.DetectDuplicateListings(ctx, adID)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range candidates {
+		if c.Score < s.listingThreshold {
+			break
+		}
+		details := fmt.Sprintf("photo overlap %.2f, title similarity %.2f, address similarity %.2f",
+			c.PhotoOverlap, c.TitleSimilarity, c.AddressSimilarity)
+		err := s.repo.CreateModerationItem(ctx, &models.ModerationItem{
+			AdvertisementID:        adID,
+			RelatedAdvertisementID: c.AdvertisementID,
+			Reason:                 models.ModerationReasonDuplicateListing,
+			Score:                  c.Score,
+			Details:                &details,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetQueue retrieves moderation items with the given status
+func (s *moderationService) GetQueue(ctx context.Context, status string, page, limit int) (*models.ModerationItemsList, error) {
+	if status == "" {
+		status = models.ModerationStatusOpen
+	}
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 20
+	}
+
+	items, total, err := s.repo.GetPageModerationItems(ctx, status, (page-1)*limit, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.ModerationItemsList{
+		Total: total,
+		Page:  page,
+		Limit: limit,
+		Items: items,
+	}, nil
+}
+
+// ResolveItem stores a moderator decision for a queue item
+func (s *moderationService) ResolveItem(ctx context.Context, moderatorID, itemID int, status string) (*models.ModerationItem, error) {
+	if status != models.ModerationStatusConfirmed && status != models.ModerationStatusDismissed {
+		return nil, apperr.Invalid("invalid_status", "status must be confirmed or dismissed")
+	}
+
+	if _, err := s.repo.GetModerationItemByID(ctx, itemID); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.ResolveModerationItem(ctx, itemID, status, moderatorID, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetModerationItemByID(ctx, itemID)
+}
+
+// photoOverlap returns the share of photos of the smaller set that have a near-duplicate in the other set
+func (s *moderationService) photoOverlap(a, b []uint64) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+
+	matched := 0
+	for _, x := range a {
+		for _, y := range b {
+			if imagehash.Distance(x, y) <= s.maxPhotoDistance {
+				matched++
+				break
+			}
+		}
+	}
+
+	return float64(matched) / float64(len(a))
+}
+
+// textSimilarity returns the Jaccard similarity of the word sets of two strings
+func textSimilarity(a, b string) float64 {
+	wa, wb := words(a), words(b)
+	if len(wa) == 0 || len(wb) == 0 {
+		return 0
+	}
+
+	common := 0
+	for w := range wa {
+		if wb[w] {
+			common++
+		}
+	}
+
+	return float64(common) / float64(len(wa)+len(wb)-common)
+}
+
+// words splits s into a set of lower-cased words
+func words(s string) map[string]bool {
+	set := map[string]bool{}
+	for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		set[w] = true
+	}
+	return set
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTextSimilarity(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want float64
+	}{
+		{"both empty", "", "", 0},
+		{"one empty", "Квартира в центре", "", 0},
+		{"whitespace only", "   \t\n", "   ", 0},
+		{"punctuation only", "!!! --- ...", "!!! --- ...", 0},
+		{"identical", "two room flat", "two room flat", 1},
+		{"case and punctuation ignored", "Two-room flat!", "two room, FLAT", 1},
+		{"repeated words count once", "flat flat flat", "flat", 1},
+		{"word order ignored", "flat near the park", "the park near flat", 1},
+		{"partial overlap", "sunny flat downtown", "sunny house downtown", 0.5},
+		{"no overlap", "sunny flat", "dark house", 0},
+		{"cyrillic identical", "Квартира в центре", "квартира В ЦЕНТРЕ", 1},
+		{"cyrillic partial overlap", "Уютная квартира в центре", "Просторная квартира в центре", 0.6},
+		{"yo and ye are different letters", "ёлка", "елка", 0},
+		{"accented letters", "Café près de la gare", "CAFÉ près de la gare", 1},
+		{"accents matter", "café", "cafe", 0},
+		{"digits are words", "2 rooms 45 m2", "2 rooms 45 m²", 0.6},
+		{"mixed scripts", "Apartment квартира 東京", "квартира 東京 apartment", 1},
+		{"emoji are separators", "🏠sea🌊view", "sea view", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := textSimilarity(tt.a, tt.b)
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("textSimilarity(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+			if back := textSimilarity(tt.b, tt.a); back != got {
+				t.Errorf("textSimilarity is not symmetric: %v vs %v", got, back)
+			}
+		})
+	}
+}
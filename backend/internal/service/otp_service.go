@@ -1,31 +1,51 @@
 package service
 
 import (
+	"context"
 	"crypto/rand"
-	"errors"
 	"fmt"
 	"math/big"
 	"time"
 
+	"rentor/internal/apperr"
 	"rentor/internal/logger"
+	"rentor/internal/models"
 	"rentor/internal/repository"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
 type otpService struct {
-	repo         repository.OTPRepository
-	emailService EmailService
+	repo              repository.OTPRepository
+	emailService      EmailService
+	smsService        SMSService
+	otpLength         int
+	expirationMinutes int
+	maxAttempts       int
 }
 
-// NewOTPService creates a new OTP service
-func NewOTPService(repo repository.OTPRepository, emailService EmailService) OTPService {
+// NewOTPService creates a new OTP service, codes of otpLength digits are valid for expirationMinutes
+// and maxAttempts tries
+func NewOTPService(repo repository.OTPRepository, emailService EmailService, smsService SMSService,
+	otpLength int, expirationMinutes int, maxAttempts int) OTPService {
 	return &otpService{
-		repo:         repo,
-		emailService: emailService,
+		repo:              repo,
+		emailService:      emailService,
+		smsService:        smsService,
+		otpLength:         otpLength,
+		expirationMinutes: expirationMinutes,
+		maxAttempts:       maxAttempts,
 	}
 }
 
+// otpMessages subject and text of the message carrying a code, per purpose
+var otpMessages = map[string]struct{ subject, text string }{
+	models.OTPPurposeLogin:           {"Your OTP", "Your OTP is: %s"},
+	models.OTPPurposeAccountDeletion: {"Confirm deleting your account", "Your code to confirm deleting your Rentor account: %s\n\nIf you did not ask for this, ignore this email and your account stays as it is."},
+	models.OTPPurposeEmailChange:     {"Confirm your new email", "Your code to confirm this email for your Rentor account: %s"},
+	models.OTPPurposePhoneChange:     {"", "Rentor code: %s"},
+}
+
 // GenerateOTP generates a random OTP string
 func (s *otpService) generateOTP(length int) (string, error) {
 	const digits = "0123456789"
@@ -41,10 +61,16 @@ func (s *otpService) generateOTP(length int) (string, error) {
 	return string(otp), nil
 }
 
-// GenerateAndStoreOTP creates a new OTP, hashes it, and stores in DB
-func (s *otpService) GenerateAndStoreOTP(userID int, email string, otpLength int, expirationMinutes int, maxAttempts int) error {
+// GenerateAndStoreOTP creates a new OTP for the purpose, hashes it, stores it in DB and sends it to target:
+// by SMS for phone changes, by email otherwise
+func (s *otpService) GenerateAndStoreOTP(ctx context.Context, userID int, purpose, target string) error {
+	message, ok := otpMessages[purpose]
+	if !ok {
+		return fmt.Errorf("unknown OTP purpose %q", purpose)
+	}
+
 	// Generate OTP
-	otpCode, err := s.generateOTP(otpLength)
+	otpCode, err := s.generateOTP(s.otpLength)
 	if err != nil {
 		return fmt.Errorf("failed to generate OTP: %w", err)
 	}
@@ -55,45 +81,50 @@ func (s *otpService) GenerateAndStoreOTP(userID int, email string, otpLength int
 		return fmt.Errorf("failed to hash OTP: %w", err)
 	}
 
-	// Delete any existing OTP for this email
-	_ = s.repo.DeleteOTPByEmail(email)
+	// Delete any existing OTP for this target and purpose
+	_ = s.repo.DeleteOTPs(ctx, purpose, target)
 
 	// Store in database
-	expiresAt := time.Now().Add(time.Duration(expirationMinutes) * time.Minute)
-	err = s.repo.CreateOTP(userID, email, string(hashedCode), maxAttempts, expiresAt)
+	expiresAt := time.Now().Add(time.Duration(s.expirationMinutes) * time.Minute)
+	err = s.repo.CreateOTP(ctx, userID, purpose, target, string(hashedCode), s.maxAttempts, expiresAt)
 	if err != nil {
 		return fmt.Errorf("failed to store OTP: %w", err)
 	}
 
-	// send OTP via email
-	err = s.emailService.SendEmail(email, "Your OTP", "Your OTP is: "+otpCode)
+	// send OTP by SMS or email
+	text := fmt.Sprintf(message.text, otpCode)
+	if purpose == models.OTPPurposePhoneChange {
+		err = s.smsService.SendSMS(target, text)
+	} else {
+		err = s.emailService.SendEmail(target, message.subject, text)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to send OTP via email: %w", err)
+		return fmt.Errorf("failed to send OTP: %w", err)
 	}
 
-	logger.Info("OTP sent", logger.Field("email", email), logger.Field("code", otpCode), logger.Field("expires_at", expiresAt))
+	logger.Info("OTP sent", logger.Field("purpose", purpose), logger.Field("target", target), logger.Field("code", otpCode), logger.Field("expires_at", expiresAt))
 
 	return nil
 }
 
-// VerifyOTP verifies the provided OTP code
-func (s *otpService) VerifyOTP(email string, otpCode string, maxAttempts int) (int, error) {
+// VerifyOTP verifies the code sent to target for the purpose, returns the user it was sent to
+func (s *otpService) VerifyOTP(ctx context.Context, purpose, target string, otpCode string) (int, error) {
 	// Get OTP record from DB
-	otpRecord, err := s.repo.GetOTPByEmail(email)
+	otpRecord, err := s.repo.GetOTP(ctx, purpose, target)
 	if err != nil {
 		return 0, fmt.Errorf("OTP not found: %w", err)
 	}
 
 	// Check if OTP is expired
 	if time.Now().After(otpRecord.ExpiresAt) {
-		_ = s.repo.DeleteOTPByID(otpRecord.ID)
-		return 0, errors.New("OTP expired")
+		_ = s.repo.DeleteOTPByID(ctx, otpRecord.ID)
+		return 0, apperr.Unauthorized("otp_expired", "OTP expired")
 	}
 
 	// Check if max attempts exceeded
 	if otpRecord.Attempts >= otpRecord.MaxAttempts {
-		_ = s.repo.DeleteOTPByID(otpRecord.ID)
-		return 0, errors.New("too many failed attempts, OTP locked")
+		_ = s.repo.DeleteOTPByID(ctx, otpRecord.ID)
+		return 0, apperr.RateLimited("otp_locked", "too many failed attempts, OTP locked")
 	}
 
 	// Compare OTP (bcrypt comparison)
@@ -101,17 +132,17 @@ func (s *otpService) VerifyOTP(email string, otpCode string, maxAttempts int) (i
 	if err != nil {
 		// Increment attempts
 		otpRecord.Attempts++
-		_ = s.repo.UpdateOTPAttempts(otpRecord.ID, otpRecord.Attempts)
-		return 0, fmt.Errorf("invalid OTP (attempts: %d/%d)", otpRecord.Attempts, otpRecord.MaxAttempts)
+		_ = s.repo.UpdateOTPAttempts(ctx, otpRecord.ID, otpRecord.Attempts)
+		return 0, apperr.Unauthorized("invalid_otp", fmt.Sprintf("invalid OTP (attempts: %d/%d)", otpRecord.Attempts, otpRecord.MaxAttempts))
 	}
 
 	// OTP is valid, delete it from DB
-	_ = s.repo.DeleteOTPByID(otpRecord.ID)
+	_ = s.repo.DeleteOTPByID(ctx, otpRecord.ID)
 
 	return otpRecord.UserID, nil
 }
 
 // CleanupExpiredOTPs removes expired OTP records
-func (s *otpService) CleanupExpiredOTPs() error {
-	return s.repo.DeleteExpiredOTPs(time.Now())
+func (s *otpService) CleanupExpiredOTPs(ctx context.Context) error {
+	return s.repo.DeleteExpiredOTPs(ctx, time.Now())
 }
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"rentor/internal/logger"
+	"rentor/internal/repository"
+)
+
+// purgeService permanently removes soft-deleted advertisements and users together with their image files
+type purgeService struct {
+	adRepo    repository.AdRepository
+	userRepo  repository.UserRepository
+	imageSvc  ImageService
+	retention time.Duration
+}
+
+// NewPurgeService creates a new purge service. Records deleted longer than retention ago are purged,
+// a non-positive retention keeps deleted records forever.
+func NewPurgeService(adRepo repository.AdRepository, userRepo repository.UserRepository, imageSvc ImageService, retention time.Duration) PurgeService {
+	return &purgeService{
+		adRepo:    adRepo,
+		userRepo:  userRepo,
+		imageSvc:  imageSvc,
+		retention: retention,
+	}
+}
+
+// PurgeDeleted purges advertisements and users deleted before the retention cutoff.
+// A record that fails to purge is logged and retried on the next run, the others are still purged.
+func (s *purgeService) PurgeDeleted(ctx context.Context) error {
+	if s.retention <= 0 {
+		return nil
+	}
+
+	log := logger.With(logger.Field("component", "purge"))
+	cutoff := time.Now().UTC().Add(-s.retention)
+
+	var errs []error
+	purgedAds, purgedUsers, removedFiles := 0, 0, 0
+
+	ads, err := s.adRepo.GetAdvertisementsDeletedBefore(ctx, cutoff)
+	if err != nil {
+		return err
+	}
+	for _, ad := range ads {
+		paths, err := s.adRepo.PurgeAdvertisement(ctx, ad.ID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("advertisement %d: %w", ad.ID, err))
+			continue
+		}
+		purgedAds++
+		removedFiles += s.removeFiles(paths)
+	}
+
+	users, err := s.userRepo.GetUsersDeletedBefore(ctx, cutoff)
+	if err != nil {
+		return errors.Join(append(errs, err)...)
+	}
+	for _, user := range users {
+		paths, err := s.userRepo.PurgeUserByID(ctx, user.ID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("user %d: %w", user.ID, err))
+			continue
+		}
+		purgedUsers++
+		removedFiles += s.removeFiles(paths)
+	}
+
+	if purgedAds > 0 || purgedUsers > 0 {
+		log.Info("deleted records purged",
+			logger.Field("advertisements", purgedAds),
+			logger.Field("users", purgedUsers),
+			logger.Field("files", removedFiles),
+		)
+	}
+
+	return errors.Join(errs...)
+}
+
+// removeFiles deletes image files of purged photos. The rows are already gone, so a file that
+// can't be removed is only logged; the image reconcile treats it as an orphan later.
+func (s *purgeService) removeFiles(paths []string) int {
+	removed := 0
+	for _, path := range paths {
+		if err := s.imageSvc.DeleteImage(path); err != nil {
+			logger.Warn("failed to remove image of purged record", logger.Field("path", path), logger.Field("error", err.Error()))
+			continue
+		}
+		removed++
+	}
+	return removed
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"rentor/internal/logger"
+	"rentor/internal/models"
+	"rentor/internal/money"
+	"rentor/internal/repository"
+)
+
+// weights of the similar listing score components; candidates are always in the same city
+const (
+	similarTypeWeight     = 0.3
+	similarRoomsWeight    = 0.25
+	similarPriceWeight    = 0.2
+	similarSquareWeight   = 0.1
+	similarDistanceWeight = 0.15
+)
+
+// earthRadiusKm is used to compute distances between advertisement coordinates
+const earthRadiusKm = 6371.0
+
+// recommendationService recommends similar listings and keeps the recently viewed advertisements
+type recommendationService struct {
+	repo                repository.RecommendationRepository
+	adService           AdvertisementService
+	priceBand           float64
+	maxDistanceKm       float64
+	recentlyViewedLimit int
+	recentlyViewedTTL   time.Duration
+}
+
+// NewRecommendationService creates a new recommendation service.
+// Similar listings cost within ±priceBand (a fraction) of the price; the location score drops to zero at maxDistanceKm.
+// recentlyViewedLimit advertisements are kept per viewer for recentlyViewedTTL.
+func NewRecommendationService(repo repository.RecommendationRepository, adService AdvertisementService, priceBand, maxDistanceKm float64, recentlyViewedLimit int, recentlyViewedTTL time.Duration) RecommendationService {
+	return &recommendationService{
+		repo:                repo,
+		adService:           adService,
+		priceBand:           priceBand,
+		maxDistanceKm:       maxDistanceKm,
+		recentlyViewedLimit: recentlyViewedLimit,
+		recentlyViewedTTL:   recentlyViewedTTL,
+	}
+}
+
+// GetSimilar returns up to limit active advertisements most similar to adID
+func (s *recommendationService) GetSimilar(ctx context.Context, adID, limit int) (*models.SimilarAdvertisementsList, error) {
+	ad, err := s.repo.GetListingFeatures(ctx, adID)
+	if err != nil {
+		return nil, err
+	}
+
+	// only listings priced in the same currency and period are compared
+	price := ad.Price.Major()
+	candidates, err := s.repo.GetSimilarCandidates(ctx, ad, money.FromMajor(price*(1-s.priceBand)), money.FromMajor(price*(1+s.priceBand)))
+	if err != nil {
+		return nil, err
+	}
+
+	scored := make([]*models.SimilarAdvertisement, 0, len(candidates))
+	for _, c := range candidates {
+		scored = append(scored, s.score(ad, c))
+	}
+
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].Score > scored[j].Score
+	})
+	if len(scored) > limit {
+		scored = scored[:limit]
+	}
+
+	ids := make([]int, len(scored))
+	for i, item := range scored {
+		ids[i] = item.ID
+	}
+	previews, err := s.adService.GetAdvertisementPreviews(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := map[int]models.AdPreview{}
+	for _, p := range previews {
+		byID[p.ID] = p
+	}
+
+	list := &models.SimilarAdvertisementsList{
+		AdvertisementID: adID,
+		Items:           []*models.SimilarAdvertisement{},
+	}
+	for _, item := range scored {
+		// the advertisement may have been deleted between the two queries
+		if preview, ok := byID[item.ID]; ok {
+			item.AdPreview = preview
+			list.Items = append(list.Items, item)
+		}
+	}
+
+	return list, nil
+}
+
+// score compares candidate with ad. Components that can't be compared (no square or coordinates on
+// one side) are left out and the score is normalized over the remaining weights.
+func (s *recommendationService) score(ad, candidate *models.ListingFeatures) *models.SimilarAdvertisement {
+	item := &models.SimilarAdvertisement{AdPreview: models.AdPreview{ID: candidate.ID}}
+
+	total, weights := 0.0, similarTypeWeight+similarRoomsWeight+similarPriceWeight
+
+	if strings.EqualFold(strings.TrimSpace(ad.Type), strings.TrimSpace(candidate.Type)) {
+		total += similarTypeWeight
+	}
+	if strings.EqualFold(strings.TrimSpace(ad.Rooms), strings.TrimSpace(candidate.Rooms)) {
+		total += similarRoomsWeight
+	}
+
+	if band := ad.Price.Major() * s.priceBand; band > 0 {
+		total += similarPriceWeight * math.Max(0, 1-math.Abs((candidate.Price-ad.Price).Major())/band)
+	} else if candidate.Price == ad.Price {
+		total += similarPriceWeight
+	}
+
+	if ad.Square > 0 && candidate.Square > 0 {
+		total += similarSquareWeight * math.Min(ad.Square, candidate.Square) / math.Max(ad.Square, candidate.Square)
+		weights += similarSquareWeight
+	}
+
+	if ad.Latitude != nil && ad.Longitude != nil && candidate.Latitude != nil && candidate.Longitude != nil {
+		distance := haversineKm(*ad.Latitude, *ad.Longitude, *candidate.Latitude, *candidate.Longitude)
+		item.DistanceKm = &distance
+		if s.maxDistanceKm > 0 {
+			total += similarDistanceWeight * math.Max(0, 1-distance/s.maxDistanceKm)
+			weights += similarDistanceWeight
+		}
+	}
+
+	item.Score = total / weights
+	return item
+}
+
+// RecordView remembers that viewer (see GetRecentlyViewed) opened the advertisement
+func (s *recommendationService) RecordView(ctx context.Context, viewer string, adID int) error {
+	return s.repo.RecordRecentlyViewed(ctx, viewer, adID, time.Now().UTC(), s.recentlyViewedLimit)
+}
+
+// GetRecentlyViewed returns the advertisements last opened by viewer, last viewed first.
+// viewer is "user:<id>" for signed-in users and "visitor:<cookie>" for anonymous visitors.
+func (s *recommendationService) GetRecentlyViewed(ctx context.Context, viewer string) (*models.RecentlyViewedList, error) {
+	ids, err := s.repo.GetRecentlyViewedIDs(ctx, viewer, s.recentlyViewedLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	previews, err := s.adService.GetAdvertisementPreviews(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	if previews == nil {
+		previews = []models.AdPreview{}
+	}
+
+	return &models.RecentlyViewedList{Items: previews}, nil
+}
+
+// CleanupRecentlyViewed deletes views older than the TTL, mostly left behind by visitors who never came back
+func (s *recommendationService) CleanupRecentlyViewed(ctx context.Context) error {
+	if s.recentlyViewedTTL <= 0 {
+		return nil
+	}
+
+	deleted, err := s.repo.DeleteRecentlyViewedBefore(ctx, time.Now().UTC().Add(-s.recentlyViewedTTL))
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		logger.Info("expired recently viewed entries deleted", logger.Field("component", "recommendations"), logger.Field("deleted", deleted))
+	}
+	return nil
+}
+
+// haversineKm returns the great-circle distance between two points in kilometers
+func haversineKm(lat1, lon1, lat2, lon2 float64) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRad(lat2 - lat1)
+	dLon := toRad(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
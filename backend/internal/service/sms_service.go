@@ -0,0 +1,61 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"rentor/internal/logger"
+)
+
+// smsService sends text messages through an HTTP gateway
+type smsService struct {
+	gatewayURL string
+	token      string
+	client     *http.Client
+}
+
+// NewSMSService creates a new SMS service. Messages are POSTed as {"to","text"} JSON to gatewayURL with
+// token as a bearer token; without a gateway (development) they are only written to the log.
+func NewSMSService(gatewayURL, token string) SMSService {
+	return &smsService{
+		gatewayURL: gatewayURL,
+		token:      token,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// SendSMS sends text to the phone number to
+func (s *smsService) SendSMS(to, text string) error {
+	if s.gatewayURL == "" {
+		logger.Info("SMS gateway is not configured, message not sent", logger.Field("to", to), logger.Field("text", text))
+		return nil
+	}
+
+	body, err := json.Marshal(map[string]string{"to": to, "text": text})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.gatewayURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("SMS gateway responded with %s", resp.Status)
+	}
+	return nil
+}
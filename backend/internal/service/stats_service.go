@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"rentor/internal/apperr"
+	"rentor/internal/logger"
+	"rentor/internal/models"
+	"rentor/internal/repository"
+)
+
+// statsKey identifies a buffered counter: events are counted per advertisement and UTC day
+type statsKey struct {
+	adID int
+	day  string
+}
+
+// statsService counts impressions, views, favorites and contact reveals of advertisements.
+// Events are only added to in-memory counters; Flush writes them to the DB in one batch,
+// so recording an event never costs a query on the request path.
+type statsService struct {
+	repo          repository.StatsRepository
+	adRepo        repository.AdRepository
+	dashboardDays int
+	maxBuffered   int
+
+	mu      sync.Mutex
+	pending map[statsKey]*models.AdvertisementCounters
+	dropped int // events lost because the buffer was full, reported by the next flush
+
+	flushMu  sync.Mutex  // one flush at a time
+	flushing atomic.Bool // an early flush is running
+}
+
+// NewStatsService creates a new statistics service. dashboardDays is the period summed up in the
+// landlord dashboard. The buffer holds at most maxBuffered counters (advertisement × day): a flush
+// starts early when it is half full and events of new counters are dropped when it is full.
+func NewStatsService(repo repository.StatsRepository, adRepo repository.AdRepository, dashboardDays, maxBuffered int) StatsService {
+	return &statsService{
+		repo:          repo,
+		adRepo:        adRepo,
+		dashboardDays: dashboardDays,
+		maxBuffered:   maxBuffered,
+		pending:       map[statsKey]*models.AdvertisementCounters{},
+	}
+}
+
+// RecordImpressions counts an impression of each advertisement shown in search results
+func (s *statsService) RecordImpressions(adIDs []int) {
+	for _, id := range adIDs {
+		s.record(id, models.StatsEventImpression)
+	}
+}
+
+// RecordView counts an opened detail page
+func (s *statsService) RecordView(adID int) {
+	s.record(adID, models.StatsEventView)
+}
+
+// RecordFavorite counts adding the advertisement to favorites
+func (s *statsService) RecordFavorite(adID int) {
+	s.record(adID, models.StatsEventFavorite)
+}
+
+// RecordContact counts a landlord contact reveal
+func (s *statsService) RecordContact(adID int) {
+	s.record(adID, models.StatsEventContact)
+}
+
+func (s *statsService) record(adID int, event string) {
+	key := statsKey{adID: adID, day: time.Now().UTC().Format(time.DateOnly)}
+
+	s.mu.Lock()
+	c, ok := s.pending[key]
+	if !ok {
+		if s.maxBuffered > 0 && len(s.pending) >= s.maxBuffered {
+			s.dropped++
+			s.mu.Unlock()
+			return
+		}
+		c = &models.AdvertisementCounters{}
+		s.pending[key] = c
+	}
+	c.Add(event, 1)
+	full := s.maxBuffered > 0 && len(s.pending) >= s.maxBuffered/2
+	s.mu.Unlock()
+
+	if full && s.flushing.CompareAndSwap(false, true) {
+		go func() {
+			defer s.flushing.Store(false)
+			if err := s.Flush(context.Background()); err != nil {
+				logger.Error("early stats flush failed", logger.Field("component", "stats"), logger.Field("error", err.Error()))
+			}
+		}()
+	}
+}
+
+// Flush writes the buffered counters to the DB. If the write fails the counters are put back
+// into the buffer and written by the next flush.
+func (s *statsService) Flush(ctx context.Context) error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	s.mu.Lock()
+	pending, dropped := s.pending, s.dropped
+	s.pending = map[statsKey]*models.AdvertisementCounters{}
+	s.dropped = 0
+	s.mu.Unlock()
+
+	if dropped > 0 {
+		logger.Warn("stats buffer was full, events dropped", logger.Field("component", "stats"), logger.Field("dropped", dropped))
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	batch := make([]*models.AdvertisementDailyCounters, 0, len(pending))
+	for key, c := range pending {
+		batch = append(batch, &models.AdvertisementDailyCounters{
+			AdvertisementID:       key.adID,
+			Day:                   key.day,
+			AdvertisementCounters: *c,
+		})
+	}
+
+	if err := s.repo.IncrementAdvertisementStats(ctx, batch); err != nil {
+		s.mu.Lock()
+		for key, c := range pending {
+			if cur, ok := s.pending[key]; ok {
+				cur.Merge(c)
+			} else {
+				s.pending[key] = c
+			}
+		}
+		s.mu.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+// GetAdvertisementStats returns the statistics of an advertisement for the last days to its owner.
+// Events still in the buffer are not included.
+func (s *statsService) GetAdvertisementStats(ctx context.Context, userID, adID, days int) (*models.AdvertisementStats, error) {
+	owner, err := s.adRepo.GetUserID(ctx, adID)
+	if err != nil {
+		return nil, err
+	}
+	if userID != owner {
+		return nil, apperr.ErrNotOwner
+	}
+
+	daily, err := s.repo.GetAdvertisementDailyStats(ctx, adID, statsSince(days))
+	if err != nil {
+		return nil, err
+	}
+
+	favoritedBy, err := s.adRepo.CountFavorites(ctx, adID)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &models.AdvertisementStats{
+		AdvertisementID: adID,
+		Days:            days,
+		FavoritedBy:     favoritedBy,
+		Daily:           daily,
+	}
+	if stats.Daily == nil {
+		stats.Daily = []*models.AdvertisementDailyCounters{}
+	}
+	for _, d := range daily {
+		stats.Totals.Merge(&d.AdvertisementCounters)
+	}
+	stats.ViewRate, stats.ContactRate = statsRates(&stats.Totals)
+
+	return stats, nil
+}
+
+// GetDashboard returns the summary over all advertisements of a landlord for the dashboard period
+func (s *statsService) GetDashboard(ctx context.Context, userID int) (*models.LandlordDashboard, error) {
+	totals, err := s.repo.GetUserTotals(ctx, userID, statsSince(s.dashboardDays))
+	if err != nil {
+		return nil, err
+	}
+
+	dashboard := &models.LandlordDashboard{
+		Days:   s.dashboardDays,
+		Totals: *totals,
+	}
+	dashboard.ViewRate, dashboard.ContactRate = statsRates(totals)
+
+	return dashboard, nil
+}
+
+// GetDashboardCounters returns the counters of each advertisement for the dashboard period.
+// Advertisements without events get zero counters.
+func (s *statsService) GetDashboardCounters(ctx context.Context, adIDs []int) (map[int]*models.AdvertisementCounters, error) {
+	totals, err := s.repo.GetAdvertisementTotals(ctx, adIDs, statsSince(s.dashboardDays))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range adIDs {
+		if _, ok := totals[id]; !ok {
+			totals[id] = &models.AdvertisementCounters{}
+		}
+	}
+	return totals, nil
+}
+
+// statsSince returns the first day of a period of days ending today
+func statsSince(days int) time.Time {
+	return time.Now().UTC().AddDate(0, 0, -days+1)
+}
+
+// statsRates returns views per impression and contact reveals per view (0 when there is nothing to divide by)
+func statsRates(c *models.AdvertisementCounters) (viewRate, contactRate float64) {
+	if c.Impressions > 0 {
+		viewRate = float64(c.Views) / float64(c.Impressions)
+	}
+	if c.Views > 0 {
+		contactRate = float64(c.Contacts) / float64(c.Views)
+	}
+	return viewRate, contactRate
+}
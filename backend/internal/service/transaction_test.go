@@ -0,0 +1,191 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"rentor/internal/models"
+	"rentor/internal/repository"
+	"rentor/internal/service"
+	"rentor/internal/storage"
+	"rentor/internal/storage/storagetest"
+)
+
+var errInjected = errors.New("injected failure")
+
+// injectingTx runs transactions of the wrapped TxManager with repositories changed by inject
+type injectingTx struct {
+	repository.TxManager
+	inject func(repos *repository.Repositories)
+}
+
+func (m injectingTx) WithinTx(ctx context.Context, fn func(repos *repository.Repositories) error) error {
+	return m.TxManager.WithinTx(ctx, func(repos *repository.Repositories) error {
+		m.inject(repos)
+		return fn(repos)
+	})
+}
+
+// failingProfiles fails every profile write after the steps before it have run
+type failingProfiles struct {
+	repository.UserProfileRepository
+}
+
+func (failingProfiles) CreateUserProfile(ctx context.Context, profile *models.UserProfile) (int, error) {
+	return 0, errInjected
+}
+
+func (failingProfiles) UpdateUserProfile(ctx context.Context, id int, profile *models.UserProfile) error {
+	return errInjected
+}
+
+// failProfileWrites is an inject func of injectingTx
+func failProfileWrites(repos *repository.Repositories) {
+	repos.UserProfile = failingProfiles{repos.UserProfile}
+}
+
+// openRepositories opens a migrated SQLite database
+func openRepositories(t *testing.T) (repository.DBTX, *repository.Repositories) {
+	t.Helper()
+	db := storagetest.OpenSQLite(t, storagetest.SQLiteOptions())
+	conn := repository.NewDB(db.DB, db.Read, repository.Dialect(storage.DriverSQLite))
+	return conn, repository.NewRepositories(conn)
+}
+
+// failPhotoInsert makes the database reject photos whose URL ends with suffix
+func failPhotoInsert(t *testing.T, conn repository.DBTX, suffix string) {
+	t.Helper()
+	_, err := conn.ExecContext(context.Background(), fmt.Sprintf(`
+        CREATE TRIGGER fail_photo_insert BEFORE INSERT ON advertisement_photos
+        WHEN NEW.photo_url LIKE '%%%s'
+        BEGIN SELECT RAISE(ABORT, 'injected failure'); END
+    `, suffix))
+	if err != nil {
+		t.Fatalf("create trigger: %v", err)
+	}
+}
+
+// isInjected reports whether err comes from the trigger of failPhotoInsert
+func isInjected(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "injected failure")
+}
+
+// countRows runs a SELECT COUNT(*) query
+func countRows(t *testing.T, conn repository.DBTX, query string, args ...any) int {
+	t.Helper()
+	var n int
+	if err := conn.QueryRowContext(context.Background(), query, args...).Scan(&n); err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	return n
+}
+
+// createOwner creates a user with an advertisement
+func createOwner(t *testing.T, repos *repository.Repositories) (userID, adID int) {
+	t.Helper()
+	ctx := context.Background()
+	userID, err := repos.User.CreateUser(ctx, "", "owner@example.com")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	adID, err = repos.Advertisement.CreateAdvertisement(ctx, userID, &models.CreateAdvertisementInput{
+		Title: "Flat", Price: 10000000, Currency: "KZT", PricePeriod: "month",
+		Type: "apartment", Rooms: "1", City: "Almaty", Address: "Abay 1", Square: 30,
+	})
+	if err != nil {
+		t.Fatalf("create advertisement: %v", err)
+	}
+	return userID, adID
+}
+
+func TestCreateUserRollsBackOnProfileFailure(t *testing.T) {
+	conn, repos := openRepositories(t)
+	ctx := context.Background()
+	users := service.NewUserService(repos.User, repos.UserProfile,
+		injectingTx{repository.NewTxManager(conn), failProfileWrites})
+
+	phone := "+77011234567"
+	if _, err := users.RegisterUser(ctx, &models.CreateUserInput{Email: "new@example.com", Phone: &phone}); !errors.Is(err, errInjected) {
+		t.Errorf("RegisterUser: err = %v, want %v", err, errInjected)
+	}
+	if _, err := users.FindOrCreateUserByEmail(ctx, "other@example.com"); !errors.Is(err, errInjected) {
+		t.Errorf("FindOrCreateUserByEmail: err = %v, want %v", err, errInjected)
+	}
+
+	if n := countRows(t, conn, "SELECT COUNT(*) FROM \"user\""); n != 0 {
+		t.Errorf("%d users were kept without a profile", n)
+	}
+	if n := countRows(t, conn, "SELECT COUNT(*) FROM user_profile"); n != 0 {
+		t.Errorf("%d profiles were kept", n)
+	}
+}
+
+func TestUpdateUserProfileRollsBackOnProfileFailure(t *testing.T) {
+	conn, repos := openRepositories(t)
+	ctx := context.Background()
+	userID, err := service.NewUserService(repos.User, repos.UserProfile, repository.NewTxManager(conn)).
+		RegisterUser(ctx, &models.CreateUserInput{Email: "user@example.com", Phone: strPtr("+77011234567")})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	profiles := service.NewUserProfileService(repos.User, repos.UserProfile,
+		injectingTx{repository.NewTxManager(conn), failProfileWrites})
+
+	// the phone is removed from the user before the profile is written, by a full update and by a patch
+	name := "Aigerim"
+	if _, err := profiles.UpdateUserProfile(ctx, userID, &models.UpdateUserProfileInput{FirstName: &name}, nil); !errors.Is(err, errInjected) {
+		t.Errorf("UpdateUserProfile: err = %v, want %v", err, errInjected)
+	}
+	expectProfileUnchanged(t, repos, userID)
+	patch := []byte(`{"first_name": "Aigerim", "phone_number": null}`)
+	if _, err := profiles.PatchUserProfile(ctx, userID, patch, nil); !errors.Is(err, errInjected) {
+		t.Errorf("PatchUserProfile: err = %v, want %v", err, errInjected)
+	}
+	expectProfileUnchanged(t, repos, userID)
+}
+
+// expectProfileUnchanged checks that a failed update kept the phone, the profile and its version of a new user
+func expectProfileUnchanged(t *testing.T, repos *repository.Repositories, userID int) {
+	t.Helper()
+	ctx := context.Background()
+	user, err := repos.User.GetUserByID(ctx, userID)
+	if err != nil {
+		t.Fatalf("get user: %v", err)
+	}
+	if user.Phone == nil {
+		t.Error("the phone of the user was removed although the profile was not updated")
+	}
+	profile, err := repos.UserProfile.GetUserProfileByUserID(ctx, userID)
+	if err != nil {
+		t.Fatalf("get profile: %v", err)
+	}
+	if profile.FirstName != nil || profile.Version != 1 {
+		t.Errorf("profile = %+v, want the profile before the update at version 1", profile)
+	}
+}
+
+func TestAddImagesRollsBackOnImageFailure(t *testing.T) {
+	conn, repos := openRepositories(t)
+	ctx := context.Background()
+	userID, adID := createOwner(t, repos)
+	ads := service.NewadvertisementService(repos.Advertisement, repository.NewTxManager(conn), nil, time.Minute, time.Hour, nil)
+
+	// the first photo is inserted before the second one fails
+	failPhotoInsert(t, conn, "/bad.png")
+	images := []models.SavedImage{{URL: "/static/good.png"}, {URL: "/static/bad.png"}}
+	if _, err := ads.AddImages(ctx, userID, adID, images); !isInjected(err) {
+		t.Fatalf("AddImages: err = %v, want the injected failure", err)
+	}
+
+	if n := countRows(t, conn, "SELECT COUNT(*) FROM advertisement_photos WHERE advertisement_id = ?", adID); n != 0 {
+		t.Errorf("%d photos were linked by the failed call", n)
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
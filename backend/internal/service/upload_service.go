@@ -0,0 +1,348 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"rentor/internal/apperr"
+	"rentor/internal/logger"
+	"rentor/internal/models"
+	"rentor/internal/repository"
+)
+
+var (
+	ErrUploadTooLarge       = apperr.New(apperr.KindTooLarge, "upload_too_large", "upload exceeds size limit")
+	ErrUploadTypeNotAllowed = apperr.New(apperr.KindUnsupportedType, "content_type_not_allowed", "content type not allowed")
+	ErrUploadSessionClosed  = apperr.New(apperr.KindGone, "upload_session_closed", "upload session expired or already committed")
+)
+
+// imageExtensions maps allowed MIME types to file extensions of stored images
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+	"image/gif":  ".gif",
+}
+
+type uploadService struct {
+	uploadRepo   repository.UploadRepository
+	adRepo       repository.AdRepository
+	txManager    repository.TxManager
+	adService    AdvertisementService
+	imageSvc     ImageService
+	stagingPath  string
+	maxFileSize  int64
+	maxFiles     int
+	allowedTypes []string
+	sessionTTL   time.Duration
+}
+
+// NewUploadService creates a new upload session service
+func NewUploadService(uploadRepo repository.UploadRepository, adRepo repository.AdRepository, txManager repository.TxManager, adService AdvertisementService,
+	imageSvc ImageService, stagingPath string, maxFileSize int64, maxFiles int, allowedTypes []string, sessionTTL time.Duration) UploadService {
+	return &uploadService{
+		uploadRepo:   uploadRepo,
+		adRepo:       adRepo,
+		txManager:    txManager,
+		adService:    adService,
+		imageSvc:     imageSvc,
+		stagingPath:  stagingPath,
+		maxFileSize:  maxFileSize,
+		maxFiles:     maxFiles,
+		allowedTypes: allowedTypes,
+		sessionTTL:   sessionTTL,
+	}
+}
+
+// CreateSession opens an upload session and returns pre-authorized upload URLs
+func (s *uploadService) CreateSession(ctx context.Context, userID, adID int, input *models.CreateUploadSessionInput) (*models.UploadSessionResponse, error) {
+	owner, err := s.adRepo.GetUserID(ctx, adID)
+	if err != nil {
+		return nil, err
+	}
+	if userID != owner {
+		return nil, apperr.ErrNotOwner
+	}
+
+	if len(input.Files) == 0 {
+		return nil, apperr.Invalid("no_files", "no files requested")
+	}
+	if len(input.Files) > s.maxFiles {
+		return nil, apperr.Invalid("too_many_files", fmt.Sprintf("too many files: at most %d per session", s.maxFiles))
+	}
+
+	session := &models.UploadSession{
+		AdvertisementID: adID,
+		UserID:          userID,
+		Status:          models.UploadSessionPending,
+		ExpiresAt:       time.Now().Add(s.sessionTTL),
+	}
+
+	for _, f := range input.Files {
+		contentType, err := s.checkContentType(f.ContentType)
+		if err != nil {
+			return nil, err
+		}
+		if f.Size > s.maxFileSize {
+			return nil, ErrUploadTooLarge
+		}
+
+		token, err := generateUploadToken()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate upload token: %w", err)
+		}
+
+		session.Objects = append(session.Objects, models.UploadObject{
+			Token:       token,
+			Filename:    token + imageExtensions[contentType],
+			ContentType: contentType,
+			MaxSize:     s.maxFileSize,
+		})
+	}
+
+	sessionID, err := s.uploadRepo.CreateUploadSession(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &models.UploadSessionResponse{
+		SessionID: sessionID,
+		ExpiresAt: session.ExpiresAt,
+		CommitURL: fmt.Sprintf("/advertisements/%d/images/uploads/%d/commit", adID, sessionID),
+	}
+	for _, obj := range session.Objects {
+		resp.Uploads = append(resp.Uploads, models.UploadTarget{
+			UploadID:  obj.ID,
+			UploadURL: "/uploads/" + obj.Token,
+			Method:    http.MethodPut,
+			Headers:   map[string]string{"Content-Type": obj.ContentType},
+			Policy: models.UploadPolicy{
+				MaxSize:      obj.MaxSize,
+				AllowedTypes: []string{obj.ContentType},
+			},
+		})
+	}
+
+	return resp, nil
+}
+
+// Upload stores the body of a single upload in the staging directory
+func (s *uploadService) Upload(ctx context.Context, token, contentType string, body io.Reader) error {
+	obj, err := s.uploadRepo.GetUploadObjectByToken(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	session, err := s.uploadRepo.GetUploadSessionByID(ctx, obj.SessionID)
+	if err != nil {
+		return err
+	}
+	if session.Status != models.UploadSessionPending || time.Now().After(session.ExpiresAt) {
+		return ErrUploadSessionClosed
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != obj.ContentType {
+		return ErrUploadTypeNotAllowed
+	}
+
+	if err := os.MkdirAll(s.stagingPath, 0o755); err != nil {
+		return err
+	}
+
+	// the body is written next to the staged file and renamed over it: a repeated upload replaces the file
+	// instead of rewriting it, so a copy a running commit has already stored is never changed
+	stagedPath := filepath.Join(s.stagingPath, obj.Filename)
+	dst, err := os.CreateTemp(s.stagingPath, obj.Filename+".*.part")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(dst.Name())
+
+	// read one byte past the limit so oversized bodies can be detected
+	n, err := io.Copy(dst, io.LimitReader(body, obj.MaxSize+1))
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if n > obj.MaxSize {
+		return ErrUploadTooLarge
+	}
+	if n == 0 {
+		return apperr.Invalid("empty_upload", "empty upload")
+	}
+	if err := os.Rename(dst.Name(), stagedPath); err != nil {
+		return err
+	}
+
+	return s.uploadRepo.MarkUploadObjectUploaded(ctx, obj.ID, n, time.Now())
+}
+
+// CommitSession verifies uploaded files and links them to the advertisement
+func (s *uploadService) CommitSession(ctx context.Context, userID, adID, sessionID int) (*models.ImagesUploadResponse, error) {
+	session, err := s.uploadRepo.GetUploadSessionByID(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.AdvertisementID != adID || session.UserID != userID {
+		return nil, apperr.ErrNotOwner
+	}
+	if session.Status != models.UploadSessionPending || time.Now().After(session.ExpiresAt) {
+		return nil, ErrUploadSessionClosed
+	}
+
+	var uploaded []models.UploadObject
+	for _, obj := range session.Objects {
+		if obj.UploadedAt != nil {
+			uploaded = append(uploaded, obj)
+		}
+	}
+	if len(uploaded) == 0 {
+		return nil, apperr.Invalid("no_files", "no files uploaded")
+	}
+
+	// the files are checked and stored before the transaction, so the only writer connection of SQLite
+	// is not held while they are read and hashed. Storing keeps the staged files: a failed commit only
+	// discards the stored copies and can be retried.
+	images := make([]models.SavedImage, 0, len(uploaded))
+	for _, obj := range uploaded {
+		if err := s.verifyObject(&obj); err != nil {
+			s.discardImages(images)
+			return nil, fmt.Errorf("upload %d: %w", obj.ID, err)
+		}
+		image, err := s.imageSvc.StoreAdvertisementImage(adID, filepath.Join(s.stagingPath, obj.Filename))
+		if err != nil {
+			s.discardImages(images)
+			return nil, err
+		}
+		images = append(images, image)
+	}
+
+	// the session is closed in the transaction that links the photos:
+	// of concurrent commits only the first one links them, the others get a conflict
+	err = s.txManager.WithinTx(ctx, func(repos *repository.Repositories) error {
+		if err := repos.Upload.MarkUploadSessionCommitted(ctx, session.ID, time.Now()); err != nil {
+			return err
+		}
+		owner, err := repos.Advertisement.GetUserID(ctx, adID)
+		if err != nil {
+			return err
+		}
+		if owner != userID {
+			return apperr.ErrNotOwner
+		}
+		return repos.Advertisement.CreateAdvertisementImages(ctx, adID, images)
+	})
+	if err != nil {
+		s.discardImages(images)
+		return nil, err
+	}
+	s.removeStaged(uploaded)
+
+	// committed photos are screened like multipart uploads
+	return s.adService.ScreenImages(ctx, userID, adID, images), nil
+}
+
+// CleanupExpiredSessions removes expired sessions and their staged files
+func (s *uploadService) CleanupExpiredSessions(ctx context.Context) error {
+	sessions, err := s.uploadRepo.GetExpiredUploadSessions(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+
+	removed := 0
+	for _, session := range sessions {
+		// committed sessions normally have no staged files left, unless removing them after the commit failed
+		s.removeStaged(session.Objects)
+		if err := s.uploadRepo.DeleteUploadSessionByID(ctx, session.ID); err != nil {
+			return err
+		}
+		removed++
+	}
+
+	if removed > 0 {
+		logger.Info("expired upload sessions removed", logger.Field("count", removed))
+	}
+
+	return nil
+}
+
+// verifyObject checks that the staged file matches what was uploaded and declared
+func (s *uploadService) verifyObject(obj *models.UploadObject) error {
+	f, err := os.Open(filepath.Join(s.stagingPath, obj.Filename))
+	if err != nil {
+		return apperr.Invalid("upload_incomplete", "uploaded file is missing")
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if obj.Size == nil || info.Size() != *obj.Size {
+		return apperr.Invalid("upload_incomplete", "uploaded file size mismatch")
+	}
+	if info.Size() > obj.MaxSize {
+		return ErrUploadTooLarge
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	if http.DetectContentType(head[:n]) != obj.ContentType {
+		return ErrUploadTypeNotAllowed
+	}
+
+	return nil
+}
+
+// checkContentType normalizes a declared content type and checks it against the policy
+func (s *uploadService) checkContentType(contentType string) (string, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", ErrUploadTypeNotAllowed
+	}
+	if _, ok := imageExtensions[mediaType]; !ok || !slices.Contains(s.allowedTypes, mediaType) {
+		return "", ErrUploadTypeNotAllowed
+	}
+	return mediaType, nil
+}
+
+// discardImages removes already stored images after a failed commit
+func (s *uploadService) discardImages(images []models.SavedImage) {
+	for _, image := range images {
+		_ = s.imageSvc.DeleteImage(image.URL)
+	}
+}
+
+// removeStaged removes the staged files of objects, files that are already gone are skipped
+func (s *uploadService) removeStaged(objects []models.UploadObject) {
+	for _, obj := range objects {
+		err := os.Remove(filepath.Join(s.stagingPath, obj.Filename))
+		if err != nil && !os.IsNotExist(err) {
+			logger.Warn("failed to remove staged upload", logger.Field("error", err.Error()), logger.Field("file", obj.Filename))
+		}
+	}
+}
+
+// generateUploadToken returns a random hex token used as upload capability
+func generateUploadToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
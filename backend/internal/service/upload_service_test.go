@@ -0,0 +1,227 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"rentor/internal/models"
+	"rentor/internal/repository"
+	"rentor/internal/service"
+	"rentor/internal/storage"
+	"rentor/internal/storage/storagetest"
+)
+
+// slowImages stores images without touching the files, slowly enough for concurrent commits to overlap
+type slowImages struct {
+	service.ImageService
+	mu     sync.Mutex
+	stored int
+}
+
+func (i *slowImages) StoreAdvertisementImage(adID int, srcPath string) (models.SavedImage, error) {
+	time.Sleep(50 * time.Millisecond)
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.stored++
+	return models.SavedImage{URL: fmt.Sprintf("/static/ad_%d_%d.png", adID, i.stored)}, nil
+}
+
+func (i *slowImages) DeleteImage(path string) error {
+	return nil
+}
+
+// screenedImages answers ScreenImages without running the duplicate checks
+type screenedImages struct {
+	service.AdvertisementService
+}
+
+func (screenedImages) ScreenImages(ctx context.Context, userID, adID int, images []models.SavedImage) *models.ImagesUploadResponse {
+	return &models.ImagesUploadResponse{Count: len(images)}
+}
+
+// pngImage encodes a small PNG image
+func pngImage(t *testing.T) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for x := range 16 {
+		img.Set(x, x, color.White)
+	}
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return b.Bytes()
+}
+
+// stagedUpload creates an upload session for files PNG images of the advertisement and uploads them
+func stagedUpload(t *testing.T, uploads service.UploadService, userID, adID, files int) int {
+	t.Helper()
+	ctx := context.Background()
+	photo := pngImage(t)
+	specs := make([]models.UploadFileSpec, files)
+	for i := range specs {
+		specs[i] = models.UploadFileSpec{ContentType: "image/png", Size: int64(len(photo))}
+	}
+	session, err := uploads.CreateSession(ctx, userID, adID, &models.CreateUploadSessionInput{Files: specs})
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	for _, upload := range session.Uploads {
+		token := strings.TrimPrefix(upload.UploadURL, "/uploads/")
+		if err := uploads.Upload(ctx, token, "image/png", bytes.NewReader(photo)); err != nil {
+			t.Fatalf("upload: %v", err)
+		}
+	}
+	return session.SessionID
+}
+
+// countFiles returns the number of files in dir
+func countFiles(t *testing.T, dir string) int {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read %s: %v", dir, err)
+	}
+	return len(entries)
+}
+
+// TestCommitSessionCanBeRetried fails the insert of the second photo of a commit: nothing is linked,
+// the staged files are kept and the same session commits on the next try
+func TestCommitSessionCanBeRetried(t *testing.T) {
+	conn, repos := openRepositories(t)
+	ctx := context.Background()
+	userID, adID := createOwner(t, repos)
+
+	stagingDir, imagesDir := t.TempDir(), t.TempDir()
+	uploads := service.NewUploadService(repos.Upload, repos.Advertisement, repository.NewTxManager(conn), screenedImages{},
+		service.NewimageService(imagesDir, "/static/"), stagingDir, 1<<20, 5, []string{"image/png"}, time.Hour)
+	sessionID := stagedUpload(t, uploads, userID, adID, 2)
+
+	_, err := conn.ExecContext(ctx, `
+        CREATE TRIGGER fail_second_photo BEFORE INSERT ON advertisement_photos
+        WHEN (SELECT COUNT(*) FROM advertisement_photos WHERE advertisement_id = NEW.advertisement_id) > 0
+        BEGIN SELECT RAISE(ABORT, 'injected failure'); END
+    `)
+	if err != nil {
+		t.Fatalf("create trigger: %v", err)
+	}
+
+	if _, err := uploads.CommitSession(ctx, userID, adID, sessionID); !isInjected(err) {
+		t.Fatalf("CommitSession error = %v, want the injected failure", err)
+	}
+	session, err := repos.Upload.GetUploadSessionByID(ctx, sessionID)
+	if err != nil {
+		t.Fatalf("get session: %v", err)
+	}
+	if session.Status != models.UploadSessionPending {
+		t.Errorf("session status = %s after the failed commit, want %s", session.Status, models.UploadSessionPending)
+	}
+	if n := countRows(t, conn, "SELECT COUNT(*) FROM advertisement_photos WHERE advertisement_id = ?", adID); n != 0 {
+		t.Errorf("the failed commit left %d photos, want 0", n)
+	}
+	if n := countFiles(t, imagesDir); n != 0 {
+		t.Errorf("the failed commit left %d stored images, want 0", n)
+	}
+	if n := countFiles(t, stagingDir); n != 2 {
+		t.Errorf("%d staged files after the failed commit, want 2", n)
+	}
+
+	if _, err := conn.ExecContext(ctx, "DROP TRIGGER fail_second_photo"); err != nil {
+		t.Fatalf("drop trigger: %v", err)
+	}
+	resp, err := uploads.CommitSession(ctx, userID, adID, sessionID)
+	if err != nil {
+		t.Fatalf("retried commit: %v", err)
+	}
+	if resp.Count != 2 {
+		t.Errorf("retried commit linked %d photos, want 2", resp.Count)
+	}
+	if n := countRows(t, conn, "SELECT COUNT(*) FROM advertisement_photos WHERE advertisement_id = ?", adID); n != 2 {
+		t.Errorf("advertisement has %d photos, want 2", n)
+	}
+	if n := countFiles(t, imagesDir); n != 2 {
+		t.Errorf("%d stored images, want 2", n)
+	}
+	if n := countFiles(t, stagingDir); n != 0 {
+		t.Errorf("%d staged files left after the commit, want 0", n)
+	}
+}
+
+// TestCommitSessionLinksOnce commits one upload session many times at once:
+// the photos are linked exactly once, the other commits are rejected
+func TestCommitSessionLinksOnce(t *testing.T) {
+	const commits = 8
+
+	db := storagetest.OpenSQLite(t, storagetest.SQLiteOptions())
+	conn := repository.NewDB(db.DB, db.Read, repository.Dialect(storage.DriverSQLite))
+	repos := repository.NewRepositories(conn)
+	ctx := context.Background()
+
+	userID, err := repos.User.CreateUser(ctx, "", "owner@example.com")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	adID, err := repos.Advertisement.CreateAdvertisement(ctx, userID, &models.CreateAdvertisementInput{
+		Title: "Flat", Price: 10000000, Currency: "KZT", PricePeriod: "month",
+		Type: "apartment", Rooms: "1", City: "Almaty", Address: "Abay 1", Square: 30,
+	})
+	if err != nil {
+		t.Fatalf("create advertisement: %v", err)
+	}
+
+	images := &slowImages{}
+	uploads := service.NewUploadService(repos.Upload, repos.Advertisement, repository.NewTxManager(conn), screenedImages{}, images,
+		t.TempDir(), 1<<20, 5, []string{"image/png"}, time.Hour)
+
+	photo := pngImage(t)
+	session, err := uploads.CreateSession(ctx, userID, adID, &models.CreateUploadSessionInput{
+		Files: []models.UploadFileSpec{{ContentType: "image/png", Size: int64(len(photo))}},
+	})
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	token := strings.TrimPrefix(session.Uploads[0].UploadURL, "/uploads/")
+	if err := uploads.Upload(ctx, token, "image/png", bytes.NewReader(photo)); err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+
+	errs := make(chan error, commits)
+	var wg sync.WaitGroup
+	for range commits {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := uploads.CommitSession(ctx, userID, adID, session.SessionID)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		if err == nil {
+			succeeded++
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("%d of %d concurrent commits succeeded, want 1", succeeded, commits)
+	}
+
+	var photos int
+	if err := conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM advertisement_photos WHERE advertisement_id = ?", adID).Scan(&photos); err != nil {
+		t.Fatalf("count photos: %v", err)
+	}
+	if photos != 1 {
+		t.Errorf("advertisement has %d photos, want 1", photos)
+	}
+}
@@ -1,80 +1,136 @@
 package service
 
 import (
-	"errors"
+	"context"
+	"rentor/internal/apperr"
+	"rentor/internal/mergepatch"
 	"rentor/internal/models"
 	"rentor/internal/repository"
+	"rentor/internal/validation"
 )
 
 // userProfileService implements UserProfileService
 type userProfileService struct {
 	userProfileRepo repository.UserProfileRepository
 	userRepo        repository.UserRepository
+	txManager       repository.TxManager
 }
 
 // NewUserProfileService creates a new user profile service
-func NewUserProfileService(userRepo repository.UserRepository, userProfileRepo repository.UserProfileRepository) UserProfileService {
+func NewUserProfileService(userRepo repository.UserRepository, userProfileRepo repository.UserProfileRepository, txManager repository.TxManager) UserProfileService {
 	return &userProfileService{
 		userProfileRepo: userProfileRepo,
 		userRepo:        userRepo,
+		txManager:       txManager,
 	}
 }
 
 // GetUserProfile retrieves user profile
-func (s *userProfileService) GetUserProfile(userID int) (*models.UserProfile, error) {
-	profile, err := s.userProfileRepo.GetUserProfileByUserID(userID)
+func (s *userProfileService) GetUserProfile(ctx context.Context, userID int) (*models.UserProfile, error) {
+	profile, err := s.userProfileRepo.GetUserProfileByUserID(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
 	if profile == nil {
-		return nil, errors.New("user profile not found")
+		return nil, apperr.ErrProfileNotFound
 	}
 	return profile, nil
 }
 
-// UpdateUserProfile updates user profile
-func (s *userProfileService) UpdateUserProfile(userID int, input *models.UpdateUserProfileInput) error {
-	user, err := s.userRepo.GetUserByID(userID)
-	if err != nil {
-		return err
-	}
-	if user == nil {
-		return errors.New("user not found")
-	}
+// UpdateUserProfile replaces the profile and the phone number (PUT), omitted fields are cleared.
+// The phone number can only be kept or removed, a new one has to be verified first.
+// ifMatch is the profile version the client has seen (If-Match), nil skips the check. Returns the new version.
+func (s *userProfileService) UpdateUserProfile(ctx context.Context, userID int, input *models.UpdateUserProfileInput, ifMatch *int) (int, error) {
+	return s.update(ctx, userID, ifMatch, func(*models.UpdateUserProfileInput) (*models.UpdateUserProfileInput, error) {
+		return input, nil
+	})
+}
 
-	profile, err := s.userProfileRepo.GetUserProfileByUserID(userID)
-	if err != nil {
-		return err
-	}
-	if profile == nil {
-		return errors.New("user profile not found")
-	}
+// PatchUserProfile applies a JSON merge patch to the profile (PATCH), fields missing from the patch are kept
+func (s *userProfileService) PatchUserProfile(ctx context.Context, userID int, patch []byte, ifMatch *int) (int, error) {
+	return s.update(ctx, userID, ifMatch, func(current *models.UpdateUserProfileInput) (*models.UpdateUserProfileInput, error) {
+		next := *current
+		if err := mergepatch.Apply(&next, patch); err != nil {
+			return nil, err
+		}
+		if err := validation.Validate(&next); err != nil {
+			return nil, err
+		}
+		return &next, nil
+	})
+}
 
-	user.Phone = input.Phone
-	profile.FirstName = input.FirstName
-	profile.Surname = input.Surname
-	profile.Patronymic = input.Patronymic
+// update checks the version, builds the new profile from the current one and saves the user and the profile
+func (s *userProfileService) update(ctx context.Context, userID int, ifMatch *int,
+	build func(current *models.UpdateUserProfileInput) (*models.UpdateUserProfileInput, error)) (int, error) {
+	var version int
+	// user and profile are read and updated all-or-nothing
+	err := s.txManager.WithinTx(ctx, func(repos *repository.Repositories) error {
+		user, err := repos.User.GetUserByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if user == nil {
+			return apperr.ErrUserNotFound
+		}
 
-	err = s.userRepo.UpdateUser(user.UserID, user)
-	if err != nil {
-		return err
-	}
-	err = s.userProfileRepo.UpdateUserProfile(profile.ID, profile)
+		profile, err := repos.UserProfile.GetUserProfileByUserID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if profile == nil {
+			return apperr.ErrProfileNotFound
+		}
+		if ifMatch != nil && *ifMatch != profile.Version {
+			return apperr.ErrVersionMismatch
+		}
+
+		input, err := build(&models.UpdateUserProfileInput{
+			FirstName:  profile.FirstName,
+			Surname:    profile.Surname,
+			Patronymic: profile.Patronymic,
+			Phone:      user.Phone,
+		})
+		if err != nil {
+			return err
+		}
+
+		// the phone can be kept or removed here, a new one is set through the verified phone change
+		switch {
+		case input.Phone == nil:
+			user.Phone = nil
+			user.PhoneVerifiedAt = nil
+		case user.Phone == nil || normalizePhone(*input.Phone) != normalizePhone(*user.Phone):
+			return apperr.Invalid("phone_verification_required", "a new phone number has to be confirmed with POST /user/phone")
+		}
+		profile.FirstName = input.FirstName
+		profile.Surname = input.Surname
+		profile.Patronymic = input.Patronymic
+
+		if err := repos.User.UpdateUser(ctx, user.UserID, user); err != nil {
+			return err
+		}
+		// the version is checked again on write, the profile may have changed since it was read
+		if err := repos.UserProfile.UpdateUserProfile(ctx, profile.ID, profile); err != nil {
+			return err
+		}
+		version = profile.Version + 1
+		return nil
+	})
 	if err != nil {
-		return err
+		return 0, err
 	}
-
-	return nil
+	return version, nil
 }
 
 // CreateDefaultUserProfile creates a default user profile
-func (s *userProfileService) CreateDefaultUserProfile(userID int) error {
+func (s *userProfileService) CreateDefaultUserProfile(ctx context.Context, userID int) error {
 	profile := &models.UserProfile{
 		UserID:     userID,
 		FirstName:  nil,
 		Surname:    nil,
 		Patronymic: nil,
 	}
-	_, err := s.userProfileRepo.CreateUserProfile(profile)
+	_, err := s.userProfileRepo.CreateUserProfile(ctx, profile)
 	return err
 }
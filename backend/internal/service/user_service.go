@@ -1,87 +1,104 @@
 package service
 
 import (
-	"errors"
+	"context"
+	"rentor/internal/apperr"
 	"rentor/internal/logger"
 	"rentor/internal/models"
 	"rentor/internal/repository"
+	"time"
 )
 
 // userService implements UserService
 type userService struct {
 	repo        repository.UserRepository
 	profileRepo repository.UserProfileRepository
+	txManager   repository.TxManager
 }
 
 // NewUserService creates a new user service
-func NewUserService(repo repository.UserRepository, profileRepo repository.UserProfileRepository) UserService {
+func NewUserService(repo repository.UserRepository, profileRepo repository.UserProfileRepository, txManager repository.TxManager) UserService {
 	return &userService{
 		repo:        repo,
 		profileRepo: profileRepo,
+		txManager:   txManager,
 	}
 }
 
 // RegisterUser creates a new user (with validation)
-func (s *userService) RegisterUser(input *models.CreateUserInput) (int, error) {
+func (s *userService) RegisterUser(ctx context.Context, input *models.CreateUserInput) (int, error) {
 	if input.Email == "" && input.Phone == nil {
-		return 0, errors.New("email or phone required")
+		return 0, apperr.Invalid("contact_required", "email or phone required")
 	}
 
 	// Check if user already exists
 	if input.Email != "" {
-		existing, _ := s.repo.GetUserByEmail(input.Email)
+		existing, _ := s.repo.GetUserByEmail(ctx, input.Email)
 		if existing != nil {
-			return 0, errors.New("user with this email already exists")
+			return 0, apperr.Conflict("email_taken", "user with this email already exists")
 		}
 	}
 
 	if input.Phone != nil {
-		existing, _ := s.repo.GetUserByPhone(*input.Phone)
+		existing, _ := s.repo.GetUserByPhone(ctx, *input.Phone)
 		if existing != nil {
-			return 0, errors.New("user with this phone already exists")
+			return 0, apperr.Conflict("phone_taken", "user with this phone already exists")
 		}
 	}
 
-	userID, err := s.repo.CreateUser(*input.Phone, input.Email)
-	if err != nil {
-		return 0, err
+	phone := ""
+	if input.Phone != nil {
+		phone = *input.Phone
 	}
 
-	// Create default profile
-	profile := &models.UserProfile{
-		UserID:     userID,
-		FirstName:  nil,
-		Surname:    nil,
-		Patronymic: nil,
+	return s.createUserWithProfile(ctx, phone, input.Email)
+}
+
+// GetUser retrieves a user by ID
+func (s *userService) GetUser(ctx context.Context, id int) (*models.User, error) {
+	return s.repo.GetUserByID(ctx, id)
+}
+
+// SetUserRole changes the role of the user with the given email
+func (s *userService) SetUserRole(ctx context.Context, email, role string) error {
+	if role != models.RoleUser && role != models.RoleAdmin {
+		return apperr.Invalid("invalid_role", "role must be user or admin")
 	}
-	_, err = s.profileRepo.CreateUserProfile(profile)
+
+	user, err := s.repo.GetUserByEmail(ctx, email)
 	if err != nil {
-		// Log error but don't fail (user is already created)
-		logger.Error("failed to create user profile", logger.Field("error", err.Error()), logger.Field("user_id", userID))
-		return userID, nil
+		return err
+	}
+	if user == nil {
+		return apperr.ErrUserNotFound
 	}
 
-	return userID, nil
+	return s.repo.UpdateUserRole(ctx, user.UserID, role)
 }
 
-// GetUser retrieves a user by ID
-func (s *userService) GetUser(id int) (*models.User, error) {
-	return s.repo.GetUserByID(id)
+// DeleteUser soft-deletes a user together with their advertisements
+func (s *userService) DeleteUser(ctx context.Context, id int) error {
+	return s.repo.DeleteUserByID(ctx, id, time.Now().UTC())
+}
+
+// RestoreUser restores a soft-deleted user and the advertisements deleted with them
+func (s *userService) RestoreUser(ctx context.Context, id int) error {
+	return s.repo.RestoreUserByID(ctx, id)
 }
 
 // GetUserByEmail retrieves a user by email
-func (s *userService) GetUserByEmail(email string) (*models.User, error) {
-	return s.repo.GetUserByEmail(email)
+func (s *userService) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	return s.repo.GetUserByEmail(ctx, email)
 }
 
 // GetUserByPhone retrieves a user by phone
-func (s *userService) GetUserByPhone(phone string) (*models.User, error) {
-	return s.repo.GetUserByPhone(phone)
+func (s *userService) GetUserByPhone(ctx context.Context, phone string) (*models.User, error) {
+	return s.repo.GetUserByPhone(ctx, phone)
 }
 
 // FindOrCreateUserByEmail finds existing user or creates new one
-func (s *userService) FindOrCreateUserByEmail(email string) (*models.User, error) {
-	user, err := s.repo.GetUserByEmail(email)
+func (s *userService) FindOrCreateUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	user, err := s.repo.GetUserByEmail(ctx, email)
 	if err != nil {
 		return nil, err
 	}
@@ -91,21 +108,60 @@ func (s *userService) FindOrCreateUserByEmail(email string) (*models.User, error
 		return user, nil
 	}
 
-	// Create new user
-	userID, err := s.repo.CreateUser("", email)
+	// The email of a deleted account stays taken until the account is purged
+	deleted, err := s.repo.GetDeletedUserByEmail(ctx, email)
 	if err != nil {
 		return nil, err
 	}
+	if deleted != nil {
+		// Owners who deleted their account may sign in during the grace period, which cancels the deletion
+		if deleted.EraseAfter != nil {
+			return &models.User{UserID: deleted.ID, Email: email}, nil
+		}
+		return nil, apperr.Forbidden("account_deleted", "account is deleted")
+	}
 
-	// Create default profile
-	profile := &models.UserProfile{
-		UserID:     userID,
-		FirstName:  nil,
-		Surname:    nil,
-		Patronymic: nil,
+	// Create new user
+	userID, err := s.createUserWithProfile(ctx, "", email)
+	if err != nil {
+		return nil, err
 	}
-	_, _ = s.profileRepo.CreateUserProfile(profile)
 
 	// Return the newly created user
-	return s.repo.GetUserByID(userID)
+	return s.repo.GetUserByID(ctx, userID)
+}
+
+// MarkEmailVerified records that the user has confirmed their email, signing in with a code sent there does
+func (s *userService) MarkEmailVerified(ctx context.Context, id int) error {
+	return s.repo.SetEmailVerified(ctx, id, time.Now().UTC())
+}
+
+// createUserWithProfile creates a user together with the default profile in one transaction,
+// so a user never exists without a profile
+func (s *userService) createUserWithProfile(ctx context.Context, phone, email string) (int, error) {
+	var userID int
+	err := s.txManager.WithinTx(ctx, func(repos *repository.Repositories) error {
+		var err error
+		userID, err = repos.User.CreateUser(ctx, phone, email)
+		if err != nil {
+			return err
+		}
+
+		// Create default profile
+		profile := &models.UserProfile{
+			UserID:     userID,
+			FirstName:  nil,
+			Surname:    nil,
+			Patronymic: nil,
+		}
+		_, err = repos.UserProfile.CreateUserProfile(ctx, profile)
+		return err
+	})
+	if err != nil {
+		// the user was rolled back, there is no id to log and the email is personal data
+		logger.Error("failed to create user", logger.Field("error", err.Error()))
+		return 0, err
+	}
+
+	return userID, nil
 }
@@ -0,0 +1,506 @@
+package storage
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"rentor/internal/config"
+	"rentor/internal/logger"
+)
+
+// backup archive layout: rentor-<UTC time>.tar.gz with the database snapshot and the image files
+const (
+	backupPrefix     = "rentor-"
+	backupExt        = ".tar.gz"
+	backupTimeLayout = "20060102T150405Z"
+	backupDBEntry    = "storage.db"
+	backupImagesDir  = "images"
+)
+
+// BackupInfo describes a backup archive
+type BackupInfo struct {
+	Name      string    `json:"name"`
+	Path      string    `json:"path"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RestoreReport describes a completed restore
+type RestoreReport struct {
+	Archive        string `json:"archive"`
+	ImagesRestored bool   `json:"images_restored"`
+	// previous files are kept next to the restored ones under these names
+	PreviousDB     string `json:"previous_db,omitempty"`
+	PreviousImages string `json:"previous_images,omitempty"`
+}
+
+// Backuper creates and restores point-in-time backups of the SQLite database.
+// A backup is a tar.gz archive with a consistent snapshot of the database taken with VACUUM INTO
+// and, optionally, the files of the image storage.
+type Backuper struct {
+	db            *DB
+	storagePath   string
+	imagePath     string
+	dir           string
+	retention     int
+	includeImages bool
+}
+
+// NewBackuper creates a backuper for the configured database. Only SQLite is supported,
+// PostgreSQL databases are backed up with pg_dump.
+func NewBackuper(db *DB, cfg *config.Config) (*Backuper, error) {
+	if cfg.StorageDriver != DriverSQLite {
+		return nil, fmt.Errorf("NewBackuper: backups are supported for the %s driver only, use pg_dump for %s", DriverSQLite, cfg.StorageDriver)
+	}
+
+	return &Backuper{
+		db:            db,
+		storagePath:   cfg.StoragePath,
+		imagePath:     cfg.ImageStoragePath,
+		dir:           cfg.Backup.Dir,
+		retention:     cfg.Backup.Retention,
+		includeImages: cfg.Backup.IncludeImages,
+	}, nil
+}
+
+// Create takes a snapshot of the database, checks its integrity and writes the backup archive.
+// Backups beyond the retention limit are removed afterwards.
+// The snapshot is taken first and the images are archived after it, so the archive may contain
+// images uploaded after the snapshot; they are orphans and are cleaned up by the image reconcile.
+func (b *Backuper) Create(ctx context.Context) (*BackupInfo, error) {
+	start := time.Now()
+
+	if err := os.MkdirAll(b.dir, 0o755); err != nil {
+		return nil, fmt.Errorf("Create: failed to create backup directory: %w", err)
+	}
+
+	// work files live in the backup directory, so the finished archive is moved into place with a rename
+	// and an interrupted backup never leaves a truncated archive behind
+	workDir, err := os.MkdirTemp(b.dir, ".backup-")
+	if err != nil {
+		return nil, fmt.Errorf("Create: failed to create work directory: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	snapshot := filepath.Join(workDir, backupDBEntry)
+	if err := b.snapshot(ctx, snapshot); err != nil {
+		return nil, err
+	}
+
+	if err := checkIntegrity(ctx, snapshot); err != nil {
+		return nil, fmt.Errorf("Create: snapshot is corrupted: %w", err)
+	}
+
+	createdAt := time.Now().UTC()
+	name := backupPrefix + createdAt.Format(backupTimeLayout) + backupExt
+	target := filepath.Join(b.dir, name)
+	if _, err := os.Stat(target); err == nil {
+		return nil, fmt.Errorf("Create: backup %s already exists", name)
+	}
+
+	partial := filepath.Join(workDir, name)
+	if err := b.writeArchive(ctx, partial, snapshot); err != nil {
+		return nil, err
+	}
+
+	if err := os.Rename(partial, target); err != nil {
+		return nil, fmt.Errorf("Create: failed to move backup into place: %w", err)
+	}
+
+	stat, err := os.Stat(target)
+	if err != nil {
+		return nil, fmt.Errorf("Create: %w", err)
+	}
+
+	info := &BackupInfo{Name: name, Path: target, Size: stat.Size(), CreatedAt: createdAt}
+
+	logger.Info("backup created",
+		logger.Field("backup", info.Name),
+		logger.Field("size", info.Size),
+		logger.Field("images", b.includeImages),
+		logger.Field("duration", time.Since(start).String()),
+	)
+
+	if err := b.Prune(); err != nil {
+		logger.Warn("failed to remove old backups", logger.Field("error", err.Error()))
+	}
+
+	return info, nil
+}
+
+// List returns the backups in the backup directory, newest first
+func (b *Backuper) List() ([]*BackupInfo, error) {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("List: failed to read backup directory: %w", err)
+	}
+
+	var backups []*BackupInfo
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, backupPrefix) || !strings.HasSuffix(name, backupExt) {
+			continue
+		}
+
+		createdAt, err := time.Parse(backupTimeLayout, strings.TrimSuffix(strings.TrimPrefix(name, backupPrefix), backupExt))
+		if err != nil {
+			continue
+		}
+
+		stat, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("List: %w", err)
+		}
+
+		backups = append(backups, &BackupInfo{
+			Name:      name,
+			Path:      filepath.Join(b.dir, name),
+			Size:      stat.Size(),
+			CreatedAt: createdAt,
+		})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+
+	return backups, nil
+}
+
+// Prune removes the oldest backups beyond the retention limit. A non-positive limit keeps every backup.
+func (b *Backuper) Prune() error {
+	if b.retention <= 0 {
+		return nil
+	}
+
+	backups, err := b.List()
+	if err != nil {
+		return err
+	}
+
+	for _, old := range backups[min(b.retention, len(backups)):] {
+		if err := os.Remove(old.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("Prune: failed to remove %s: %w", old.Name, err)
+		}
+		logger.Info("old backup removed", logger.Field("backup", old.Name))
+	}
+
+	return nil
+}
+
+// Restore replaces the database (and the images, when the archive has them) with the contents of a backup.
+// archive is a path or the name of a backup in the backup directory.
+// The backup is extracted and checked with PRAGMA integrity_check before any file is touched.
+// Restore holds the database lock, so it fails with ErrDatabaseLocked while the server runs and the server
+// can't start until it is done. Restore closes the database, the Backuper can't be used afterwards.
+// The replaced files are kept next to the restored ones.
+func (b *Backuper) Restore(ctx context.Context, archive string) (*RestoreReport, error) {
+	archive, err := b.resolve(archive)
+	if err != nil {
+		return nil, err
+	}
+
+	lock, err := LockDatabase(b.storagePath)
+	if err != nil {
+		return nil, fmt.Errorf("Restore: stop the server first: %w", err)
+	}
+	defer lock.Release()
+
+	// extracting next to the live files keeps the swap a rename on the same file system
+	workDir, err := os.MkdirTemp(filepath.Dir(b.storagePath), ".restore-")
+	if err != nil {
+		return nil, fmt.Errorf("Restore: failed to create work directory: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	hasImages, err := extractArchive(archive, workDir)
+	if err != nil {
+		return nil, err
+	}
+
+	restoredDB := filepath.Join(workDir, backupDBEntry)
+	if _, err := os.Stat(restoredDB); err != nil {
+		return nil, fmt.Errorf("Restore: %s has no database", filepath.Base(archive))
+	}
+
+	if err := checkIntegrity(ctx, restoredDB); err != nil {
+		return nil, fmt.Errorf("Restore: backup is corrupted, nothing was changed: %w", err)
+	}
+
+	// the connections must be closed before the files are swapped; closing the last connection
+	// checkpoints the WAL into the database file
+	if err := b.db.Close(); err != nil {
+		return nil, fmt.Errorf("Restore: failed to close database: %w", err)
+	}
+
+	report := &RestoreReport{Archive: archive, ImagesRestored: hasImages}
+	suffix := ".pre-restore-" + time.Now().UTC().Format(backupTimeLayout)
+
+	if report.PreviousDB, err = moveAside(b.storagePath, suffix); err != nil {
+		return nil, err
+	}
+	// a WAL left behind belongs to the previous database and must not be applied to the restored one
+	for _, ext := range []string{"-wal", "-shm"} {
+		if _, err := moveAside(b.storagePath+ext, suffix); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := os.Rename(restoredDB, b.storagePath); err != nil {
+		return nil, fmt.Errorf("Restore: failed to move database into place: %w", err)
+	}
+
+	if hasImages {
+		if report.PreviousImages, err = moveAside(b.imagePath, suffix); err != nil {
+			return nil, err
+		}
+		if err := os.Rename(filepath.Join(workDir, backupImagesDir), b.imagePath); err != nil {
+			return nil, fmt.Errorf("Restore: failed to move images into place: %w", err)
+		}
+	}
+
+	logger.Info("backup restored",
+		logger.Field("backup", filepath.Base(archive)),
+		logger.Field("images", hasImages),
+	)
+
+	return report, nil
+}
+
+// snapshot writes a consistent copy of the live database to dst.
+// VACUUM INTO only reads the database, so it runs on the read pool and doesn't block writers.
+func (b *Backuper) snapshot(ctx context.Context, dst string) error {
+	pool := b.db.DB
+	if b.db.Read != nil {
+		pool = b.db.Read
+	}
+
+	if _, err := pool.ExecContext(ctx, "VACUUM INTO ?", dst); err != nil {
+		return fmt.Errorf("Create: failed to snapshot database: %w", err)
+	}
+	return nil
+}
+
+// writeArchive writes the database snapshot and the image files to a tar.gz archive at dst
+func (b *Backuper) writeArchive(ctx context.Context, dst, snapshot string) (err error) {
+	f, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("Create: failed to create archive: %w", err)
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil && cerr != nil {
+			err = fmt.Errorf("Create: failed to write archive: %w", cerr)
+		}
+	}()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	if err := addFile(tw, snapshot, backupDBEntry); err != nil {
+		return err
+	}
+
+	if b.includeImages {
+		err := filepath.WalkDir(b.imagePath, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if p == b.imagePath && errors.Is(err, fs.ErrNotExist) {
+					return fs.SkipDir
+				}
+				return err
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+
+			rel, err := filepath.Rel(b.imagePath, p)
+			if err != nil {
+				return err
+			}
+			return addFile(tw, p, path.Join(backupImagesDir, filepath.ToSlash(rel)))
+		})
+		if err != nil {
+			return fmt.Errorf("Create: failed to archive images: %w", err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("Create: failed to write archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("Create: failed to write archive: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("Create: failed to write archive: %w", err)
+	}
+
+	return nil
+}
+
+// resolve finds the archive by path or by name in the backup directory
+func (b *Backuper) resolve(archive string) (string, error) {
+	if _, err := os.Stat(archive); err == nil {
+		return archive, nil
+	}
+
+	inDir := filepath.Join(b.dir, filepath.Base(archive))
+	if _, err := os.Stat(inDir); err == nil {
+		return inDir, nil
+	}
+
+	return "", fmt.Errorf("Restore: backup %s not found", archive)
+}
+
+// addFile writes the file at src to the archive under name
+func addFile(tw *tar.Writer, src, name string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	header, err := tar.FileInfoHeader(stat, "")
+	if err != nil {
+		return err
+	}
+	header.Name = name
+
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// extractArchive extracts a backup archive into dir and reports whether it contains images.
+// Only the database and files under images/ are accepted, any other entry fails the restore.
+func extractArchive(archive, dir string) (bool, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return false, fmt.Errorf("Restore: failed to open backup: %w", err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return false, fmt.Errorf("Restore: failed to read backup: %w", err)
+	}
+	defer gz.Close()
+
+	hasImages := false
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return false, fmt.Errorf("Restore: failed to read backup: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := path.Clean(header.Name)
+		isImage := strings.HasPrefix(name, backupImagesDir+"/")
+		if name != backupDBEntry && !isImage || !filepath.IsLocal(name) {
+			return false, fmt.Errorf("Restore: unexpected entry %q in backup", header.Name)
+		}
+		hasImages = hasImages || isImage
+
+		dst := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return false, fmt.Errorf("Restore: %w", err)
+		}
+		if err := writeFile(dst, tr, header.FileInfo().Mode().Perm()); err != nil {
+			return false, fmt.Errorf("Restore: failed to extract %s: %w", name, err)
+		}
+	}
+
+	return hasImages, nil
+}
+
+// writeFile writes r to a new file at dst
+func writeFile(dst string, r io.Reader, perm fs.FileMode) error {
+	f, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// moveAside renames p to p+suffix and returns the new name, or "" when p doesn't exist
+func moveAside(p, suffix string) (string, error) {
+	if _, err := os.Lstat(p); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", nil
+		}
+		return "", fmt.Errorf("Restore: %w", err)
+	}
+
+	if err := os.Rename(p, p+suffix); err != nil {
+		return "", fmt.Errorf("Restore: failed to move %s aside: %w", p, err)
+	}
+	return p + suffix, nil
+}
+
+// checkIntegrity runs PRAGMA integrity_check on the SQLite database at p
+func checkIntegrity(ctx context.Context, p string) error {
+	db, err := sql.Open("sqlite3", "file:"+p+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, "PRAGMA integrity_check")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return err
+		}
+		if line != "ok" {
+			problems = append(problems, line)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("integrity check failed: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
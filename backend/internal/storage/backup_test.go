@@ -0,0 +1,107 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"rentor/internal/config"
+	"rentor/internal/logger"
+	"rentor/internal/storage"
+	"rentor/internal/storage/storagetest"
+)
+
+func TestLockDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.db")
+
+	lock, err := storage.LockDatabase(path)
+	if err != nil {
+		t.Fatalf("first lock: %v", err)
+	}
+	if _, err := storage.LockDatabase(path); !errors.Is(err, storage.ErrDatabaseLocked) {
+		t.Errorf("second lock: err = %v, want %v", err, storage.ErrDatabaseLocked)
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	lock, err = storage.LockDatabase(path)
+	if err != nil {
+		t.Fatalf("lock after release: %v", err)
+	}
+	lock.Release()
+}
+
+// openBackuper opens the database of cfg for a CLI command, without the read pool
+func openBackuper(t *testing.T, cfg *config.Config) (*storage.DB, *storage.Backuper) {
+	t.Helper()
+	db, err := storage.Connect(cfg)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	backuper, err := storage.NewBackuper(db, cfg)
+	if err != nil {
+		t.Fatalf("NewBackuper: %v", err)
+	}
+	return db, backuper
+}
+
+func TestRestoreRefusesWhileServerRuns(t *testing.T) {
+	logger.InitNop()
+	dir := t.TempDir()
+	cfg := &config.Config{
+		StorageDriver:    storage.DriverSQLite,
+		StoragePath:      filepath.Join(dir, "storage.db"),
+		ImageStoragePath: filepath.Join(dir, "images"),
+		Backup:           config.Backup{Dir: filepath.Join(dir, "backups")},
+		SQLite:           storagetest.SQLiteOptions(),
+	}
+	cfg.SQLite.MaxReadConns = 0
+	ctx := context.Background()
+
+	db, backuper := openBackuper(t, cfg)
+	if _, err := db.ExecContext(ctx, "CREATE TABLE item (name TEXT); INSERT INTO item VALUES ('backed up')"); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	backup, err := backuper.Create(ctx)
+	if err != nil {
+		t.Fatalf("create backup: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, "UPDATE item SET name = 'changed'"); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	// the server holds the lock while it runs
+	server, err := storage.LockDatabase(cfg.StoragePath)
+	if err != nil {
+		t.Fatalf("server lock: %v", err)
+	}
+	if _, err := backuper.Restore(ctx, backup.Name); !errors.Is(err, storage.ErrDatabaseLocked) {
+		t.Fatalf("restore while the server runs: err = %v, want %v", err, storage.ErrDatabaseLocked)
+	}
+	var name string
+	if err := db.QueryRowContext(ctx, "SELECT name FROM item").Scan(&name); err != nil || name != "changed" {
+		t.Fatalf("database was touched by the refused restore: %q, %v", name, err)
+	}
+	if err := server.Release(); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+
+	if _, err := backuper.Restore(ctx, backup.Name); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	// restore has released the lock, the server can start again
+	server, err = storage.LockDatabase(cfg.StoragePath)
+	if err != nil {
+		t.Fatalf("server lock after restore: %v", err)
+	}
+	server.Release()
+
+	restored, _ := openBackuper(t, cfg)
+	if err := restored.QueryRowContext(ctx, "SELECT name FROM item").Scan(&name); err != nil || name != "backed up" {
+		t.Errorf("restored item = %q, %v, want the backed up one", name, err)
+	}
+}
@@ -3,19 +3,116 @@ package storage
 import (
 	"database/sql"
 	"fmt"
+	"net/url"
+	"strings"
 
+	"rentor/internal/config"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
 	_ "github.com/mattn/go-sqlite3"
 )
 
-// TODO: keep-alive, connection pool, etc.
+// supported storage drivers (config: storage_driver)
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+// DB is the application database.
+// The embedded pool is used for writes, transactions and migrations.
+// For SQLite, reads go through a separate read-only pool, so they never wait for the single writer.
+type DB struct {
+	*sql.DB
+	Read *sql.DB // read-only pool, nil when reads share the write pool
+}
+
+// Close closes both pools
+func (db *DB) Close() error {
+	if db.Read != nil {
+		if err := db.Read.Close(); err != nil {
+			return err
+		}
+	}
+	return db.DB.Close()
+}
+
+// Connect opens the database selected by cfg.StorageDriver.
+// For SQLite StoragePath is the database file, for PostgreSQL StorageDSN is the connection string.
+func Connect(cfg *config.Config) (*DB, error) {
+	switch cfg.StorageDriver {
+	case DriverSQLite:
+		return connectSQLite(cfg.StoragePath, cfg.SQLite)
+	case DriverPostgres:
+		db, err := open("pgx", cfg.StorageDSN)
+		if err != nil {
+			return nil, err
+		}
+		return &DB{DB: db}, nil
+	default:
+		return nil, fmt.Errorf("Connect: unsupported storage driver %q", cfg.StorageDriver)
+	}
+}
+
+// connectSQLite opens the write pool and the read pool of a SQLite database.
+// SQLite allows one writer at a time: the write pool has a single connection, so writers queue up
+// in database/sql instead of failing with SQLITE_BUSY, and transactions take the write lock on BEGIN.
+func connectSQLite(path string, opts config.SQLite) (*DB, error) {
+	params := url.Values{}
+	params.Set("_busy_timeout", fmt.Sprint(opts.BusyTimeout.Milliseconds()))
+	if opts.ForeignKeys {
+		params.Set("_foreign_keys", "on")
+	} else {
+		params.Set("_foreign_keys", "off")
+	}
+	if opts.Synchronous != "" {
+		params.Set("_synchronous", strings.ToUpper(opts.Synchronous))
+	}
+
+	writeParams := url.Values{}
+	for k, v := range params {
+		writeParams[k] = v
+	}
+	if opts.JournalMode != "" {
+		writeParams.Set("_journal_mode", strings.ToUpper(opts.JournalMode))
+	}
+	writeParams.Set("_txlock", "immediate")
+
+	write, err := open("sqlite3", "file:"+path+"?"+writeParams.Encode())
+	if err != nil {
+		return nil, err
+	}
+	write.SetMaxOpenConns(1)
+	write.SetConnMaxIdleTime(opts.ConnMaxIdleTime)
+
+	db := &DB{DB: write}
+	if opts.MaxReadConns <= 0 {
+		return db, nil
+	}
+
+	// the read pool is opened after the write pool, which creates the database file
+	params.Set("mode", "ro")
+	read, err := open("sqlite3", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		write.Close()
+		return nil, err
+	}
+	read.SetMaxOpenConns(opts.MaxReadConns)
+	read.SetMaxIdleConns(opts.MaxReadConns)
+	read.SetConnMaxIdleTime(opts.ConnMaxIdleTime)
+	db.Read = read
+
+	return db, nil
+}
 
-func Connect(storage_path string) (*sql.DB, error) {
-	db, err := sql.Open("sqlite3", storage_path)
+// open opens a pool and checks the connection
+func open(driverName, dsn string) (*sql.DB, error) {
+	db, err := sql.Open(driverName, dsn)
 	if err != nil {
 		return nil, fmt.Errorf("Connect: failed to connect to database: %w", err)
 	}
 
 	if err := db.Ping(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("Connect: failed to ping database: %w", err)
 	}
 
@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrDatabaseLocked is returned when another rentor process holds the lock of the database
+var ErrDatabaseLocked = errors.New("the database is locked by another rentor process")
+
+// Lock is an exclusive lock of a SQLite database, kept in <storage_path>.lock next to it.
+// The server holds it while it runs and restore takes it before replacing the database files,
+// so neither can start while the other is running. The lock goes away with the process that holds it.
+type Lock struct {
+	f *os.File
+}
+
+// LockDatabase takes the lock of the SQLite database at storagePath without waiting.
+// It fails with ErrDatabaseLocked if another process holds the lock.
+func LockDatabase(storagePath string) (*Lock, error) {
+	f, err := os.OpenFile(storagePath+".lock", os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("LockDatabase: %w", err)
+	}
+
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &Lock{f: f}, nil
+}
+
+// Release releases the lock, the lock file is left in place for the next process
+func (l *Lock) Release() error {
+	if err := unlockFile(l.f); err != nil {
+		l.f.Close()
+		return fmt.Errorf("Release: %w", err)
+	}
+	return l.f.Close()
+}
@@ -1,40 +1,235 @@
 package storage
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+
 	"rentor/internal/logger"
+	"rentor/migrations"
 
 	"github.com/pressly/goose/v3"
 )
 
-const (
-	directionUp   = "up"
-	directionDown = "down"
-)
+// goose dialects of the storage drivers
+var gooseDialects = map[string]goose.Dialect{
+	DriverSQLite:   goose.DialectSQLite3,
+	DriverPostgres: goose.DialectPostgres,
+}
+
+// Migrator applies and rolls back the embedded migrations of a storage driver
+type Migrator struct {
+	provider *goose.Provider
+}
+
+// NewMigrator creates a migrator for the migrations of driver
+func NewMigrator(db *sql.DB, driver string) (*Migrator, error) {
+	dialect, ok := gooseDialects[driver]
+	if !ok {
+		return nil, fmt.Errorf("NewMigrator: unsupported storage driver %q", driver)
+	}
+
+	fsys, err := fs.Sub(migrations.FS, driver)
+	if err != nil {
+		return nil, fmt.Errorf("NewMigrator: failed to open migrations: %w", err)
+	}
+
+	provider, err := goose.NewProvider(dialect, db, fsys)
+	if err != nil {
+		return nil, fmt.Errorf("NewMigrator: failed to load migrations: %w", err)
+	}
+
+	return &Migrator{provider: provider}, nil
+}
+
+// Up applies all pending migrations
+func (m *Migrator) Up(ctx context.Context) error {
+	results, err := m.provider.Up(ctx)
+	logResults(results)
+	if err != nil {
+		return fmt.Errorf("migrate up: %w", err)
+	}
+	return nil
+}
+
+// UpTo applies pending migrations up to and including version
+func (m *Migrator) UpTo(ctx context.Context, version int64) error {
+	results, err := m.provider.UpTo(ctx, version)
+	logResults(results)
+	if err != nil {
+		return fmt.Errorf("migrate up-to %d: %w", version, err)
+	}
+	return nil
+}
+
+// Down rolls back the latest applied migration
+func (m *Migrator) Down(ctx context.Context) error {
+	result, err := m.provider.Down(ctx)
+	logResults([]*goose.MigrationResult{result})
+	if err != nil {
+		return fmt.Errorf("migrate down: %w", err)
+	}
+	return nil
+}
+
+// DownTo rolls back applied migrations newer than version, version itself stays applied (0 rolls back everything)
+func (m *Migrator) DownTo(ctx context.Context, version int64) error {
+	results, err := m.provider.DownTo(ctx, version)
+	logResults(results)
+	if err != nil {
+		return fmt.Errorf("migrate down-to %d: %w", version, err)
+	}
+	return nil
+}
+
+// Redo rolls back the latest applied migration and applies it again
+func (m *Migrator) Redo(ctx context.Context) error {
+	down, err := m.provider.Down(ctx)
+	logResults([]*goose.MigrationResult{down})
+	if err != nil {
+		return fmt.Errorf("migrate redo: %w", err)
+	}
 
-// TODO: handle more goose commands (status, redo, etc.) as needed
+	up, err := m.provider.ApplyVersion(ctx, down.Source.Version, true)
+	logResults([]*goose.MigrationResult{up})
+	if err != nil {
+		return fmt.Errorf("migrate redo: %w", err)
+	}
+	return nil
+}
+
+// Status returns every migration with its state in the database
+func (m *Migrator) Status(ctx context.Context) ([]*goose.MigrationStatus, error) {
+	return m.provider.Status(ctx)
+}
 
-func RunMigrations(db *sql.DB, migrationsDir string, direction string) error {
+// HasPending reports whether there are migrations not applied to the database
+func (m *Migrator) HasPending(ctx context.Context) (bool, error) {
+	return m.provider.HasPending(ctx)
+}
 
-	// Set the dialect for goose
-	if err := goose.SetDialect("sqlite3"); err != nil {
-		return fmt.Errorf("runMigrations: failed to set goose dialect: %w", err)
+// logResults logs applied and rolled back migrations
+func logResults(results []*goose.MigrationResult) {
+	for _, r := range results {
+		if r == nil || r.Source == nil {
+			continue
+		}
+		if r.Error != nil {
+			logger.Error("migration failed",
+				logger.Field("migration", filepath.Base(r.Source.Path)),
+				logger.Field("direction", r.Direction),
+				logger.Field("error", r.Error.Error()),
+			)
+			continue
+		}
+		logger.Info("migration applied",
+			logger.Field("migration", filepath.Base(r.Source.Path)),
+			logger.Field("direction", r.Direction),
+			logger.Field("duration", r.Duration.String()),
+		)
 	}
+}
 
-	switch direction {
-	case directionUp:
-		if err := goose.Up(db, migrationsDir); err != nil {
-			return fmt.Errorf("runMigrations: failed to apply up migrations: %w", err)
+// CreateMigration creates an empty SQL migration with the next version in dir/<driver> for every storage driver
+func CreateMigration(dir, name string) error {
+	goose.SetSequential(true)
+	for _, driver := range drivers() {
+		if err := goose.Create(nil, filepath.Join(dir, driver), name, "sql"); err != nil {
+			return fmt.Errorf("create migration for %s: %w", driver, err)
 		}
-		logger.Info("Database migrations applied successfully", logger.Field("direction", directionUp))
-	case directionDown:
-		if err := goose.Down(db, migrationsDir); err != nil {
-			return fmt.Errorf("runMigrations: failed to apply down migrations: %w", err)
+	}
+	return nil
+}
+
+// ValidateMigrations checks the migrations in fsys (a directory with a subdirectory per driver, like the
+// migrations directory of the repository) without touching the database: file names and versions,
+// goose annotations, and that every driver has the same set of versions
+func ValidateMigrations(fsys fs.FS) error {
+	var errs []error
+	versions := map[string][]int64{}
+
+	for _, driver := range drivers() {
+		entries, err := fs.ReadDir(fsys, driver)
+		if err != nil {
+			return fmt.Errorf("validate migrations: %w", err)
+		}
+
+		seen := map[int64]string{}
+		for _, entry := range entries {
+			name := entry.Name()
+			path := driver + "/" + name
+
+			// only SQL files are embedded into the binary (see migrations.FS)
+			if entry.IsDir() || filepath.Ext(name) != ".sql" {
+				errs = append(errs, fmt.Errorf("%s: not a SQL migration, it would not be embedded", path))
+				continue
+			}
+
+			version, err := goose.NumericComponent(name)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", path, err))
+				continue
+			}
+			if other, ok := seen[version]; ok {
+				errs = append(errs, fmt.Errorf("%s: version %d is also used by %s", path, version, other))
+			}
+			seen[version] = name
+			versions[driver] = append(versions[driver], version)
+
+			content, err := fs.ReadFile(fsys, path)
+			if err != nil {
+				return fmt.Errorf("validate migrations: %w", err)
+			}
+			if err := validateAnnotations(content); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			}
+		}
+	}
+
+	for _, driver := range drivers()[1:] {
+		if !slices.Equal(versions[driver], versions[drivers()[0]]) {
+			errs = append(errs, fmt.Errorf("%s and %s migrations have different versions: %v vs %v",
+				drivers()[0], driver, versions[drivers()[0]], versions[driver]))
 		}
-		logger.Info("Database migrations rolled back successfully", logger.Field("direction", directionDown))
-	default:
-		return fmt.Errorf("invalid migration direction: %s", direction)
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateAnnotations checks the goose annotations of a SQL migration
+func validateAnnotations(content []byte) error {
+	var up, down, open int
+	for _, line := range bytes.Split(content, []byte("\n")) {
+		switch strings.TrimSpace(string(line)) {
+		case "-- +goose Up":
+			up++
+		case "-- +goose Down":
+			down++
+		case "-- +goose StatementBegin":
+			open++
+		case "-- +goose StatementEnd":
+			open--
+		}
+	}
+
+	switch {
+	case up != 1:
+		return errors.New("migration must have exactly one -- +goose Up annotation")
+	case down > 1:
+		return errors.New("migration must have at most one -- +goose Down annotation")
+	case open != 0:
+		return errors.New("unbalanced -- +goose StatementBegin/StatementEnd annotations")
 	}
 	return nil
 }
+
+// drivers returns the supported storage drivers in a stable order
+func drivers() []string {
+	return []string{DriverSQLite, DriverPostgres}
+}
@@ -0,0 +1,95 @@
+package storage_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"rentor/internal/storage"
+	"rentor/migrations"
+)
+
+const (
+	upDown = "-- +goose Up\nCREATE TABLE item (id INTEGER);\n\n-- +goose Down\nDROP TABLE item;\n"
+	upOnly = "-- +goose Up\nCREATE INDEX idx_item ON item(id);\n"
+)
+
+func TestValidateMigrationsOfRepository(t *testing.T) {
+	if err := storage.ValidateMigrations(migrations.FS); err != nil {
+		t.Errorf("embedded migrations: %v", err)
+	}
+	if err := storage.ValidateMigrations(os.DirFS("../../migrations")); err != nil {
+		t.Errorf("migrations directory: %v", err)
+	}
+}
+
+func TestValidateMigrations(t *testing.T) {
+	valid := fstest.MapFS{
+		"sqlite/00001_item.sql":   {Data: []byte(upDown)},
+		"sqlite/00002_index.sql":  {Data: []byte(upOnly)},
+		"postgres/00001_item.sql": {Data: []byte(upDown)},
+		"postgres/00002_idx.sql":  {Data: []byte(upOnly)},
+	}
+	if err := storage.ValidateMigrations(valid); err != nil {
+		t.Fatalf("valid migrations: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		change fstest.MapFS
+		want   string
+	}{
+		{
+			name:   "no up annotation",
+			change: fstest.MapFS{"sqlite/00002_index.sql": {Data: []byte("CREATE INDEX idx_item ON item(id);\n")}},
+			want:   "sqlite/00002_index.sql: migration must have exactly one -- +goose Up annotation",
+		},
+		{
+			name:   "two down annotations",
+			change: fstest.MapFS{"postgres/00001_item.sql": {Data: []byte(upDown + "-- +goose Down\n")}},
+			want:   "postgres/00001_item.sql: migration must have at most one -- +goose Down annotation",
+		},
+		{
+			name:   "unbalanced statement",
+			change: fstest.MapFS{"sqlite/00002_index.sql": {Data: []byte(upOnly + "-- +goose StatementBegin\n")}},
+			want:   "sqlite/00002_index.sql: unbalanced",
+		},
+		{
+			name:   "duplicate version",
+			change: fstest.MapFS{"sqlite/00002_other.sql": {Data: []byte(upOnly)}},
+			want:   "sqlite/00002_other.sql: version 2 is also used by 00002_index.sql",
+		},
+		{
+			name:   "version of one driver only",
+			change: fstest.MapFS{"sqlite/00003_more.sql": {Data: []byte(upOnly)}},
+			want:   "sqlite and postgres migrations have different versions: [1 2 3] vs [1 2]",
+		},
+		{
+			name:   "no version",
+			change: fstest.MapFS{"postgres/item.sql": {Data: []byte(upOnly)}},
+			want:   "postgres/item.sql:",
+		},
+		{
+			name:   "not a SQL file",
+			change: fstest.MapFS{"sqlite/00003_more.sq": {Data: []byte(upOnly)}},
+			want:   "sqlite/00003_more.sq: not a SQL migration",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fstest.MapFS{}
+			for name, file := range valid {
+				fsys[name] = file
+			}
+			for name, file := range tt.change {
+				fsys[name] = file
+			}
+
+			err := storage.ValidateMigrations(fsys)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -1,63 +1,188 @@
 package store
 
 import (
-	"database/sql"
-
 	"rentor/internal/config"
 	"rentor/internal/repository"
 	"rentor/internal/service"
+	"rentor/internal/storage"
 )
 
 // Store includes all layers of the application.
 // This is the central place for initializing all layers of the application
 type Store struct {
 	// Repositories (working with DB)
-	User          repository.UserRepository
-	UserProfile   repository.UserProfileRepository
-	OTP           repository.OTPRepository
-	Advertisement repository.AdvertisementRepository
+	User           repository.UserRepository
+	UserProfile    repository.UserProfileRepository
+	OTP            repository.OTPRepository
+	Advertisement  repository.AdvertisementRepository
+	Upload         repository.UploadRepository
+	Moderation     repository.ModerationRepository
+	Analytics      repository.AnalyticsRepository
+	Stats          repository.StatsRepository
+	Recommendation repository.RecommendationRepository
+	Import         repository.ImportRepository
+	Export         repository.ExportRepository
+	Account        repository.AccountRepository
 
 	// Services (business logic)
-	UserService        service.UserService
-	UserProfileService service.UserProfileService
-	OTPService         service.OTPService
-	JWTService         service.JWTService
-	EmailService       service.EmailService
-	AdService          service.AdvertisementService
-	ImageService       service.ImageService
+	UserService           service.UserService
+	UserProfileService    service.UserProfileService
+	OTPService            service.OTPService
+	JWTService            service.JWTService
+	EmailService          service.EmailService
+	SMSService            service.SMSService
+	AdService             service.AdvertisementService
+	ImageService          service.ImageService
+	UploadService         service.UploadService
+	ImageReconcile        service.ImageReconcileService
+	ModerationService     service.ModerationService
+	PurgeService          service.PurgeService
+	AnalyticsService      service.AnalyticsService
+	StatsService          service.StatsService
+	RecommendationService service.RecommendationService
+	ImportService         service.ImportService
+	ExportService         service.ExportService
+	AccountService        service.AccountService
+	ContactService        service.ContactService
 }
 
 // NewStore creates a new store with initialized layers
-func NewStore(db *sql.DB, cfg *config.Config) *Store {
-	// Create repositories
+func NewStore(storageDB *storage.DB, cfg *config.Config) *Store {
+	// Create repositories, queries are adapted to the storage driver
+	db := repository.NewDB(storageDB.DB, storageDB.Read, repository.Dialect(cfg.StorageDriver))
 	userRepo := repository.NewUserRepository(db)
 	userProfileRepo := repository.NewUserProfileRepository(db)
 	otpRepo := repository.NewOTPRepository(db)
 	adRepo := repository.NewAdRepository(db)
+	uploadRepo := repository.NewUploadRepository(db)
+	moderationRepo := repository.NewModerationRepository(db)
+	analyticsRepo := repository.NewAnalyticsRepository(db)
+	statsRepo := repository.NewStatsRepository(db)
+	recommendationRepo := repository.NewRecommendationRepository(db)
+	importRepo := repository.NewImportRepository(db)
+	exportRepo := repository.NewExportRepository(db)
+	accountRepo := repository.NewAccountRepository(db)
+	txManager := repository.NewTxManager(db)
 
 	// Create services, passing repositories to them
-	userService := service.NewUserService(userRepo, userProfileRepo)
-	userProfileService := service.NewUserProfileService(userRepo, userProfileRepo)
+	userService := service.NewUserService(userRepo, userProfileRepo, txManager)
+	userProfileService := service.NewUserProfileService(userRepo, userProfileRepo, txManager)
 	jwtService := service.NewJWTService(
 		cfg.Auth.JWTSecret,
 		cfg.Auth.AccessTokenTTL,
 		cfg.Auth.RefreshTokenTTL,
 	)
 	emailService := service.NewEmailService(cfg.SMTP.SMTPFrom, cfg.SMTP.SMTPPassWord, cfg.SMTP.SMTPHost, cfg.SMTP.SMTPPort)
-	otpService := service.NewOTPService(otpRepo, emailService)
-	adService := service.NewadvertisementService(adRepo)
+	smsService := service.NewSMSService(cfg.SMS.GatewayURL, cfg.SMS.Token)
+	otpService := service.NewOTPService(
+		otpRepo,
+		emailService,
+		smsService,
+		cfg.Auth.OTPLength,
+		cfg.Auth.OTPExpirationMinutes,
+		cfg.Auth.OTPMaxAttempts,
+	)
+	contactService := service.NewContactService(userRepo, otpService, emailService, smsService)
+	moderationService := service.NewModerationService(
+		moderationRepo,
+		cfg.Moderation.PhotoMaxDistance,
+		cfg.Moderation.DuplicateListingThreshold,
+	)
+	adService := service.NewadvertisementService(
+		adRepo,
+		txManager,
+		moderationService,
+		cfg.SoftDelete.UndoWindow,
+		cfg.History.PriceDropPeriod,
+		cfg.Currency.Table,
+	)
 	imageService := service.NewimageService(cfg.ImageStoragePath, cfg.BaseURL)
+	imageReconcileService := service.NewImageReconcileService(adRepo, cfg.ImageStoragePath)
+	analyticsService := service.NewAnalyticsService(analyticsRepo, cfg.Currency.Table)
+	statsService := service.NewStatsService(statsRepo, adRepo, cfg.Stats.DashboardDays, cfg.Stats.MaxBuffered)
+	recommendationService := service.NewRecommendationService(
+		recommendationRepo,
+		adService,
+		cfg.Recommendations.PriceBand,
+		cfg.Recommendations.MaxDistanceKm,
+		cfg.Recommendations.RecentlyViewedLimit,
+		cfg.Recommendations.RecentlyViewedTTL,
+	)
+	purgeService := service.NewPurgeService(adRepo, userRepo, imageService, cfg.SoftDelete.Retention)
+	uploadService := service.NewUploadService(
+		uploadRepo,
+		adRepo,
+		txManager,
+		adService,
+		imageService,
+		cfg.Uploads.StagingPath,
+		cfg.Uploads.MaxFileSize,
+		cfg.Uploads.MaxFiles,
+		cfg.Uploads.AllowedTypes,
+		cfg.Uploads.SessionTTL,
+	)
+	importService := service.NewImportService(
+		importRepo,
+		adRepo,
+		adService,
+		imageService,
+		cfg.Uploads.StagingPath,
+		cfg.Imports.MaxRows,
+		cfg.Imports.MaxSyncRows,
+		cfg.Imports.MaxPhotos,
+		cfg.Uploads.MaxFileSize,
+		cfg.Uploads.AllowedTypes,
+		cfg.Imports.PhotoTimeout,
+		cfg.Imports.AllowPrivateHosts,
+	)
+	exportService := service.NewExportService(
+		exportRepo,
+		adRepo,
+		userRepo,
+		userProfileRepo,
+		cfg.ImageStoragePath,
+		cfg.Exports.Path,
+		cfg.Exports.SiteURL,
+		cfg.Exports.ArchiveTTL,
+	)
+	accountService := service.NewAccountService(
+		accountRepo,
+		imageService,
+		cfg.Exports.Path,
+		cfg.Uploads.StagingPath,
+		cfg.SoftDelete.AccountGracePeriod,
+	)
 
 	return &Store{
-		User:               userRepo,
-		UserProfile:        userProfileRepo,
-		OTP:                otpRepo,
-		UserService:        userService,
-		UserProfileService: userProfileService,
-		OTPService:         otpService,
-		JWTService:         jwtService,
-		EmailService:       emailService,
-		AdService:          adService,
-		ImageService:       imageService,
+		User:                  userRepo,
+		UserProfile:           userProfileRepo,
+		OTP:                   otpRepo,
+		Upload:                uploadRepo,
+		Moderation:            moderationRepo,
+		Analytics:             analyticsRepo,
+		Stats:                 statsRepo,
+		Recommendation:        recommendationRepo,
+		Import:                importRepo,
+		Export:                exportRepo,
+		Account:               accountRepo,
+		UserService:           userService,
+		UserProfileService:    userProfileService,
+		OTPService:            otpService,
+		JWTService:            jwtService,
+		EmailService:          emailService,
+		SMSService:            smsService,
+		AdService:             adService,
+		ImageService:          imageService,
+		UploadService:         uploadService,
+		ImageReconcile:        imageReconcileService,
+		ModerationService:     moderationService,
+		PurgeService:          purgeService,
+		AnalyticsService:      analyticsService,
+		StatsService:          statsService,
+		RecommendationService: recommendationService,
+		ImportService:         importService,
+		ExportService:         exportService,
+		AccountService:        accountService,
+		ContactService:        contactService,
 	}
 }
@@ -0,0 +1,102 @@
+package validation
+
+import (
+	"cmp"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Required fails on an empty or whitespace-only string
+func Required() Rule[string] {
+	return func(v string) *FieldError {
+		if strings.TrimSpace(v) == "" {
+			return &FieldError{Code: "required", Message: "is required"}
+		}
+		return nil
+	}
+}
+
+// MaxLength fails on strings longer than n characters
+func MaxLength(n int) Rule[string] {
+	return func(v string) *FieldError {
+		if utf8.RuneCountInString(v) > n {
+			return &FieldError{Code: "max_length", Message: fmt.Sprintf("must be at most %d characters", n)}
+		}
+		return nil
+	}
+}
+
+// OneOf fails on values that are not in allowed
+func OneOf(allowed ...string) Rule[string] {
+	return func(v string) *FieldError {
+		if !slices.Contains(allowed, v) {
+			return &FieldError{Code: "one_of", Message: "must be one of: " + strings.Join(allowed, ", ")}
+		}
+		return nil
+	}
+}
+
+// Matches fails on strings not matching re; description tells the client the expected format
+func Matches(re *regexp.Regexp, description string) Rule[string] {
+	return func(v string) *FieldError {
+		if !re.MatchString(v) {
+			return &FieldError{Code: "format", Message: "must be " + description}
+		}
+		return nil
+	}
+}
+
+// Email fails on strings that are not a bare email address
+func Email() Rule[string] {
+	return func(v string) *FieldError {
+		addr, err := mail.ParseAddress(v)
+		if err != nil || addr.Address != v {
+			return &FieldError{Code: "format", Message: "must be a valid email address"}
+		}
+		return nil
+	}
+}
+
+// Min fails on values less than min
+func Min[T cmp.Ordered](min T) Rule[T] {
+	return func(v T) *FieldError {
+		if v < min {
+			return &FieldError{Code: "min", Message: fmt.Sprintf("must be at least %v", min)}
+		}
+		return nil
+	}
+}
+
+// Max fails on values greater than max
+func Max[T cmp.Ordered](max T) Rule[T] {
+	return func(v T) *FieldError {
+		if v > max {
+			return &FieldError{Code: "max", Message: fmt.Sprintf("must be at most %v", max)}
+		}
+		return nil
+	}
+}
+
+// NotEmpty fails on empty lists
+func NotEmpty[T any]() Rule[[]T] {
+	return func(v []T) *FieldError {
+		if len(v) == 0 {
+			return &FieldError{Code: "required", Message: "must not be empty"}
+		}
+		return nil
+	}
+}
+
+// MaxItems fails on lists longer than n
+func MaxItems[T any](n int) Rule[[]T] {
+	return func(v []T) *FieldError {
+		if len(v) > n {
+			return &FieldError{Code: "max_items", Message: fmt.Sprintf("must have at most %d items", n)}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,107 @@
+// Package validation checks request inputs against declarative per-field rules.
+//
+// An input model declares its rules in a Validate method:
+//
+//	func (in *CreateAdvertisementInput) Validate() error {
+//		return validation.Check(
+//			validation.Field("title", in.Title, validation.Required(), validation.MaxLength(200)),
+//			validation.OptionalField("description", in.Description, validation.MaxLength(5000)),
+//		)
+//	}
+//
+// Check collects every failing field, so the client gets all errors of a form at once.
+package validation
+
+import (
+	"errors"
+	"strings"
+
+	"rentor/internal/apperr"
+)
+
+// Validator is implemented by input models that declare validation rules
+type Validator interface {
+	Validate() error
+}
+
+// FieldError describes why a field of the input is invalid
+type FieldError struct {
+	Field   string `json:"field"`   // JSON name of the field
+	Code    string `json:"code"`    // machine readable reason: required|max_length|one_of|min|max|...
+	Message string `json:"message"` // human readable reason
+}
+
+// Errors is the list of invalid fields returned by Check
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	parts := make([]string, len(e))
+	for i, fe := range e {
+		parts[i] = fe.Field + ": " + fe.Message
+	}
+	return "validation failed: " + strings.Join(parts, "; ")
+}
+
+// Is makes errors.Is(err, apperr.ErrValidation) hold for field errors too
+func (e Errors) Is(target error) bool {
+	return target == apperr.ErrValidation
+}
+
+// Rule checks a value and returns the reason it is invalid or nil
+type Rule[T any] func(value T) *FieldError
+
+// Field applies rules to a field value and returns the first failure.
+// The rules after a failing one are skipped, e.g. MaxLength isn't reported for an empty required title.
+func Field[T any](name string, value T, rules ...Rule[T]) *FieldError {
+	for _, rule := range rules {
+		if fe := rule(value); fe != nil {
+			fe.Field = name
+			return fe
+		}
+	}
+	return nil
+}
+
+// OptionalField applies rules to an optional (pointer) field; a nil value is valid
+func OptionalField[T any](name string, value *T, rules ...Rule[T]) *FieldError {
+	if value == nil {
+		return nil
+	}
+	return Field(name, *value, rules...)
+}
+
+// NewError creates a field error for checks that don't fit a rule, e.g. ones comparing several fields
+func NewError(field, code, message string) *FieldError {
+	return &FieldError{Field: field, Code: code, Message: message}
+}
+
+// Check returns Errors with all failed fields or nil if there are none
+func Check(results ...*FieldError) error {
+	var errs Errors
+	for _, fe := range results {
+		if fe != nil {
+			errs = append(errs, *fe)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// Validate runs v.Validate if v declares rules; other values are always valid
+func Validate(v any) error {
+	if validator, ok := v.(Validator); ok {
+		return validator.Validate()
+	}
+	return nil
+}
+
+// AsErrors reports whether err is (or wraps) a validation failure and returns the field errors
+func AsErrors(err error) (Errors, bool) {
+	var errs Errors
+	if errors.As(err, &errs) {
+		return errs, true
+	}
+	return nil, false
+}
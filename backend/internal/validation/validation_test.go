@@ -0,0 +1,135 @@
+package validation_test
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"rentor/internal/apperr"
+	"rentor/internal/validation"
+)
+
+// code returns the code of a rule result, "" if the value is valid
+func code(fe *validation.FieldError) string {
+	if fe == nil {
+		return ""
+	}
+	return fe.Code
+}
+
+func TestStringRules(t *testing.T) {
+	digits := regexp.MustCompile(`^\d+$`)
+	tests := []struct {
+		name  string
+		rule  validation.Rule[string]
+		value string
+		want  string
+	}{
+		{"required", validation.Required(), "Flat", ""},
+		{"required empty", validation.Required(), "", "required"},
+		{"required whitespace", validation.Required(), " \t\n", "required"},
+		{"max length at the bound", validation.MaxLength(5), "abcde", ""},
+		{"max length over the bound", validation.MaxLength(5), "abcdef", "max_length"},
+		{"max length counts characters", validation.MaxLength(5), "Алмат", ""},
+		{"max length of cyrillic", validation.MaxLength(5), "Алматы", "max_length"},
+		{"one of", validation.OneOf("house", "room"), "room", ""},
+		{"one of unknown", validation.OneOf("house", "room"), "castle", "one_of"},
+		{"one of is case-sensitive", validation.OneOf("house", "room"), "Room", "one_of"},
+		{"one of empty", validation.OneOf("house", "room"), "", "one_of"},
+		{"matches", validation.Matches(digits, "digits"), "123", ""},
+		{"matches not", validation.Matches(digits, "digits"), "12a", "format"},
+		{"email", validation.Email(), "owner@example.com", ""},
+		{"email with a name", validation.Email(), "Owner <owner@example.com>", "format"},
+		{"email without a domain", validation.Email(), "owner@", "format"},
+		{"email with spaces", validation.Email(), " owner@example.com", "format"},
+	}
+	for _, tt := range tests {
+		if got := code(tt.rule(tt.value)); got != tt.want {
+			t.Errorf("%s: %q gives %q, want %q", tt.name, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestOrderedRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  validation.Rule[float64]
+		value float64
+		want  string
+	}{
+		{"min at the bound", validation.Min(-90.0), -90, ""},
+		{"min under the bound", validation.Min(-90.0), -90.0001, "min"},
+		{"max at the bound", validation.Max(90.0), 90, ""},
+		{"max over the bound", validation.Max(90.0), 90.0001, "max"},
+	}
+	for _, tt := range tests {
+		if got := code(tt.rule(tt.value)); got != tt.want {
+			t.Errorf("%s: %v gives %q, want %q", tt.name, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestListRules(t *testing.T) {
+	if got := code(validation.NotEmpty[int]()(nil)); got != "required" {
+		t.Errorf("NotEmpty(nil) gives %q, want required", got)
+	}
+	if got := code(validation.NotEmpty[int]()([]int{1})); got != "" {
+		t.Errorf("NotEmpty([1]) gives %q", got)
+	}
+	if got := code(validation.MaxItems[string](2)([]string{"a", "b"})); got != "" {
+		t.Errorf("MaxItems(2) of 2 items gives %q", got)
+	}
+	if got := code(validation.MaxItems[string](2)([]string{"a", "b", "c"})); got != "max_items" {
+		t.Errorf("MaxItems(2) of 3 items gives %q, want max_items", got)
+	}
+}
+
+func TestField(t *testing.T) {
+	// the first failing rule is reported, with the name of the field
+	fe := validation.Field("title", "", validation.Required(), validation.MaxLength(0))
+	if fe == nil || fe.Field != "title" || fe.Code != "required" {
+		t.Errorf("Field = %+v, want a required title", fe)
+	}
+	if fe := validation.Field("title", strings.Repeat("a", 3), validation.Required(), validation.MaxLength(2)); code(fe) != "max_length" {
+		t.Errorf("Field = %+v, want max_length", fe)
+	}
+	if fe := validation.OptionalField[string]("description", nil, validation.Required()); fe != nil {
+		t.Errorf("OptionalField(nil) = %+v, want nil", fe)
+	}
+	empty := ""
+	if fe := validation.OptionalField("description", &empty, validation.Required()); code(fe) != "required" {
+		t.Errorf("OptionalField(\"\") = %+v, want required", fe)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	if err := validation.Check(nil, nil); err != nil {
+		t.Errorf("Check without failures = %v", err)
+	}
+
+	err := validation.Check(
+		validation.Field("title", "", validation.Required()),
+		nil,
+		validation.NewError("floor", "above_floors_total", "must not be above floorsTotal"),
+	)
+	if !errors.Is(err, apperr.ErrValidation) {
+		t.Errorf("errors.Is(%v, apperr.ErrValidation) = false", err)
+	}
+	var fields validation.Errors
+	if !errors.As(err, &fields) {
+		t.Fatalf("Check returned %T, want validation.Errors", err)
+	}
+	got := make([]string, len(fields))
+	for i, fe := range fields {
+		got[i] = fe.Field + ":" + fe.Code
+	}
+	if want := []string{"title:required", "floor:above_floors_total"}; !slices.Equal(got, want) {
+		t.Errorf("fields = %q, want %q", got, want)
+	}
+
+	if err := validation.Validate(struct{}{}); err != nil {
+		t.Errorf("Validate of a value without rules = %v", err)
+	}
+}
@@ -0,0 +1,11 @@
+// Package migrations embeds the goose SQL migrations of every storage driver into the binary,
+// so migrations don't depend on the working directory.
+package migrations
+
+import "embed"
+
+// FS contains one directory of migrations per storage driver (sqlite, postgres).
+// Both directories must contain the same migration versions.
+//
+//go:embed sqlite/*.sql postgres/*.sql
+var FS embed.FS
@@ -0,0 +1,16 @@
+-- +goose Up
+
+-- we do not store passwords here, only email and phone for OTP auth
+-- other user details are in user_profile table
+-- "user" is a reserved word in PostgreSQL, the table name is always quoted
+CREATE TABLE IF NOT EXISTS "user" (
+    id SERIAL PRIMARY KEY, -- Unique identifier for each user
+    email TEXT UNIQUE NOT NULL, -- User's email address
+    phone_number TEXT UNIQUE, -- E.164 format
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP, -- Timestamp of user creation
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP -- Timestamp of last user update
+);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS "user";
@@ -0,0 +1,36 @@
+-- +goose Up
+
+-- enum-like fields are stored as TEXT to keep the schema in line with SQLite
+
+-- we store advertisement linked to user profiles
+CREATE TABLE IF NOT EXISTS advertisement (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL, -- Foreign key to user table
+    title TEXT NOT NULL, -- Title of the advertisement
+    description TEXT, -- Detailed description of the advertisement
+    price NUMERIC NOT NULL, -- Price of the placement
+    type TEXT NOT NULL, -- Type of placement (apartment|house|room)
+    rooms TEXT NOT NULL, -- Number of rooms (studio|1|2|3|4|5|6+)
+    city TEXT NOT NULL, -- City where the placement is located
+    address TEXT NOT NULL, -- Full address of the placement
+    latitude DOUBLE PRECISION, -- Latitude for geolocation
+    longitude DOUBLE PRECISION, -- Longitude for geolocation
+    square DOUBLE PRECISION NOT NULL, -- Square footage of the placement
+    status TEXT NOT NULL, -- Status of the advertisement (active|paused)
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP, -- Timestamp of advertisement creation
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP, -- Timestamp of last advertisement update
+    FOREIGN KEY (user_id) REFERENCES "user"(id) ON DELETE CASCADE
+);
+
+-- table to store photos related to advertisements (images stored as URLs that point to external storage)
+CREATE TABLE IF NOT EXISTS advertisement_photos (
+    id SERIAL PRIMARY KEY,
+    advertisement_id INTEGER NOT NULL, -- Foreign key to advertisement table
+    photo_url TEXT NOT NULL, -- URL of the photo
+    FOREIGN KEY (advertisement_id) REFERENCES advertisement(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS advertisement_photos;
+DROP TABLE IF EXISTS advertisement;
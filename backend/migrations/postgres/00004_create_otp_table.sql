@@ -0,0 +1,20 @@
+-- +goose Up
+
+CREATE TABLE IF NOT EXISTS otp_codes (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    email TEXT NOT NULL,
+    code_hash TEXT NOT NULL,
+    attempts INTEGER DEFAULT 0,
+    max_attempts INTEGER DEFAULT 5,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES "user"(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_otp_email_expires ON otp_codes(email, expires_at);
+CREATE INDEX IF NOT EXISTS idx_otp_user_id ON otp_codes(user_id);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS otp_codes;
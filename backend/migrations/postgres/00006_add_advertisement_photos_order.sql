@@ -0,0 +1,27 @@
+-- +goose Up
+
+-- photos get an explicit order, a single cover photo per advertisement and an optional caption
+ALTER TABLE advertisement_photos ADD COLUMN position INTEGER NOT NULL DEFAULT 0; -- Order of the photo inside the advertisement (0-based)
+ALTER TABLE advertisement_photos ADD COLUMN is_cover BOOLEAN NOT NULL DEFAULT FALSE; -- TRUE for the photo shown in listings
+ALTER TABLE advertisement_photos ADD COLUMN caption TEXT; -- Optional caption of the photo
+
+-- existing photos keep their insertion order, the first one becomes the cover
+UPDATE advertisement_photos
+SET position = (
+    SELECT COUNT(*)
+    FROM advertisement_photos p
+    WHERE p.advertisement_id = advertisement_photos.advertisement_id
+      AND p.id < advertisement_photos.id
+);
+UPDATE advertisement_photos SET is_cover = TRUE WHERE position = 0;
+
+CREATE INDEX IF NOT EXISTS idx_advertisement_photos_ad_position ON advertisement_photos(advertisement_id, position);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_advertisement_photos_cover ON advertisement_photos(advertisement_id) WHERE is_cover;
+
+-- +goose Down
+
+DROP INDEX IF EXISTS idx_advertisement_photos_cover;
+DROP INDEX IF EXISTS idx_advertisement_photos_ad_position;
+ALTER TABLE advertisement_photos DROP COLUMN caption;
+ALTER TABLE advertisement_photos DROP COLUMN is_cover;
+ALTER TABLE advertisement_photos DROP COLUMN position;
@@ -0,0 +1,15 @@
+-- +goose Up
+
+-- soft deletion: deleted rows keep deleted_at until they are restored or purged, reads skip them
+ALTER TABLE advertisement ADD COLUMN deleted_at TIMESTAMPTZ;
+ALTER TABLE "user" ADD COLUMN deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_advertisement_deleted_at ON advertisement(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_user_deleted_at ON "user"(deleted_at);
+
+-- +goose Down
+
+DROP INDEX IF EXISTS idx_user_deleted_at;
+DROP INDEX IF EXISTS idx_advertisement_deleted_at;
+ALTER TABLE "user" DROP COLUMN deleted_at;
+ALTER TABLE advertisement DROP COLUMN deleted_at;
@@ -0,0 +1,26 @@
+-- +goose Up
+
+-- every update of an advertisement, one row per changed field; rows of one update share the version
+CREATE TABLE IF NOT EXISTS advertisement_history (
+    id SERIAL PRIMARY KEY,
+    advertisement_id INTEGER NOT NULL, -- Changed advertisement
+    version INTEGER NOT NULL, -- Number of the update, starting from 1
+    changed_by INTEGER, -- User who made the change
+    field TEXT NOT NULL, -- Name of the changed field (title|description|price|...)
+    old_value TEXT, -- Value before the change, NULL if it was empty
+    new_value TEXT, -- Value after the change, NULL if it was cleared
+    changed_at TIMESTAMPTZ NOT NULL,
+    UNIQUE (advertisement_id, version, field),
+    FOREIGN KEY (advertisement_id) REFERENCES advertisement(id) ON DELETE CASCADE,
+    FOREIGN KEY (changed_by) REFERENCES "user"(id) ON DELETE SET NULL
+);
+
+-- last price change, shown as the "price changed" indicator and the price drop badge
+ALTER TABLE advertisement ADD COLUMN previous_price NUMERIC;
+ALTER TABLE advertisement ADD COLUMN price_changed_at TIMESTAMPTZ;
+
+-- +goose Down
+
+ALTER TABLE advertisement DROP COLUMN price_changed_at;
+ALTER TABLE advertisement DROP COLUMN previous_price;
+DROP TABLE IF EXISTS advertisement_history;
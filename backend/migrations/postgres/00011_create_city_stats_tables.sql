@@ -0,0 +1,32 @@
+-- +goose Up
+
+-- price rollups of active listings per city, rebuilt by the analytics job
+-- type and rooms are '' in the rollups over all types / all room counts
+CREATE TABLE IF NOT EXISTS city_price_stats (
+    city TEXT NOT NULL, -- City in lower case
+    type TEXT NOT NULL, -- Type of placement or '' for all
+    rooms TEXT NOT NULL, -- Number of rooms or '' for all
+    listings INTEGER NOT NULL, -- Number of active listings in the group
+    price_p25 DOUBLE PRECISION NOT NULL,
+    price_median DOUBLE PRECISION NOT NULL,
+    price_p75 DOUBLE PRECISION NOT NULL,
+    price_p90 DOUBLE PRECISION NOT NULL,
+    price_per_m2_median DOUBLE PRECISION, -- NULL if no listing in the group has a square
+    computed_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (city, type, rooms)
+);
+
+-- daily snapshot per city, the last run of the analytics job on a day wins
+CREATE TABLE IF NOT EXISTS city_daily_stats (
+    city TEXT NOT NULL, -- City in lower case
+    day TEXT NOT NULL, -- UTC date (YYYY-MM-DD)
+    active_listings INTEGER NOT NULL, -- Active listings at the time of the snapshot
+    new_listings INTEGER NOT NULL, -- Active listings created on that day
+    price_median DOUBLE PRECISION, -- Median price of the active listings
+    PRIMARY KEY (city, day)
+);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS city_daily_stats;
+DROP TABLE IF EXISTS city_price_stats;
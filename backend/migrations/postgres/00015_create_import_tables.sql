@@ -0,0 +1,35 @@
+-- +goose Up
+
+-- advertisements imported from a feed are matched by the id the landlord uses in the feed,
+-- so importing the same file again updates them instead of creating duplicates
+ALTER TABLE advertisement ADD COLUMN external_id TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_advertisement_user_external_id ON advertisement(user_id, external_id) WHERE external_id IS NOT NULL;
+
+-- URL a photo was fetched from during an import, NULL for uploaded photos
+ALTER TABLE advertisement_photos ADD COLUMN source_url TEXT;
+
+-- large imports are processed in the background, the rows wait in payload until they are imported
+CREATE TABLE IF NOT EXISTS import_job (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL, -- Owner of the imported advertisements
+    format TEXT NOT NULL, -- Format of the imported file (csv|json|xml)
+    status TEXT NOT NULL, -- Status of the job (queued|running|completed|failed)
+    total INTEGER NOT NULL, -- Number of rows in the file
+    processed INTEGER NOT NULL DEFAULT 0, -- Number of rows imported so far
+    payload TEXT, -- Parsed rows (JSON), cleared when the job is finished
+    report TEXT, -- Per-row results (JSON), updated while the job runs
+    error TEXT, -- Why the job failed
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ,
+    FOREIGN KEY (user_id) REFERENCES "user"(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_import_job_status ON import_job(status, id);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS import_job;
+ALTER TABLE advertisement_photos DROP COLUMN source_url;
+DROP INDEX IF EXISTS idx_advertisement_user_external_id;
+ALTER TABLE advertisement DROP COLUMN external_id;
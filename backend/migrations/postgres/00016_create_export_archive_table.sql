@@ -0,0 +1,22 @@
+-- +goose Up
+
+-- personal data archives are generated in the background and kept for a limited time
+CREATE TABLE IF NOT EXISTS export_archive (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL, -- Owner of the exported data
+    status TEXT NOT NULL, -- Status of the archive (queued|completed|failed)
+    path TEXT, -- Archive file in the export directory, set once it is generated
+    size BIGINT, -- Size of the archive in bytes
+    error TEXT, -- Why the archive could not be generated
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ, -- The archive is deleted after this time
+    FOREIGN KEY (user_id) REFERENCES "user"(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_export_archive_status ON export_archive(status, id);
+CREATE INDEX IF NOT EXISTS idx_export_archive_user_id ON export_archive(user_id);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS export_archive;
@@ -0,0 +1,23 @@
+-- +goose Up
+
+-- when the email and phone were last confirmed with an OTP code, NULL until then.
+-- Emails of existing users are marked verified at their next sign-in
+ALTER TABLE "user" ADD COLUMN email_verified_at TIMESTAMPTZ;
+ALTER TABLE "user" ADD COLUMN phone_verified_at TIMESTAMPTZ;
+
+-- OTP codes are sent for several purposes to emails and phones, the address moves to target
+ALTER TABLE otp_codes RENAME COLUMN email TO target;
+ALTER TABLE otp_codes ADD COLUMN purpose TEXT NOT NULL DEFAULT 'login'; -- What the code confirms (login|account_deletion|email_change|phone_change)
+
+DROP INDEX IF EXISTS idx_otp_email_expires;
+CREATE INDEX IF NOT EXISTS idx_otp_purpose_target ON otp_codes(purpose, target);
+
+-- +goose Down
+
+DROP INDEX IF EXISTS idx_otp_purpose_target;
+ALTER TABLE otp_codes DROP COLUMN purpose;
+ALTER TABLE otp_codes RENAME COLUMN target TO email;
+CREATE INDEX IF NOT EXISTS idx_otp_email_expires ON otp_codes(email, expires_at);
+
+ALTER TABLE "user" DROP COLUMN phone_verified_at;
+ALTER TABLE "user" DROP COLUMN email_verified_at;
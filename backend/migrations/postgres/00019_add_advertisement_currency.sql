@@ -0,0 +1,20 @@
+-- +goose Up
+
+-- prices are stored in minor units (kopecks, tiyns, cents) of the listing currency, per day or per month
+ALTER TABLE advertisement ALTER COLUMN price TYPE BIGINT USING ROUND(price * 100);
+ALTER TABLE advertisement ALTER COLUMN previous_price TYPE BIGINT USING ROUND(previous_price * 100);
+
+-- existing listings were priced in tenge per month
+ALTER TABLE advertisement ADD COLUMN currency TEXT NOT NULL DEFAULT 'KZT'; -- ISO 4217 code of the price currency
+ALTER TABLE advertisement ADD COLUMN price_period TEXT NOT NULL DEFAULT 'month'; -- What the price is paid for (day|month)
+
+CREATE INDEX IF NOT EXISTS idx_advertisement_currency_price ON advertisement(currency, price);
+
+-- +goose Down
+
+DROP INDEX IF EXISTS idx_advertisement_currency_price;
+ALTER TABLE advertisement DROP COLUMN price_period;
+ALTER TABLE advertisement DROP COLUMN currency;
+
+ALTER TABLE advertisement ALTER COLUMN previous_price TYPE NUMERIC USING previous_price / 100.0;
+ALTER TABLE advertisement ALTER COLUMN price TYPE NUMERIC USING price / 100.0;
@@ -0,0 +1,38 @@
+-- +goose Up
+
+-- upload sessions let clients upload advertisement photos directly to storage
+-- instead of streaming multipart bodies through the API handlers.
+-- A session is opened for one advertisement, holds a set of upload slots
+-- and is either committed (photos linked) or garbage-collected after expiry.
+CREATE TABLE IF NOT EXISTS upload_session (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    advertisement_id INTEGER NOT NULL, -- Foreign key to advertisement table
+    user_id INTEGER NOT NULL, -- Owner who opened the session
+    status TEXT NOT NULL, -- Status of the session (pending|committed)
+    expires_at DATETIME NOT NULL, -- Uncommitted sessions are removed after this moment
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    committed_at DATETIME,
+    FOREIGN KEY (advertisement_id) REFERENCES advertisement(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES user(id) ON DELETE CASCADE
+);
+
+-- one row per file the client is allowed to upload within a session
+CREATE TABLE IF NOT EXISTS upload_object (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    session_id INTEGER NOT NULL, -- Foreign key to upload_session table
+    token TEXT UNIQUE NOT NULL, -- Random capability token used in the upload URL
+    filename TEXT NOT NULL, -- File name inside the staging directory
+    content_type TEXT NOT NULL, -- Declared MIME type
+    max_size INTEGER NOT NULL, -- Maximum allowed size in bytes
+    size INTEGER, -- Actual size in bytes once uploaded
+    uploaded_at DATETIME, -- NULL until the client has uploaded the file
+    FOREIGN KEY (session_id) REFERENCES upload_session(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_upload_session_status_expires ON upload_session(status, expires_at);
+CREATE INDEX IF NOT EXISTS idx_upload_object_session_id ON upload_object(session_id);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS upload_object;
+DROP TABLE IF EXISTS upload_session;
@@ -0,0 +1,32 @@
+-- +goose Up
+
+-- perceptual hash (64-bit dHash, hex) of every stored photo, used to find reposted photos
+ALTER TABLE advertisement_photos ADD COLUMN phash TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_advertisement_photos_phash ON advertisement_photos(phash);
+
+-- advertisements flagged for manual review
+CREATE TABLE IF NOT EXISTS moderation_queue (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    advertisement_id INTEGER NOT NULL, -- Flagged advertisement
+    related_advertisement_id INTEGER NOT NULL, -- Advertisement it duplicates
+    reason TEXT NOT NULL, -- Why it was flagged (duplicate_photo|duplicate_listing)
+    score REAL NOT NULL, -- Similarity score in [0, 1]
+    details TEXT, -- Human readable explanation
+    status TEXT NOT NULL DEFAULT 'open', -- Review status (open|confirmed|dismissed)
+    resolved_by INTEGER, -- Moderator who reviewed the item
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    resolved_at DATETIME,
+    UNIQUE (advertisement_id, related_advertisement_id, reason),
+    FOREIGN KEY (advertisement_id) REFERENCES advertisement(id) ON DELETE CASCADE,
+    FOREIGN KEY (related_advertisement_id) REFERENCES advertisement(id) ON DELETE CASCADE,
+    FOREIGN KEY (resolved_by) REFERENCES user(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_moderation_queue_status ON moderation_queue(status, created_at);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS moderation_queue;
+DROP INDEX IF EXISTS idx_advertisement_photos_phash;
+ALTER TABLE advertisement_photos DROP COLUMN phash;
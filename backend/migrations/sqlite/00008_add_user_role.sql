@@ -0,0 +1,8 @@
+-- +goose Up
+
+-- role of the user (user|admin), admins can access moderation endpoints
+ALTER TABLE user ADD COLUMN role TEXT NOT NULL DEFAULT 'user';
+
+-- +goose Down
+
+ALTER TABLE user DROP COLUMN role;
@@ -0,0 +1,31 @@
+-- +goose Up
+
+-- advertisements saved by users
+CREATE TABLE IF NOT EXISTS favorite (
+    user_id INTEGER NOT NULL, -- User who saved the advertisement
+    advertisement_id INTEGER NOT NULL, -- Saved advertisement
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (user_id, advertisement_id),
+    FOREIGN KEY (user_id) REFERENCES "user"(id) ON DELETE CASCADE,
+    FOREIGN KEY (advertisement_id) REFERENCES advertisement(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_favorite_advertisement_id ON favorite(advertisement_id);
+
+-- per-advertisement event counters per day, incremented by the flushes of the stats buffer
+CREATE TABLE IF NOT EXISTS advertisement_daily_stats (
+    advertisement_id INTEGER NOT NULL,
+    day TEXT NOT NULL, -- UTC date (YYYY-MM-DD)
+    impressions INTEGER NOT NULL DEFAULT 0, -- Appearances in search results
+    views INTEGER NOT NULL DEFAULT 0, -- Opened detail pages
+    favorites INTEGER NOT NULL DEFAULT 0, -- Times added to favorites
+    contacts INTEGER NOT NULL DEFAULT 0, -- Landlord contact reveals
+    PRIMARY KEY (advertisement_id, day),
+    FOREIGN KEY (advertisement_id) REFERENCES advertisement(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS advertisement_daily_stats;
+DROP INDEX IF EXISTS idx_favorite_advertisement_id;
+DROP TABLE IF EXISTS favorite;
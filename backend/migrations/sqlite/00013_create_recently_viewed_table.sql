@@ -0,0 +1,17 @@
+-- +goose Up
+
+-- advertisements recently opened by a user ("user:<id>") or an anonymous visitor ("visitor:<cookie>")
+CREATE TABLE IF NOT EXISTS recently_viewed (
+    viewer TEXT NOT NULL, -- Key of the user or the visitor cookie
+    advertisement_id INTEGER NOT NULL,
+    viewed_at DATETIME NOT NULL, -- Last time the viewer opened the advertisement
+    PRIMARY KEY (viewer, advertisement_id),
+    FOREIGN KEY (advertisement_id) REFERENCES advertisement(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_recently_viewed_viewed_at ON recently_viewed(viewed_at);
+
+-- +goose Down
+
+DROP INDEX IF EXISTS idx_recently_viewed_viewed_at;
+DROP TABLE IF EXISTS recently_viewed;
@@ -0,0 +1,10 @@
+-- +goose Up
+
+-- version of the editable fields, incremented on every update and exposed as the ETag
+ALTER TABLE advertisement ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE user_profile ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+-- +goose Down
+
+ALTER TABLE user_profile DROP COLUMN version;
+ALTER TABLE advertisement DROP COLUMN version;
@@ -0,0 +1,28 @@
+-- +goose Up
+
+-- accounts deleted by their owners stay deactivated for a grace period, during which signing in
+-- cancels the deletion, and are erased once erase_after has passed
+ALTER TABLE user ADD COLUMN erase_after DATETIME;
+-- random reference tying together the audit records of one deletion
+ALTER TABLE user ADD COLUMN deletion_reference TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_user_erase_after ON user(erase_after) WHERE erase_after IS NOT NULL;
+
+-- what happened to deleted accounts, without anything that identifies the person
+CREATE TABLE IF NOT EXISTS account_deletion_audit (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    reference TEXT NOT NULL, -- Reference of the deletion, the account itself is not recorded
+    event TEXT NOT NULL, -- What happened (requested|cancelled|erased)
+    advertisements INTEGER NOT NULL DEFAULT 0, -- Advertisements deleted, restored or erased with the account
+    photos INTEGER NOT NULL DEFAULT 0, -- Photo files removed when the account was erased
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_account_deletion_audit_reference ON account_deletion_audit(reference);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS account_deletion_audit;
+DROP INDEX IF EXISTS idx_user_erase_after;
+ALTER TABLE user DROP COLUMN deletion_reference;
+ALTER TABLE user DROP COLUMN erase_after;
@@ -0,0 +1,34 @@
+-- +goose Up
+
+-- prices are stored in minor units (kopecks, tiyns, cents) of the listing currency, per day or per month
+ALTER TABLE advertisement ADD COLUMN price_minor INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE advertisement ADD COLUMN previous_price_minor INTEGER;
+UPDATE advertisement SET
+    price_minor = CAST(ROUND(price * 100) AS INTEGER),
+    previous_price_minor = CAST(ROUND(previous_price * 100) AS INTEGER);
+ALTER TABLE advertisement DROP COLUMN price;
+ALTER TABLE advertisement DROP COLUMN previous_price;
+ALTER TABLE advertisement RENAME COLUMN price_minor TO price;
+ALTER TABLE advertisement RENAME COLUMN previous_price_minor TO previous_price;
+
+-- existing listings were priced in tenge per month
+ALTER TABLE advertisement ADD COLUMN currency TEXT NOT NULL DEFAULT 'KZT'; -- ISO 4217 code of the price currency
+ALTER TABLE advertisement ADD COLUMN price_period TEXT NOT NULL DEFAULT 'month'; -- What the price is paid for (day|month)
+
+CREATE INDEX IF NOT EXISTS idx_advertisement_currency_price ON advertisement(currency, price);
+
+-- +goose Down
+
+DROP INDEX IF EXISTS idx_advertisement_currency_price;
+ALTER TABLE advertisement DROP COLUMN price_period;
+ALTER TABLE advertisement DROP COLUMN currency;
+
+ALTER TABLE advertisement ADD COLUMN price_major NUMERIC NOT NULL DEFAULT 0;
+ALTER TABLE advertisement ADD COLUMN previous_price_major NUMERIC;
+UPDATE advertisement SET
+    price_major = price / 100.0,
+    previous_price_major = previous_price / 100.0;
+ALTER TABLE advertisement DROP COLUMN price;
+ALTER TABLE advertisement DROP COLUMN previous_price;
+ALTER TABLE advertisement RENAME COLUMN price_major TO price;
+ALTER TABLE advertisement RENAME COLUMN previous_price_major TO previous_price;
@@ -0,0 +1,70 @@
+-- +goose Up
+
+-- catalog of the amenities a listing can have, grouped for the filter UI
+CREATE TABLE IF NOT EXISTS amenity (
+    code TEXT PRIMARY KEY, -- Code used by the API (wifi|parking|...)
+    category TEXT NOT NULL, -- Group of the amenity (building|comfort|appliances)
+    title TEXT NOT NULL, -- Name shown to users
+    position INTEGER NOT NULL DEFAULT 0 -- Order of the amenity in the catalog
+);
+
+INSERT INTO amenity (code, category, title, position) VALUES
+    ('elevator', 'building', 'Лифт', 1),
+    ('freight_elevator', 'building', 'Грузовой лифт', 2),
+    ('parking', 'building', 'Парковка', 3),
+    ('underground_parking', 'building', 'Подземный паркинг', 4),
+    ('concierge', 'building', 'Консьерж', 5),
+    ('security', 'building', 'Охрана', 6),
+    ('playground', 'building', 'Детская площадка', 7),
+    ('wifi', 'comfort', 'Интернет (Wi-Fi)', 10),
+    ('air_conditioning', 'comfort', 'Кондиционер', 11),
+    ('balcony', 'comfort', 'Балкон', 12),
+    ('heated_floors', 'comfort', 'Тёплый пол', 13),
+    ('tv', 'comfort', 'Телевизор', 14),
+    ('washing_machine', 'appliances', 'Стиральная машина', 20),
+    ('dishwasher', 'appliances', 'Посудомоечная машина', 21),
+    ('fridge', 'appliances', 'Холодильник', 22),
+    ('microwave', 'appliances', 'Микроволновая печь', 23),
+    ('stove', 'appliances', 'Плита', 24);
+
+CREATE TABLE IF NOT EXISTS advertisement_amenity (
+    advertisement_id INTEGER NOT NULL,
+    amenity_code TEXT NOT NULL,
+    PRIMARY KEY (advertisement_id, amenity_code),
+    FOREIGN KEY (advertisement_id) REFERENCES advertisement(id) ON DELETE CASCADE,
+    FOREIGN KEY (amenity_code) REFERENCES amenity(code)
+);
+
+-- the amenity filter looks listings up by amenity
+CREATE INDEX IF NOT EXISTS idx_advertisement_amenity_code ON advertisement_amenity(amenity_code, advertisement_id);
+
+-- attributes of the property, NULL if the landlord didn't fill them in
+ALTER TABLE advertisement ADD COLUMN floor INTEGER; -- Floor of the apartment or room, negative for basements
+ALTER TABLE advertisement ADD COLUMN floors_total INTEGER; -- Floors of the building
+ALTER TABLE advertisement ADD COLUMN furnishing TEXT; -- Furnishing of the property (none|partial|full)
+ALTER TABLE advertisement ADD COLUMN pets_allowed BOOLEAN;
+ALTER TABLE advertisement ADD COLUMN utilities_included BOOLEAN; -- Utilities are included in the price
+ALTER TABLE advertisement ADD COLUMN deposit INTEGER; -- Security deposit in minor units of the price currency
+
+CREATE INDEX IF NOT EXISTS idx_advertisement_floor ON advertisement(floor);
+CREATE INDEX IF NOT EXISTS idx_advertisement_furnishing ON advertisement(furnishing);
+CREATE INDEX IF NOT EXISTS idx_advertisement_pets_allowed ON advertisement(pets_allowed);
+CREATE INDEX IF NOT EXISTS idx_advertisement_utilities_included ON advertisement(utilities_included);
+
+-- +goose Down
+
+DROP INDEX IF EXISTS idx_advertisement_utilities_included;
+DROP INDEX IF EXISTS idx_advertisement_pets_allowed;
+DROP INDEX IF EXISTS idx_advertisement_furnishing;
+DROP INDEX IF EXISTS idx_advertisement_floor;
+
+ALTER TABLE advertisement DROP COLUMN deposit;
+ALTER TABLE advertisement DROP COLUMN utilities_included;
+ALTER TABLE advertisement DROP COLUMN pets_allowed;
+ALTER TABLE advertisement DROP COLUMN furnishing;
+ALTER TABLE advertisement DROP COLUMN floors_total;
+ALTER TABLE advertisement DROP COLUMN floor;
+
+DROP INDEX IF EXISTS idx_advertisement_amenity_code;
+DROP TABLE IF EXISTS advertisement_amenity;
+DROP TABLE IF EXISTS amenity;
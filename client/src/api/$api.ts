@@ -1,4 +1,4 @@
-import { API_BASE_URL } from '../utils/constants'
+import { API_URL } from '../utils/constants'
 
 export type Query = Record<string, string | number | boolean | undefined | null>
 
@@ -14,7 +14,7 @@ const buildPathWithQuery = (path: string, query?: Query): string => {
 }
 
 const buildUrl = (path: string): string => {
-  const baseUrl = API_BASE_URL.replace(/\/$/, '')
+  const baseUrl = API_URL
   const cleanPath = path.startsWith('/') ? path : `/${path}`
   return `${baseUrl}${cleanPath}`
 }
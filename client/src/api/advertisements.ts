@@ -1,5 +1,5 @@
 import type { Advertisement, AdvertisementListResponse } from '../types'
-import { API_BASE_URL } from '../utils/constants'
+import { API_URL } from '../utils/constants'
 import { $api } from './$api'
 
 export interface AdvertisementCreateData {
@@ -50,7 +50,7 @@ export const uploadAdvertisementImages = async (
   const formData = new FormData()
   files.forEach((file) => formData.append(IMAGES_FIELD_NAME, file))
 
-  const response = await fetch(`${API_BASE_URL}/advertisements/${id}/images`, {
+  const response = await fetch(`${API_URL}/advertisements/${id}/images`, {
     method: 'POST',
     credentials: 'include',
     body: formData,
@@ -71,7 +71,7 @@ export const deleteAdvertisementImage = async (
   imageId: string
 ): Promise<void> => {
   const response = await fetch(
-    `${API_BASE_URL}/advertisements/${adId}/images/${imageId}`,
+    `${API_URL}/advertisements/${adId}/images/${imageId}`,
     {
       method: 'DELETE',
       credentials: 'include',
@@ -19,4 +19,7 @@ export const ROOMS_LABEL_MAP: Record<AdvertisementRooms, string> = {
 export const CURRENCY_SYMBOL = '₸'
 
 export const API_BASE_URL =
-  import.meta.env.VITE_API_BASE_URL || 'http://localhost:8080'
\ No newline at end of file
+  import.meta.env.VITE_API_BASE_URL || 'http://localhost:8080'
+
+// Versioned API root, static files (images) are served from API_BASE_URL
+export const API_URL = `${API_BASE_URL.replace(/\/$/, '')}/v1`
\ No newline at end of file